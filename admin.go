@@ -0,0 +1,159 @@
+package oairouter
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// registerAdminRoutes adds the /admin endpoints when enabled by WithAdmin.
+func (r *Router) registerAdminRoutes() {
+	r.mux.HandleFunc("GET /admin/backends", r.requireAdmin(r.handleAdminListBackends))
+	r.mux.HandleFunc("GET /admin/backends/{id}", r.requireAdmin(r.handleAdminGetBackend))
+	r.mux.HandleFunc("POST /admin/backends", r.requireAdmin(r.handleAdminAddBackend))
+	r.mux.HandleFunc("DELETE /admin/backends/{id}", r.requireAdmin(r.handleAdminDeleteBackend))
+	r.mux.HandleFunc("GET /admin/canaries", r.requireAdmin(r.handleAdminListCanaries))
+	r.mux.HandleFunc("PUT /admin/canaries/{model...}", r.requireAdmin(r.handleAdminSetCanary))
+	r.mux.HandleFunc("DELETE /admin/canaries/{model...}", r.requireAdmin(r.handleAdminDeleteCanary))
+	r.mux.HandleFunc("POST /admin/route", r.requireAdmin(r.handleAdminRoute))
+}
+
+// requireAdmin rejects requests that don't carry the admin bearer token, if
+// one is configured.
+func (r *Router) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.adminToken != "" {
+			token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(r.adminToken)) != 1 {
+				types.WriteError(w, http.StatusUnauthorized, types.NewAPIError("invalid admin token", types.ErrorTypeAuth, nil))
+				return
+			}
+		}
+		next(w, req)
+	}
+}
+
+// handleAdminListBackends handles GET /admin/backends.
+func (r *Router) handleAdminListBackends(w http.ResponseWriter, req *http.Request) {
+	resp := struct {
+		Object string        `json:"object"`
+		Data   []BackendInfo `json:"data"`
+	}{
+		Object: "list",
+		Data:   r.registry.Snapshot(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminGetBackend handles GET /admin/backends/{id}.
+func (r *Router) handleAdminGetBackend(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	info, ok := r.registry.BackendInfo(id)
+	if !ok {
+		types.WriteError(w, http.StatusNotFound, types.NewAPIError("backend not found: "+id, types.ErrorTypeNotFound, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// addBackendRequest is the body of POST /admin/backends.
+type addBackendRequest struct {
+	ID      string      `json:"id"`
+	Type    BackendType `json:"type,omitempty"` // defaults to generic
+	BaseURL string      `json:"base_url"`
+}
+
+// handleAdminAddBackend handles POST /admin/backends, constructing a backend
+// with the router's BackendFactory and registering it.
+func (r *Router) handleAdminAddBackend(w http.ResponseWriter, req *http.Request) {
+	if r.backendFactory == nil {
+		types.WriteError(w, http.StatusNotImplemented, types.ServerError("no backend factory configured"))
+		return
+	}
+
+	var body addBackendRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if body.ID == "" {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidParamError("id is required", "id"))
+		return
+	}
+	if u, err := url.Parse(body.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidParamError("base_url must be an absolute http or https URL", "base_url"))
+		return
+	}
+	if body.Type == "" {
+		body.Type = BackendGeneric
+	}
+
+	backend, err := r.backendFactory(body.ID, body.Type, body.BaseURL)
+	if err != nil {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidRequestError("invalid backend: "+err.Error()))
+		return
+	}
+
+	r.configureBackend(backend)
+	if err := backend.HealthCheck(req.Context()); err != nil {
+		r.logger.Warn("health check failed for added backend", "backend", backend.ID(), "error", err)
+	}
+
+	if err := r.registry.RegisterNew(req.Context(), backend); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrBackendExists) {
+			status = http.StatusConflict
+		}
+		r.registry.finishWarmup(backend)
+		types.WriteError(w, status, types.InvalidRequestError(err.Error()))
+		return
+	}
+	r.logger.Info("backend added", "id", backend.ID(), "type", backend.Type(), "url", backend.BaseURL(), "source", "admin")
+	r.startWarmup(backend)
+
+	info, _ := r.registry.BackendInfo(backend.ID())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleAdminDeleteBackend handles DELETE /admin/backends/{id}. The backend
+// stops receiving new requests immediately; the response is sent once its
+// in-flight requests have drained.
+func (r *Router) handleAdminDeleteBackend(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if _, ok := r.registry.LookupByID(id); !ok {
+		types.WriteError(w, http.StatusNotFound, types.NewAPIError("backend not found: "+id, types.ErrorTypeNotFound, nil))
+		return
+	}
+
+	r.RemoveBackend(id)
+	r.logger.Info("backend removed", "id", id, "source", "admin")
+
+	if err := r.registry.WaitIdle(req.Context(), id); err != nil {
+		types.WriteError(w, http.StatusGatewayTimeout, types.ServerError("backend removed but not drained: "+err.Error()))
+		return
+	}
+
+	resp := struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Deleted bool   `json:"deleted"`
+	}{
+		ID:      id,
+		Object:  "backend",
+		Deleted: true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
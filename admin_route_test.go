@@ -0,0 +1,144 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// routeDryRun sends POST /admin/route for model with headers and decodes the
+// response.
+func routeDryRun(t *testing.T, r *Router, model string, headers map[string]string) routeResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/route?model="+model, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp routeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAdminRoute_ReportsDecision(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""))
+	for _, b := range []*mockBackend{modelBackend("backend-a", "gpt-4", false), modelBackend("backend-b", "gpt-4", true)} {
+		b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+			t.Error("dry run called a backend")
+			return nil, nil
+		}
+		r.AddBackend(context.Background(), b)
+	}
+	r.AddBackend(context.Background(), modelBackend("backend-c", "llama-3", true))
+
+	resp := routeDryRun(t, r, "gpt-4", nil)
+	if resp.BackendID != "backend-b" || resp.Strategy != strategyFirstAvailable || resp.Reason == "" {
+		t.Errorf("decision = %+v", resp)
+	}
+	if resp.Operation != OperationChat || resp.RoutedModel != "gpt-4" {
+		t.Errorf("operation = %q, routed model = %q", resp.Operation, resp.RoutedModel)
+	}
+	if len(resp.Candidates) != 2 {
+		t.Fatalf("candidates = %+v, want backend-a and backend-b", resp.Candidates)
+	}
+	a, b := resp.Candidates[0], resp.Candidates[1]
+	if a.ID != "backend-a" || a.Healthy || !a.CanServe {
+		t.Errorf("candidate a = %+v", a)
+	}
+	if b.ID != "backend-b" || !b.Healthy || b.HealthScore <= 0 {
+		t.Errorf("candidate b = %+v", b)
+	}
+}
+
+func TestAdminRoute_OverrideAndFallback(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""), WithBackendOverrideHeader(), WithModelFallback("gpt-4", []string{"llama-3"}))
+	r.AddBackend(context.Background(), modelBackend("backend-a", "gpt-4", false))
+	r.AddBackend(context.Background(), modelBackend("backend-b", "llama-3", true))
+
+	resp := routeDryRun(t, r, "gpt-4", nil)
+	if resp.BackendID != "backend-b" || resp.Fallback != "llama-3" || resp.RoutedModel != "llama-3" {
+		t.Errorf("fallback decision = %+v", resp)
+	}
+
+	resp = routeDryRun(t, r, "gpt-4", map[string]string{BackendIDHeader: "backend-a"})
+	if resp.BackendID != "backend-a" || resp.Strategy != strategyOverride || resp.Fallback != "" {
+		t.Errorf("override decision = %+v", resp)
+	}
+}
+
+func TestAdminRoute_DoesNotPinSessions(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+	r, _ := NewRouter(WithAdmin(""), WithSessionStore(store))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	resp := routeDryRun(t, r, "test-model", map[string]string{SessionHeader: "session-1"})
+	if resp.BackendID != "backend-a" || resp.Strategy != strategySession {
+		t.Errorf("decision = %+v", resp)
+	}
+	if _, ok, _ := store.Get(context.Background(), sessionKey("test-model", "session-1")); ok {
+		t.Error("dry run pinned the session")
+	}
+}
+
+func TestAdminRoute_SessionlessRequestsUseRoutingOptions(t *testing.T) {
+	r := newTestRouter(t, []Backend{
+		modelBackend("backend-a", "test-model", true),
+		modelBackend("backend-b", "test-model", true),
+	}, WithAdmin(""), WithSessionAffinity(true), WithHealthScoring(true))
+
+	if resp := routeDryRun(t, r, "test-model", nil); resp.Strategy != strategyHealthScore {
+		t.Errorf("sessionless strategy = %q, want %q", resp.Strategy, strategyHealthScore)
+	}
+	if resp := routeDryRun(t, r, "test-model", map[string]string{SessionHeader: "session-1"}); resp.Strategy != strategySession {
+		t.Errorf("session strategy = %q, want %q", resp.Strategy, strategySession)
+	}
+}
+
+func TestAdminRoute_DoesNotAdvanceRoundRobin(t *testing.T) {
+	r := newTestRouter(t, []Backend{
+		modelBackend("backend-a", "test-model", true),
+		modelBackend("backend-b", "test-model", true),
+	}, WithAdmin(""), WithRoutingPolicy(NewRoundRobin()))
+
+	for range 3 {
+		if resp := routeDryRun(t, r, "test-model", nil); resp.BackendID != "backend-a" {
+			t.Errorf("dry run reported %s, want backend-a", resp.BackendID)
+		}
+	}
+	if got, _ := servedBy(t, r, "test-model"); got != "backend-a" {
+		t.Errorf("first real request served by %s, want backend-a", got)
+	}
+	if resp := routeDryRun(t, r, "test-model", nil); resp.BackendID != "backend-b" {
+		t.Errorf("dry run after one request reported %s, want backend-b", resp.BackendID)
+	}
+}
+
+func TestAdminRoute_Errors(t *testing.T) {
+	r, _ := NewRouter(WithAdmin("secret"))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	for path, want := range map[string]int{
+		"/admin/route": http.StatusBadRequest,
+		"/admin/route?model=test-model&operation=tts":  http.StatusBadRequest,
+		"/admin/route?model=missing":                   http.StatusNotFound,
+		"/admin/route?model=test-model&operation=chat": http.StatusOK,
+	} {
+		if w := adminDo(r, http.MethodPost, path, "", "secret"); w.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+	if w := adminDo(r, http.MethodPost, "/admin/route?model=test-model", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,206 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func adminGet(r *Router, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_Disabled(t *testing.T) {
+	r, _ := NewRouter()
+	if w := adminGet(r, "/admin/backends", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 when admin is disabled", w.Code)
+	}
+}
+
+func TestAdmin_ListBackends(t *testing.T) {
+	a := newMockBackend("backend-a", true)
+	a.models = []string{"model-y", "model-x"}
+	b := newMockBackend("backend-b", false)
+
+	r, _ := NewRouter(WithAdmin(""))
+	r.AddBackend(context.Background(), b)
+	r.AddBackend(context.Background(), a)
+
+	release := r.registry.Acquire("backend-a")
+	defer release()
+
+	w := adminGet(r, "/admin/backends", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []BackendInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []BackendInfo{
+		{ID: "backend-a", Type: BackendGeneric, BaseURL: "http://localhost:8080", Healthy: true, InFlight: 1, Models: []string{"model-x", "model-y"}},
+		{ID: "backend-b", Type: BackendGeneric, BaseURL: "http://localhost:8080", Healthy: false, InFlight: 0, Models: []string{"test-model"}},
+	}
+	if !reflect.DeepEqual(resp.Data, want) {
+		t.Errorf("backends = %+v, want %+v", resp.Data, want)
+	}
+}
+
+func TestAdmin_GetBackend(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	w := adminGet(r, "/admin/backends/backend-a", "")
+	var info BackendInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "backend-a" || !info.Healthy {
+		t.Errorf("info = %+v", info)
+	}
+
+	if w := adminGet(r, "/admin/backends/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for unknown backend", w.Code)
+	}
+}
+
+func TestAdmin_Token(t *testing.T) {
+	r, _ := NewRouter(WithAdmin("secret"))
+
+	if w := adminGet(r, "/admin/backends", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", w.Code)
+	}
+	if w := adminGet(r, "/admin/backends", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", w.Code)
+	}
+	if w := adminGet(r, "/admin/backends", "secret"); w.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", w.Code)
+	}
+}
+
+func mockFactory(id string, typ BackendType, baseURL string) (Backend, error) {
+	b := newMockBackend(id, true)
+	b.typ = typ
+	return b, nil
+}
+
+func adminDo(r *Router, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_AddBackend(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""), WithBackendFactory(mockFactory))
+
+	w := adminDo(r, http.MethodPost, "/admin/backends", `{"id":"new","type":"vllm","base_url":"http://10.0.0.5:8000"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var info BackendInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "new" || info.Type != BackendVLLM || !reflect.DeepEqual(info.Models, []string{"test-model"}) {
+		t.Errorf("info = %+v", info)
+	}
+	if _, ok := r.registry.LookupByModel("test-model"); !ok {
+		t.Error("added backend is not routable")
+	}
+
+	w = adminDo(r, http.MethodPost, "/admin/backends", `{"id":"new","base_url":"http://10.0.0.6:8000"}`, "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate ID: status = %d, want 409", w.Code)
+	}
+}
+
+func TestAdmin_AddBackendValidation(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""), WithBackendFactory(mockFactory))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed body", `{"id":`},
+		{"missing id", `{"base_url":"http://localhost:8000"}`},
+		{"missing url", `{"id":"x"}`},
+		{"relative url", `{"id":"x","base_url":"localhost:8000"}`},
+		{"unsupported scheme", `{"id":"x","base_url":"ftp://localhost"}`},
+	}
+	for _, tt := range tests {
+		if w := adminDo(r, http.MethodPost, "/admin/backends", tt.body, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, w.Code)
+		}
+	}
+	if r.registry.Count() != 0 {
+		t.Errorf("registered %d backends from invalid requests", r.registry.Count())
+	}
+}
+
+func TestAdmin_AddBackendWithoutFactory(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""))
+	w := adminDo(r, http.MethodPost, "/admin/backends", `{"id":"x","base_url":"http://localhost:8000"}`, "")
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", w.Code)
+	}
+}
+
+func TestAdmin_DeleteBackendDrains(t *testing.T) {
+	r, _ := NewRouter(WithAdmin(""))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	release := r.registry.Acquire("backend-a")
+	var released atomic.Bool
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		released.Store(true)
+		release()
+	}()
+
+	w := adminDo(r, http.MethodDelete, "/admin/backends/backend-a", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if !released.Load() {
+		t.Error("DELETE returned before in-flight requests drained")
+	}
+	if _, ok := r.registry.LookupByID("backend-a"); ok {
+		t.Error("backend still registered")
+	}
+
+	if w := adminDo(r, http.MethodDelete, "/admin/backends/backend-a", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second delete: status = %d, want 404", w.Code)
+	}
+}
+
+func TestAdmin_WriteRequiresToken(t *testing.T) {
+	r, _ := NewRouter(WithAdmin("secret"), WithBackendFactory(mockFactory))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	if w := adminDo(r, http.MethodPost, "/admin/backends", `{"id":"x","base_url":"http://localhost:8000"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST: status = %d, want 401", w.Code)
+	}
+	if w := adminDo(r, http.MethodDelete, "/admin/backends/backend-a", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE: status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package oairouter
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/http"
+	"sync"
+)
+
+// RequestIDHeader carries the ID used to correlate a request with its audit
+// record. A client-supplied value is kept if it is valid (see
+// maxRequestIDLength); otherwise the router generates one and returns it in
+// the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds a client-supplied request ID. Longer IDs, and
+// ones with characters other than letters, digits, '-', '_', '.' and ':',
+// are replaced, since the ID names audit records and appears in logs.
+const maxRequestIDLength = 128
+
+// AuditSinkFunc opens the sink that receives a copy of one streamed response.
+// It may return nil to skip auditing the request.
+type AuditSinkFunc func(requestID string) io.WriteCloser
+
+// requestID returns the request's X-Request-ID, generating one if it is
+// absent or invalid.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		// IDs only need to be unique, not unpredictable
+		binary.BigEndian.PutUint64(b, mathrand.Uint64())
+		binary.BigEndian.PutUint32(b[8:], mathrand.Uint32())
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client-supplied request ID can be kept.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// auditTee copies SSE events to an audit sink without blocking the client.
+// Events are queued without bound and written by a separate goroutine, so a
+// slow sink delays the audit record, not the stream; the sink is closed once
+// the queue drains after close.
+type auditTee struct {
+	sink   io.WriteCloser
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending [][]byte
+	closed  bool
+	wake    chan struct{}
+}
+
+func newAuditTee(sink io.WriteCloser, logger *slog.Logger) *auditTee {
+	t := &auditTee{
+		sink:   sink,
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+	}
+	go t.run()
+	return t
+}
+
+// write queues one SSE data payload in the same framing sent to the client.
+func (t *auditTee) write(data string) {
+	t.mu.Lock()
+	t.pending = append(t.pending, []byte("data: "+data+"\n\n"))
+	t.mu.Unlock()
+	t.signal()
+}
+
+// close marks the end of the stream. Queued events are still written before
+// the sink is closed.
+func (t *auditTee) close() {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.signal()
+}
+
+func (t *auditTee) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *auditTee) run() {
+	failed := false
+	for {
+		t.mu.Lock()
+		batch, closed := t.pending, t.closed
+		t.pending = nil
+		t.mu.Unlock()
+
+		for _, b := range batch {
+			if failed {
+				break
+			}
+			if _, err := t.sink.Write(b); err != nil {
+				// Keep draining so the queue doesn't grow, but stop writing.
+				t.logger.Error("audit sink write failed", "error", err)
+				failed = true
+			}
+		}
+
+		if closed {
+			if err := t.sink.Close(); err != nil {
+				t.logger.Error("audit sink close failed", "error", err)
+			}
+			return
+		}
+		<-t.wake
+	}
+}
@@ -0,0 +1,189 @@
+package oairouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// recordingSink is an audit sink that signals when it is closed.
+type recordingSink struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed chan struct{}
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{closed: make(chan struct{})}
+}
+
+func (s *recordingSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *recordingSink) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *recordingSink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// reassemble joins the delta content of the SSE chunks in stream.
+func reassemble(t *testing.T, stream string) (content string, done bool) {
+	t.Helper()
+	var sb strings.Builder
+	for _, line := range strings.Split(stream, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", data, err)
+		}
+		for _, c := range chunk.Choices {
+			sb.WriteString(c.Delta.Content)
+		}
+	}
+	return sb.String(), done
+}
+
+func TestAuditSink_ReceivesFullStream(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"The "}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"quick "}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"fox"}}]}`,
+		), nil
+	}
+
+	sink := newRecordingSink()
+	var gotID string
+	r, _ := NewRouter(WithLogRedactor(nil), WithAuditSink(func(id string) io.WriteCloser {
+		gotID = id
+		return sink
+	}))
+	r.AddBackend(context.Background(), b)
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	select {
+	case <-sink.closed:
+	case <-time.After(time.Second):
+		t.Fatal("audit sink was not closed")
+	}
+
+	if gotID != "req-123" {
+		t.Errorf("sink opened for %q, want req-123", gotID)
+	}
+	if got := w.Header().Get(RequestIDHeader); got != "req-123" {
+		t.Errorf("%s = %q, want req-123", RequestIDHeader, got)
+	}
+
+	content, done := reassemble(t, sink.String())
+	if content != "The quick fox" || !done {
+		t.Errorf("audit content = %q (done=%v), want %q with [DONE]", content, done, "The quick fox")
+	}
+	if sink.String() != w.Body.String() {
+		t.Errorf("audit record differs from client stream:\n%s\nvs\n%s", sink.String(), w.Body.String())
+	}
+}
+
+// blockingSink holds every write until release is closed.
+type blockingSink struct {
+	recordingSink
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(p []byte) (int, error) {
+	<-s.release
+	return s.recordingSink.Write(p)
+}
+
+func TestAuditSink_SlowSinkDoesNotBlockClient(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"choices":[{"delta":{"content":"a"}}]}`, `{"choices":[{"delta":{"content":"b"}}]}`), nil
+	}
+
+	sink := &blockingSink{recordingSink: *newRecordingSink(), release: make(chan struct{})}
+	r, _ := NewRouter(WithLogRedactor(nil), WithAuditSink(func(string) io.WriteCloser { return sink }))
+	r.AddBackend(context.Background(), b)
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	served := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		served <- w
+	}()
+
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-served:
+	case <-time.After(time.Second):
+		t.Fatal("client stream blocked on the audit sink")
+	}
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Error("expected a generated request ID")
+	}
+
+	close(sink.release)
+	select {
+	case <-sink.closed:
+	case <-time.After(time.Second):
+		t.Fatal("audit sink was not closed")
+	}
+	if content, _ := reassemble(t, sink.String()); content != "ab" {
+		t.Errorf("audit content = %q, want %q", content, "ab")
+	}
+}
+
+func TestRequestID_ReplacesInvalidClientIDs(t *testing.T) {
+	for id, kept := range map[string]bool{
+		"req-123":                true,
+		"0f1e2d3c.span:4_a":      true,
+		"":                       false,
+		"has space":              false,
+		"line\nbreak":            false,
+		"../../etc/passwd":       false,
+		strings.Repeat("a", 129): false,
+		strings.Repeat("a", 128): true,
+		"café":                   false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set(RequestIDHeader, id)
+		got := requestID(req)
+		if kept && got != id {
+			t.Errorf("requestID(%q) = %q, want it kept", id, got)
+		}
+		if !kept && (got == id || !validRequestID(got)) {
+			t.Errorf("requestID(%q) = %q, want a generated ID", id, got)
+		}
+	}
+}
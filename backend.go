@@ -2,7 +2,9 @@ package oairouter
 
 import (
 	"context"
+	"crypto/tls"
 	"net/url"
+	"time"
 
 	"github.com/stevemurr/oairouter/types"
 )
@@ -11,13 +13,145 @@ import (
 type BackendType string
 
 const (
-	BackendVLLM     BackendType = "vllm"
-	BackendOllama   BackendType = "ollama"
-	BackendLlamaCpp BackendType = "llamacpp"
-	BackendLMStudio BackendType = "lmstudio"
-	BackendGeneric  BackendType = "generic"
+	BackendVLLM      BackendType = "vllm"
+	BackendOllama    BackendType = "ollama"
+	BackendLlamaCpp  BackendType = "llamacpp"
+	BackendLMStudio  BackendType = "lmstudio"
+	BackendAnthropic BackendType = "anthropic"
+	BackendGeneric   BackendType = "generic"
 )
 
+// Capability identifies an OpenAI API surface or feature a backend can serve.
+type Capability string
+
+const (
+	CapabilityChat        Capability = "chat"
+	CapabilityCompletions Capability = "completions"
+	CapabilityEmbeddings  Capability = "embeddings"
+	CapabilityLogprobs    Capability = "logprobs"
+	CapabilityStreaming   Capability = "streaming"
+	CapabilityTools       Capability = "tools"
+
+	// CapabilityStreamingN is streaming chat with n > 1, where the choices'
+	// chunks are interleaved by index. Streaming requests with n > 1 routed to
+	// a backend without it are rejected with 400.
+	CapabilityStreamingN Capability = "streaming_n"
+
+	// CapabilityMaxCompletionTokens is accepting max_completion_tokens, the
+	// newer name for max_tokens. Chat requests are forwarded with whichever
+	// of the two the backend accepts.
+	CapabilityMaxCompletionTokens Capability = "max_completion_tokens"
+
+	// CapabilityFunctions is accepting functions and function_call, the
+	// deprecated forms of tools and tool_choice. Chat requests with tools
+	// are forwarded in that form to backends with it but not CapabilityTools.
+	CapabilityFunctions Capability = "functions"
+)
+
+// allCapabilities lists the known capabilities in their canonical order.
+var allCapabilities = []Capability{
+	CapabilityChat, CapabilityCompletions, CapabilityEmbeddings,
+	CapabilityLogprobs, CapabilityStreaming, CapabilityTools, CapabilityStreamingN,
+	CapabilityMaxCompletionTokens, CapabilityFunctions,
+}
+
+// ValidCapability reports whether c is a known capability.
+func ValidCapability(c Capability) bool {
+	for _, known := range allCapabilities {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilityProber is optionally implemented by backends that detect their
+// capabilities by sending small requests to the server. The registry calls
+// ProbeCapabilities before registering the backend; implementations should
+// cache the results so re-registration doesn't probe again.
+type CapabilityProber interface {
+	ProbeCapabilities(ctx context.Context) error
+}
+
+// probeCapabilities runs a backend's capability probe, if it has one. A failed
+// probe leaves the backend's capabilities unchanged.
+func probeCapabilities(ctx context.Context, b Backend) {
+	if p, ok := b.(CapabilityProber); ok {
+		p.ProbeCapabilities(ctx)
+	}
+}
+
+// ImageGenerator is optionally implemented by backends that serve the OpenAI
+// images API. Requests for POST /v1/images/generations routed to a backend
+// without it fail with 400.
+type ImageGenerator interface {
+	GenerateImages(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error)
+}
+
+// TLSConfigurer is optionally implemented by backends whose HTTP client can
+// be given a TLS configuration. The router calls SetTLSConfig before it
+// registers a backend that has settings from WithBackendTLS.
+type TLSConfigurer interface {
+	SetTLSConfig(cfg *tls.Config)
+}
+
+// Tiered is optionally implemented by backends with a routing priority.
+// Lookups prefer backends of the lowest tier, using a higher tier only when
+// every backend of the lower ones is unhealthy or at its concurrency limit.
+// Backends that don't implement it are tier 0.
+type Tiered interface {
+	Tier() int
+}
+
+// ConcurrencyLimiter is optionally implemented by backends that should serve
+// at most MaxConcurrency requests at once, or any number if it is 0. A
+// backend at its limit is passed over for others serving the model; if all
+// are at their limits, the preferred one is used anyway.
+type ConcurrencyLimiter interface {
+	MaxConcurrency() int
+}
+
+// Tagged is optionally implemented by backends labeled with tags, such as a
+// team or a hardware class. WithModelPrefixRoute sends models with a prefix
+// to the backends with a tag.
+type Tagged interface {
+	Tags() []string
+}
+
+// HealthErrorReporter is optionally implemented by backends that remember why
+// their last health check failed. The message is shown in GET /health?verbose=true.
+type HealthErrorReporter interface {
+	LastHealthError() string
+}
+
+// ModelsReporter is optionally implemented by backends that remember the
+// model list they last fetched, e.g. while health checking. The registry's
+// model cache adopts that list when it is newer than its own.
+type ModelsReporter interface {
+	LastModels() (models []types.Model, fetched time.Time)
+}
+
+// ModelSeeder is optionally implemented by backends that know which models
+// they serve before their model list can be fetched, e.g. from a discovery
+// label. While Models fails, the registry indexes the seeded models so
+// requests can be routed; the fetched list replaces them once it succeeds.
+type ModelSeeder interface {
+	SeedModels() []types.Model
+}
+
+// seedModels returns a backend's seeded models, if it has any.
+func seedModels(b Backend) []types.Model {
+	if s, ok := b.(ModelSeeder); ok {
+		return s.SeedModels()
+	}
+	return nil
+}
+
+// backendSupports reports whether b can serve capability c.
+func backendSupports(b Backend, c Capability) bool {
+	return b.Capabilities().Supports(c)
+}
+
 // Backend represents an LLM inference server.
 type Backend interface {
 	// Identity
@@ -32,6 +166,10 @@ type Backend interface {
 	HealthCheck(ctx context.Context) error
 	IsHealthy() bool
 
+	// Capabilities describes the API surfaces and request features the
+	// backend serves, so unsupported requests are rejected before dispatch.
+	Capabilities() BackendCapabilities
+
 	// OpenAI-compatible request handlers
 	ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error)
 	ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error)
@@ -51,3 +189,7 @@ type StreamEvent struct {
 	// Done indicates this is the final event
 	Done bool
 }
+
+// BackendFactory constructs a backend from its ID, type, and base URL. It is
+// used to create backends at runtime, e.g. through the admin API.
+type BackendFactory func(id string, typ BackendType, baseURL string) (Backend, error)
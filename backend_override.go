@@ -0,0 +1,54 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// BackendIDHeader forces a request to the backend with that ID, bypassing
+// model routing. It is only honored when enabled with
+// WithBackendOverrideHeader.
+const BackendIDHeader = "X-Backend-ID"
+
+type backendPinKey struct{}
+
+// overrideBackend returns the backend named by the request's X-Backend-ID
+// header, if override is enabled and the header is set. The backend must
+// exist and serve model; its health is not checked, so a backend can be
+// inspected while it is failing.
+func (r *Router) overrideBackend(req *http.Request, model string) (Backend, *types.RouterError, bool) {
+	if !r.backendOverride {
+		return nil, nil, false
+	}
+	id := req.Header.Get(BackendIDHeader)
+	if id == "" {
+		return nil, nil, false
+	}
+
+	b, ok := r.registry.LookupByID(id)
+	if !ok {
+		code := "backend_not_found"
+		return nil, types.NewRouterError(http.StatusNotFound,
+			types.NewAPIError("backend not found: "+id, types.ErrorTypeNotFound, &code), nil), true
+	}
+	if !r.registry.ServesModel(id, model) {
+		return nil, types.NewRouterError(http.StatusBadRequest,
+			types.InvalidParamError("backend "+id+" does not serve model "+model, "model"), nil), true
+	}
+	return b, nil, true
+}
+
+// withPinnedBackend marks a request as pinned to its backend, so it isn't
+// hedged, fanned out, or rerouted to other backends.
+func withPinnedBackend(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), backendPinKey{}, true))
+}
+
+// backendPinned reports whether ctx belongs to a request pinned with
+// X-Backend-ID or routed by a model prefix.
+func backendPinned(ctx context.Context) bool {
+	pinned, _ := ctx.Value(backendPinKey{}).(bool)
+	return pinned
+}
@@ -0,0 +1,81 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// idBackend counts the chat requests it serves, answering with its ID.
+func idBackend(id string, calls *atomic.Int64) *mockBackend {
+	b := newMockBackend(id, true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		calls.Add(1)
+		return &types.ChatCompletionResponse{ID: id}, nil
+	}
+	return b
+}
+
+func TestBackendOverride_DispatchesToNamedBackend(t *testing.T) {
+	r, err := NewRouter(WithBackendOverrideHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aCalls, bCalls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &aCalls))
+	unhealthy := idBackend("backend-b", &bCalls)
+	unhealthy.SetHealthy(false)
+	r.AddBackend(context.Background(), unhealthy)
+
+	body := testChatBody
+	for range 5 {
+		if rec := postChat(t, r, body, withHeader(BackendIDHeader, "backend-b")); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if aCalls.Load() != 0 || bCalls.Load() != 5 {
+		t.Errorf("expected all 5 requests on backend-b, got a=%d b=%d", aCalls.Load(), bCalls.Load())
+	}
+}
+
+func TestBackendOverride_Errors(t *testing.T) {
+	r, err := NewRouter(WithBackendOverrideHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	other := idBackend("backend-b", &calls)
+	other.models = []string{"other-model"}
+	r.AddBackend(context.Background(), other)
+
+	body := testChatBody
+	if rec := postChat(t, r, body, withHeader(BackendIDHeader, "missing")); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown backend: expected 404, got %d", rec.Code)
+	}
+	rec := postChat(t, r, body, withHeader(BackendIDHeader, "backend-b"))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "does not serve model") {
+		t.Errorf("wrong model: expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no backend calls, got %d", calls.Load())
+	}
+}
+
+func TestBackendOverride_IgnoredUnlessEnabled(t *testing.T) {
+	r, err := NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+
+	body := testChatBody
+	if rec := postChat(t, r, body, withHeader(BackendIDHeader, "missing")); rec.Code != http.StatusOK {
+		t.Errorf("expected the header to be ignored, got %d", rec.Code)
+	}
+}
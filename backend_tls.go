@@ -0,0 +1,45 @@
+package oairouter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// backendTLSConfig builds the TLS configuration for WithBackendTLS. caBundle
+// holds PEM certificates to trust in place of the system roots; it may be
+// empty when only skipping verification.
+func backendTLSConfig(caBundle []byte, insecureSkipVerify bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if len(caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("CA bundle contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// configureBackend applies the router's per-backend settings to b before it
+// is registered. A backend already registered as this same instance, e.g.
+// one carried over by a reload, is serving and was configured when it was
+// added, so it is left alone.
+func (r *Router) configureBackend(b Backend) {
+	if existing, ok := r.registry.LookupByID(b.ID()); ok && existing == b {
+		return
+	}
+	r.holdForWarmup(b)
+	cfg, ok := r.backendTLS[b.ID()]
+	if !ok {
+		return
+	}
+	if c, ok := b.(TLSConfigurer); ok {
+		c.SetTLSConfig(cfg)
+	} else {
+		r.logger.Warn("backend does not support TLS configuration", "backend", b.ID())
+	}
+}
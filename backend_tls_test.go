@@ -0,0 +1,76 @@
+package oairouter
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+)
+
+// tlsMockBackend records the TLS configuration it is given.
+type tlsMockBackend struct {
+	*mockBackend
+	tls  *tls.Config
+	sets int
+}
+
+func (b *tlsMockBackend) SetTLSConfig(cfg *tls.Config) { b.tls, b.sets = cfg, b.sets+1 }
+
+func TestWithBackendTLS_AppliedOnRegistration(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	r, err := NewRouter(WithBackendTLS("backend-a", ca, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &tlsMockBackend{mockBackend: newMockBackend("backend-a", true)}
+	b := &tlsMockBackend{mockBackend: newMockBackend("backend-b", true)}
+	r.AddBackend(context.Background(), a)
+	r.AddBackend(context.Background(), b)
+
+	if a.tls == nil || a.tls.RootCAs == nil || a.tls.InsecureSkipVerify {
+		t.Errorf("backend-a: expected the CA bundle to be trusted, got %+v", a.tls)
+	}
+	if b.tls != nil {
+		t.Error("backend-b: expected no TLS settings")
+	}
+}
+
+func TestWithBackendTLS_Invalid(t *testing.T) {
+	if _, err := NewRouter(WithBackendTLS("backend-a", []byte("not a certificate"), false)); err == nil {
+		t.Error("expected an error for a bundle without certificates")
+	}
+	if _, err := NewRouter(WithBackendTLS("", nil, true)); err == nil {
+		t.Error("expected an error for an empty backend ID")
+	}
+	if _, err := NewRouter(WithBackendTLS("backend-a", nil, true)); err != nil {
+		t.Errorf("skip-verify without a bundle: %v", err)
+	}
+}
+
+func TestWithBackendTLS_NotReappliedToServingBackends(t *testing.T) {
+	r, err := NewRouter(WithBackendTLS("backend-a", nil, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &tlsMockBackend{mockBackend: newMockBackend("backend-a", true)}
+	r.AddBackend(context.Background(), a)
+
+	if err := r.ReplaceBackends(context.Background(), []Backend{a}); err != nil {
+		t.Fatal(err)
+	}
+	if a.sets != 1 {
+		t.Errorf("TLS configured %d times, want once", a.sets)
+	}
+
+	swapped := &tlsMockBackend{mockBackend: newMockBackend("backend-a", true)}
+	if err := r.ReplaceBackends(context.Background(), []Backend{swapped}); err != nil {
+		t.Fatal(err)
+	}
+	if swapped.sets != 1 || a.sets != 1 {
+		t.Errorf("TLS configured %d times on the new instance and %d on the old, want 1 and 1", swapped.sets, a.sets)
+	}
+}
@@ -0,0 +1,563 @@
+package backends
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// AnthropicVersion is the anthropic-version header sent with each request.
+const AnthropicVersion = "2023-06-01"
+
+// DefaultAnthropicMaxTokens is the max_tokens sent for chat requests that set
+// no token limit, since the Messages API requires one.
+const DefaultAnthropicMaxTokens = 4096
+
+// AnthropicBackend talks to a server implementing Anthropic's Messages API.
+// Chat requests are translated from the OpenAI format, and responses and
+// streamed events are converted back to OpenAI completions and chunks. The
+// Messages API has no text completions or embeddings, so the backend doesn't
+// serve them. Set the API key with WithAPIKey.
+type AnthropicBackend struct {
+	*GenericBackend
+}
+
+// NewAnthropicBackend creates a backend for a Messages API server, e.g.
+// "https://api.anthropic.com".
+func NewAnthropicBackend(id string, baseURL string, opts ...GenericBackendOption) (*AnthropicBackend, error) {
+	g, err := NewGenericBackend(id, baseURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.backendType = oairouter.BackendAnthropic
+	g.decodeModels = decodeAnthropicModels
+	g.authorize = func(req *http.Request) {
+		req.Header.Set("anthropic-version", AnthropicVersion)
+		if g.apiKey != "" {
+			req.Header.Set("x-api-key", g.apiKey)
+		}
+	}
+	// The Messages API serves only chat, a single choice at a time, without
+	// logprobs. Either token limit field is accepted and sent as max_tokens
+	g.protocolCaps = map[oairouter.Capability]bool{
+		oairouter.CapabilityCompletions:         false,
+		oairouter.CapabilityEmbeddings:          false,
+		oairouter.CapabilityLogprobs:            false,
+		oairouter.CapabilityStreamingN:          false,
+		oairouter.CapabilityMaxCompletionTokens: true,
+	}
+	return &AnthropicBackend{GenericBackend: g}, nil
+}
+
+// ProbeCapabilities is a no-op: capability probes use the OpenAI routes,
+// which Messages API servers don't have.
+func (b *AnthropicBackend) ProbeCapabilities(ctx context.Context) error {
+	return nil
+}
+
+// decodeAnthropicModels converts a Messages API /v1/models response to
+// OpenAI models.
+func decodeAnthropicModels(body []byte) ([]types.Model, error) {
+	var list struct {
+		Data []struct {
+			ID        string    `json:"id"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+
+	models := make([]types.Model, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, types.Model{ID: m.ID, Object: "model", Created: m.CreatedAt.Unix(), OwnedBy: "anthropic"})
+	}
+	return models, nil
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice    any                `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"` // user or assistant
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block of any type: text, image, tool_use or
+// tool_result.
+type anthropicBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`      // image
+	ID        string                `json:"id,omitempty"`          // tool_use
+	Name      string                `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage       `json:"input,omitempty"`       // tool_use
+	ToolUseID string                `json:"tool_use_id,omitempty"` // tool_result
+	Content   string                `json:"content,omitempty"`     // tool_result
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"` // base64 or url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+func (u anthropicUsage) usage() *types.Usage {
+	return &types.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// anthropicFinishReason maps a stop_reason to an OpenAI finish_reason.
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// toAnthropicRequest translates an OpenAI chat request to the Messages API.
+// System messages become the system prompt, tool calls become tool_use
+// blocks, and tool messages become tool_result blocks in a user message.
+// Consecutive messages with the same role are merged, as the API requires
+// roles to alternate.
+func toAnthropicRequest(req *types.ChatCompletionRequest, stream bool) (*anthropicRequest, error) {
+	if req.N != nil && *req.N > 1 {
+		return nil, errors.New("anthropic messages API does not support n > 1")
+	}
+
+	out := &anthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     DefaultAnthropicMaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+		ToolChoice:    toAnthropicToolChoice(req.ToolChoice),
+	}
+	if req.MaxCompletionTokens != nil {
+		out.MaxTokens = *req.MaxCompletionTokens
+	} else if req.MaxTokens != nil {
+		out.MaxTokens = *req.MaxTokens
+	}
+
+	for _, t := range req.Tools {
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	var system []string
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system", "developer":
+			text, err := contentText(m.Content)
+			if err != nil {
+				return nil, err
+			}
+			system = append(system, text)
+		case "tool":
+			text, err := contentText(m.Content)
+			if err != nil {
+				return nil, err
+			}
+			out.appendMessage("user", anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: text})
+		case "assistant":
+			blocks, err := toAnthropicBlocks(m.Content)
+			if err != nil {
+				return nil, err
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage("{}")
+				if json.Valid([]byte(tc.Function.Arguments)) {
+					input = json.RawMessage(tc.Function.Arguments)
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			out.appendMessage("assistant", blocks...)
+		default:
+			blocks, err := toAnthropicBlocks(m.Content)
+			if err != nil {
+				return nil, err
+			}
+			out.appendMessage("user", blocks...)
+		}
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out, nil
+}
+
+// appendMessage adds blocks to the conversation, merging them into the last
+// message if it has the same role.
+func (r *anthropicRequest) appendMessage(role string, blocks ...anthropicBlock) {
+	if len(blocks) == 0 {
+		return
+	}
+	if n := len(r.Messages); n > 0 && r.Messages[n-1].Role == role {
+		r.Messages[n-1].Content = append(r.Messages[n-1].Content, blocks...)
+		return
+	}
+	r.Messages = append(r.Messages, anthropicMessage{Role: role, Content: blocks})
+}
+
+// contentText joins the text parts of OpenAI message content.
+func contentText(content any) (string, error) {
+	parts, err := contentParts(content)
+	if err != nil {
+		return "", err
+	}
+	var text []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			text = append(text, p.Text)
+		}
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// toAnthropicBlocks converts OpenAI message content to text and image
+// blocks. Empty text is dropped, as the API rejects empty text blocks.
+func toAnthropicBlocks(content any) ([]anthropicBlock, error) {
+	parts, err := contentParts(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []anthropicBlock
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			if p.Text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+			}
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			source := &anthropicImageSource{Type: "url", URL: p.ImageURL.URL}
+			if header, data, ok := strings.Cut(p.ImageURL.URL, ";base64,"); ok && strings.HasPrefix(header, "data:") {
+				source = &anthropicImageSource{Type: "base64", MediaType: strings.TrimPrefix(header, "data:"), Data: data}
+			}
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: source})
+		}
+	}
+	return blocks, nil
+}
+
+// toAnthropicToolChoice maps an OpenAI tool_choice to the Messages API's.
+func toAnthropicToolChoice(choice any) any {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "auto", "none":
+			return map[string]string{"type": c}
+		case "required":
+			return map[string]string{"type": "any"}
+		}
+	case map[string]any:
+		if fn, ok := c["function"].(map[string]any); ok {
+			if name, ok := fn["name"].(string); ok {
+				return map[string]string{"type": "tool", "name": name}
+			}
+		}
+	}
+	return nil
+}
+
+// toOpenAIMessage converts response content blocks to an assistant message.
+func toOpenAIMessage(blocks []anthropicBlock) types.ChatMessage {
+	msg := types.ChatMessage{Role: "assistant"}
+	var text []string
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, types.ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: types.ToolCallFunction{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "")
+	return msg
+}
+
+func (b *AnthropicBackend) ChatCompletion(ctx context.Context, chatReq *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	native, err := toAnthropicRequest(chatReq, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp anthropicResponse
+	if err := b.postJSON(ctx, b.apiPath("/messages"), "chat completion", native, &resp); err != nil {
+		return nil, err
+	}
+
+	return &types.ChatCompletionResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []types.Choice{{
+			Index:        0,
+			Message:      toOpenAIMessage(resp.Content),
+			FinishReason: anthropicFinishReason(resp.StopReason),
+		}},
+		Usage: resp.Usage.usage(),
+	}, nil
+}
+
+// anthropicStreamEvent covers the events of a streamed Messages response.
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Message      *anthropicResponse `json:"message"`       // message_start
+	Index        int                `json:"index"`         // content_block_*
+	ContentBlock *anthropicBlock    `json:"content_block"` // content_block_start
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`         // text_delta
+		PartialJSON string `json:"partial_json"` // input_json_delta
+		StopReason  string `json:"stop_reason"`  // message_delta
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"` // message_delta
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStream converts streamed Messages events to OpenAI chunks.
+type anthropicStream struct {
+	id, model    string
+	created      int64
+	includeUsage bool
+	toolCalls    map[int]int // content block index -> tool call index
+	usage        anthropicUsage
+}
+
+func (s *anthropicStream) chunk(delta types.ChatDelta, finish *string) types.ChatCompletionChunk {
+	return types.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []types.ChunkChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+	}
+}
+
+// convert returns the chunks for one event, and whether the stream is done.
+func (s *anthropicStream) convert(ev *anthropicStreamEvent) (chunks []any, done bool, err error) {
+	switch ev.Type {
+	case "message_start":
+		if ev.Message != nil {
+			s.id = ev.Message.ID
+			s.model = ev.Message.Model
+			s.usage.InputTokens = ev.Message.Usage.InputTokens
+		}
+		return []any{s.chunk(types.ChatDelta{Role: "assistant"}, nil)}, false, nil
+
+	case "content_block_start":
+		block := ev.ContentBlock
+		if block == nil {
+			return nil, false, nil
+		}
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				return []any{s.chunk(types.ChatDelta{Content: block.Text}, nil)}, false, nil
+			}
+		case "tool_use":
+			index := len(s.toolCalls)
+			s.toolCalls[ev.Index] = index
+			call := types.ToolCall{Index: &index, ID: block.ID, Type: "function", Function: types.ToolCallFunction{Name: block.Name}}
+			return []any{s.chunk(types.ChatDelta{ToolCalls: []types.ToolCall{call}}, nil)}, false, nil
+		}
+
+	case "content_block_delta":
+		switch ev.Delta.Type {
+		case "text_delta":
+			return []any{s.chunk(types.ChatDelta{Content: ev.Delta.Text}, nil)}, false, nil
+		case "input_json_delta":
+			index, ok := s.toolCalls[ev.Index]
+			if !ok || ev.Delta.PartialJSON == "" {
+				return nil, false, nil
+			}
+			call := types.ToolCall{Index: &index, Function: types.ToolCallFunction{Arguments: ev.Delta.PartialJSON}}
+			return []any{s.chunk(types.ChatDelta{ToolCalls: []types.ToolCall{call}}, nil)}, false, nil
+		}
+
+	case "message_delta":
+		if ev.Usage != nil {
+			s.usage.OutputTokens = ev.Usage.OutputTokens
+		}
+		if ev.Delta.StopReason != "" {
+			finish := anthropicFinishReason(ev.Delta.StopReason)
+			return []any{s.chunk(types.ChatDelta{}, &finish)}, false, nil
+		}
+
+	case "message_stop":
+		if s.includeUsage {
+			final := s.chunk(types.ChatDelta{}, nil)
+			final.Choices = []types.ChunkChoice{}
+			final.Usage = s.usage.usage()
+			return []any{final}, true, nil
+		}
+		return nil, true, nil
+
+	case "error":
+		if ev.Error != nil {
+			return nil, false, fmt.Errorf("anthropic stream error: %s: %s", ev.Error.Type, ev.Error.Message)
+		}
+		return nil, false, errors.New("anthropic stream error")
+	}
+	return nil, false, nil
+}
+
+func (b *AnthropicBackend) ChatCompletionStream(ctx context.Context, chatReq *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	native, err := toAnthropicRequest(chatReq, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.post(ctx, b.apiPath("/messages"), "stream request", native)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &anthropicStream{
+		id:           newCompletionID("chatcmpl-"),
+		model:        chatReq.Model,
+		created:      time.Now().Unix(),
+		includeUsage: chatReq.StreamOptions != nil && chatReq.StreamOptions.IncludeUsage,
+		toolCalls:    make(map[int]int),
+	}
+	events := make(chan oairouter.StreamEvent, 100)
+
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		send := func(event oairouter.StreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:"); ok {
+				var ev anthropicStreamEvent
+				if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+					send(oairouter.StreamEvent{Err: fmt.Errorf("invalid stream event: %w", err), Done: true})
+					return
+				}
+
+				chunks, done, convErr := stream.convert(&ev)
+				if convErr != nil {
+					send(oairouter.StreamEvent{Err: convErr, Done: true})
+					return
+				}
+				for _, chunk := range chunks {
+					data, err := json.Marshal(chunk)
+					if err != nil {
+						send(oairouter.StreamEvent{Err: err, Done: true})
+						return
+					}
+					if !send(oairouter.StreamEvent{Data: string(data)}) {
+						return
+					}
+				}
+				if done {
+					send(oairouter.StreamEvent{Data: "[DONE]", Done: true})
+					return
+				}
+			}
+
+			if err != nil {
+				if err == io.EOF {
+					send(oairouter.StreamEvent{Done: true})
+				} else {
+					send(oairouter.StreamEvent{Err: err, Done: true})
+				}
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// errAnthropicUnsupported is returned for the OpenAI surfaces the Messages
+// API has no equivalent of.
+func errAnthropicUnsupported(op string) error {
+	return fmt.Errorf("anthropic messages API does not support %s", op)
+}
+
+func (b *AnthropicBackend) Completion(ctx context.Context, compReq *types.CompletionRequest) (*types.CompletionResponse, error) {
+	return nil, errAnthropicUnsupported("completions")
+}
+
+func (b *AnthropicBackend) CompletionStream(ctx context.Context, compReq *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	return nil, errAnthropicUnsupported("completions")
+}
+
+func (b *AnthropicBackend) Embeddings(ctx context.Context, embReq *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	return nil, errAnthropicUnsupported("embeddings")
+}
@@ -0,0 +1,315 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// anthropicServer fakes a Messages API server and records the last request
+// body and headers.
+func anthropicServer(t *testing.T, lastReq *map[string]any, lastHeader *http.Header) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lastHeader != nil {
+			*lastHeader = r.Header.Clone()
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"type":"model","id":"claude-test","display_name":"Claude Test","created_at":"2024-05-01T12:00:00Z"}],"has_more":false}`))
+		case "/v1/messages":
+			var req map[string]any
+			json.NewDecoder(r.Body).Decode(&req)
+			if lastReq != nil {
+				*lastReq = req
+			}
+			if req["stream"] == true {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte(`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"claude-test","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`))
+				return
+			}
+			w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":15}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAnthropicBackend_Models(t *testing.T) {
+	var header http.Header
+	b, _ := NewAnthropicBackend("claude", anthropicServer(t, nil, &header).URL, WithAPIKey("secret"))
+
+	models, err := b.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "claude-test" || models[0].OwnedBy != "anthropic" || models[0].Created != 1714564800 {
+		t.Errorf("models = %+v", models)
+	}
+	if header.Get("x-api-key") != "secret" || header.Get("anthropic-version") != AnthropicVersion || header.Get("Authorization") != "" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if b.Type() != oairouter.BackendAnthropic {
+		t.Errorf("Type() = %s, want anthropic", b.Type())
+	}
+	caps := b.Capabilities()
+	if !caps.SupportsChat || !caps.SupportsStreaming || !caps.SupportsTools || caps.SupportsCompletions || caps.SupportsEmbeddings || caps.SupportsStreamingN {
+		t.Errorf("Capabilities() = %+v, expected chat only", caps)
+	}
+}
+
+func TestAnthropicBackend_ChatCompletion(t *testing.T) {
+	var sent map[string]any
+	b, _ := NewAnthropicBackend("claude", anthropicServer(t, &sent, nil).URL)
+
+	maxTokens := 100
+	resp, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+		Model: "claude-test",
+		Messages: []types.ChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Weather in Paris?"},
+			{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "toolu_0", Type: "function", Function: types.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: "tool", ToolCallID: "toolu_0", Content: "sunny"},
+			{Role: "user", Content: "And tomorrow?"},
+		},
+		MaxTokens:  &maxTokens,
+		Stop:       types.StopSequences{"END"},
+		Tools:      []types.Tool{{Type: "function", Function: types.ToolFunction{Name: "get_weather", Parameters: map[string]any{"type": "object"}}}},
+		ToolChoice: "required",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent["system"] != "Be brief." || sent["max_tokens"] != float64(100) || !reflect.DeepEqual(sent["stop_sequences"], []any{"END"}) {
+		t.Errorf("unexpected request: %v", sent)
+	}
+	if !reflect.DeepEqual(sent["tool_choice"], map[string]any{"type": "any"}) {
+		t.Errorf("tool_choice = %v", sent["tool_choice"])
+	}
+	tools := sent["tools"].([]any)
+	if tool := tools[0].(map[string]any); tool["name"] != "get_weather" || tool["input_schema"] == nil {
+		t.Errorf("tools = %v", tools)
+	}
+
+	// The tool result and the following user message merge into one turn
+	messages := sent["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 alternating messages, got %v", messages)
+	}
+	toolUse := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if toolUse["type"] != "tool_use" || toolUse["id"] != "toolu_0" || !reflect.DeepEqual(toolUse["input"], map[string]any{"city": "Paris"}) {
+		t.Errorf("tool_use block = %v", toolUse)
+	}
+	last := messages[2].(map[string]any)
+	if last["role"] != "user" || len(last["content"].([]any)) != 2 {
+		t.Fatalf("last message = %v", last)
+	}
+	if result := last["content"].([]any)[0].(map[string]any); result["type"] != "tool_result" || result["tool_use_id"] != "toolu_0" || result["content"] != "sunny" {
+		t.Errorf("tool_result block = %v", result)
+	}
+
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Let me check." || choice.FinishReason != "tool_calls" {
+		t.Errorf("choice = %+v", choice)
+	}
+	if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].ID != "toolu_1" || choice.Message.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool calls = %+v", choice.Message.ToolCalls)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 15 || resp.Usage.TotalTokens != 25 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicBackend_DefaultMaxTokensAndImages(t *testing.T) {
+	var sent map[string]any
+	b, _ := NewAnthropicBackend("claude", anthropicServer(t, &sent, nil).URL)
+
+	var content any
+	json.Unmarshal([]byte(`[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]`), &content)
+	if _, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+		Model:    "claude-test",
+		Messages: []types.ChatMessage{{Role: "user", Content: content}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent["max_tokens"] != float64(DefaultAnthropicMaxTokens) {
+		t.Errorf("max_tokens = %v", sent["max_tokens"])
+	}
+	blocks := sent["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	want := []any{
+		map[string]any{"type": "text", "text": "What is this?"},
+		map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+		map[string]any{"type": "image", "source": map[string]any{"type": "url", "url": "https://example.com/cat.png"}},
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("content = %v", blocks)
+	}
+}
+
+func TestAnthropicBackend_ChatCompletionStream(t *testing.T) {
+	b, _ := NewAnthropicBackend("claude", anthropicServer(t, nil, nil).URL)
+
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:         "claude-test",
+		Messages:      []types.ChatMessage{{Role: "user", Content: "Weather in Paris?"}},
+		StreamOptions: &types.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content, arguments, finish string
+	var role, toolID, toolName string
+	var usage *types.Usage
+	var last oairouter.StreamEvent
+	for event := range events {
+		last = event
+		if event.Err != nil {
+			t.Fatal(event.Err)
+		}
+		if event.Done {
+			break
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.ID != "msg_1" || chunk.Object != "chat.completion.chunk" {
+			t.Errorf("unexpected chunk: %s", event.Data)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			role += c.Delta.Role
+			content += c.Delta.Content
+			for _, tc := range c.Delta.ToolCalls {
+				if tc.Index == nil || *tc.Index != 0 {
+					t.Errorf("tool call delta without index 0: %s", event.Data)
+				}
+				toolID += tc.ID
+				toolName += tc.Function.Name
+				arguments += tc.Function.Arguments
+			}
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+	}
+
+	if last.Data != "[DONE]" {
+		t.Errorf("expected the stream to end with [DONE], got %+v", last)
+	}
+	if role != "assistant" || content != "Let me check." || finish != "tool_calls" {
+		t.Errorf("role=%q content=%q finish=%q", role, content, finish)
+	}
+	if toolID != "toolu_1" || toolName != "get_weather" || arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call id=%q name=%q arguments=%q", toolID, toolName, arguments)
+	}
+	if usage == nil || usage.PromptTokens != 10 || usage.CompletionTokens != 15 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestAnthropicBackend_StreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer srv.Close()
+	b, _ := NewAnthropicBackend("claude", srv.URL)
+
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:    "claude-test",
+		Messages: []types.ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := <-events
+	if event.Err == nil || !strings.Contains(event.Err.Error(), "Overloaded") || !event.Done {
+		t.Errorf("expected an overloaded error, got %+v", event)
+	}
+}
+
+func TestAnthropicBackend_Unsupported(t *testing.T) {
+	b, _ := NewAnthropicBackend("claude", "http://localhost:1")
+
+	n := 2
+	if _, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{Model: "claude-test", N: &n}); err == nil {
+		t.Error("expected n > 1 to be rejected")
+	}
+	if _, err := b.Embeddings(context.Background(), &types.EmbeddingsRequest{Model: "claude-test", Input: "hi"}); err == nil {
+		t.Error("expected embeddings to be rejected")
+	}
+}
+
+func TestNewBackend_Anthropic(t *testing.T) {
+	b, err := NewBackend("claude", oairouter.BackendAnthropic, "http://localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*AnthropicBackend); !ok {
+		t.Errorf("NewBackend returned %T, want *AnthropicBackend", b)
+	}
+}
+
+func TestGenericBackend_APIKey(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("openai", srv.URL, WithAPIKey("secret"))
+	if _, err := b.Models(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Authorization = %q", header.Get("Authorization"))
+	}
+}
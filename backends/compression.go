@@ -0,0 +1,93 @@
+package backends
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/stevemurr/oairouter"
+)
+
+// do adds the client headers forwarded by the router, authorizes and sends
+// req, and returns the response with its body decompressed.
+func (b *GenericBackend) do(req *http.Request) (*http.Response, error) {
+	for name, values := range oairouter.ForwardedHeaders(req.Context()) {
+		if _, set := req.Header[name]; !set {
+			req.Header[name] = values
+		}
+	}
+	if b.authorize != nil {
+		b.authorize(req)
+	} else if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decompressBody replaces a gzip- or deflate-encoded response body with its
+// decoded content, as http.Transport does for gzip it requested itself.
+// Servers may compress anyway, and custom clients may not decode at all.
+// Other encodings are left alone.
+func decompressBody(resp *http.Response) error {
+	var decoded io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress gzip response: %w", err)
+		}
+		decoded = zr
+	case "deflate":
+		zr, err := newDeflateReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress deflate response: %w", err)
+		}
+		decoded = zr
+	default:
+		return nil
+	}
+
+	resp.Body = &decodedBody{ReadCloser: decoded, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader decodes a deflate body. The HTTP deflate coding is
+// zlib-wrapped, but some servers send raw deflate data, so the zlib header
+// is checked for first.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody closes both the decompressor and the underlying body.
+type decodedBody struct {
+	io.ReadCloser
+	raw io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	d.ReadCloser.Close()
+	return d.raw.Close()
+}
@@ -0,0 +1,77 @@
+package backends
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// compressingServer answers every request with body, compressed with
+// encoding regardless of what the client accepts.
+func compressingServer(t *testing.T, encoding string, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(status)
+		var zw io.WriteCloser
+		if encoding == "gzip" {
+			zw = gzip.NewWriter(w)
+		} else {
+			zw = zlib.NewWriter(w)
+		}
+		io.WriteString(zw, body)
+		zw.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// noDecompression is a client whose transport leaves bodies encoded, as the
+// default transport does when the request sets Accept-Encoding itself.
+var noDecompression = &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+func TestDecompress_GzipChatCompletion(t *testing.T) {
+	srv := compressingServer(t, "gzip", http.StatusOK, `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"}}]}`)
+	b, _ := NewGenericBackend("b", srv.URL, WithHTTPClient(noDecompression))
+
+	resp, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "chatcmpl-1" || len(resp.Choices) != 1 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestDecompress_DeflateModelsAndEmbeddings(t *testing.T) {
+	srv := compressingServer(t, "deflate", http.StatusOK, `{"object":"list","data":[{"id":"m","object":"model"}]}`)
+	b, _ := NewGenericBackend("b", srv.URL, WithHTTPClient(noDecompression))
+
+	models, err := b.Models(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "m" {
+		t.Errorf("Models() = %+v, %v", models, err)
+	}
+	if _, err := b.Embeddings(context.Background(), &types.EmbeddingsRequest{Model: "m", Input: "hi"}); err != nil {
+		t.Errorf("Embeddings() = %v", err)
+	}
+}
+
+func TestDecompress_GzipErrorBody(t *testing.T) {
+	srv := compressingServer(t, "gzip", http.StatusBadRequest, `{"error":{"message":"bad prompt","type":"invalid_request_error"}}`)
+	b, _ := NewGenericBackend("b", srv.URL, WithHTTPClient(noDecompression))
+
+	_, err := b.Completion(context.Background(), &types.CompletionRequest{Model: "m", Prompt: "hi"})
+	var httpErr *oairouter.BackendHTTPError
+	if !errors.As(err, &httpErr) || httpErr.APIError == nil || httpErr.APIError.Error.Message != "bad prompt" {
+		t.Errorf("expected the decoded upstream error, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// contentParts returns OpenAI message content as content parts. String
+// content is a single text part, and parts decoded from JSON as []any are
+// converted. Nil content has no parts.
+func contentParts(content any) ([]types.ContentPart, error) {
+	switch c := content.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []types.ContentPart{{Type: "text", Text: c}}, nil
+	case []types.ContentPart:
+		return c, nil
+	case []any:
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		var parts []types.ContentPart
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return nil, fmt.Errorf("invalid message content: %w", err)
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("unsupported message content type %T", content)
+	}
+}
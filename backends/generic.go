@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,22 +25,71 @@ type GenericBackend struct {
 	backendType oairouter.BackendType
 	baseURL     *url.URL
 	httpClient  *http.Client
-
-	healthy atomic.Bool
-	mu      sync.RWMutex
-	models  []types.Model
+	transport   TransportConfig
+	tlsConfig   *tls.Config
+	caps        map[oairouter.Capability]bool // nil means all capabilities
+	apiKey      string                        // Credential sent with each request, if set
+	maxContext  int                           // Max prompt tokens, 0 if unknown
+	maxTokens   int                           // Cap on the token limit sent, 0 for none
+	defaultMax  int                           // Token limit sent when a request sets none, 0 for none
+
+	maxCompletionTokens bool // Send the token limit as max_completion_tokens
+
+	// protocolCaps are capabilities the wire protocol decides regardless of
+	// configuration or probes, set by backends embedding GenericBackend
+	protocolCaps map[oairouter.Capability]bool
+
+	tier           int // Routing priority; lower tiers are preferred
+	maxConcurrency int // Requests served at once before others are preferred, 0 for no limit
+	tags           []string
+
+	streamFormat StreamFormat // How streamed responses are framed
+	apiPrefix    string       // Path the API is mounted under, "/v1" by default
+
+	healthCheckPath    string // empty means check by fetching models
+	healthCheckTimeout time.Duration
+
+	probe  bool                          // Probe capabilities before registration
+	probed map[oairouter.Capability]bool // Cached probe results, guarded by mu
+
+	// modelsPath and decodeModels fetch and parse the model list; the
+	// defaults are the models endpoint under apiPrefix and the OpenAI
+	// response shape
+	modelsPath   string
+	decodeModels func([]byte) ([]types.Model, error)
+
+	// authorize adds the API key and any protocol headers to a request; the
+	// default sends the key as a Bearer token
+	authorize func(*http.Request)
+
+	seed []types.Model // Models to route before the model list is fetched
+
+	healthy       atomic.Bool
+	lastHealthErr atomic.Pointer[string] // Message of the last failed health check, nil after a success
+	mu            sync.RWMutex
+	models        []types.Model
+	modelsAt      time.Time // When models was fetched
 }
 
 // GenericBackendOption configures a GenericBackend.
 type GenericBackendOption func(*GenericBackend)
 
-// WithHTTPClient sets a custom HTTP client.
+// WithHTTPClient sets a custom HTTP client, used instead of the default one
+// built from the backend's TransportConfig.
 func WithHTTPClient(client *http.Client) GenericBackendOption {
 	return func(b *GenericBackend) {
 		b.httpClient = client
 	}
 }
 
+// WithAPIKey sets the API key sent with each request, as a Bearer token for
+// OpenAI-compatible servers and as x-api-key for Anthropic ones.
+func WithAPIKey(key string) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.apiKey = key
+	}
+}
+
 // WithBackendType sets the backend type.
 func WithBackendType(t oairouter.BackendType) GenericBackendOption {
 	return func(b *GenericBackend) {
@@ -47,6 +97,132 @@ func WithBackendType(t oairouter.BackendType) GenericBackendOption {
 	}
 }
 
+// WithCapabilities restricts the API surfaces the backend serves.
+// By default a backend is assumed to support chat, completions, and embeddings.
+func WithCapabilities(caps ...oairouter.Capability) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.caps = make(map[oairouter.Capability]bool, len(caps))
+		for _, c := range caps {
+			b.caps[c] = true
+		}
+	}
+}
+
+// WithMaxContextTokens declares the largest prompt the backend accepts, so
+// the router rejects longer prompts instead of forwarding them.
+func WithMaxContextTokens(n int) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.maxContext = n
+	}
+}
+
+// WithMaxTokensCap caps the token limit chat and completion requests send
+// the backend, e.g. to keep a small server from running out of memory. The
+// router lowers larger max_tokens (or max_completion_tokens) to n and sets
+// the X-Max-Tokens-Clamped response header. Requests that set no limit are
+// left unset unless WithDefaultMaxTokens is also given.
+func WithMaxTokensCap(n int) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.maxTokens = n
+	}
+}
+
+// WithDefaultMaxTokens sets the token limit sent for chat and completion
+// requests that set none. It is lowered to the WithMaxTokensCap cap, if any.
+func WithDefaultMaxTokens(n int) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.defaultMax = n
+	}
+}
+
+// WithMaxCompletionTokens declares that the backend takes the chat token
+// limit as max_completion_tokens, as OpenAI's reasoning models require,
+// rather than max_tokens. The router renames whichever the client sent.
+func WithMaxCompletionTokens() GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.maxCompletionTokens = true
+	}
+}
+
+// WithTier sets the backend's routing tier (default 0). Requests prefer
+// backends of the lowest tier serving their model, and overflow to the next
+// tier only when all of them are unhealthy or at their concurrency limit.
+func WithTier(tier int) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.tier = tier
+	}
+}
+
+// WithTags labels the backend with tags, which WithModelPrefixRoute routes
+// model prefixes by.
+func WithTags(tags ...string) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.tags = append(b.tags, tags...)
+	}
+}
+
+// WithMaxConcurrency sets how many requests the backend serves at once
+// before requests for its model prefer other backends, e.g. a higher tier.
+// If every backend is at its limit, requests still go to the preferred one.
+func WithMaxConcurrency(n int) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.maxConcurrency = n
+	}
+}
+
+// WithHealthCheckPath checks health by requesting a lightweight endpoint
+// (e.g. "/health") instead of fetching the model list. Only a 200 response
+// marks the backend healthy. The path is relative to the base URL, not the
+// API prefix, since servers usually mount health checks at their root.
+func WithHealthCheckPath(path string) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.healthCheckPath = path
+	}
+}
+
+// WithAPIPrefix sets the path the backend mounts its API under, for servers
+// that serve it at e.g. "/openai/v1" instead of "/v1". Chat, completions,
+// embeddings, images, and the model list are requested under the prefix.
+// An empty prefix serves the endpoints from the base URL's root.
+func WithAPIPrefix(prefix string) GenericBackendOption {
+	return func(b *GenericBackend) {
+		prefix = strings.TrimRight(prefix, "/")
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		b.apiPrefix = prefix
+	}
+}
+
+// WithHealthCheckTimeout bounds each health check, independent of the HTTP
+// client's request timeout (default: 5s).
+func WithHealthCheckTimeout(d time.Duration) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.healthCheckTimeout = d
+	}
+}
+
+// WithCapabilityProbe makes the backend detect streaming, tools, and logprobs
+// support by sending one-token chat requests when it is registered, instead
+// of assuming them. Results are cached for the life of the backend.
+func WithCapabilityProbe(enabled bool) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.probe = enabled
+	}
+}
+
+// WithSeedModels declares models the backend serves before its model list
+// can be fetched, so the registry routes them while the server is still
+// starting. The fetched list replaces them once available.
+func WithSeedModels(ids ...string) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.seed = make([]types.Model, len(ids))
+		for i, id := range ids {
+			b.seed[i] = types.Model{ID: id, Object: "model"}
+		}
+	}
+}
+
 // NewGenericBackend creates a new generic OpenAI-compatible backend.
 func NewGenericBackend(id string, baseURL string, opts ...GenericBackendOption) (*GenericBackend, error) {
 	u, err := url.Parse(baseURL)
@@ -55,18 +231,29 @@ func NewGenericBackend(id string, baseURL string, opts ...GenericBackendOption)
 	}
 
 	b := &GenericBackend{
-		id:          id,
-		backendType: oairouter.BackendGeneric,
-		baseURL:     u,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute, // Long timeout for completions
-		},
+		id:                 id,
+		backendType:        oairouter.BackendGeneric,
+		baseURL:            u,
+		transport:          DefaultTransportConfig,
+		healthCheckTimeout: 5 * time.Second,
+		apiPrefix:          "/v1",
 	}
 	b.healthy.Store(true)
 
 	for _, opt := range opts {
 		opt(b)
 	}
+	b.modelsPath = b.apiPath("/models")
+
+	if b.httpClient == nil {
+		b.httpClient = &http.Client{
+			Timeout:   5 * time.Minute, // Long timeout for completions
+			Transport: NewTransport(b.transport),
+		}
+	}
+	if b.tlsConfig != nil {
+		b.httpClient = clientWithTLS(b.httpClient, b.tlsConfig)
+	}
 
 	return b, nil
 }
@@ -83,6 +270,61 @@ func (b *GenericBackend) BaseURL() *url.URL {
 	return b.baseURL
 }
 
+// Supports reports whether the backend serves the given capability.
+// Capabilities set with WithCapabilities take precedence over probe results.
+// CapabilityMaxCompletionTokens is never assumed: older servers ignore
+// max_completion_tokens and would generate without a limit, so it must be
+// declared with WithMaxCompletionTokens or WithCapabilities. Neither is
+// CapabilityFunctions, which would have tool requests sent to a backend that
+// lacks tools in their deprecated form instead of rejected. Backends built on
+// GenericBackend for another protocol fix the capabilities it decides.
+func (b *GenericBackend) Supports(c oairouter.Capability) bool {
+	if supported, ok := b.protocolCaps[c]; ok {
+		return supported
+	}
+	if c == oairouter.CapabilityMaxCompletionTokens {
+		return b.maxCompletionTokens || b.caps[c]
+	}
+	if c == oairouter.CapabilityFunctions {
+		return b.caps[c]
+	}
+	if b.caps != nil {
+		return b.caps[c]
+	}
+
+	b.mu.RLock()
+	supported, ok := b.probed[c]
+	b.mu.RUnlock()
+	if ok {
+		return supported
+	}
+	return true
+}
+
+// Tier implements oairouter.Tiered.
+func (b *GenericBackend) Tier() int {
+	return b.tier
+}
+
+// Tags implements oairouter.Tagged.
+func (b *GenericBackend) Tags() []string {
+	return b.tags
+}
+
+// MaxConcurrency implements oairouter.ConcurrencyLimiter.
+func (b *GenericBackend) MaxConcurrency() int {
+	return b.maxConcurrency
+}
+
+// Capabilities describes the backend from Supports, with the context limit
+// set by WithMaxContextTokens.
+func (b *GenericBackend) Capabilities() oairouter.BackendCapabilities {
+	caps := oairouter.CapabilitiesFrom(b.Supports)
+	caps.MaxContextTokens = b.maxContext
+	caps.MaxTokensCap, caps.DefaultMaxTokens = b.maxTokens, b.defaultMax
+	return caps
+}
+
 func (b *GenericBackend) IsHealthy() bool {
 	return b.healthy.Load()
 }
@@ -91,46 +333,174 @@ func (b *GenericBackend) setHealthy(healthy bool) {
 	b.healthy.Store(healthy)
 }
 
+// LastHealthError returns why the most recent health check failed, or "" if
+// it succeeded or none has run.
+func (b *GenericBackend) LastHealthError() string {
+	if msg := b.lastHealthErr.Load(); msg != nil {
+		return *msg
+	}
+	return ""
+}
+
 func (b *GenericBackend) HealthCheck(ctx context.Context) error {
-	// Try to fetch models as a health check
-	_, err := b.Models(ctx)
+	if b.healthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.healthCheckTimeout)
+		defer cancel()
+	}
+
+	var err error
+	if b.healthCheckPath != "" {
+		err = b.checkHealthEndpoint(ctx)
+	} else {
+		// Try to fetch models as a health check
+		_, err = b.Models(ctx)
+	}
 	b.setHealthy(err == nil)
+	if err != nil {
+		msg := err.Error()
+		b.lastHealthErr.Store(&msg)
+	} else {
+		b.lastHealthErr.Store(nil)
+	}
 	return err
 }
 
+// checkHealthEndpoint requests the configured health path and expects a 200.
+func (b *GenericBackend) checkHealthEndpoint(ctx context.Context) error {
+	u := b.baseURL.JoinPath(b.healthCheckPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("health check failed: %s - %s", resp.Status, string(body))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
 func (b *GenericBackend) Models(ctx context.Context) ([]types.Model, error) {
-	u := b.baseURL.JoinPath("/v1/models")
+	body, err := b.get(ctx, b.modelsPath, "models request")
+	if err != nil {
+		return nil, err
+	}
+
+	var models []types.Model
+	if b.decodeModels != nil {
+		models, err = b.decodeModels(body)
+	} else {
+		var modelsResp types.ModelsResponse
+		err = json.Unmarshal(body, &modelsResp)
+		models = modelsResp.Data
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode models response: %w", err)
+	}
+
+	b.setModels(models)
+	return models, nil
+}
+
+// SeedModels returns the models set with WithSeedModels.
+func (b *GenericBackend) SeedModels() []types.Model {
+	return b.seed
+}
+
+// apiPath returns the path of an API endpoint, e.g. "/chat/completions",
+// under the backend's API prefix.
+func (b *GenericBackend) apiPath(endpoint string) string {
+	return b.apiPrefix + endpoint
+}
+
+// get requests path and returns the body of a 200 response.
+func (b *GenericBackend) get(ctx context.Context, path, op string) ([]byte, error) {
+	u := b.baseURL.JoinPath(path)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := b.httpClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("models request failed: %s - %s", resp.Status, string(body))
+		return nil, oairouter.NewBackendHTTPError(op, resp)
 	}
+	return io.ReadAll(resp.Body)
+}
 
-	var modelsResp types.ModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode models response: %w", err)
+// post sends a JSON request and returns the response of a 200 reply.
+func (b *GenericBackend) post(ctx context.Context, path, op string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	u := b.baseURL.JoinPath(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, oairouter.NewBackendHTTPError(op, resp)
+	}
+	return resp, nil
+}
+
+// postJSON sends a non-streaming request and decodes the response.
+func (b *GenericBackend) postJSON(ctx context.Context, path, op string, body any, out any) error {
+	resp, err := b.post(ctx, path, op, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
 	}
+	return nil
+}
 
+// setModels records the most recently fetched model list.
+func (b *GenericBackend) setModels(models []types.Model) {
 	b.mu.Lock()
-	b.models = modelsResp.Data
+	b.models, b.modelsAt = models, time.Now()
 	b.mu.Unlock()
+}
 
-	return modelsResp.Data, nil
+// LastModels returns the most recently fetched model list and when it was
+// fetched, including fetches made by HealthCheck. The time is zero if the list
+// hasn't been fetched yet.
+func (b *GenericBackend) LastModels() ([]types.Model, time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.models, b.modelsAt
 }
 
 func (b *GenericBackend) ChatCompletion(ctx context.Context, chatReq *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
-	u := b.baseURL.JoinPath("/v1/chat/completions")
+	u := b.baseURL.JoinPath(b.apiPath("/chat/completions"))
 
 	body, err := json.Marshal(chatReq)
 	if err != nil {
@@ -143,15 +513,14 @@ func (b *GenericBackend) ChatCompletion(ctx context.Context, chatReq *types.Chat
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.httpClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("chat completion failed: %s - %s", resp.Status, string(respBody))
+		return nil, oairouter.NewBackendHTTPError("chat completion", resp)
 	}
 
 	var chatResp types.ChatCompletionResponse
@@ -173,57 +542,79 @@ func (b *GenericBackend) streamRequest(ctx context.Context, endpoint string, bod
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
 
-	resp, err := b.httpClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("stream request failed: %s - %s", resp.Status, string(respBody))
+		defer resp.Body.Close()
+		return nil, oairouter.NewBackendHTTPError("stream request", resp)
 	}
 
+	format := responseStreamFormat(b.streamFormat, resp.Header.Get("Content-Type"))
 	events := make(chan oairouter.StreamEvent, 100)
 
 	go func() {
 		defer close(events)
 		defer resp.Body.Close()
 
-		reader := bufio.NewReader(resp.Body)
-		for {
+		// Every send also watches ctx, so a consumer that stops reading and
+		// cancels doesn't leave this goroutine blocked on a full channel
+		// holding the backend connection open.
+		send := func(event oairouter.StreamEvent) bool {
 			select {
+			case events <- event:
+				return true
 			case <-ctx.Done():
-				events <- oairouter.StreamEvent{Err: ctx.Err(), Done: true}
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			if err := ctx.Err(); err != nil {
+				send(oairouter.StreamEvent{Err: err, Done: true})
 				return
-			default:
 			}
 
 			line, err := reader.ReadString('\n')
+			if err == io.EOF {
+				// The last line of an NDJSON stream may not end with a newline
+				if data, ok := streamLineData(line, format); ok {
+					if data == "[DONE]" {
+						send(oairouter.StreamEvent{Data: data, Done: true})
+						return
+					}
+					if !send(oairouter.StreamEvent{Data: data}) {
+						return
+					}
+				}
+			}
 			if err != nil {
 				// Send error event for non-EOF errors, but always send Done
 				// to ensure the stream terminates properly for the client
 				if err != io.EOF {
-					events <- oairouter.StreamEvent{Err: err, Done: true}
+					send(oairouter.StreamEvent{Err: err, Done: true})
 				} else {
 					// EOF without [DONE] - signal clean termination
-					events <- oairouter.StreamEvent{Done: true}
+					send(oairouter.StreamEvent{Done: true})
 				}
 				return
 			}
 
-			line = strings.TrimSpace(line)
-			if line == "" || !strings.HasPrefix(line, "data: ") {
+			data, ok := streamLineData(line, format)
+			if !ok {
 				continue
 			}
-
-			data := strings.TrimPrefix(line, "data: ")
 			if data == "[DONE]" {
-				events <- oairouter.StreamEvent{Data: data, Done: true}
+				send(oairouter.StreamEvent{Data: data, Done: true})
 				return
 			}
 
-			events <- oairouter.StreamEvent{Data: data}
+			if !send(oairouter.StreamEvent{Data: data}) {
+				return
+			}
 		}
 	}()
 
@@ -236,11 +627,11 @@ func (b *GenericBackend) ChatCompletionStream(ctx context.Context, chatReq *type
 	if err != nil {
 		return nil, err
 	}
-	return b.streamRequest(ctx, "/v1/chat/completions", body)
+	return b.streamRequest(ctx, b.apiPath("/chat/completions"), body)
 }
 
 func (b *GenericBackend) Completion(ctx context.Context, compReq *types.CompletionRequest) (*types.CompletionResponse, error) {
-	u := b.baseURL.JoinPath("/v1/completions")
+	u := b.baseURL.JoinPath(b.apiPath("/completions"))
 
 	body, err := json.Marshal(compReq)
 	if err != nil {
@@ -253,15 +644,14 @@ func (b *GenericBackend) Completion(ctx context.Context, compReq *types.Completi
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.httpClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("completion failed: %s - %s", resp.Status, string(respBody))
+		return nil, oairouter.NewBackendHTTPError("completion", resp)
 	}
 
 	var compResp types.CompletionResponse
@@ -278,11 +668,11 @@ func (b *GenericBackend) CompletionStream(ctx context.Context, compReq *types.Co
 	if err != nil {
 		return nil, err
 	}
-	return b.streamRequest(ctx, "/v1/completions", body)
+	return b.streamRequest(ctx, b.apiPath("/completions"), body)
 }
 
 func (b *GenericBackend) Embeddings(ctx context.Context, embReq *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
-	u := b.baseURL.JoinPath("/v1/embeddings")
+	u := b.baseURL.JoinPath(b.apiPath("/embeddings"))
 
 	body, err := json.Marshal(embReq)
 	if err != nil {
@@ -295,15 +685,14 @@ func (b *GenericBackend) Embeddings(ctx context.Context, embReq *types.Embedding
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.httpClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("embeddings failed: %s - %s", resp.Status, string(respBody))
+		return nil, oairouter.NewBackendHTTPError("embeddings", resp)
 	}
 
 	var embResp types.EmbeddingsResponse
@@ -313,3 +702,72 @@ func (b *GenericBackend) Embeddings(ctx context.Context, embReq *types.Embedding
 
 	return &embResp, nil
 }
+
+// GenerateImages implements oairouter.ImageGenerator. The response is decoded
+// straight from the connection, so large base64 images aren't buffered twice.
+func (b *GenericBackend) GenerateImages(ctx context.Context, imgReq *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	u := b.baseURL.JoinPath(b.apiPath("/images/generations"))
+
+	body, err := json.Marshal(imgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, oairouter.NewBackendHTTPError("image generation", resp)
+	}
+
+	var imgResp types.ImageGenerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&imgResp); err != nil {
+		return nil, fmt.Errorf("failed to decode image generation response: %w", err)
+	}
+
+	return &imgResp, nil
+}
+
+// NewBackend creates the backend implementation for a backend type: an
+// LMStudioBackend for BackendLMStudio, an AnthropicBackend for
+// BackendAnthropic, and a GenericBackend of that type otherwise.
+func NewBackend(id string, typ oairouter.BackendType, baseURL string, opts ...GenericBackendOption) (oairouter.Backend, error) {
+	switch typ {
+	case oairouter.BackendLMStudio:
+		b, err := NewLMStudioBackend(id, baseURL, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case oairouter.BackendAnthropic:
+		b, err := NewAnthropicBackend(id, baseURL, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	b, err := NewGenericBackend(id, baseURL, append(opts[:len(opts):len(opts)], WithBackendType(typ))...)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Factory returns an oairouter.BackendFactory that builds backends with
+// NewBackend and the given options, e.g. for registering backends through the
+// admin API.
+func Factory(opts ...GenericBackendOption) oairouter.BackendFactory {
+	return func(id string, typ oairouter.BackendType, baseURL string) (oairouter.Backend, error) {
+		return NewBackend(id, typ, baseURL, opts...)
+	}
+}
@@ -0,0 +1,358 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestHealthCheck_CustomPath(t *testing.T) {
+	var modelsCalls atomic.Int64
+	var status atomic.Int64
+	status.Store(http.StatusOK)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(int(status.Load()))
+		case "/v1/models":
+			modelsCalls.Add(1)
+			w.Write([]byte(`{"object":"list","data":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	b, err := NewGenericBackend("llamacpp", srv.URL, WithHealthCheckPath("/health"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+	if modelsCalls.Load() != 0 {
+		t.Error("health check should not fetch models when a path is configured")
+	}
+
+	status.Store(http.StatusServiceUnavailable)
+	if err := b.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected a non-200 health response to fail")
+	}
+	if b.IsHealthy() {
+		t.Error("expected backend to be marked unhealthy")
+	}
+}
+
+func TestHealthCheck_FallsBackToModels(t *testing.T) {
+	var modelsCalls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			modelsCalls.Add(1)
+			w.Write([]byte(`{"object":"list","data":[{"id":"m"}]}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("generic", srv.URL)
+	if err := b.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+	if modelsCalls.Load() != 1 {
+		t.Errorf("expected the models endpoint to be used, got %d calls", modelsCalls.Load())
+	}
+}
+
+func TestHealthCheck_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	b, _ := NewGenericBackend("slow", srv.URL,
+		WithHealthCheckPath("/health"),
+		WithHealthCheckTimeout(20*time.Millisecond),
+	)
+
+	start := time.Now()
+	if err := b.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("health check took %v, expected it to respect the short timeout", elapsed)
+	}
+}
+
+func TestHealthCheck_LastHealthError(t *testing.T) {
+	var status atomic.Int64
+	status.Store(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("loading model"))
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("vllm", srv.URL, WithHealthCheckPath("/health"))
+	if msg := b.LastHealthError(); msg != "" {
+		t.Errorf("LastHealthError() before any check = %q, want empty", msg)
+	}
+
+	b.HealthCheck(context.Background())
+	if msg := b.LastHealthError(); !strings.Contains(msg, "503") || !strings.Contains(msg, "loading model") {
+		t.Errorf("LastHealthError() = %q, want the 503 and body", msg)
+	}
+
+	status.Store(http.StatusOK)
+	b.HealthCheck(context.Background())
+	if msg := b.LastHealthError(); msg != "" {
+		t.Errorf("LastHealthError() after recovery = %q, want empty", msg)
+	}
+}
+
+func TestHealthCheck_RecordsLastModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":"list","data":[{"id":"llama","object":"model"}]}`))
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("vllm", srv.URL)
+	if _, at := b.LastModels(); !at.IsZero() {
+		t.Errorf("LastModels() before any fetch has time %v, want zero", at)
+	}
+
+	if err := b.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	models, at := b.LastModels()
+	if len(models) != 1 || models[0].ID != "llama" || at.IsZero() {
+		t.Errorf("LastModels() = %+v, %v; want llama with a fetch time", models, at)
+	}
+}
+
+func TestChatCompletion_ReturnsBackendHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"max_tokens too large","type":"invalid_request_error","param":"max_tokens"}}`))
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("vllm", srv.URL)
+	_, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{Model: "m"})
+
+	var httpErr *oairouter.BackendHTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected BackendHTTPError, got %T: %v", err, err)
+	}
+	if httpErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d, want 400", httpErr.StatusCode)
+	}
+	if httpErr.APIError == nil || httpErr.APIError.Error.Message != "max_tokens too large" {
+		t.Errorf("expected parsed upstream error, got %+v", httpErr.APIError)
+	}
+}
+
+func TestGenerateImages_PassesImageDataThrough(t *testing.T) {
+	image := strings.Repeat("iVBORw0KGgo", 100_000) // ~1 MB of base64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/images/generations" {
+			http.NotFound(w, r)
+			return
+		}
+		var req types.ImageGenerationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"created":1700000000,"data":[{"b64_json":%q,"revised_prompt":%q}]}`, image, req.Prompt+", detailed")
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("images", srv.URL)
+	resp, err := b.GenerateImages(context.Background(), &types.ImageGenerationRequest{Model: "m", Prompt: "a cat", ResponseFormat: "b64_json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].B64JSON != image || resp.Data[0].RevisedPrompt != "a cat, detailed" {
+		t.Errorf("image data not passed through intact: %d images", len(resp.Data))
+	}
+}
+
+func TestCapabilities_MaxCompletionTokensDeclared(t *testing.T) {
+	b, _ := NewGenericBackend("b", "http://localhost:8000", WithMaxContextTokens(4096))
+	caps := b.Capabilities()
+	if caps.SupportsMaxCompletionTokens || !caps.SupportsChat || caps.MaxContextTokens != 4096 {
+		t.Errorf("Capabilities() = %+v", caps)
+	}
+
+	declared, _ := NewGenericBackend("b", "http://localhost:8000", WithMaxCompletionTokens())
+	if !declared.Supports(oairouter.CapabilityMaxCompletionTokens) {
+		t.Error("expected max_completion_tokens with WithMaxCompletionTokens")
+	}
+	listed, _ := NewGenericBackend("b", "http://localhost:8000",
+		WithCapabilities(oairouter.CapabilityChat, oairouter.CapabilityMaxCompletionTokens))
+	if !listed.Supports(oairouter.CapabilityMaxCompletionTokens) {
+		t.Error("expected max_completion_tokens with WithCapabilities")
+	}
+}
+
+func TestCapabilities_FunctionsDeclared(t *testing.T) {
+	b, _ := NewGenericBackend("b", "http://localhost:8000")
+	if b.Supports(oairouter.CapabilityFunctions) || !b.Supports(oairouter.CapabilityTools) {
+		t.Errorf("Capabilities() = %+v", b.Capabilities())
+	}
+	listed, _ := NewGenericBackend("b", "http://localhost:8000",
+		WithCapabilities(oairouter.CapabilityChat, oairouter.CapabilityFunctions))
+	if caps := listed.Capabilities(); !caps.SupportsFunctions || caps.SupportsTools {
+		t.Errorf("Capabilities() = %+v", caps)
+	}
+}
+
+func TestFactory(t *testing.T) {
+	factory := Factory(WithHealthCheckPath("/healthz"))
+
+	b, err := factory("added", oairouter.BackendVLLM, "http://10.0.0.5:8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ID() != "added" || b.Type() != oairouter.BackendVLLM || b.BaseURL().String() != "http://10.0.0.5:8000" {
+		t.Errorf("backend = %s %s %s", b.ID(), b.Type(), b.BaseURL())
+	}
+	if gb := b.(*GenericBackend); gb.healthCheckPath != "/healthz" {
+		t.Errorf("healthCheckPath = %q, want /healthz", gb.healthCheckPath)
+	}
+
+	if _, err := factory("bad", oairouter.BackendGeneric, "http://[::1"); err == nil {
+		t.Error("expected error for invalid URL")
+	}
+}
+
+func TestStreamRequest_CancelReleasesProducer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%d\"}}]}\n\n", i)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	b, _ := NewGenericBackend("vllm", srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := b.ChatCompletionStream(ctx, &types.ChatCompletionRequest{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop reading once the buffer is full, leaving the producer blocked on a send
+	deadline := time.Now().Add(2 * time.Second)
+	for len(events) < cap(events) {
+		if time.Now().After(deadline) {
+			t.Fatal("stream buffer never filled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// A released producer sends nothing more and closes the channel
+	received := 0
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				if received != cap(events) {
+					t.Errorf("received %d events, want %d buffered before cancel", received, cap(events))
+				}
+				return
+			}
+			received++
+		case <-timeout:
+			t.Fatal("producer did not close the stream after cancel")
+		}
+	}
+}
+
+func TestAPIPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"/openai/v1", "/openai/v1"},
+		{"openai/v1/", "/openai/v1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		var paths []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			switch strings.TrimPrefix(r.URL.Path, tt.want) {
+			case "/models":
+				w.Write([]byte(`{"object":"list","data":[{"id":"m"}]}`))
+			case "/chat/completions", "/completions", "/embeddings":
+				w.Write([]byte(`{}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+
+		ctx := context.Background()
+		b, _ := NewGenericBackend("prefixed", srv.URL, WithAPIPrefix(tt.prefix))
+		if err := b.HealthCheck(ctx); err != nil {
+			t.Errorf("prefix %q: health check failed: %v", tt.prefix, err)
+		}
+		if _, err := b.ChatCompletion(ctx, &types.ChatCompletionRequest{Model: "m"}); err != nil {
+			t.Errorf("prefix %q: chat failed: %v", tt.prefix, err)
+		}
+		if _, err := b.Completion(ctx, &types.CompletionRequest{Model: "m"}); err != nil {
+			t.Errorf("prefix %q: completion failed: %v", tt.prefix, err)
+		}
+		if _, err := b.Embeddings(ctx, &types.EmbeddingsRequest{Model: "m"}); err != nil {
+			t.Errorf("prefix %q: embeddings failed: %v", tt.prefix, err)
+		}
+		srv.Close()
+
+		want := []string{tt.want + "/models", tt.want + "/chat/completions", tt.want + "/completions", tt.want + "/embeddings"}
+		if fmt.Sprint(paths) != fmt.Sprint(want) {
+			t.Errorf("prefix %q: requested %v, want %v", tt.prefix, paths, want)
+		}
+	}
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := oairouter.ContextWithForwardedHeaders(context.Background(), http.Header{
+		"Openai-Organization": {"org-1"},
+		"Authorization":       {"Bearer client-key"},
+	})
+	b, _ := NewGenericBackend("openai", srv.URL, WithAPIKey("backend-key"))
+	if _, err := b.ChatCompletion(ctx, &types.ChatCompletionRequest{Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("OpenAI-Organization") != "org-1" {
+		t.Errorf("OpenAI-Organization = %q, want org-1", got.Get("OpenAI-Organization"))
+	}
+	if got.Get("Authorization") != "Bearer backend-key" {
+		t.Errorf("Authorization = %q, want the backend's key", got.Get("Authorization"))
+	}
+}
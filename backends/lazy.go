@@ -0,0 +1,414 @@
+package backends
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// DefaultLazyStartTimeout is how long a LazyBackend waits for its server to
+// start and pass a health check.
+const DefaultLazyStartTimeout = 5 * time.Minute
+
+// lazyHealthPollInterval is how often a starting LazyBackend checks whether
+// its server is healthy yet.
+const lazyHealthPollInterval = time.Second
+
+// errLazyStopped is returned by Models while a LazyBackend that has no known
+// models is stopped.
+var errLazyStopped = errors.New("backend is stopped and has no known models")
+
+// LazyBackend wraps a backend whose server is started on demand, so the
+// router can scale it from zero. The first request calls the start function,
+// waits until the backend passes a health check, and is then proxied to it;
+// requests arriving meanwhile wait for the same start. Once running, requests
+// go straight through. With WithIdleStop, the server is stopped again after
+// it has served no requests for a while.
+//
+// While stopped, the backend reports itself healthy, since a request will
+// start it, and lists the models it last fetched, or else the wrapped
+// backend's seeded models (see WithSeedModels), without starting it. Deep
+// health checks and warmup send requests, so they start it as well.
+//
+// The wrapped backend's optional interfaces are forwarded: its tier, tags,
+// concurrency limit, last health error, last fetched models and TLS
+// configuration pass through unchanged, and image generation starts the
+// server like any other request. Where the wrapped backend doesn't implement
+// one, LazyBackend reports the router's default (tier 0, no tags, no limit)
+// and GenerateImages fails. Capability probes run only while the server is
+// running, so registering a stopped backend doesn't start it.
+type LazyBackend struct {
+	oairouter.Backend
+
+	start        func(ctx context.Context) error
+	stop         func(ctx context.Context) error // Called after idleTimeout without requests, if set
+	logger       *slog.Logger
+	idleTimeout  time.Duration
+	startTimeout time.Duration
+	pollInterval time.Duration
+
+	// lifecycle is held while the server is started or stopped, so requests
+	// share one start and never overlap a stop
+	lifecycle chan struct{}
+	running   atomic.Bool
+
+	mu     sync.Mutex
+	active int         // Requests in flight, including open streams
+	idle   *time.Timer // Stops the server once it fires, if idleTimeout is set
+	models []types.Model
+}
+
+// LazyBackendOption configures a LazyBackend.
+type LazyBackendOption func(*LazyBackend)
+
+// WithIdleStop calls stop once the backend has served no requests for
+// timeout, and marks it stopped, even if stop fails, so the next request
+// starts it again.
+func WithIdleStop(timeout time.Duration, stop func(ctx context.Context) error) LazyBackendOption {
+	return func(b *LazyBackend) {
+		b.idleTimeout, b.stop = timeout, stop
+	}
+}
+
+// WithStartTimeout bounds how long the backend waits for its server to start
+// and become healthy, DefaultLazyStartTimeout by default. It also bounds each
+// call to the stop function.
+func WithStartTimeout(d time.Duration) LazyBackendOption {
+	return func(b *LazyBackend) {
+		b.startTimeout = d
+	}
+}
+
+// WithLazyLogger sets the logger that reports failed stops, slog.Default()
+// by default.
+func WithLazyLogger(l *slog.Logger) LazyBackendOption {
+	return func(b *LazyBackend) {
+		b.logger = l
+	}
+}
+
+// NewLazyBackend wraps backend, whose server is launched by start. start should
+// return once the server is launched; the backend then waits for it to pass
+// a health check.
+func NewLazyBackend(backend oairouter.Backend, start func(ctx context.Context) error, opts ...LazyBackendOption) (*LazyBackend, error) {
+	if backend == nil || start == nil {
+		return nil, fmt.Errorf("lazy backend needs a backend and a start function")
+	}
+	b := &LazyBackend{
+		Backend:      backend,
+		start:        start,
+		startTimeout: DefaultLazyStartTimeout,
+		pollInterval: lazyHealthPollInterval,
+		lifecycle:    make(chan struct{}, 1),
+		logger:       slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.startTimeout <= 0 {
+		return nil, fmt.Errorf("lazy backend start timeout must be positive")
+	}
+	if b.stop != nil && b.idleTimeout <= 0 {
+		return nil, fmt.Errorf("lazy backend idle timeout must be positive")
+	}
+	if b.logger == nil {
+		return nil, fmt.Errorf("lazy backend logger must not be nil")
+	}
+	return b, nil
+}
+
+// Running reports whether the server has been started and not since stopped.
+func (b *LazyBackend) Running() bool {
+	return b.running.Load()
+}
+
+// Models lists the wrapped backend's models while running. While stopped it
+// returns the models last fetched, or the seeded ones, without starting it.
+func (b *LazyBackend) Models(ctx context.Context) ([]types.Model, error) {
+	if b.running.Load() {
+		models, err := b.Backend.Models(ctx)
+		if err == nil {
+			b.mu.Lock()
+			b.models = models
+			b.mu.Unlock()
+		}
+		return models, err
+	}
+
+	b.mu.Lock()
+	models := b.models
+	b.mu.Unlock()
+	if models == nil {
+		models = b.SeedModels()
+	}
+	if models == nil {
+		return nil, errLazyStopped
+	}
+	return models, nil
+}
+
+// SeedModels returns the wrapped backend's seeded models, if it has any.
+func (b *LazyBackend) SeedModels() []types.Model {
+	if s, ok := b.Backend.(oairouter.ModelSeeder); ok {
+		return s.SeedModels()
+	}
+	return nil
+}
+
+// Tier returns the wrapped backend's tier, or 0.
+func (b *LazyBackend) Tier() int {
+	if t, ok := b.Backend.(oairouter.Tiered); ok {
+		return t.Tier()
+	}
+	return 0
+}
+
+// Tags returns the wrapped backend's tags, if it has any.
+func (b *LazyBackend) Tags() []string {
+	if t, ok := b.Backend.(oairouter.Tagged); ok {
+		return t.Tags()
+	}
+	return nil
+}
+
+// MaxConcurrency returns the wrapped backend's concurrency limit, or 0 for
+// none.
+func (b *LazyBackend) MaxConcurrency() int {
+	if l, ok := b.Backend.(oairouter.ConcurrencyLimiter); ok {
+		return l.MaxConcurrency()
+	}
+	return 0
+}
+
+// LastHealthError returns why the wrapped backend's last health check failed,
+// if it remembers.
+func (b *LazyBackend) LastHealthError() string {
+	if r, ok := b.Backend.(oairouter.HealthErrorReporter); ok {
+		return r.LastHealthError()
+	}
+	return ""
+}
+
+// LastModels returns the model list the wrapped backend last fetched, if it
+// remembers one.
+func (b *LazyBackend) LastModels() ([]types.Model, time.Time) {
+	if r, ok := b.Backend.(oairouter.ModelsReporter); ok {
+		return r.LastModels()
+	}
+	return nil, time.Time{}
+}
+
+// SetTLSConfig passes cfg to the wrapped backend, if it can be configured.
+func (b *LazyBackend) SetTLSConfig(cfg *tls.Config) {
+	if c, ok := b.Backend.(oairouter.TLSConfigurer); ok {
+		c.SetTLSConfig(cfg)
+	}
+}
+
+// ProbeCapabilities probes the wrapped backend while running. A stopped
+// backend isn't started to be probed.
+func (b *LazyBackend) ProbeCapabilities(ctx context.Context) error {
+	p, ok := b.Backend.(oairouter.CapabilityProber)
+	if !ok || !b.running.Load() {
+		return nil
+	}
+	return p.ProbeCapabilities(ctx)
+}
+
+// HealthCheck checks the wrapped backend while running; a stopped backend is
+// healthy.
+func (b *LazyBackend) HealthCheck(ctx context.Context) error {
+	if !b.running.Load() {
+		return nil
+	}
+	return b.Backend.HealthCheck(ctx)
+}
+
+// IsHealthy reports the wrapped backend's health while running; a stopped
+// backend is healthy.
+func (b *LazyBackend) IsHealthy() bool {
+	return !b.running.Load() || b.Backend.IsHealthy()
+}
+
+func (b *LazyBackend) ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.Backend.ChatCompletion(ctx, req)
+}
+
+func (b *LazyBackend) ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	events, err := b.Backend.ChatCompletionStream(ctx, req)
+	return b.track(ctx, events, err)
+}
+
+func (b *LazyBackend) Completion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.Backend.Completion(ctx, req)
+}
+
+func (b *LazyBackend) CompletionStream(ctx context.Context, req *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	events, err := b.Backend.CompletionStream(ctx, req)
+	return b.track(ctx, events, err)
+}
+
+func (b *LazyBackend) Embeddings(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.Backend.Embeddings(ctx, req)
+}
+
+func (b *LazyBackend) GenerateImages(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	g, ok := b.Backend.(oairouter.ImageGenerator)
+	if !ok {
+		return nil, fmt.Errorf("backend %s does not serve image generation", b.ID())
+	}
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return g.GenerateImages(ctx, req)
+}
+
+// track counts a stream as in flight until it ends, forwarding its events.
+func (b *LazyBackend) track(ctx context.Context, events <-chan oairouter.StreamEvent, err error) (<-chan oairouter.StreamEvent, error) {
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+	out := make(chan oairouter.StreamEvent)
+	go func() {
+		defer b.release()
+		defer close(out)
+		for event := range events {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				for range events {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// acquire counts a request as in flight, starting the server first if it is
+// stopped. The request is released again if the start fails.
+func (b *LazyBackend) acquire(ctx context.Context) error {
+	b.mu.Lock()
+	b.active++
+	if b.idle != nil {
+		b.idle.Stop()
+	}
+	b.mu.Unlock()
+
+	if err := b.ensureRunning(ctx); err != nil {
+		b.release()
+		return err
+	}
+	return nil
+}
+
+// release ends a request, scheduling the idle stop once none are in flight.
+func (b *LazyBackend) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active--
+	if b.active > 0 || b.stop == nil {
+		return
+	}
+	if b.idle == nil {
+		b.idle = time.AfterFunc(b.idleTimeout, b.stopIfIdle)
+	} else {
+		b.idle.Reset(b.idleTimeout)
+	}
+}
+
+// ensureRunning starts the server unless it is running. Concurrent callers
+// wait for the same start. The start isn't cancelled when ctx is, so a
+// client giving up doesn't fail the requests waiting with it.
+func (b *LazyBackend) ensureRunning(ctx context.Context) error {
+	if b.running.Load() {
+		return nil
+	}
+	select {
+	case b.lifecycle <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.lifecycle }()
+	if b.running.Load() {
+		return nil
+	}
+
+	startCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), b.startTimeout)
+	defer cancel()
+	if err := b.start(startCtx); err != nil {
+		return fmt.Errorf("failed to start backend %s: %w", b.ID(), err)
+	}
+	if err := b.waitHealthy(startCtx); err != nil {
+		return fmt.Errorf("backend %s didn't become healthy after starting: %w", b.ID(), err)
+	}
+	b.running.Store(true)
+	return nil
+}
+
+// waitHealthy polls the wrapped backend's health check until it passes.
+func (b *LazyBackend) waitHealthy(ctx context.Context) error {
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for {
+		err := b.Backend.HealthCheck(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopIfIdle stops the server if no request has arrived since the idle timer
+// was set. A request arriving during the stop waits for it, then starts the
+// server again.
+func (b *LazyBackend) stopIfIdle() {
+	b.lifecycle <- struct{}{}
+	defer func() { <-b.lifecycle }()
+
+	b.mu.Lock()
+	if b.active > 0 || !b.running.Load() {
+		b.mu.Unlock()
+		return
+	}
+	b.running.Store(false)
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.startTimeout)
+	defer cancel()
+	if err := b.stop(ctx); err != nil {
+		b.logger.Warn("failed to stop idle backend", "backend", b.ID(), "error", err)
+	}
+}
@@ -0,0 +1,269 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// onDemandServer is an OpenAI-compatible server that answers only while
+// up, as a container started on demand would.
+type onDemandServer struct {
+	up           atomic.Bool
+	starts       atomic.Int64
+	stops        atomic.Int64
+	chats        atomic.Int64
+	startLatency time.Duration // How long after start the server comes up
+	srv          *httptest.Server
+}
+
+func newOnDemandServer(t *testing.T) *onDemandServer {
+	s := &onDemandServer{}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.up.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"object":"list","data":[{"id":"llama-3","object":"model"}]}`))
+		case "/v1/chat/completions":
+			s.chats.Add(1)
+			w.Write([]byte(`{"id":"1","object":"chat.completion","model":"llama-3","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *onDemandServer) start(ctx context.Context) error {
+	s.starts.Add(1)
+	time.AfterFunc(s.startLatency, func() { s.up.Store(true) })
+	return nil
+}
+
+func (s *onDemandServer) stop(ctx context.Context) error {
+	s.stops.Add(1)
+	s.up.Store(false)
+	return nil
+}
+
+func newLazy(t *testing.T, s *onDemandServer, opts ...LazyBackendOption) *LazyBackend {
+	t.Helper()
+	g, err := NewGenericBackend("lazy", s.srv.URL, WithSeedModels("llama-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewLazyBackend(g, s.start, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.pollInterval = 5 * time.Millisecond
+	return b
+}
+
+var lazyChat = &types.ChatCompletionRequest{Model: "llama-3", Messages: []types.ChatMessage{{Role: "user", Content: "hi"}}}
+
+func TestLazyBackend_StartsOnFirstRequest(t *testing.T) {
+	s := newOnDemandServer(t)
+	s.startLatency = 20 * time.Millisecond
+	b := newLazy(t, s)
+
+	// Stopped, it is healthy and lists its seeded models without starting
+	models, err := b.Models(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "llama-3" {
+		t.Fatalf("Models() = %v, %v", models, err)
+	}
+	if err := b.HealthCheck(context.Background()); err != nil || !b.IsHealthy() {
+		t.Errorf("stopped backend unhealthy: %v", err)
+	}
+	if s.starts.Load() != 0 || b.Running() {
+		t.Fatal("backend started before a request")
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.ChatCompletion(context.Background(), lazyChat); err != nil {
+				t.Errorf("ChatCompletion: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.starts.Load(); n != 1 {
+		t.Errorf("started %d times, want 1", n)
+	}
+	if n := s.chats.Load(); n != 5 || !b.Running() {
+		t.Errorf("served %d chats, running = %v", n, b.Running())
+	}
+
+	// Later requests go straight through
+	b.ChatCompletion(context.Background(), lazyChat)
+	if n := s.starts.Load(); n != 1 {
+		t.Errorf("started %d times, want 1", n)
+	}
+}
+
+func TestLazyBackend_StopsWhenIdle(t *testing.T) {
+	s := newOnDemandServer(t)
+	b := newLazy(t, s, WithIdleStop(30*time.Millisecond, s.stop))
+
+	if _, err := b.ChatCompletion(context.Background(), lazyChat); err != nil {
+		t.Fatal(err)
+	}
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{Model: "llama-3", Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An open stream keeps it running
+	time.Sleep(60 * time.Millisecond)
+	if s.stops.Load() != 0 {
+		t.Fatal("stopped with a stream open")
+	}
+	for range events {
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for b.Running() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if b.Running() || s.stops.Load() != 1 {
+		t.Fatalf("running = %v, stops = %d after idling", b.Running(), s.stops.Load())
+	}
+
+	// The next request starts it again
+	if _, err := b.ChatCompletion(context.Background(), lazyChat); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.starts.Load(); n != 2 {
+		t.Errorf("started %d times, want 2", n)
+	}
+}
+
+func TestLazyBackend_StartFailure(t *testing.T) {
+	s := newOnDemandServer(t)
+	g, _ := NewGenericBackend("lazy", s.srv.URL)
+	b, _ := NewLazyBackend(g, func(ctx context.Context) error { return errors.New("no GPU free") })
+
+	_, err := b.ChatCompletion(context.Background(), lazyChat)
+	if err == nil || !strings.Contains(err.Error(), "no GPU free") || b.Running() {
+		t.Errorf("ChatCompletion error = %v, running = %v", err, b.Running())
+	}
+	if _, err := b.Models(context.Background()); err == nil {
+		t.Error("Models succeeded for a stopped backend with no known models")
+	}
+}
+
+func TestLazyBackend_StartTimeout(t *testing.T) {
+	s := newOnDemandServer(t)
+	s.startLatency = time.Hour
+	b := newLazy(t, s, WithStartTimeout(30*time.Millisecond))
+
+	if _, err := b.ChatCompletion(context.Background(), lazyChat); err == nil || b.Running() {
+		t.Errorf("ChatCompletion error = %v, running = %v", err, b.Running())
+	}
+}
+
+func TestNewLazyBackend_Invalid(t *testing.T) {
+	g, _ := NewGenericBackend("lazy", "http://localhost:8000")
+	start := func(ctx context.Context) error { return nil }
+	if _, err := NewLazyBackend(g, nil); err == nil {
+		t.Error("accepted a nil start function")
+	}
+	if _, err := NewLazyBackend(g, start, WithIdleStop(0, start)); err == nil {
+		t.Error("accepted a zero idle timeout")
+	}
+	if _, err := NewLazyBackend(g, start, WithStartTimeout(-time.Second)); err == nil {
+		t.Error("accepted a negative start timeout")
+	}
+}
+
+func TestLazyBackend_ForwardsOptionalInterfaces(t *testing.T) {
+	s := newOnDemandServer(t)
+	g, _ := NewGenericBackend("lazy", s.srv.URL, WithTier(2), WithTags("gpu"), WithMaxConcurrency(4))
+	b, _ := NewLazyBackend(g, s.start)
+
+	var backend oairouter.Backend = b
+	if tier := backend.(oairouter.Tiered).Tier(); tier != 2 {
+		t.Errorf("tier = %d, want 2", tier)
+	}
+	if tags := backend.(oairouter.Tagged).Tags(); len(tags) != 1 || tags[0] != "gpu" {
+		t.Errorf("tags = %v, want [gpu]", tags)
+	}
+	if n := backend.(oairouter.ConcurrencyLimiter).MaxConcurrency(); n != 4 {
+		t.Errorf("max concurrency = %d, want 4", n)
+	}
+	for name, ok := range map[string]bool{
+		"HealthErrorReporter": implements[oairouter.HealthErrorReporter](backend),
+		"ModelsReporter":      implements[oairouter.ModelsReporter](backend),
+		"TLSConfigurer":       implements[oairouter.TLSConfigurer](backend),
+		"CapabilityProber":    implements[oairouter.CapabilityProber](backend),
+		"ImageGenerator":      implements[oairouter.ImageGenerator](backend),
+		"ModelSeeder":         implements[oairouter.ModelSeeder](backend),
+	} {
+		if !ok {
+			t.Errorf("LazyBackend doesn't implement %s", name)
+		}
+	}
+
+	// A wrapped backend without the interfaces gets the router's defaults
+	bare, _ := NewLazyBackend(struct{ oairouter.Backend }{g}, s.start)
+	if bare.Tier() != 0 || bare.Tags() != nil || bare.MaxConcurrency() != 0 {
+		t.Errorf("bare tier, tags, limit = %d, %v, %d", bare.Tier(), bare.Tags(), bare.MaxConcurrency())
+	}
+	if _, err := bare.GenerateImages(context.Background(), &types.ImageGenerationRequest{}); err == nil {
+		t.Error("bare backend generated images")
+	}
+	if s.starts.Load() != 0 {
+		t.Error("forwarding started the server")
+	}
+}
+
+func implements[I any](b oairouter.Backend) bool {
+	_, ok := b.(I)
+	return ok
+}
+
+func TestLazyBackend_LogsFailedStop(t *testing.T) {
+	s := newOnDemandServer(t)
+	logs := make(logLines, 1)
+	b := newLazy(t, s,
+		WithIdleStop(10*time.Millisecond, func(ctx context.Context) error { return errors.New("container busy") }),
+		WithLazyLogger(slog.New(slog.NewTextHandler(logs, nil))),
+	)
+	if _, err := b.ChatCompletion(context.Background(), lazyChat); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case line := <-logs:
+		if !strings.Contains(line, "container busy") {
+			t.Errorf("logged %q, want the stop error", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failed stop wasn't logged")
+	}
+}
+
+// logLines receives each log record written to it.
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
@@ -0,0 +1,113 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// LMStudioBackend proxies requests to an LM Studio server. It behaves like a
+// GenericBackend but accepts the model list shapes LM Studio returns and
+// strips request fields LM Studio doesn't support.
+type LMStudioBackend struct {
+	*GenericBackend
+}
+
+// NewLMStudioBackend creates a backend for an LM Studio server.
+func NewLMStudioBackend(id string, baseURL string, opts ...GenericBackendOption) (*LMStudioBackend, error) {
+	g, err := NewGenericBackend(id, baseURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.backendType = oairouter.BackendLMStudio
+	g.decodeModels = decodeLMStudioModels
+	return &LMStudioBackend{GenericBackend: g}, nil
+}
+
+// lmStudioModel covers the fields LM Studio uses to describe a model across
+// its OpenAI-compatible and native endpoints.
+type lmStudioModel struct {
+	ID        string `json:"id"`
+	Model     string `json:"model"`
+	Object    string `json:"object"`
+	Created   int64  `json:"created"`
+	OwnedBy   string `json:"owned_by"`
+	Publisher string `json:"publisher"`
+}
+
+// decodeLMStudioModels parses a model list that is either the OpenAI shape
+// ({"data": [...]}), a {"models": [...]} object, or a bare array, and fills in
+// the fields OpenAI clients expect.
+func decodeLMStudioModels(body []byte) ([]types.Model, error) {
+	var raw []lmStudioModel
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var wrapped struct {
+			Data   []lmStudioModel `json:"data"`
+			Models []lmStudioModel `json:"models"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, err
+		}
+		raw = wrapped.Data
+		if raw == nil {
+			raw = wrapped.Models
+		}
+	}
+
+	models := make([]types.Model, 0, len(raw))
+	for _, m := range raw {
+		id := m.ID
+		if id == "" {
+			id = m.Model
+		}
+		if id == "" {
+			return nil, fmt.Errorf("model entry without an id")
+		}
+
+		owner := m.OwnedBy
+		if owner == "" {
+			owner = m.Publisher
+		}
+		if owner == "" {
+			owner = "lmstudio"
+		}
+
+		models = append(models, types.Model{ID: id, Object: "model", Created: m.Created, OwnedBy: owner})
+	}
+	return models, nil
+}
+
+func (b *LMStudioBackend) ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	return b.GenericBackend.ChatCompletion(ctx, lmStudioChatRequest(req))
+}
+
+func (b *LMStudioBackend) ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	return b.GenericBackend.ChatCompletionStream(ctx, lmStudioChatRequest(req))
+}
+
+func (b *LMStudioBackend) Completion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	return b.GenericBackend.Completion(ctx, lmStudioCompletionRequest(req))
+}
+
+func (b *LMStudioBackend) CompletionStream(ctx context.Context, req *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	return b.GenericBackend.CompletionStream(ctx, lmStudioCompletionRequest(req))
+}
+
+// lmStudioChatRequest returns a copy of req without fields LM Studio rejects.
+func lmStudioChatRequest(req *types.ChatCompletionRequest) *types.ChatCompletionRequest {
+	stripped := *req
+	stripped.LogitBias = nil
+	return &stripped
+}
+
+// lmStudioCompletionRequest returns a copy of req without fields LM Studio rejects.
+func lmStudioCompletionRequest(req *types.CompletionRequest) *types.CompletionRequest {
+	stripped := *req
+	stripped.LogitBias = nil
+	stripped.BestOf = nil
+	stripped.Echo = false
+	return &stripped
+}
@@ -0,0 +1,95 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestLMStudioModels_NormalizesShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"openai shape", `{"object":"list","data":[{"id":"qwen2.5-7b","object":"model","owned_by":"organization_owner"}]}`},
+		{"models object", `{"models":[{"id":"qwen2.5-7b","publisher":"organization_owner"}]}`},
+		{"bare array", `[{"model":"qwen2.5-7b","owned_by":"organization_owner"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			b, err := NewLMStudioBackend("lms", srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			models, err := b.Models(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := types.Model{ID: "qwen2.5-7b", Object: "model", OwnedBy: "organization_owner"}
+			if len(models) != 1 || models[0].ID != want.ID || models[0].Object != want.Object || models[0].OwnedBy != want.OwnedBy {
+				t.Errorf("models = %+v, want [%+v]", models, want)
+			}
+			if err := b.HealthCheck(context.Background()); err != nil || !b.IsHealthy() {
+				t.Errorf("HealthCheck() = %v, healthy = %v", err, b.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestLMStudioChatCompletion_StripsUnsupportedFields(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"x","object":"chat.completion","choices":[]}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewLMStudioBackend("lms", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &types.ChatCompletionRequest{Model: "qwen2.5-7b", LogitBias: map[string]int{"50256": -100}}
+	if _, err := b.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["logit_bias"]; ok {
+		t.Error("logit_bias was forwarded to LM Studio")
+	}
+	if got["model"] != "qwen2.5-7b" {
+		t.Errorf("model = %v, want qwen2.5-7b", got["model"])
+	}
+	if req.LogitBias == nil {
+		t.Error("caller's request was modified")
+	}
+}
+
+func TestNewBackend_SelectsImplementation(t *testing.T) {
+	b, err := NewBackend("lms", oairouter.BackendLMStudio, "http://localhost:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*LMStudioBackend); !ok || b.Type() != oairouter.BackendLMStudio {
+		t.Errorf("lmstudio: got %T of type %s", b, b.Type())
+	}
+
+	b, err = NewBackend("vllm", oairouter.BackendVLLM, "http://localhost:8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*GenericBackend); !ok || b.Type() != oairouter.BackendVLLM {
+		t.Errorf("vllm: got %T of type %s", b, b.Type())
+	}
+}
@@ -0,0 +1,511 @@
+package backends
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// OllamaBackend talks to Ollama's native /api endpoints, for servers that
+// predate its OpenAI-compatible /v1 routes. Requests and responses are
+// translated to and from the OpenAI format, and streamed NDJSON is converted
+// to SSE chunks.
+type OllamaBackend struct {
+	*GenericBackend
+}
+
+// NewOllamaBackend creates a backend for an Ollama server's native API.
+func NewOllamaBackend(id string, baseURL string, opts ...GenericBackendOption) (*OllamaBackend, error) {
+	g, err := NewGenericBackend(id, baseURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.backendType = oairouter.BackendOllama
+	g.modelsPath = "/api/tags"
+	g.decodeModels = decodeOllamaTags
+	// The native chat API streams a single choice, so n > 1 can't be
+	// streamed, and takes the token limit from max_tokens
+	g.protocolCaps = map[oairouter.Capability]bool{
+		oairouter.CapabilityStreamingN:          false,
+		oairouter.CapabilityMaxCompletionTokens: false,
+	}
+	return &OllamaBackend{GenericBackend: g}, nil
+}
+
+// ProbeCapabilities is a no-op: capability probes use the OpenAI routes,
+// which native-only servers don't have.
+func (b *OllamaBackend) ProbeCapabilities(ctx context.Context) error {
+	return nil
+}
+
+// ollamaTag is a model entry in an /api/tags response.
+type ollamaTag struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// decodeOllamaTags converts an /api/tags response to OpenAI models.
+func decodeOllamaTags(body []byte) ([]types.Model, error) {
+	var tags struct {
+		Models []ollamaTag `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, err
+	}
+
+	models := make([]types.Model, 0, len(tags.Models))
+	for _, t := range tags.Models {
+		id := t.Name
+		if id == "" {
+			id = t.Model
+		}
+		models = append(models, types.Model{ID: id, Object: "model", Created: t.ModifiedAt.Unix(), OwnedBy: "ollama"})
+	}
+	return models, nil
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   any             `json:"format,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+	Tools    []types.Tool    `json:"tools,omitempty"`
+}
+
+type ollamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// ollamaResponse covers /api/chat and /api/generate responses and stream lines.
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`  // chat
+	Response        string        `json:"response"` // generate
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (r *ollamaResponse) usage() *types.Usage {
+	return &types.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (r *ollamaResponse) finishReason() string {
+	switch {
+	case len(r.Message.ToolCalls) > 0:
+		return "tool_calls"
+	case r.DoneReason == "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
+
+// ollamaOptions maps OpenAI sampling parameters to Ollama model options.
+func ollamaOptions(temperature, topP *float64, maxTokens *int, stop []string, seed *int, presence, frequency *float64) map[string]any {
+	opts := make(map[string]any)
+	if temperature != nil {
+		opts["temperature"] = *temperature
+	}
+	if topP != nil {
+		opts["top_p"] = *topP
+	}
+	if maxTokens != nil {
+		opts["num_predict"] = *maxTokens
+	}
+	if len(stop) > 0 {
+		opts["stop"] = stop
+	}
+	if seed != nil {
+		opts["seed"] = *seed
+	}
+	if presence != nil {
+		opts["presence_penalty"] = *presence
+	}
+	if frequency != nil {
+		opts["frequency_penalty"] = *frequency
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
+
+// toOllamaChatRequest translates an OpenAI chat request to /api/chat.
+func toOllamaChatRequest(req *types.ChatCompletionRequest, stream bool) (*ollamaChatRequest, error) {
+	out := &ollamaChatRequest{
+		Model:   req.Model,
+		Stream:  stream,
+		Options: ollamaOptions(req.Temperature, req.TopP, req.MaxTokens, req.Stop, req.Seed, req.PresencePenalty, req.FrequencyPenalty),
+		Tools:   req.Tools,
+	}
+
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case "json_object":
+			out.Format = "json"
+		case "json_schema":
+			if schema, ok := rf.JSONSchema.(map[string]any); ok && schema["schema"] != nil {
+				out.Format = schema["schema"]
+			} else {
+				out.Format = "json"
+			}
+		}
+	}
+
+	for _, m := range req.Messages {
+		msg := ollamaMessage{Role: m.Role}
+		if err := setOllamaContent(&msg, m.Content); err != nil {
+			return nil, err
+		}
+		for _, tc := range m.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage("{}")
+			if json.Valid([]byte(tc.Function.Arguments)) {
+				call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+	return out, nil
+}
+
+// setOllamaContent flattens OpenAI message content into Ollama's text and
+// base64 image fields. Only data: image URLs can be forwarded.
+func setOllamaContent(msg *ollamaMessage, content any) error {
+	parts, err := contentParts(content)
+	if err != nil {
+		return err
+	}
+
+	var text []string
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			text = append(text, p.Text)
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			_, data, ok := strings.Cut(p.ImageURL.URL, ";base64,")
+			if !ok || !strings.HasPrefix(p.ImageURL.URL, "data:") {
+				return errors.New("ollama native API only accepts base64 data: image URLs")
+			}
+			msg.Images = append(msg.Images, data)
+		}
+	}
+	msg.Content = strings.Join(text, "\n")
+	return nil
+}
+
+// toOpenAIToolCalls converts Ollama tool calls, whose arguments are JSON
+// objects, to OpenAI tool calls with JSON string arguments.
+func toOpenAIToolCalls(calls []ollamaToolCall) []types.ToolCall {
+	var out []types.ToolCall
+	for i, c := range calls {
+		out = append(out, types.ToolCall{
+			ID:   fmt.Sprintf("call_%d", i),
+			Type: "function",
+			Function: types.ToolCallFunction{
+				Name:      c.Function.Name,
+				Arguments: string(c.Function.Arguments),
+			},
+		})
+	}
+	return out
+}
+
+// toOllamaPrompt accepts a single string prompt, the only form /api/generate supports.
+func toOllamaPrompt(prompt any) (string, error) {
+	p, err := types.NormalizePrompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	if p.Kind != types.PromptText || len(p.Text) != 1 {
+		return "", errors.New("ollama native API only accepts a single string prompt")
+	}
+	return p.Text[0], nil
+}
+
+// newCompletionID returns a random ID with the given prefix.
+func newCompletionID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+func (b *OllamaBackend) ChatCompletion(ctx context.Context, chatReq *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	native, err := toOllamaChatRequest(chatReq, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ollamaResponse
+	if err := b.postJSON(ctx, "/api/chat", "chat completion", native, &resp); err != nil {
+		return nil, err
+	}
+
+	return &types.ChatCompletionResponse{
+		ID:      newCompletionID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []types.Choice{{
+			Index: 0,
+			Message: types.ChatMessage{
+				Role:      "assistant",
+				Content:   resp.Message.Content,
+				ToolCalls: toOpenAIToolCalls(resp.Message.ToolCalls),
+			},
+			FinishReason: resp.finishReason(),
+		}},
+		Usage: resp.usage(),
+	}, nil
+}
+
+func (b *OllamaBackend) ChatCompletionStream(ctx context.Context, chatReq *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	native, err := toOllamaChatRequest(chatReq, true)
+	if err != nil {
+		return nil, err
+	}
+
+	id := newCompletionID("chatcmpl-")
+	includeUsage := chatReq.StreamOptions != nil && chatReq.StreamOptions.IncludeUsage
+	sentRole := false
+
+	return b.streamNDJSON(ctx, "/api/chat", native, func(line *ollamaResponse) []any {
+		chunk := func(delta types.ChatDelta, finish *string) types.ChatCompletionChunk {
+			return types.ChatCompletionChunk{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: line.CreatedAt.Unix(),
+				Model:   line.Model,
+				Choices: []types.ChunkChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+			}
+		}
+
+		var chunks []any
+		delta := types.ChatDelta{Content: line.Message.Content, ToolCalls: toOpenAIToolCalls(line.Message.ToolCalls)}
+		if !sentRole {
+			delta.Role = "assistant"
+			sentRole = true
+		}
+		if delta.Role != "" || delta.Content != "" || len(delta.ToolCalls) > 0 {
+			chunks = append(chunks, chunk(delta, nil))
+		}
+
+		if line.Done {
+			finish := line.finishReason()
+			chunks = append(chunks, chunk(types.ChatDelta{}, &finish))
+			if includeUsage {
+				final := chunk(types.ChatDelta{}, nil)
+				final.Choices = []types.ChunkChoice{}
+				final.Usage = line.usage()
+				chunks = append(chunks, final)
+			}
+		}
+		return chunks
+	})
+}
+
+func (b *OllamaBackend) Completion(ctx context.Context, compReq *types.CompletionRequest) (*types.CompletionResponse, error) {
+	native, err := toOllamaGenerateRequest(compReq, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ollamaResponse
+	if err := b.postJSON(ctx, "/api/generate", "completion", native, &resp); err != nil {
+		return nil, err
+	}
+
+	return &types.CompletionResponse{
+		ID:      newCompletionID("cmpl-"),
+		Object:  "text_completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []types.CompletionChoice{{Text: resp.Response, Index: 0, FinishReason: resp.finishReason()}},
+		Usage:   resp.usage(),
+	}, nil
+}
+
+func (b *OllamaBackend) CompletionStream(ctx context.Context, compReq *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	native, err := toOllamaGenerateRequest(compReq, true)
+	if err != nil {
+		return nil, err
+	}
+
+	id := newCompletionID("cmpl-")
+	return b.streamNDJSON(ctx, "/api/generate", native, func(line *ollamaResponse) []any {
+		var finish *string
+		if line.Done {
+			reason := line.finishReason()
+			finish = &reason
+		}
+		if line.Response == "" && finish == nil {
+			return nil
+		}
+		return []any{types.CompletionChunk{
+			ID:      id,
+			Object:  "text_completion",
+			Created: line.CreatedAt.Unix(),
+			Model:   line.Model,
+			Choices: []types.CompletionChunkChoice{{Text: line.Response, Index: 0, FinishReason: finish}},
+		}}
+	})
+}
+
+func toOllamaGenerateRequest(req *types.CompletionRequest, stream bool) (*ollamaGenerateRequest, error) {
+	prompt, err := toOllamaPrompt(req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaGenerateRequest{
+		Model:   req.Model,
+		Prompt:  prompt,
+		Stream:  stream,
+		Options: ollamaOptions(req.Temperature, req.TopP, req.MaxTokens, req.Stop, req.Seed, req.PresencePenalty, req.FrequencyPenalty),
+	}, nil
+}
+
+// Embeddings uses /api/embeddings, which older servers support, sending one
+// request per input. That endpoint doesn't report token usage.
+func (b *OllamaBackend) Embeddings(ctx context.Context, embReq *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	var inputs []string
+	switch in := embReq.Input.(type) {
+	case string:
+		inputs = []string{in}
+	case []string:
+		inputs = in
+	case []any:
+		for _, v := range in {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("ollama native API only accepts string embedding inputs")
+			}
+			inputs = append(inputs, s)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported embeddings input type %T", embReq.Input)
+	}
+
+	resp := &types.EmbeddingsResponse{Object: "list", Model: embReq.Model, Usage: &types.Usage{}}
+	for i, input := range inputs {
+		var native struct {
+			Embedding []float64 `json:"embedding"`
+		}
+		body := map[string]string{"model": embReq.Model, "prompt": input}
+		if err := b.postJSON(ctx, "/api/embeddings", "embeddings", body, &native); err != nil {
+			return nil, err
+		}
+		resp.Data = append(resp.Data, types.EmbeddingData{Object: "embedding", Embedding: native.Embedding, Index: i})
+	}
+	return resp, nil
+}
+
+// streamNDJSON posts a streaming request and converts each NDJSON line to
+// zero or more OpenAI chunks with convert, ending with [DONE].
+func (b *OllamaBackend) streamNDJSON(ctx context.Context, path string, body any, convert func(*ollamaResponse) []any) (<-chan oairouter.StreamEvent, error) {
+	resp, err := b.post(ctx, path, "stream request", body)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan oairouter.StreamEvent, 100)
+
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		send := func(event oairouter.StreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var native ollamaResponse
+				if err := json.Unmarshal(line, &native); err != nil {
+					send(oairouter.StreamEvent{Err: fmt.Errorf("invalid stream line: %w", err), Done: true})
+					return
+				}
+				if native.Error != "" {
+					send(oairouter.StreamEvent{Err: errors.New(native.Error), Done: true})
+					return
+				}
+
+				for _, chunk := range convert(&native) {
+					data, err := json.Marshal(chunk)
+					if err != nil {
+						send(oairouter.StreamEvent{Err: err, Done: true})
+						return
+					}
+					if !send(oairouter.StreamEvent{Data: string(data)}) {
+						return
+					}
+				}
+
+				if native.Done {
+					send(oairouter.StreamEvent{Data: "[DONE]", Done: true})
+					return
+				}
+			}
+
+			if err != nil {
+				if err == io.EOF {
+					send(oairouter.StreamEvent{Done: true})
+				} else {
+					send(oairouter.StreamEvent{Err: err, Done: true})
+				}
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
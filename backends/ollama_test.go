@@ -0,0 +1,194 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// ollamaServer fakes Ollama's native API and records the last chat request.
+func ollamaServer(t *testing.T, lastChat *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama3:latest","model":"llama3:latest","modified_at":"2024-05-01T12:00:00Z"}]}`))
+		case "/api/chat":
+			var req map[string]any
+			json.NewDecoder(r.Body).Decode(&req)
+			if lastChat != nil {
+				*lastChat = req
+			}
+			if req["stream"] == true {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.Write([]byte(`{"model":"llama3:latest","created_at":"2024-05-01T12:00:00Z","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3:latest","created_at":"2024-05-01T12:00:00Z","message":{"role":"assistant","content":"lo"},"done":false}
+{"model":"llama3:latest","created_at":"2024-05-01T12:00:00Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":2}
+`))
+				return
+			}
+			w.Write([]byte(`{"model":"llama3:latest","created_at":"2024-05-01T12:00:00Z","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":5}`))
+		case "/api/generate":
+			w.Write([]byte(`{"model":"llama3:latest","created_at":"2024-05-01T12:00:00Z","response":"4","done":true,"done_reason":"length","prompt_eval_count":3,"eval_count":1}`))
+		case "/api/embeddings":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			w.Write([]byte(`{"embedding":[` + map[string]string{"a": "1,0", "b": "0,1"}[req["prompt"]] + `]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOllamaBackend_Models(t *testing.T) {
+	b, _ := NewOllamaBackend("ollama-old", ollamaServer(t, nil).URL)
+
+	models, err := b.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "llama3:latest" || models[0].OwnedBy != "ollama" || models[0].Created != 1714564800 {
+		t.Errorf("models = %+v", models)
+	}
+	if err := b.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() = %v", err)
+	}
+	if b.Type() != oairouter.BackendOllama {
+		t.Errorf("Type() = %s, want ollama", b.Type())
+	}
+	if b.Supports(oairouter.CapabilityStreamingN) || !b.Supports(oairouter.CapabilityStreaming) {
+		t.Error("expected streaming without n > 1")
+	}
+	if caps := b.Capabilities(); caps.SupportsStreamingN || !caps.SupportsStreaming {
+		t.Errorf("Capabilities() = %+v, expected streaming without n > 1", caps)
+	}
+}
+
+func TestOllamaBackend_ChatCompletion(t *testing.T) {
+	var sent map[string]any
+	b, _ := NewOllamaBackend("ollama-old", ollamaServer(t, &sent).URL)
+
+	var content any
+	json.Unmarshal([]byte(`[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]`), &content)
+	temp, maxTokens := 0.2, 64
+	resp, err := b.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+		Model:       "llama3:latest",
+		Messages:    []types.ChatMessage{{Role: "user", Content: content}},
+		Temperature: &temp,
+		MaxTokens:   &maxTokens,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Request translation
+	msg := sent["messages"].([]any)[0].(map[string]any)
+	if msg["content"] != "What is this?" || msg["images"].([]any)[0] != "iVBORw0KGgo=" {
+		t.Errorf("sent message = %v", msg)
+	}
+	opts := sent["options"].(map[string]any)
+	if opts["temperature"] != 0.2 || opts["num_predict"] != float64(64) {
+		t.Errorf("sent options = %v", opts)
+	}
+
+	// Response translation
+	if resp.Object != "chat.completion" || !strings.HasPrefix(resp.ID, "chatcmpl-") {
+		t.Errorf("response = %+v", resp)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v", choice)
+	}
+	if call := choice.Message.ToolCalls[0].Function; call.Name != "get_weather" || call.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 || resp.Usage.TotalTokens != 17 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestOllamaBackend_ChatCompletionStream(t *testing.T) {
+	b, _ := NewOllamaBackend("ollama-old", ollamaServer(t, nil).URL)
+
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:         "llama3:latest",
+		Messages:      []types.ChatMessage{{Role: "user", Content: "hi"}},
+		StreamOptions: &types.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	var finish string
+	var usage *types.Usage
+	var done bool
+	for ev := range events {
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if ev.Done {
+			done = ev.Data == "[DONE]"
+			continue
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", ev.Data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("chunk object = %q", chunk.Object)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			text.WriteString(c.Delta.Content)
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+	}
+
+	if text.String() != "Hello" || finish != "stop" || !done {
+		t.Errorf("text = %q, finish = %q, done = %v", text.String(), finish, done)
+	}
+	if usage == nil || usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v, want 9 total tokens", usage)
+	}
+}
+
+func TestOllamaBackend_Completion(t *testing.T) {
+	b, _ := NewOllamaBackend("ollama-old", ollamaServer(t, nil).URL)
+
+	resp, err := b.Completion(context.Background(), &types.CompletionRequest{Model: "llama3:latest", Prompt: "2+2="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Text != "4" || resp.Choices[0].FinishReason != "length" {
+		t.Errorf("choices = %+v", resp.Choices)
+	}
+
+	if _, err := b.Completion(context.Background(), &types.CompletionRequest{Model: "llama3:latest", Prompt: []any{"a", "b"}}); err == nil {
+		t.Error("expected error for a batch prompt")
+	}
+}
+
+func TestOllamaBackend_Embeddings(t *testing.T) {
+	b, _ := NewOllamaBackend("ollama-old", ollamaServer(t, nil).URL)
+
+	resp, err := b.Embeddings(context.Background(), &types.EmbeddingsRequest{Model: "llama3:latest", Input: []any{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].Embedding[0] != 1 || resp.Data[1].Index != 1 || resp.Data[1].Embedding[1] != 1 {
+		t.Errorf("data = %+v", resp.Data)
+	}
+}
@@ -0,0 +1,120 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/stevemurr/oairouter"
+)
+
+// capabilityProbes are the chat request fields that exercise each probed
+// capability. A probe passes if the backend accepts the request.
+var capabilityProbes = []struct {
+	capability oairouter.Capability
+	fields     map[string]any
+}{
+	{oairouter.CapabilityStreaming, map[string]any{"stream": true}},
+	{oairouter.CapabilityTools, map[string]any{"tools": []any{map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name":       "probe",
+			"parameters": map[string]any{"type": "object", "properties": map[string]any{}},
+		},
+	}}}},
+	{oairouter.CapabilityLogprobs, map[string]any{"logprobs": true, "top_logprobs": 1}},
+}
+
+// ProbeCapabilities implements oairouter.CapabilityProber. When enabled with
+// WithCapabilityProbe, it sends a one-token chat request per capability using
+// the backend's first model. A 4xx response marks the capability unsupported;
+// transport errors and 5xx responses abort the probe without caching anything,
+// so it runs again on the next registration.
+func (b *GenericBackend) ProbeCapabilities(ctx context.Context) error {
+	if !b.probe || !b.Supports(oairouter.CapabilityChat) {
+		return nil
+	}
+
+	b.mu.RLock()
+	done := b.probed != nil
+	b.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	models, err := b.Models(ctx)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("capability probe: backend has no models")
+	}
+
+	probed := make(map[oairouter.Capability]bool, len(capabilityProbes))
+	for _, p := range capabilityProbes {
+		req := map[string]any{
+			"model":      models[0].ID,
+			"messages":   []any{map[string]any{"role": "user", "content": "hi"}},
+			"max_tokens": 1,
+		}
+		for k, v := range p.fields {
+			req[k] = v
+		}
+
+		supported, err := b.probeChat(ctx, req)
+		if err != nil {
+			return fmt.Errorf("capability probe %s: %w", p.capability, err)
+		}
+		probed[p.capability] = supported
+	}
+
+	b.mu.Lock()
+	b.probed = probed
+	b.mu.Unlock()
+	return nil
+}
+
+// probeChat sends a probe request and reports whether the backend accepted it.
+func (b *GenericBackend) probeChat(ctx context.Context, probe map[string]any) (bool, error) {
+	if b.healthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.healthCheckTimeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(probe)
+	if err != nil {
+		return false, err
+	}
+
+	u := b.baseURL.JoinPath(b.apiPath("/chat/completions"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// A server that ignores "stream" answers with plain JSON
+		if probe["stream"] == true {
+			return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"), nil
+		}
+		return true, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
@@ -0,0 +1,113 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+)
+
+// probeServer serves one model, rejects chat requests with tools, and
+// streams when asked. chatStatus overrides the status of every chat request.
+func probeServer(t *testing.T, chatCalls *atomic.Int64, chatStatus int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			w.Write([]byte(`{"object":"list","data":[{"id":"probe-model","object":"model"}]}`))
+			return
+		}
+
+		chatCalls.Add(1)
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["model"] != "probe-model" {
+			t.Errorf("probe used model %v, want probe-model", req["model"])
+		}
+
+		switch {
+		case chatStatus != 0:
+			w.WriteHeader(chatStatus)
+		case req["tools"] != nil:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"tools are not supported","type":"invalid_request_error"}}`))
+		case req["stream"] == true:
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {}\n\ndata: [DONE]\n\n"))
+		default:
+			w.Write([]byte(`{"id":"x","object":"chat.completion","choices":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	var calls atomic.Int64
+	srv := probeServer(t, &calls, 0)
+
+	b, _ := NewGenericBackend("probed", srv.URL, WithCapabilityProbe(true))
+	if err := b.ProbeCapabilities(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Supports(oairouter.CapabilityTools) {
+		t.Error("tools capability set for a backend that rejects tools")
+	}
+	for _, c := range []oairouter.Capability{oairouter.CapabilityStreaming, oairouter.CapabilityLogprobs, oairouter.CapabilityChat} {
+		if !b.Supports(c) {
+			t.Errorf("%s capability not set", c)
+		}
+	}
+
+	// Results are cached
+	before := calls.Load()
+	if err := b.ProbeCapabilities(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != before {
+		t.Error("second probe sent requests despite cached results")
+	}
+}
+
+func TestProbeCapabilities_ServerErrorNotCached(t *testing.T) {
+	var calls atomic.Int64
+	srv := probeServer(t, &calls, http.StatusServiceUnavailable)
+
+	b, _ := NewGenericBackend("probed", srv.URL, WithCapabilityProbe(true))
+	if err := b.ProbeCapabilities(context.Background()); err == nil {
+		t.Fatal("expected error when the backend returns 503")
+	}
+	if !b.Supports(oairouter.CapabilityTools) {
+		t.Error("failed probe changed capabilities")
+	}
+}
+
+func TestProbeCapabilities_Disabled(t *testing.T) {
+	var calls atomic.Int64
+	srv := probeServer(t, &calls, 0)
+
+	b, _ := NewGenericBackend("unprobed", srv.URL)
+	if err := b.ProbeCapabilities(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 0 || !b.Supports(oairouter.CapabilityTools) {
+		t.Error("probe ran without WithCapabilityProbe")
+	}
+}
+
+func TestProbeCapabilities_OnRegister(t *testing.T) {
+	var calls atomic.Int64
+	srv := probeServer(t, &calls, 0)
+
+	b, _ := NewGenericBackend("probed", srv.URL, WithCapabilityProbe(true))
+	if err := oairouter.NewBackendRegistry().Register(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Supports(oairouter.CapabilityTools) {
+		t.Error("registration did not probe capabilities")
+	}
+}
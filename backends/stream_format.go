@@ -0,0 +1,72 @@
+package backends
+
+import (
+	"mime"
+	"strings"
+)
+
+// StreamFormat is how a backend frames the events of a streamed response.
+type StreamFormat string
+
+const (
+	// StreamFormatAuto picks the format from the response's Content-Type.
+	// When that doesn't say, lines with an SSE data: field and bare JSON
+	// lines are both accepted.
+	StreamFormatAuto StreamFormat = ""
+
+	// StreamFormatSSE reads the data: fields of server-sent events.
+	StreamFormatSSE StreamFormat = "sse"
+
+	// StreamFormatNDJSON reads each non-empty line as one JSON event, as
+	// some llama.cpp builds stream.
+	StreamFormatNDJSON StreamFormat = "ndjson"
+)
+
+// WithStreamFormat sets how streamed responses are parsed instead of
+// detecting it from each response. Events are sent to clients as SSE
+// either way.
+func WithStreamFormat(f StreamFormat) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.streamFormat = f
+	}
+}
+
+// responseStreamFormat resolves the auto format from a response's
+// Content-Type, leaving it auto if the type is missing or unrecognized.
+func responseStreamFormat(f StreamFormat, contentType string) StreamFormat {
+	if f != StreamFormatAuto {
+		return f
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/event-stream":
+		return StreamFormatSSE
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/jsonlines":
+		return StreamFormatNDJSON
+	}
+	return StreamFormatAuto
+}
+
+// streamLineData returns the event data carried by one line of a stream in
+// format f, or false if the line carries none, e.g. an SSE comment or event
+// name.
+func streamLineData(line string, f StreamFormat) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false
+	}
+	if f != StreamFormatNDJSON {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			return strings.TrimSpace(data), true
+		}
+	}
+	switch f {
+	case StreamFormatNDJSON:
+		return line, true
+	case StreamFormatAuto:
+		if line[0] == '{' || line[0] == '[' {
+			return line, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package backends
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// streamServer serves body as a streamed chat response with contentType.
+func streamServer(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// collectStream returns the data of each event in a chat stream.
+func collectStream(t *testing.T, b *GenericBackend) []string {
+	t.Helper()
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []string
+	for event := range events {
+		if event.Err != nil {
+			t.Fatal(event.Err)
+		}
+		if event.Data != "" {
+			data = append(data, event.Data)
+		}
+	}
+	return data
+}
+
+const (
+	sseStream = ": keep-alive\n\nevent: chunk\ndata: {\"id\":\"1\"}\n\ndata:{\"id\":\"2\"}\n\ndata: [DONE]\n\n"
+
+	// The last line has no trailing newline
+	ndjsonStream = "{\"id\":\"1\"}\n\n{\"id\":\"2\"}"
+)
+
+func TestStreamFormat(t *testing.T) {
+	want := []string{`{"id":"1"}`, `{"id":"2"}`}
+	tests := []struct {
+		name        string
+		format      StreamFormat
+		contentType string
+		body        string
+		want        []string
+	}{
+		{"sse detected", StreamFormatAuto, "text/event-stream", sseStream, append(want, "[DONE]")},
+		{"ndjson detected", StreamFormatAuto, "application/x-ndjson", ndjsonStream, want},
+		{"ndjson unlabeled", StreamFormatAuto, "application/json", ndjsonStream, want},
+		{"sse unlabeled", StreamFormatAuto, "", sseStream, append(want, "[DONE]")},
+		{"ndjson configured", StreamFormatNDJSON, "text/event-stream", ndjsonStream, want},
+		{"sse configured", StreamFormatSSE, "application/x-ndjson", ndjsonStream, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := NewGenericBackend("llamacpp", streamServer(t, tt.contentType, tt.body).URL, WithStreamFormat(tt.format))
+			if got := collectStream(t, b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamLineData(t *testing.T) {
+	tests := []struct {
+		line   string
+		format StreamFormat
+		want   string
+		ok     bool
+	}{
+		{"data: {}\n", StreamFormatSSE, "{}", true},
+		{"event: message\n", StreamFormatSSE, "", false},
+		{"{}\n", StreamFormatSSE, "", false},
+		{"data: {}\n", StreamFormatNDJSON, "data: {}", true},
+		{"  {\"a\":1}  \n", StreamFormatNDJSON, `{"a":1}`, true},
+		{"\n", StreamFormatNDJSON, "", false},
+		{": comment\n", StreamFormatAuto, "", false},
+		{"[DONE]\n", StreamFormatAuto, "[DONE]", true},
+	}
+	for _, tt := range tests {
+		got, ok := streamLineData(tt.line, tt.format)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("streamLineData(%q, %q) = %q, %v, want %q, %v", tt.line, tt.format, got, ok, tt.want, tt.ok)
+		}
+	}
+}
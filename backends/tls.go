@@ -0,0 +1,44 @@
+package backends
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// WithTLSConfig sets the TLS configuration used to reach an HTTPS backend,
+// e.g. to trust an internal CA. It applies to every request the backend
+// makes, streaming included. With WithHTTPClient, the client's transport is
+// copied rather than modified; clients whose transport isn't an
+// *http.Transport are used as is.
+func WithTLSConfig(cfg *tls.Config) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.tlsConfig = cfg
+	}
+}
+
+// SetTLSConfig replaces the backend's TLS configuration, as WithTLSConfig
+// does. It implements oairouter.TLSConfigurer and must be called before the
+// backend serves requests.
+func (b *GenericBackend) SetTLSConfig(cfg *tls.Config) {
+	b.tlsConfig = cfg
+	b.httpClient = clientWithTLS(b.httpClient, cfg)
+}
+
+// clientWithTLS returns a copy of c whose transport uses cfg. c is returned
+// unchanged if its transport can't be configured.
+func clientWithTLS(c *http.Client, cfg *tls.Config) *http.Client {
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return c
+	}
+	t = t.Clone()
+	t.TLSClientConfig = cfg.Clone()
+
+	clone := *c
+	clone.Transport = t
+	return &clone
+}
@@ -0,0 +1,90 @@
+package backends
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// tlsServer serves a model list and a short chat stream over HTTPS with a
+// self-signed certificate, and returns a TLS config that trusts it.
+func tlsServer(t *testing.T) (*httptest.Server, *tls.Config) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			io.WriteString(w, `{"object":"list","data":[{"id":"test-model","object":"model"}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, &tls.Config{RootCAs: pool}
+}
+
+func streamChat(b *GenericBackend) error {
+	events, err := b.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{Model: "test-model", Stream: true})
+	if err != nil {
+		return err
+	}
+	for event := range events {
+		if event.Err != nil {
+			return event.Err
+		}
+	}
+	return nil
+}
+
+func TestWithTLSConfig_TrustsCustomCA(t *testing.T) {
+	srv, cfg := tlsServer(t)
+
+	untrusted, _ := NewGenericBackend("b", srv.URL)
+	if _, err := untrusted.Models(context.Background()); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected a certificate error without the CA, got %v", err)
+	}
+
+	b, err := NewGenericBackend("b", srv.URL, WithTLSConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Models(context.Background()); err != nil {
+		t.Errorf("Models: %v", err)
+	}
+	if err := streamChat(b); err != nil {
+		t.Errorf("stream: %v", err)
+	}
+}
+
+func TestWithTLSConfig_CopiesCustomClient(t *testing.T) {
+	srv, cfg := tlsServer(t)
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+
+	b, _ := NewGenericBackend("b", srv.URL, WithHTTPClient(client), WithTLSConfig(cfg))
+	if _, err := b.Models(context.Background()); err != nil {
+		t.Errorf("Models: %v", err)
+	}
+	if client.Transport != transport || (transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil) {
+		t.Error("the supplied client was modified")
+	}
+}
+
+func TestSetTLSConfig_SkipVerify(t *testing.T) {
+	srv, _ := tlsServer(t)
+
+	b, _ := NewGenericBackend("b", srv.URL)
+	b.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	if err := streamChat(b); err != nil {
+		t.Errorf("stream: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package backends
+
+import (
+	"net/http"
+	"time"
+)
+
+// TransportConfig tunes the connection pool of a backend's default HTTP
+// transport. Go's default transport keeps only 2 idle connections per host,
+// so concurrent streams to one backend keep opening new connections.
+type TransportConfig struct {
+	MaxIdleConns        int           // Idle connections kept across all hosts; 0 means no limit
+	MaxIdleConnsPerHost int           // Idle connections kept per host
+	MaxConnsPerHost     int           // Total connections per host; 0 means no limit
+	IdleConnTimeout     time.Duration // How long an idle connection is kept
+	ForceAttemptHTTP2   bool          // Negotiate HTTP/2 with TLS backends
+}
+
+// DefaultTransportConfig is used for backends created without
+// WithHTTPClient or WithTransportConfig.
+var DefaultTransportConfig = TransportConfig{
+	MaxIdleConns:        256,
+	MaxIdleConnsPerHost: 64,
+	IdleConnTimeout:     90 * time.Second,
+	ForceAttemptHTTP2:   true,
+}
+
+// NewTransport returns a copy of http.DefaultTransport, keeping its proxy,
+// dial and TLS settings, with the pool tuned by cfg.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = cfg.MaxIdleConns
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	t.ForceAttemptHTTP2 = cfg.ForceAttemptHTTP2
+	return t
+}
+
+// WithTransportConfig tunes the connection pool of the backend's default
+// HTTP client. It has no effect when WithHTTPClient supplies the client.
+func WithTransportConfig(cfg TransportConfig) GenericBackendOption {
+	return func(b *GenericBackend) {
+		b.transport = cfg
+	}
+}
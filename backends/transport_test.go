@@ -0,0 +1,123 @@
+package backends
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer serves a short SSE stream and counts the connections
+// clients open to it.
+func countingServer(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := range 3 {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%d\"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	tb.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+// streamConcurrently makes n concurrent requests with client, reading each
+// response to the end so its connection can be reused.
+func streamConcurrently(tb testing.TB, client *http.Client, url string, n int) {
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				tb.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewGenericBackend_DefaultTransportReusesConnections(t *testing.T) {
+	srv, conns := countingServer(t)
+	b, _ := NewGenericBackend("pooled", srv.URL)
+
+	const concurrency = 16
+	for range 3 {
+		streamConcurrently(t, b.httpClient, srv.URL, concurrency)
+	}
+	if n := conns.Load(); n > concurrency {
+		t.Errorf("opened %d connections over 3 rounds of %d concurrent streams, want at most %d", n, concurrency, concurrency)
+	}
+}
+
+func TestNewGenericBackend_TransportOptions(t *testing.T) {
+	b, _ := NewGenericBackend("tuned", "http://localhost:8000", WithTransportConfig(TransportConfig{
+		MaxIdleConnsPerHost: 8,
+		MaxConnsPerHost:     32,
+		IdleConnTimeout:     time.Minute,
+	}))
+	tr := b.httpClient.Transport.(*http.Transport)
+	if tr.MaxIdleConnsPerHost != 8 || tr.MaxConnsPerHost != 32 || tr.IdleConnTimeout != time.Minute || tr.ForceAttemptHTTP2 {
+		t.Errorf("transport = idle/host %d, conns/host %d, idle timeout %v, http2 %v",
+			tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, tr.IdleConnTimeout, tr.ForceAttemptHTTP2)
+	}
+	if tr.Proxy == nil {
+		t.Error("expected the default transport's proxy settings to be kept")
+	}
+
+	client := &http.Client{}
+	custom, _ := NewGenericBackend("custom", "http://localhost:8000", WithHTTPClient(client), WithTransportConfig(DefaultTransportConfig))
+	if custom.httpClient != client || client.Transport != nil {
+		t.Error("WithHTTPClient's client should be used unmodified")
+	}
+}
+
+// BenchmarkConcurrentStreams compares connection setup under concurrent
+// streaming between Go's default pool (2 idle connections per host) and the
+// backend default. conns/op is the number of new TCP connections per round
+// of 32 concurrent streams.
+func BenchmarkConcurrentStreams(b *testing.B) {
+	const concurrency = 32
+	for _, bc := range []struct {
+		name      string
+		transport func() *http.Transport
+	}{
+		{"GoDefault", func() *http.Transport { return http.DefaultTransport.(*http.Transport).Clone() }},
+		{"Tuned", func() *http.Transport { return NewTransport(DefaultTransportConfig) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			srv, conns := countingServer(b)
+			tr := bc.transport()
+			defer tr.CloseIdleConnections()
+			client := &http.Client{Transport: tr}
+
+			// Warm the pool so both cases start from a steady state
+			streamConcurrently(b, client, srv.URL, concurrency)
+			conns.Store(0)
+
+			b.ResetTimer()
+			for range b.N {
+				streamConcurrently(b, client, srv.URL, concurrency)
+			}
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
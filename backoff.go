@@ -0,0 +1,40 @@
+package oairouter
+
+import "time"
+
+// Backoff configures exponentially increasing retry delays.
+type Backoff struct {
+	Initial     time.Duration // Delay before the first retry; zero disables retries
+	Max         time.Duration // Upper bound on the delay between retries
+	MaxAttempts int           // Maximum number of retries; zero means unlimited
+}
+
+// DefaultModelRetryBackoff is used when retrying model fetches for newly
+// registered backends.
+var DefaultModelRetryBackoff = Backoff{
+	Initial:     time.Second,
+	Max:         30 * time.Second,
+	MaxAttempts: 10,
+}
+
+// DefaultHealthCheckBackoff spaces out health checks of unhealthy backends,
+// starting from the health check interval.
+var DefaultHealthCheckBackoff = Backoff{
+	Max: 5 * time.Minute,
+}
+
+// Delay returns the wait before the given retry attempt (starting at 0),
+// doubling each attempt up to Max.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if b.Max > 0 && d >= b.Max {
+			return b.Max
+		}
+	}
+	if b.Max > 0 && d > b.Max {
+		return b.Max
+	}
+	return d
+}
@@ -0,0 +1,432 @@
+package oairouter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// BatchStore holds the state of batch jobs. The router keeps a running
+// batch's live state itself and saves a snapshot after each request
+// finishes. Implementations must be safe for concurrent use.
+type BatchStore interface {
+	// Get returns the batch with id. The boolean is false if there is none.
+	Get(ctx context.Context, id string) (*types.Batch, bool, error)
+
+	// Put saves batch, replacing any earlier state with its ID.
+	Put(ctx context.Context, batch *types.Batch) error
+}
+
+// DefaultBatchRetention is how long a MemoryBatchStore from
+// NewMemoryBatchStore keeps finished batches.
+const DefaultBatchRetention = 24 * time.Hour
+
+// MemoryBatchStore is an in-process BatchStore. Finished batches are
+// evicted once they are older than the store's retention.
+type MemoryBatchStore struct {
+	mu        sync.RWMutex
+	batches   map[string]*types.Batch
+	retention time.Duration
+}
+
+// NewMemoryBatchStore creates an empty in-memory batch store that keeps
+// finished batches for DefaultBatchRetention.
+func NewMemoryBatchStore() *MemoryBatchStore {
+	return NewMemoryBatchStoreWithRetention(DefaultBatchRetention)
+}
+
+// NewMemoryBatchStoreWithRetention creates an empty in-memory batch store
+// that keeps finished batches for retention.
+func NewMemoryBatchStoreWithRetention(retention time.Duration) *MemoryBatchStore {
+	return &MemoryBatchStore{batches: make(map[string]*types.Batch), retention: retention}
+}
+
+// Get implements BatchStore.
+func (s *MemoryBatchStore) Get(ctx context.Context, id string) (*types.Batch, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	batch, ok := s.batches[id]
+	if !ok {
+		return nil, false, nil
+	}
+	return copyBatch(batch), true, nil
+}
+
+// Put implements BatchStore.
+func (s *MemoryBatchStore) Put(ctx context.Context, batch *types.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[batch.ID] = copyBatch(batch)
+	s.evict(time.Now().Add(-s.retention).Unix())
+	return nil
+}
+
+// evict removes batches that finished before cutoff, in Unix seconds.
+func (s *MemoryBatchStore) evict(cutoff int64) {
+	for id, batch := range s.batches {
+		if finished := max(batch.CompletedAt, batch.CancelledAt); finished > 0 && finished < cutoff {
+			delete(s.batches, id)
+		}
+	}
+}
+
+// copyBatch copies batch so later changes to either don't affect the other.
+// Results are never modified once added, so they are shared.
+func copyBatch(batch *types.Batch) *types.Batch {
+	c := *batch
+	c.Results = slices.Clone(batch.Results)
+	return &c
+}
+
+// batchEndpoints are the endpoints a batch can send its requests to.
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+// batchRunner runs batch jobs in the background, sending at most
+// concurrency of their requests to the router at once across all batches.
+type batchRunner struct {
+	store BatchStore
+	slots chan struct{}
+	wg    sync.WaitGroup // Running batches
+
+	mu      sync.Mutex
+	running map[string]*runningBatch
+	stopped bool // Set by Router.Stop; no batches are started
+}
+
+// runningBatch is the live state of a batch being run, guarded by
+// batchRunner.mu.
+type runningBatch struct {
+	batch  *types.Batch
+	cancel context.CancelFunc
+}
+
+func newBatchRunner(store BatchStore, concurrency int) *batchRunner {
+	return &batchRunner{
+		store:   store,
+		slots:   make(chan struct{}, concurrency),
+		running: make(map[string]*runningBatch),
+	}
+}
+
+// acquire waits for a free request slot. It reports false, without taking
+// a slot, if ctx is done first.
+func (b *batchRunner) acquire(ctx context.Context) bool {
+	select {
+	case b.slots <- struct{}{}:
+		if ctx.Err() != nil {
+			b.release()
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (b *batchRunner) release() {
+	<-b.slots
+}
+
+// start runs run in the background unless the runner is stopped, in which
+// case it reports false.
+func (b *batchRunner) start(run *runningBatch, serve func()) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return false
+	}
+	b.running[run.batch.ID] = run
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		serve()
+	}()
+	return true
+}
+
+// stop cancels the running batches and stops new ones from starting. The
+// cancelled batches are done once wg is.
+func (b *batchRunner) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for _, run := range b.running {
+		run.cancel()
+	}
+}
+
+// resume lets batches start again after stop.
+func (b *batchRunner) resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = false
+}
+
+// update applies change to a running batch and saves the result.
+func (b *batchRunner) update(r *Router, run *runningBatch, change func(*types.Batch)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change(run.batch)
+	// Saved under the lock so snapshots reach the store in order
+	if err := b.store.Put(context.Background(), run.batch); err != nil {
+		r.logger.Warn("failed to save batch", "batch", run.batch.ID, "error", err)
+	}
+}
+
+// newBatchID returns a random ID with prefix, in the style of OpenAI's.
+func newBatchID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// handleCreateBatch handles POST /v1/batches. It validates the batch, saves
+// it, and starts running its requests before responding.
+func (r *Router) handleCreateBatch(w http.ResponseWriter, req *http.Request) {
+	var batchReq types.BatchRequest
+	if err := json.NewDecoder(req.Body).Decode(&batchReq); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if apiErr := validateBatch(&batchReq); apiErr != nil {
+		types.WriteError(w, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	now := time.Now().Unix()
+	batch := &types.Batch{
+		ID:               newBatchID("batch_"),
+		Object:           "batch",
+		Endpoint:         batchReq.Endpoint,
+		CompletionWindow: batchReq.CompletionWindow,
+		Status:           types.BatchStatusInProgress,
+		CreatedAt:        now,
+		InProgressAt:     now,
+		RequestCounts:    types.BatchRequestCounts{Total: len(batchReq.Requests)},
+		Metadata:         batchReq.Metadata,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningBatch{batch: copyBatch(batch), cancel: cancel}
+	header := r.batchHeader(req)
+	if err := r.batches.store.Put(req.Context(), batch); err != nil {
+		cancel()
+		types.WriteError(w, http.StatusInternalServerError, types.ServerError("failed to save batch: "+err.Error()))
+		return
+	}
+	if !r.batches.start(run, func() { r.runBatch(ctx, run, batchReq.Requests, header) }) {
+		cancel()
+		batch.Status, batch.CancelledAt = types.BatchStatusCancelled, time.Now().Unix()
+		if err := r.batches.store.Put(req.Context(), batch); err != nil {
+			r.logger.Warn("failed to save batch", "batch", batch.ID, "error", err)
+		}
+		types.WriteError(w, http.StatusServiceUnavailable, types.ServerError("router is shutting down"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// batchHeader returns the headers of req that its batch's requests are sent
+// with: its credentials and forwarded headers.
+func (r *Router) batchHeader(req *http.Request) http.Header {
+	h := make(http.Header)
+	for _, name := range append([]string{"Authorization"}, r.forwardedHeaders...) {
+		if values := req.Header.Values(name); len(values) > 0 {
+			h[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return h
+}
+
+// validateBatch checks a batch request, filling in each request's default
+// method and URL.
+func validateBatch(batchReq *types.BatchRequest) *types.APIError {
+	if batchReq.InputFileID != "" {
+		return types.InvalidParamError("input files are not supported; list the batch's requests in requests", "input_file_id")
+	}
+	if !batchEndpoints[batchReq.Endpoint] {
+		return types.InvalidParamError("endpoint must be /v1/chat/completions, /v1/completions, or /v1/embeddings", "endpoint")
+	}
+	if len(batchReq.Requests) == 0 {
+		return types.InvalidParamError("requests must not be empty", "requests")
+	}
+
+	seen := make(map[string]bool, len(batchReq.Requests))
+	for i := range batchReq.Requests {
+		item := &batchReq.Requests[i]
+		if item.CustomID == "" || seen[item.CustomID] {
+			return types.InvalidParamError("each request needs a unique custom_id", "requests")
+		}
+		seen[item.CustomID] = true
+		if item.Method == "" {
+			item.Method = http.MethodPost
+		}
+		if item.URL == "" {
+			item.URL = batchReq.Endpoint
+		}
+		if item.Method != http.MethodPost || item.URL != batchReq.Endpoint {
+			return types.InvalidParamError("request "+item.CustomID+" must be a POST to the batch's endpoint", "requests")
+		}
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.Unmarshal(item.Body, &body); err != nil {
+			return types.InvalidParamError("request "+item.CustomID+" has an invalid body: "+err.Error(), "requests")
+		}
+		if body.Stream {
+			return types.InvalidParamError("request "+item.CustomID+" can't be streamed in a batch", "requests")
+		}
+	}
+	return nil
+}
+
+// runBatch serves a batch's requests, recording each result as it finishes,
+// until all are done or the batch is cancelled. Requests are sent with
+// header, from batchHeader.
+func (r *Router) runBatch(ctx context.Context, run *runningBatch, items []types.BatchRequestItem, header http.Header) {
+	var wg sync.WaitGroup
+	for _, item := range items {
+		if !r.batches.acquire(ctx) {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.batches.release()
+			result := r.serveBatchItem(ctx, item, header)
+			r.batches.update(r, run, func(batch *types.Batch) {
+				batch.Results = append(batch.Results, result)
+				if result.Error == nil && result.Response.StatusCode == http.StatusOK {
+					batch.RequestCounts.Completed++
+				} else {
+					batch.RequestCounts.Failed++
+				}
+			})
+		}()
+	}
+	wg.Wait()
+
+	r.batches.update(r, run, func(batch *types.Batch) {
+		if ctx.Err() != nil {
+			batch.Status = types.BatchStatusCancelled
+			batch.CancelledAt = time.Now().Unix()
+		} else {
+			batch.Status = types.BatchStatusCompleted
+			batch.CompletedAt = time.Now().Unix()
+		}
+	})
+	run.cancel()
+	r.batches.mu.Lock()
+	delete(r.batches.running, run.batch.ID)
+	r.batches.mu.Unlock()
+}
+
+// serveBatchItem sends one batch request through the router's handlers, so
+// it is routed like any other request.
+func (r *Router) serveBatchItem(ctx context.Context, item types.BatchRequestItem, header http.Header) types.BatchResult {
+	result := types.BatchResult{ID: newBatchID("batch_req_"), CustomID: item.CustomID}
+
+	req, err := http.NewRequestWithContext(ctx, item.Method, item.URL, bytes.NewReader(item.Body))
+	if err != nil {
+		result.Error = &types.BatchError{Code: "invalid_request", Message: err.Error()}
+		return result
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	reqID := requestID(req)
+	req.Header.Set(RequestIDHeader, reqID)
+
+	w := &batchResponseWriter{header: make(http.Header)}
+	r.mux.ServeHTTP(w, req)
+	if ctx.Err() != nil {
+		result.Error = &types.BatchError{Code: "batch_cancelled", Message: "the batch was cancelled"}
+		return result
+	}
+
+	body := w.body.Bytes()
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	result.Response = &types.BatchResponse{StatusCode: w.status, RequestID: reqID, Body: body}
+	return result
+}
+
+// batchResponseWriter collects the response to a batch request.
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// handleGetBatch handles GET /v1/batches/{id}.
+func (r *Router) handleGetBatch(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	batch, ok, err := r.batches.store.Get(req.Context(), id)
+	if err != nil {
+		types.WriteError(w, http.StatusInternalServerError, types.ServerError("failed to load batch: "+err.Error()))
+		return
+	}
+	if !ok {
+		types.WriteError(w, http.StatusNotFound, types.NewAPIError("batch not found: "+id, types.ErrorTypeNotFound, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// handleCancelBatch handles POST /v1/batches/{id}/cancel. Requests already
+// sent to a backend are cancelled too; the batch is marked cancelled once
+// they return.
+func (r *Router) handleCancelBatch(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	r.batches.mu.Lock()
+	run, ok := r.batches.running[id]
+	r.batches.mu.Unlock()
+	if !ok {
+		// Finished batches are returned as they are
+		r.handleGetBatch(w, req)
+		return
+	}
+
+	var batch *types.Batch
+	r.batches.update(r, run, func(b *types.Batch) {
+		if b.Status == types.BatchStatusInProgress {
+			b.Status = types.BatchStatusCancelling
+			b.CancellingAt = time.Now().Unix()
+		}
+		batch = copyBatch(b)
+	})
+	run.cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
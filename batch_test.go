@@ -0,0 +1,211 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// createBatch submits a batch and returns its initial state.
+func createBatch(t *testing.T, r *Router, body string) types.Batch {
+	t.Helper()
+	w := adminDo(r, http.MethodPost, "/v1/batches", body, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create status = %d, body = %s", w.Code, w.Body)
+	}
+	var batch types.Batch
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+// waitBatch polls a batch until its status is one of want.
+func waitBatch(t *testing.T, r *Router, id string, want ...string) types.Batch {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := adminDo(r, http.MethodGet, "/v1/batches/"+id, "", "")
+		var batch types.Batch
+		json.Unmarshal(w.Body.Bytes(), &batch)
+		for _, status := range want {
+			if batch.Status == status {
+				return batch
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch status = %q, want one of %q", batch.Status, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatches_RunsRequests(t *testing.T) {
+	r, err := NewRouter(WithBatches(nil, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inFlight, maxInFlight atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return &types.ChatCompletionResponse{ID: "chat", Model: req.Model}, nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	created := createBatch(t, r, `{"endpoint":"/v1/chat/completions","completion_window":"24h","requests":[
+		{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"b","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"c","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"missing","body":{"model":"missing-model","messages":[{"role":"user","content":"hi"}]}}
+	]}`)
+	if created.Object != "batch" || created.Status != types.BatchStatusInProgress || created.RequestCounts.Total != 4 {
+		t.Errorf("created batch = %+v", created)
+	}
+
+	batch := waitBatch(t, r, created.ID, types.BatchStatusCompleted)
+	if batch.RequestCounts != (types.BatchRequestCounts{Total: 4, Completed: 3, Failed: 1}) {
+		t.Errorf("request counts = %+v", batch.RequestCounts)
+	}
+	var ids []string
+	for _, result := range batch.Results {
+		ids = append(ids, result.CustomID)
+		if result.Response == nil || result.Response.RequestID == "" {
+			t.Fatalf("result %s has no response: %+v", result.CustomID, result)
+		}
+		want := http.StatusOK
+		if result.CustomID == "missing" {
+			want = http.StatusNotFound
+		}
+		if result.Response.StatusCode != want {
+			t.Errorf("result %s status = %d, want %d", result.CustomID, result.Response.StatusCode, want)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) != 4 || ids[0] != "a" || ids[3] != "missing" {
+		t.Errorf("results for %q", ids)
+	}
+	if n := maxInFlight.Load(); n > 2 {
+		t.Errorf("%d requests in flight at once, want at most 2", n)
+	}
+}
+
+func TestBatches_Cancel(t *testing.T) {
+	r, _ := NewRouter(WithBatches(nil, 1))
+	started := make(chan struct{}, 1)
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r.AddBackend(context.Background(), b)
+
+	created := createBatch(t, r, `{"endpoint":"/v1/chat/completions","requests":[
+		{"custom_id":"a","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"b","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}}
+	]}`)
+	<-started
+
+	w := adminDo(r, http.MethodPost, "/v1/batches/"+created.ID+"/cancel", "", "")
+	var cancelling types.Batch
+	json.Unmarshal(w.Body.Bytes(), &cancelling)
+	if w.Code != http.StatusOK || cancelling.Status != types.BatchStatusCancelling {
+		t.Fatalf("cancel status = %d, batch status = %q", w.Code, cancelling.Status)
+	}
+
+	batch := waitBatch(t, r, created.ID, types.BatchStatusCancelled)
+	if len(batch.Results) != 1 || batch.Results[0].Error == nil || batch.RequestCounts.Failed != 1 {
+		t.Errorf("cancelled batch = %+v", batch)
+	}
+}
+
+func TestBatches_StopCancels(t *testing.T) {
+	r, _ := NewRouter(WithBatches(nil, 1))
+	started := make(chan struct{}, 1)
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r.AddBackend(context.Background(), b)
+
+	created := createBatch(t, r, `{"endpoint":"/v1/chat/completions","requests":[
+		{"custom_id":"a","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}}
+	]}`)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	batch, _, _ := r.batches.store.Get(context.Background(), created.ID)
+	if batch.Status != types.BatchStatusCancelled {
+		t.Errorf("status after Stop = %q, want cancelled", batch.Status)
+	}
+
+	w := adminDo(r, http.MethodPost, "/v1/batches", `{"endpoint":"/v1/chat/completions","requests":[
+		{"custom_id":"a","body":{"model":"test-model","messages":[{"role":"user","content":"hi"}]}}
+	]}`, "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("create after Stop: status = %d, want 503", w.Code)
+	}
+}
+
+func TestMemoryBatchStore_EvictsFinished(t *testing.T) {
+	s := NewMemoryBatchStoreWithRetention(time.Hour)
+	ctx := context.Background()
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	s.Put(ctx, &types.Batch{ID: "done", Status: types.BatchStatusCompleted, CreatedAt: old, CompletedAt: old})
+	s.Put(ctx, &types.Batch{ID: "running", Status: types.BatchStatusInProgress, CreatedAt: old})
+	s.Put(ctx, &types.Batch{ID: "recent", Status: types.BatchStatusCompleted, CompletedAt: time.Now().Unix()})
+
+	for id, want := range map[string]bool{"done": false, "running": true, "recent": true} {
+		if _, ok, _ := s.Get(ctx, id); ok != want {
+			t.Errorf("batch %s kept = %v, want %v", id, ok, want)
+		}
+	}
+}
+
+func TestBatches_Invalid(t *testing.T) {
+	r, _ := NewRouter(WithBatches(nil, 1))
+	for _, body := range []string{
+		`{"endpoint":"/v1/chat/completions","input_file_id":"file-1"}`,
+		`{"endpoint":"/v1/images/generations","requests":[{"custom_id":"a","body":{}}]}`,
+		`{"endpoint":"/v1/chat/completions","requests":[]}`,
+		`{"endpoint":"/v1/chat/completions","requests":[{"custom_id":"a","body":{}},{"custom_id":"a","body":{}}]}`,
+		`{"endpoint":"/v1/chat/completions","requests":[{"custom_id":"a","url":"/v1/embeddings","body":{}}]}`,
+		`{"endpoint":"/v1/chat/completions","requests":[{"custom_id":"a","body":{"stream":true}}]}`,
+	} {
+		if w := adminDo(r, http.MethodPost, "/v1/batches", body, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+
+	if w := adminDo(r, http.MethodGet, "/v1/batches/batch_missing", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing batch status = %d, want 404", w.Code)
+	}
+}
+
+func TestBatches_DisabledByDefault(t *testing.T) {
+	r, _ := NewRouter()
+	if w := adminDo(r, http.MethodPost, "/v1/batches", `{}`, ""); w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want the endpoint to be absent", w.Code)
+	}
+}
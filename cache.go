@@ -0,0 +1,110 @@
+package oairouter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Cache stores serialized responses. Implementations must be safe for
+// concurrent use. A shared implementation (see the rediscache package) lets
+// several router replicas reuse each other's results.
+type Cache interface {
+	// Get returns the cached value for key. The boolean is false on a miss.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key. A ttl of zero means the entry does not expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// memoryCacheSweepInterval is how often a MemoryCache evicts expired entries
+// that were never read again.
+const memoryCacheSweepInterval = time.Minute
+
+// MemoryCache is an in-process Cache with per-entry expiry. Expired entries
+// are evicted when read, and the rest by a sweep that Set runs at most once
+// a minute.
+type MemoryCache struct {
+	mu            sync.Mutex
+	entries       map[string]memoryCacheEntry
+	sweepInterval time.Duration
+	lastSweep     time.Time
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewMemoryCache creates an empty in-memory cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries:       make(map[string]memoryCacheEntry),
+		sweepInterval: memoryCacheSweepInterval,
+		lastSweep:     time.Now(),
+	}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= c.sweepInterval {
+		c.sweep(now)
+	}
+
+	entry := memoryCacheEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	c.entries[key] = entry
+	return nil
+}
+
+// sweep evicts the entries expired by now (must hold mu).
+func (c *MemoryCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// requestCacheKey derives a stable cache key from an endpoint name and request body.
+func requestCacheKey(endpoint string, req any) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return endpoint + ":" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,158 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// fakeCache records calls so tests can assert on cache usage.
+type fakeCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+	gets    int
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (c *fakeCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	v, ok := c.entries[key]
+	return v, ok, nil
+}
+
+func (c *fakeCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = value
+	c.ttls[key] = ttl
+	return nil
+}
+
+func TestMemoryCache_Expiry(t *testing.T) {
+	c := NewMemoryCache()
+	ctx := context.Background()
+
+	c.Set(ctx, "forever", []byte("a"), 0)
+	c.Set(ctx, "short", []byte("b"), time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if v, ok, _ := c.Get(ctx, "forever"); !ok || string(v) != "a" {
+		t.Errorf("expected entry without ttl to persist, got %q %v", v, ok)
+	}
+	if _, ok, _ := c.Get(ctx, "short"); ok {
+		t.Error("expected expired entry to be a miss")
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected expired entry to be evicted, have %d entries", c.Len())
+	}
+}
+
+func TestMemoryCache_SweepsUnreadEntries(t *testing.T) {
+	c := NewMemoryCache()
+	c.sweepInterval = 10 * time.Millisecond
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(ctx, key, []byte(key), time.Millisecond)
+	}
+	c.Set(ctx, "forever", []byte("d"), 0)
+	time.Sleep(20 * time.Millisecond)
+
+	// Expired entries nobody reads again are evicted by the next Set
+	c.Set(ctx, "new", []byte("e"), time.Minute)
+	if n := c.Len(); n != 2 {
+		t.Errorf("have %d entries after the sweep, want 2", n)
+	}
+}
+
+func TestEmbeddingsCache_ServesRepeatedRequests(t *testing.T) {
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		calls.Add(1)
+		return &types.EmbeddingsResponse{
+			Object: "list",
+			Model:  req.Model,
+			Data:   []types.EmbeddingData{{Object: "embedding", Embedding: []float64{0.1, 0.2}}},
+		}, nil
+	}
+
+	cache := newFakeCache()
+	r, err := NewRouter(WithEmbeddingsCache(cache, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), b)
+
+	body := `{"model":"test-model","input":"hello"}`
+	var responses []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d: %s", i, rec.Code, rec.Body.String())
+		}
+		responses = append(responses, rec.Body.String())
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 backend call, got %d", calls.Load())
+	}
+	if responses[0] != responses[1] {
+		t.Errorf("cached response differs:\n%s\n%s", responses[0], responses[1])
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", len(cache.entries))
+	}
+	for _, ttl := range cache.ttls {
+		if ttl != time.Minute {
+			t.Errorf("expected ttl %v, got %v", time.Minute, ttl)
+		}
+	}
+
+	// A different input must miss the cache
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"test-model","input":"other"}`)))
+	if calls.Load() != 2 {
+		t.Errorf("expected a cache miss for different input, got %d backend calls", calls.Load())
+	}
+}
+
+func TestEmbeddingsCache_HitNeedsNoBackendSlot(t *testing.T) {
+	b := newTieredBackend("backend-a", 0, 1)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		return &types.EmbeddingsResponse{Object: "list", Model: req.Model}, nil
+	}
+	cache := newFakeCache()
+	r := newTestRouter(t, []Backend{b}, WithEmbeddingsCache(cache, time.Minute), WithRequestQueue(1, 50*time.Millisecond))
+
+	body := `{"model":"test-model","input":"hello"}`
+	if rec := postJSON(t, r, "/v1/embeddings", body); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// With the backend at its limit, a hit is still served without queuing
+	release := r.registry.Acquire("backend-a")
+	defer release()
+	start := time.Now()
+	if rec := postJSON(t, r, "/v1/embeddings", body); rec.Code != http.StatusOK {
+		t.Fatalf("cached request: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("cache hit waited %v for a backend slot", elapsed)
+	}
+}
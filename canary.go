@@ -0,0 +1,207 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// canaryRoutes splits each configured model's traffic between backends by
+// weight. Weights can be changed while the router serves requests.
+type canaryRoutes struct {
+	mu      sync.RWMutex
+	weights map[string]map[string]float64 // model -> target -> weight
+}
+
+// canaryTarget splits a canary weight's key into the backend ID and the model
+// requests drawn for it are sent as: "model@backend" names a model, and a
+// bare backend ID keeps the requested one.
+func canaryTarget(key, requested string) (backendID, model string) {
+	if i := strings.LastIndex(key, "@"); i >= 0 {
+		return key[i+1:], key[:i]
+	}
+	return key, requested
+}
+
+func newCanaryRoutes() *canaryRoutes {
+	return &canaryRoutes{weights: make(map[string]map[string]float64)}
+}
+
+// validateCanary checks a model's canary weights. Weights are relative, so
+// {"stable": 90, "canary": 10} and {"stable": 0.9, "canary": 0.1} are the
+// same split.
+func validateCanary(model string, weights map[string]float64) error {
+	if model == "" {
+		return fmt.Errorf("canary model must not be empty")
+	}
+	total := 0.0
+	for id, w := range weights {
+		if backendID, target := canaryTarget(id, model); backendID == "" || target == "" {
+			return fmt.Errorf("canary backend ID and model must not be empty")
+		}
+		if w < 0 {
+			return fmt.Errorf("canary weight for %s must not be negative", id)
+		}
+		total += w
+	}
+	if total <= 0 {
+		return fmt.Errorf("canary weights for %s must include a positive weight", model)
+	}
+	return nil
+}
+
+// SetCanary splits model's traffic between the backends in weights, replacing
+// any split already set for it. It takes effect for the next request.
+func (r *Router) SetCanary(model string, weights map[string]float64) error {
+	if err := validateCanary(model, weights); err != nil {
+		return err
+	}
+	r.canaries.mu.Lock()
+	defer r.canaries.mu.Unlock()
+	r.canaries.weights[model] = maps.Clone(weights)
+	return nil
+}
+
+// RemoveCanary returns model to normal routing. It reports whether a split
+// was set.
+func (r *Router) RemoveCanary(model string) bool {
+	r.canaries.mu.Lock()
+	defer r.canaries.mu.Unlock()
+	_, ok := r.canaries.weights[model]
+	delete(r.canaries.weights, model)
+	return ok
+}
+
+// Canaries returns a copy of the traffic splits, keyed by model.
+func (r *Router) Canaries() map[string]map[string]float64 {
+	r.canaries.mu.RLock()
+	defer r.canaries.mu.RUnlock()
+	canaries := make(map[string]map[string]float64, len(r.canaries.weights))
+	for model, weights := range r.canaries.weights {
+		canaries[model] = maps.Clone(weights)
+	}
+	return canaries
+}
+
+// lookupCanary picks a backend for model by its canary weights, and the
+// model to send it: model itself, or the one its weight's key names. Only
+// healthy backends serving that model for op are drawn from; it reports
+// false if the model has no split or none of its backends qualify, so the
+// request is routed normally.
+func (r *Router) lookupCanary(model string, op Operation) (Backend, string, bool) {
+	r.canaries.mu.RLock()
+	weights, ok := r.canaries.weights[model]
+	r.canaries.mu.RUnlock()
+	if !ok {
+		return nil, "", false
+	}
+
+	// Sorted so a draw maps to the same target for the same weights
+	keys := make([]string, 0, len(weights))
+	for key := range weights {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var candidates []Backend
+	var candidateModels []string
+	var candidateWeights []float64
+	total := 0.0
+	for _, key := range keys {
+		w := weights[key]
+		if w <= 0 {
+			continue
+		}
+		backendID, target := canaryTarget(key, model)
+		for _, b := range r.registry.HealthyBackendsForModelOp(target, op) {
+			if b.ID() == backendID {
+				candidates = append(candidates, b)
+				candidateModels = append(candidateModels, target)
+				candidateWeights = append(candidateWeights, w)
+				total += w
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+
+	pick := rand.Float64() * total
+	for i, w := range candidateWeights {
+		if pick < w {
+			return candidates[i], candidateModels[i], true
+		}
+		pick -= w
+	}
+	last := len(candidates) - 1
+	return candidates[last], candidateModels[last], true
+}
+
+// canaryRequest is the body of PUT /admin/canaries/{model}.
+type canaryRequest struct {
+	Weights map[string]float64 `json:"weights"`
+}
+
+// handleAdminListCanaries handles GET /admin/canaries.
+func (r *Router) handleAdminListCanaries(w http.ResponseWriter, req *http.Request) {
+	resp := struct {
+		Object string                        `json:"object"`
+		Data   map[string]map[string]float64 `json:"data"`
+	}{
+		Object: "canaries",
+		Data:   r.Canaries(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminSetCanary handles PUT /admin/canaries/{model}, e.g. to ramp a
+// canary's share of traffic.
+func (r *Router) handleAdminSetCanary(w http.ResponseWriter, req *http.Request) {
+	model := req.PathValue("model")
+
+	var body canaryRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := r.SetCanary(model, body.Weights); err != nil {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidParamError(err.Error(), "weights"))
+		return
+	}
+	r.logger.Info("canary updated", "model", model, "weights", body.Weights, "source", "admin")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleAdminDeleteCanary handles DELETE /admin/canaries/{model}.
+func (r *Router) handleAdminDeleteCanary(w http.ResponseWriter, req *http.Request) {
+	model := req.PathValue("model")
+	if !r.RemoveCanary(model) {
+		types.WriteError(w, http.StatusNotFound, types.NewAPIError("canary not found: "+model, types.ErrorTypeNotFound, nil))
+		return
+	}
+	r.logger.Info("canary removed", "model", model, "source", "admin")
+
+	resp := struct {
+		Model   string `json:"model"`
+		Object  string `json:"object"`
+		Deleted bool   `json:"deleted"`
+	}{
+		Model:   model,
+		Object:  "canary",
+		Deleted: true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,134 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// canaryRouter returns a router with stable and canary backends for
+// test-model, counting the requests each serves.
+func canaryRouter(t *testing.T, opts ...Option) (*Router, *atomic.Int64, *atomic.Int64) {
+	t.Helper()
+	var stable, canary atomic.Int64
+	r := newTestRouter(t, []Backend{idBackend("stable", &stable), idBackend("canary", &canary)}, opts...)
+	return r, &stable, &canary
+}
+
+func TestCanary_SplitsByWeight(t *testing.T) {
+	r, stable, canary := canaryRouter(t, WithCanary("test-model", map[string]float64{"stable": 90, "canary": 10}))
+
+	for range 2000 {
+		postChat(t, r, testChatBody)
+	}
+	// 10% of 2000 is 200; allow a wide margin to keep the test stable
+	if n := canary.Load(); n < 120 || n > 280 {
+		t.Errorf("canary served %d of 2000 requests, want about 200", n)
+	}
+	if stable.Load()+canary.Load() != 2000 {
+		t.Errorf("expected every request served, got %d", stable.Load()+canary.Load())
+	}
+}
+
+func TestCanary_SkipsUnhealthyBackends(t *testing.T) {
+	r, stable, canary := canaryRouter(t, WithCanary("test-model", map[string]float64{"stable": 1, "canary": 1}))
+	r.registry.backends["canary"].(*mockBackend).healthy.Store(false)
+
+	for range 20 {
+		postChat(t, r, testChatBody)
+	}
+	if canary.Load() != 0 || stable.Load() != 20 {
+		t.Errorf("expected only stable to serve, got stable=%d canary=%d", stable.Load(), canary.Load())
+	}
+}
+
+func TestCanary_AdminUpdatesWeights(t *testing.T) {
+	r, stable, canary := canaryRouter(t, WithAdmin(""), WithCanary("test-model", map[string]float64{"stable": 1}))
+
+	postChat(t, r, testChatBody)
+	if stable.Load() != 1 {
+		t.Fatalf("expected stable to serve before the ramp, got stable=%d canary=%d", stable.Load(), canary.Load())
+	}
+
+	w := adminDo(r, http.MethodPut, "/admin/canaries/test-model", `{"weights":{"stable":0,"canary":100}}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	postChat(t, r, testChatBody)
+	if canary.Load() != 1 {
+		t.Errorf("expected canary to serve after the ramp, got stable=%d canary=%d", stable.Load(), canary.Load())
+	}
+
+	want := map[string]map[string]float64{"test-model": {"stable": 0, "canary": 100}}
+	if got := r.Canaries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Canaries() = %v, want %v", got, want)
+	}
+
+	if w := adminDo(r, http.MethodDelete, "/admin/canaries/test-model", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE: status = %d", w.Code)
+	}
+	if w := adminDo(r, http.MethodDelete, "/admin/canaries/test-model", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCanary_TargetNamesAnotherModel(t *testing.T) {
+	r := newTestRouter(t, []Backend{
+		modelBackend("stable", "gpt-4", true),
+		modelBackend("next", "gpt-4-next", true),
+	}, WithCanary("gpt-4", map[string]float64{"gpt-4-next@next": 1}))
+
+	if id, model := servedBy(t, r, "gpt-4"); id != "next" || model != "gpt-4-next" {
+		t.Errorf("served by %s as %s, want next as gpt-4-next", id, model)
+	}
+
+	// A target whose backend doesn't serve the named model isn't drawn
+	r.SetCanary("gpt-4", map[string]float64{"gpt-4-next@stable": 1})
+	if id, model := servedBy(t, r, "gpt-4"); id != "stable" || model != "gpt-4" {
+		t.Errorf("served by %s as %s, want stable as gpt-4", id, model)
+	}
+}
+
+func TestCanary_InvalidWeights(t *testing.T) {
+	for _, weights := range []map[string]float64{
+		nil,
+		{"stable": 0},
+		{"stable": 1, "canary": -1},
+		{"": 1},
+		{"gpt-4-next@": 1},
+		{"@next": 1},
+	} {
+		if _, err := NewRouter(WithCanary("test-model", weights)); err == nil {
+			t.Errorf("WithCanary(%v): expected an error", weights)
+		}
+	}
+
+	r, _, _ := canaryRouter(t, WithAdmin(""))
+	if w := adminDo(r, http.MethodPut, "/admin/canaries/test-model", `{"weights":{"stable":-5}}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT: status = %d, want 400", w.Code)
+	}
+}
+
+func TestCanary_SkipsTargetsWithoutOperation(t *testing.T) {
+	chat := newMockBackend("canary", true)
+	chat.caps = []Capability{CapabilityChat}
+	embed := newMockBackend("stable", true)
+	embed.caps = []Capability{CapabilityEmbeddings}
+	embed.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		return &types.EmbeddingsResponse{Object: "list", Model: "stable"}, nil
+	}
+	r := newTestRouter(t, []Backend{chat, embed}, WithCanary("test-model", map[string]float64{"canary": 100}))
+
+	// The chat-only canary can't serve embeddings, so they're routed normally
+	for range 10 {
+		rec := postJSON(t, r, "/v1/embeddings", `{"model":"test-model","input":"hi"}`)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"model":"stable"`) {
+			t.Fatalf("expected the embeddings backend to serve, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package oairouter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// BackendCapabilities describes what a backend can serve. The router checks
+// each request against the selected backend's capabilities and rejects what
+// it can't serve with a 400 naming the unsupported feature, instead of
+// forwarding the request to fail upstream.
+type BackendCapabilities struct {
+	SupportsChat        bool
+	SupportsCompletions bool
+	SupportsEmbeddings  bool
+	SupportsStreaming   bool
+	SupportsStreamingN  bool // Streaming chat with n > 1, choices interleaved by index
+	SupportsTools       bool
+	SupportsLogprobs    bool
+
+	// SupportsMaxCompletionTokens is accepting max_completion_tokens; without
+	// it the limit is sent as max_tokens
+	SupportsMaxCompletionTokens bool
+
+	// SupportsFunctions is accepting the deprecated functions and
+	// function_call, for backends that predate tools
+	SupportsFunctions bool
+
+	// MaxContextTokens is the largest prompt the backend accepts, in tokens,
+	// or 0 if unknown. Prompts are measured with the tokenizer package's
+	// estimate.
+	MaxContextTokens int
+
+	// MaxTokensCap is the largest chat or completion token limit the
+	// backend is sent, or 0 for no cap. Larger limits are lowered to it.
+	MaxTokensCap int
+
+	// DefaultMaxTokens is the token limit sent for chat and completion
+	// requests that set none, or 0 to leave it unset. It is lowered to
+	// MaxTokensCap too.
+	DefaultMaxTokens int
+}
+
+// CapabilitiesFrom returns the capabilities for which supports reports true,
+// with no context limit.
+func CapabilitiesFrom(supports func(Capability) bool) BackendCapabilities {
+	return BackendCapabilities{
+		SupportsChat:        supports(CapabilityChat),
+		SupportsCompletions: supports(CapabilityCompletions),
+		SupportsEmbeddings:  supports(CapabilityEmbeddings),
+		SupportsStreaming:   supports(CapabilityStreaming),
+		SupportsStreamingN:  supports(CapabilityStreamingN),
+		SupportsTools:       supports(CapabilityTools),
+		SupportsLogprobs:    supports(CapabilityLogprobs),
+
+		SupportsMaxCompletionTokens: supports(CapabilityMaxCompletionTokens),
+		SupportsFunctions:           supports(CapabilityFunctions),
+	}
+}
+
+// FullCapabilities returns capabilities with every feature supported and no
+// context limit, for backends that serve the whole API.
+func FullCapabilities() BackendCapabilities {
+	return CapabilitiesFrom(func(Capability) bool { return true })
+}
+
+// Supports reports whether c is among the capabilities. Unknown capabilities
+// are not supported.
+func (caps BackendCapabilities) Supports(c Capability) bool {
+	switch c {
+	case CapabilityChat:
+		return caps.SupportsChat
+	case CapabilityCompletions:
+		return caps.SupportsCompletions
+	case CapabilityEmbeddings:
+		return caps.SupportsEmbeddings
+	case CapabilityStreaming:
+		return caps.SupportsStreaming
+	case CapabilityStreamingN:
+		return caps.SupportsStreamingN
+	case CapabilityTools:
+		return caps.SupportsTools
+	case CapabilityLogprobs:
+		return caps.SupportsLogprobs
+	case CapabilityMaxCompletionTokens:
+		return caps.SupportsMaxCompletionTokens
+	case CapabilityFunctions:
+		return caps.SupportsFunctions
+	}
+	return false
+}
+
+// capabilityDescriptions name each capability in errors, with the request
+// field that needs it.
+var capabilityDescriptions = map[Capability]struct{ desc, param string }{
+	CapabilityChat:        {"chat completions", "model"},
+	CapabilityCompletions: {"completions", "model"},
+	CapabilityEmbeddings:  {"embeddings", "model"},
+	CapabilityStreaming:   {"streaming", "stream"},
+	CapabilityStreamingN:  {"streaming with n > 1; set n to 1 or disable streaming", "n"},
+	CapabilityTools:       {"tool calls", "tools"},
+	CapabilityLogprobs:    {"logprobs", "logprobs"},
+}
+
+// checkCapabilities rejects a request that b can't serve: one that needs a
+// capability in required that b lacks, or whose prompt of promptTokens
+// exceeds b's context. promptTokens is ignored if negative.
+func checkCapabilities(b Backend, model string, required []Capability, promptTokens int) *types.RouterError {
+	caps := b.Capabilities()
+	for _, c := range required {
+		if caps.Supports(c) {
+			continue
+		}
+		d := capabilityDescriptions[c]
+		return types.NewRouterError(http.StatusBadRequest,
+			types.InvalidParamError(fmt.Sprintf("model %s does not support %s", model, d.desc), d.param), nil)
+	}
+
+	if caps.MaxContextTokens > 0 && promptTokens > caps.MaxContextTokens {
+		code := "context_length_exceeded"
+		return types.NewRouterError(http.StatusBadRequest, types.NewAPIError(
+			fmt.Sprintf("model %s accepts at most %d context tokens, but the prompt is about %d tokens", model, caps.MaxContextTokens, promptTokens),
+			types.ErrorTypeInvalidRequest, &code), nil)
+	}
+	return nil
+}
+
+// Operation is the kind of request a backend is looked up for. Lookups for an
+// operation skip backends that can't perform it, so one model name can be
+// served by, say, a chat backend and a separate embeddings backend.
+type Operation string
+
+const (
+	OperationAny             Operation = "" // Any backend serving the model
+	OperationChat            Operation = "chat"
+	OperationCompletions     Operation = "completions"
+	OperationEmbeddings      Operation = "embeddings"
+	OperationImageGeneration Operation = "image_generation"
+)
+
+// servedBy reports whether b can perform op.
+func (op Operation) servedBy(b Backend) bool {
+	switch op {
+	case OperationChat:
+		return b.Capabilities().SupportsChat
+	case OperationCompletions:
+		return b.Capabilities().SupportsCompletions
+	case OperationEmbeddings:
+		return b.Capabilities().SupportsEmbeddings
+	case OperationImageGeneration:
+		_, ok := b.(ImageGenerator)
+		return ok
+	}
+	return true
+}
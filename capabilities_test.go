@@ -0,0 +1,125 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestCapabilitiesFrom(t *testing.T) {
+	b := newMockBackend("a", true)
+	b.caps = []Capability{CapabilityChat, CapabilityTools}
+	caps := b.Capabilities()
+	if !caps.SupportsChat || !caps.SupportsTools || caps.SupportsStreaming || caps.SupportsEmbeddings {
+		t.Errorf("unexpected capabilities %+v", caps)
+	}
+	for _, c := range allCapabilities {
+		if !FullCapabilities().Supports(c) {
+			t.Errorf("FullCapabilities() doesn't support %s", c)
+		}
+	}
+	if FullCapabilities().Supports("unknown") {
+		t.Error("expected an unknown capability to be unsupported")
+	}
+}
+
+func TestCapabilities_RejectsUnsupportedRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		caps  []Capability
+		path  string
+		body  string
+		param string
+	}{
+		{"streaming", []Capability{CapabilityChat}, "/v1/chat/completions",
+			`{"model":"test-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`, "stream"},
+		{"tools", []Capability{CapabilityChat, CapabilityStreaming}, "/v1/chat/completions",
+			`{"model":"test-model","tools":[{"type":"function","function":{"name":"f"}}],"messages":[{"role":"user","content":"hi"}]}`, "tools"},
+		{"functions", []Capability{CapabilityChat}, "/v1/chat/completions",
+			`{"model":"test-model","functions":[{"name":"f"}],"messages":[{"role":"user","content":"hi"}]}`, "tools"},
+		{"completions", []Capability{CapabilityChat}, "/v1/completions",
+			`{"model":"test-model","prompt":"hi"}`, "model"},
+		{"embeddings", []Capability{CapabilityChat}, "/v1/embeddings",
+			`{"model":"test-model","input":"hi"}`, "model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewRouter()
+			b := newMockBackend("a", true)
+			b.caps = tt.caps
+			r.AddBackend(context.Background(), b)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"param":"`+tt.param+`"`) {
+				t.Errorf("expected 400 naming %s, got %d: %s", tt.param, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCapabilities_MaxContextTokens(t *testing.T) {
+	r, _ := NewRouter()
+	b := newMockBackend("a", true)
+	b.maxContext = 50
+	r.AddBackend(context.Background(), b)
+
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Errorf("short prompt: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	long := strings.Repeat("word ", 200)
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"`+long+`"}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "context_length_exceeded") {
+		t.Errorf("long prompt: expected 400 context_length_exceeded, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLookupByModelForOp(t *testing.T) {
+	reg := NewBackendRegistry()
+	chat := newMockBackend("chat", true)
+	chat.caps = []Capability{CapabilityChat, CapabilityStreaming}
+	embed := newMockBackend("embed", true)
+	embed.caps = []Capability{CapabilityEmbeddings}
+	reg.Register(context.Background(), chat)
+	reg.Register(context.Background(), embed)
+
+	for op, want := range map[Operation]string{
+		OperationChat:       "chat",
+		OperationEmbeddings: "embed",
+		OperationAny:        "chat",
+	} {
+		if b, ok := reg.LookupByModelForOp("test-model", op); !ok || b.ID() != want {
+			t.Errorf("LookupByModelForOp(%q) = %v, want %s", op, b, want)
+		}
+	}
+	if _, ok := reg.LookupByModelForOp("test-model", OperationCompletions); ok {
+		t.Error("expected no backend for completions")
+	}
+}
+
+func TestCapabilities_RoutesByOperation(t *testing.T) {
+	r, _ := NewRouter()
+	chat := newMockBackend("chat", true)
+	chat.caps = []Capability{CapabilityChat}
+	embed := newMockBackend("embed", true)
+	embed.caps = []Capability{CapabilityEmbeddings}
+	embed.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		return &types.EmbeddingsResponse{Object: "list", Model: "embed"}, nil
+	}
+	r.AddBackend(context.Background(), chat)
+	r.AddBackend(context.Background(), embed)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"test-model","input":"hi"}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"model":"embed"`) {
+		t.Errorf("expected the embeddings backend to serve, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Errorf("chat: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,258 @@
+// Package client calls a router's OpenAI-compatible endpoints and decodes
+// the responses into types structs. It can reach the router over HTTP or, for
+// tests and embedded use, call its handler in-process without a server.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// Client calls a router's public endpoints. Its methods mirror the Backend
+// interface. Non-200 responses are returned as *oairouter.BackendHTTPError,
+// with the router's error body parsed into APIError.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. It is ignored by
+// NewInProcess.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithHeader adds a header sent with every request, e.g. an Authorization
+// or session header.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// New creates a client for the router at baseURL, e.g.
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL: %s", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// NewInProcess creates a client that serves requests by calling h, usually
+// a *oairouter.Router, directly. Streams are delivered as the handler
+// flushes them, and closing a stream's context cancels the handler's
+// request.
+func NewInProcess(h http.Handler, opts ...Option) *Client {
+	c := &Client{
+		baseURL: &url.URL{Scheme: "http", Host: "oairouter.invalid"},
+		header:  make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient = &http.Client{Transport: handlerTransport{handler: h}}
+	return c
+}
+
+// ChatCompletion sends a chat completion request.
+func (c *Client) ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	var resp types.ChatCompletionResponse
+	if err := c.post(ctx, "/v1/chat/completions", "chat completion", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChatCompletionStream sends a streaming chat completion request. Each event
+// holds one chunk's raw JSON; the last has Done set.
+func (c *Client) ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	streamReq := *req
+	streamReq.Stream = true
+	return c.stream(ctx, "/v1/chat/completions", "chat completion stream", &streamReq)
+}
+
+// Completion sends a text completion request.
+func (c *Client) Completion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	var resp types.CompletionResponse
+	if err := c.post(ctx, "/v1/completions", "completion", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CompletionStream sends a streaming text completion request.
+func (c *Client) CompletionStream(ctx context.Context, req *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	streamReq := *req
+	streamReq.Stream = true
+	return c.stream(ctx, "/v1/completions", "completion stream", &streamReq)
+}
+
+// Embeddings sends an embeddings request.
+func (c *Client) Embeddings(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	var resp types.EmbeddingsResponse
+	if err := c.post(ctx, "/v1/embeddings", "embeddings", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateImages sends an image generation request.
+func (c *Client) GenerateImages(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	var resp types.ImageGenerationResponse
+	if err := c.post(ctx, "/v1/images/generations", "image generation", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Models lists the models the router serves.
+func (c *Client) Models(ctx context.Context) ([]types.Model, error) {
+	var resp types.ModelsResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/models", "models request", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Model describes one model.
+func (c *Client) Model(ctx context.Context, id string) (*types.Model, error) {
+	var model types.Model
+	if err := c.do(ctx, http.MethodGet, "/v1/models/"+id, "model request", nil, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// post sends body as JSON to path and decodes the response into out.
+func (c *Client) post(ctx context.Context, path, op string, body, out any) error {
+	return c.do(ctx, http.MethodPost, path, op, body, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path, op string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return oairouter.NewBackendHTTPError(op, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
+	}
+	return nil
+}
+
+// send builds and sends a request, encoding body as JSON if it is set.
+func (c *Client) send(ctx context.Context, method, path string, body any, stream bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.JoinPath(path).String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// stream sends a streaming request and delivers its SSE data lines as
+// events. The channel is closed after an event with Done set.
+func (c *Client) stream(ctx context.Context, path, op string, body any) (<-chan oairouter.StreamEvent, error) {
+	resp, err := c.send(ctx, http.MethodPost, path, body, true)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, oairouter.NewBackendHTTPError(op, resp)
+	}
+
+	events := make(chan oairouter.StreamEvent, 100)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		send := func(event oairouter.StreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if err == io.EOF {
+					send(oairouter.StreamEvent{Done: true})
+				} else {
+					send(oairouter.StreamEvent{Err: err, Done: true})
+				}
+				return
+			}
+
+			data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+			if !ok {
+				continue
+			}
+			if data == "[DONE]" {
+				send(oairouter.StreamEvent{Data: data, Done: true})
+				return
+			}
+			if !send(oairouter.StreamEvent{Data: data}) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
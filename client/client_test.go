@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// fakeBackend serves test-model, echoing the last chat message and
+// streaming it one word per chunk. If block is set, streams stop after the
+// first chunk until their request is done.
+type fakeBackend struct {
+	block     bool
+	cancelled chan struct{}
+}
+
+func (b *fakeBackend) ID() string                        { return "fake" }
+func (b *fakeBackend) Type() oairouter.BackendType       { return oairouter.BackendGeneric }
+func (b *fakeBackend) BaseURL() *url.URL                 { return &url.URL{Scheme: "http", Host: "fake"} }
+func (b *fakeBackend) HealthCheck(context.Context) error { return nil }
+func (b *fakeBackend) IsHealthy() bool                   { return true }
+func (b *fakeBackend) Capabilities() oairouter.BackendCapabilities {
+	return oairouter.FullCapabilities()
+}
+func (b *fakeBackend) Models(context.Context) ([]types.Model, error) {
+	return []types.Model{{ID: "test-model", Object: "model"}}, nil
+}
+func (b *fakeBackend) ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	content, _ := req.Messages[len(req.Messages)-1].Content.(string)
+	return &types.ChatCompletionResponse{
+		ID:      "chat-1",
+		Model:   req.Model,
+		Choices: []types.Choice{{Message: types.ChatMessage{Role: "assistant", Content: content}, FinishReason: "stop"}},
+	}, nil
+}
+func (b *fakeBackend) ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	events := make(chan oairouter.StreamEvent)
+	go func() {
+		defer close(events)
+		for _, word := range []string{"hello", "world"} {
+			chunk, _ := json.Marshal(types.ChatCompletionChunk{
+				ID:      "chat-1",
+				Choices: []types.ChunkChoice{{Delta: types.ChatDelta{Content: word}}},
+			})
+			select {
+			case events <- oairouter.StreamEvent{Data: string(chunk)}:
+			case <-ctx.Done():
+				return
+			}
+			if b.block {
+				<-ctx.Done()
+				close(b.cancelled)
+				return
+			}
+		}
+		events <- oairouter.StreamEvent{Data: "[DONE]", Done: true}
+	}()
+	return events, nil
+}
+func (b *fakeBackend) Completion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	return &types.CompletionResponse{ID: "cmpl-1", Model: req.Model}, nil
+}
+func (b *fakeBackend) CompletionStream(ctx context.Context, req *types.CompletionRequest) (<-chan oairouter.StreamEvent, error) {
+	return nil, errors.New("not implemented")
+}
+func (b *fakeBackend) Embeddings(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	return &types.EmbeddingsResponse{Model: req.Model, Data: []types.EmbeddingData{{Embedding: []float64{1, 2}}}}, nil
+}
+
+func newRouter(t *testing.T, b *fakeBackend) *oairouter.Router {
+	t.Helper()
+	r, err := oairouter.NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddBackend(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// clients returns an in-process client and one reaching r over HTTP.
+func clients(t *testing.T, r *oairouter.Router) map[string]*Client {
+	t.Helper()
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	remote, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*Client{"in-process": NewInProcess(r), "http": remote}
+}
+
+var chatReq = &types.ChatCompletionRequest{
+	Model:    "test-model",
+	Messages: []types.ChatMessage{{Role: "user", Content: "hi there"}},
+}
+
+func TestClient_ChatCompletion(t *testing.T) {
+	for name, c := range clients(t, newRouter(t, &fakeBackend{})) {
+		t.Run(name, func(t *testing.T) {
+			resp, err := c.ChatCompletion(context.Background(), chatReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.ID != "chat-1" || resp.Choices[0].Message.Content != "hi there" {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestClient_ChatCompletionStream(t *testing.T) {
+	for name, c := range clients(t, newRouter(t, &fakeBackend{})) {
+		t.Run(name, func(t *testing.T) {
+			events, err := c.ChatCompletionStream(context.Background(), chatReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var content string
+			var done bool
+			for event := range events {
+				if event.Err != nil {
+					t.Fatal(event.Err)
+				}
+				if event.Done {
+					done = true
+					break
+				}
+				var chunk types.ChatCompletionChunk
+				if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+					t.Fatal(err)
+				}
+				content += chunk.Choices[0].Delta.Content
+			}
+			if !done || content != "helloworld" {
+				t.Errorf("got content %q, done %v", content, done)
+			}
+			if chatReq.Stream {
+				t.Error("expected the caller's request to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestClient_InProcessStreamCancellation(t *testing.T) {
+	b := &fakeBackend{block: true, cancelled: make(chan struct{})}
+	c := NewInProcess(newRouter(t, b))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.ChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Data == "" {
+			t.Fatalf("expected the first chunk, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first chunk was not delivered before the stream finished")
+	}
+
+	cancel()
+	select {
+	case <-b.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the stream didn't cancel the backend request")
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	for name, c := range clients(t, newRouter(t, &fakeBackend{})) {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+				Model:    "missing-model",
+				Messages: []types.ChatMessage{{Role: "user", Content: "hi"}},
+			})
+			var httpErr *oairouter.BackendHTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("expected a BackendHTTPError, got %v", err)
+			}
+			if httpErr.StatusCode != http.StatusNotFound || httpErr.APIError == nil || httpErr.APIError.Error.Type != types.ErrorTypeNotFound {
+				t.Errorf("unexpected error: %+v", httpErr)
+			}
+		})
+	}
+}
+
+func TestClient_OtherEndpoints(t *testing.T) {
+	for name, c := range clients(t, newRouter(t, &fakeBackend{})) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			models, err := c.Models(ctx)
+			if err != nil || len(models) != 1 || models[0].ID != "test-model" {
+				t.Errorf("Models() = %+v, %v", models, err)
+			}
+			model, err := c.Model(ctx, "test-model")
+			if err != nil || model.ID != "test-model" {
+				t.Errorf("Model() = %+v, %v", model, err)
+			}
+			emb, err := c.Embeddings(ctx, &types.EmbeddingsRequest{Model: "test-model", Input: "hi"})
+			if err != nil || len(emb.Data) != 1 {
+				t.Errorf("Embeddings() = %+v, %v", emb, err)
+			}
+			comp, err := c.Completion(ctx, &types.CompletionRequest{Model: "test-model", Prompt: "hi"})
+			if err != nil || comp.ID != "cmpl-1" {
+				t.Errorf("Completion() = %+v, %v", comp, err)
+			}
+		})
+	}
+}
+
+func TestClient_WithHeader(t *testing.T) {
+	got := make(chan string, 1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got <- req.Header.Get("Authorization")
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	})
+	c := NewInProcess(h, WithHeader("Authorization", "Bearer secret"))
+	if _, err := c.Models(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if auth := <-got; auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:8080", "://bad"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q): expected an error", u)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// handlerTransport is an http.RoundTripper that serves requests by calling a
+// handler in a new goroutine. The response is returned once the handler
+// writes its headers, and its body is piped from the handler's writes, so
+// streamed responses arrive as they are flushed.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.Clone(ctx)
+	req.RequestURI = req.URL.RequestURI()
+	req.RemoteAddr = "127.0.0.1:0"
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{
+		header:  make(http.Header),
+		body:    pw,
+		written: make(chan struct{}),
+	}
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				pw.CloseWithError(fmt.Errorf("handler panicked: %v", p))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-w.written:
+	case <-ctx.Done():
+		cancel()
+		pr.Close()
+		return nil, ctx.Err()
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          cancelOnClose{ReadCloser: pr, cancel: cancel},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// cancelOnClose cancels the handler's request when the response body is
+// closed, so a handler still streaming stops.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// pipeResponseWriter is the http.ResponseWriter given to the handler. Body
+// writes go to a pipe read by the client.
+type pipeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	once    sync.Once
+	status  int
+	sent    http.Header   // Snapshot of header when the status was written
+	written chan struct{} // Closed when the status is written
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.written)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush implements http.Flusher. Writes reach the client as they are made,
+// so it only needs to send the headers.
+func (w *pipeResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,113 @@
+package oairouter
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// hangingBackend blocks each chat request until its context is done, then
+// reports the context's error on canceled. Streams send one chunk first.
+func hangingBackend(started chan<- struct{}, canceled chan<- error) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		events := make(chan StreamEvent)
+		go func() {
+			defer close(events)
+			events <- StreamEvent{Data: `{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`}
+			started <- struct{}{}
+			<-ctx.Done()
+			canceled <- ctx.Err()
+		}()
+		return events, nil
+	}
+	return b
+}
+
+func waitCanceled(t *testing.T, canceled <-chan error) {
+	t.Helper()
+	select {
+	case err := <-canceled:
+		if err == nil {
+			t.Error("backend context done without an error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend never saw the client go away")
+	}
+}
+
+func TestClientCancel_ReachesBackend(t *testing.T) {
+	r, _ := NewRouter()
+	started, canceled := make(chan struct{}, 1), make(chan error, 1)
+	r.AddBackend(context.Background(), hangingBackend(started, canceled))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/chat/completions",
+		strings.NewReader(testChatBody))
+	go func() {
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+	waitCanceled(t, canceled)
+}
+
+func TestClientDeadline_ReachesBackend(t *testing.T) {
+	r, _ := NewRouter()
+	started, canceled := make(chan struct{}, 1), make(chan error, 1)
+	r.AddBackend(context.Background(), hangingBackend(started, canceled))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	resp, err := client.Post(srv.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(testChatBody))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request outlived the client's deadline")
+	}
+	waitCanceled(t, canceled)
+}
+
+func TestClientCancel_ReachesStreamingBackend(t *testing.T) {
+	r, _ := NewRouter()
+	started, canceled := make(chan struct{}, 1), make(chan error, 1)
+	r.AddBackend(context.Background(), hangingBackend(started, canceled))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(streamChatBody))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Disconnect once the stream is under way
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.Contains(line, `"content":"Hi"`) {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	<-started
+	cancel()
+	waitCanceled(t, canceled)
+}
@@ -0,0 +1,113 @@
+package oairouter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// coalescedResponse is the outcome of a backend call shared by coalesced
+// requests.
+type coalescedResponse[Resp any] struct {
+	resp    *Resp
+	backend Backend
+	header  http.Header // Response headers set while serving the call
+}
+
+// coalescedCall is the context of a shared backend call, canceled once
+// every request waiting on it has gone.
+type coalescedCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// coalescingKey returns the key under which a non-streaming request is
+// coalesced with identical ones: the request's cache key, qualified by the
+// caller's API key and forwarded headers, and by backend when the request
+// was pinned to it. ok is false when coalescing is off or the request can't
+// be keyed.
+func (r *Router) coalescingKey(req *http.Request, endpoint string, apiReq any, backend Backend, pinned bool) (string, bool) {
+	if r.coalescing == nil {
+		return "", false
+	}
+	key, err := requestCacheKey(endpoint, apiReq)
+	if err != nil {
+		return "", false
+	}
+	caller := sha256.New()
+	caller.Write([]byte(requestAPIKey(req)))
+	for _, name := range r.forwardedHeaders {
+		for _, value := range req.Header.Values(name) {
+			caller.Write([]byte("\x00" + name + ":" + value))
+		}
+	}
+	key += ":" + hex.EncodeToString(caller.Sum(nil))
+	if pinned {
+		key += "@" + backend.ID()
+	}
+	return key, true
+}
+
+// joinCoalesced registers req as waiting on the shared call for key and
+// returns the call's context, detached from req's cancellation, and a func
+// to call once req stops waiting.
+func (r *Router) joinCoalesced(req *http.Request, key string) (context.Context, func()) {
+	r.coalescingMu.Lock()
+	defer r.coalescingMu.Unlock()
+	call := r.coalescingCalls[key]
+	if call == nil {
+		call = new(coalescedCall)
+		call.ctx, call.cancel = context.WithCancel(context.WithoutCancel(req.Context()))
+		r.coalescingCalls[key] = call
+	}
+	call.waiters++
+	return call.ctx, func() {
+		r.coalescingMu.Lock()
+		defer r.coalescingMu.Unlock()
+		if call.waiters--; call.waiters == 0 {
+			call.cancel()
+			delete(r.coalescingCalls, key)
+			// Nobody waits on a call still running, so later requests
+			// start a new one
+			r.coalescing.Forget(key)
+		}
+	}
+}
+
+// coalesce serves req with serve, sharing one call among concurrent
+// requests with the same key. The call is detached from the cancellation
+// and deadline of the request that started it, so a client giving up
+// doesn't fail the others waiting on it; it's canceled once all of them
+// have given up, so it runs until the latest of their deadlines. Each
+// waiter stops waiting when its own context is done, returning backend with
+// the context's error.
+func coalesce[Resp any](r *Router, w http.ResponseWriter, req *http.Request, key string, backend Backend, serve func(http.ResponseWriter, *http.Request) (*Resp, Backend, error)) (*Resp, Backend, error) {
+	callCtx, leave := r.joinCoalesced(req, key)
+	defer leave()
+	results := r.coalescing.DoChan(key, func() (any, error) {
+		// The call may outlive the request that started it, so it doesn't
+		// write to that request's log; each waiter notes its own
+		ctx := context.WithValue(callCtx, requestLogKey{}, nil)
+
+		header := &batchResponseWriter{header: make(http.Header)}
+		resp, served, err := serve(header, req.WithContext(ctx))
+		return coalescedResponse[Resp]{resp: resp, backend: served, header: header.header}, err
+	})
+
+	select {
+	case result := <-results:
+		shared := result.Val.(coalescedResponse[Resp])
+		for name, values := range shared.header {
+			w.Header()[name] = append([]string(nil), values...)
+		}
+		noteRequest(req, func(l *RequestLog) { l.BackendID = shared.backend.ID() })
+		if result.Shared {
+			r.logger.Debug("coalesced request", "key", key, "backend", shared.backend.ID())
+		}
+		return shared.resp, shared.backend, result.Err
+	case <-req.Context().Done():
+		return nil, backend, req.Context().Err()
+	}
+}
@@ -0,0 +1,201 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// gatedEmbedder counts embeddings calls and holds each until release is
+// closed, then answers with err or an embedding of the input's length.
+func gatedEmbedder(calls *atomic.Int64, started chan<- struct{}, release <-chan struct{}, err error) *mockBackend {
+	b := newMockBackend("embedder", true)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		calls.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		if err != nil {
+			return nil, err
+		}
+		n := float64(len(req.Input.(string)))
+		return &types.EmbeddingsResponse{Object: "list", Model: req.Model, Data: []types.EmbeddingData{{Object: "embedding", Embedding: []float64{n}}}}, nil
+	}
+	return b
+}
+
+// postEmbeddingAsync sends an embeddings request for input with ctx.
+func postEmbeddingAsync(r *Router, ctx context.Context, input string, wg *sync.WaitGroup) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		body := `{"model":"test-model","input":"` + input + `"}`
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)).WithContext(ctx))
+	}()
+	return rec
+}
+
+func TestRequestCoalescing_SharesOneCall(t *testing.T) {
+	r, _ := NewRouter(WithRequestCoalescing())
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	r.AddBackend(context.Background(), gatedEmbedder(&calls, started, release, nil))
+
+	var wg sync.WaitGroup
+	recs := []*httptest.ResponseRecorder{postEmbeddingAsync(r, context.Background(), "hello", &wg)}
+	<-started
+	for range 4 {
+		recs = append(recs, postEmbeddingAsync(r, context.Background(), "hello", &wg))
+	}
+	other := postEmbeddingAsync(r, context.Background(), "different", &wg)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"embedding":[5]`) {
+			t.Errorf("request %d: status = %d, body = %s", i, rec.Code, rec.Body)
+		}
+	}
+	if !strings.Contains(other.Body.String(), `"embedding":[9]`) {
+		t.Errorf("different request body = %s", other.Body)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("backend called %d times, want 2", n)
+	}
+}
+
+func TestRequestCoalescing_SharesErrors(t *testing.T) {
+	r, _ := NewRouter(WithRequestCoalescing())
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	r.AddBackend(context.Background(), gatedEmbedder(&calls, started, release, errors.New("model crashed")))
+
+	var wg sync.WaitGroup
+	recs := []*httptest.ResponseRecorder{postEmbeddingAsync(r, context.Background(), "hello", &wg)}
+	<-started
+	recs = append(recs, postEmbeddingAsync(r, context.Background(), "hello", &wg))
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, rec := range recs {
+		if rec.Code != recs[0].Code || rec.Code == http.StatusOK {
+			t.Errorf("request %d: status = %d, want the shared error", i, rec.Code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("backend called %d times, want 1", n)
+	}
+}
+
+func TestRequestCoalescing_CancelledWaiterLeavesCallRunning(t *testing.T) {
+	r, _ := NewRouter(WithRequestCoalescing())
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	r.AddBackend(context.Background(), gatedEmbedder(&calls, started, release, nil))
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	postEmbeddingAsync(r, ctx, "hello", &wg)
+	<-started
+	follower := postEmbeddingAsync(r, context.Background(), "hello", &wg)
+	time.Sleep(20 * time.Millisecond)
+
+	// The request that started the call gives up before it returns
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if follower.Code != http.StatusOK || !strings.Contains(follower.Body.String(), `"embedding":[5]`) {
+		t.Errorf("follower status = %d, body = %s", follower.Code, follower.Body)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("backend called %d times, want 1", n)
+	}
+}
+
+func TestRequestCoalescing_OffByDefault(t *testing.T) {
+	r, _ := NewRouter()
+	var calls atomic.Int64
+	release := make(chan struct{})
+	close(release)
+	r.AddBackend(context.Background(), gatedEmbedder(&calls, nil, release, nil))
+
+	var wg sync.WaitGroup
+	for range 3 {
+		postEmbeddingAsync(r, context.Background(), "hello", &wg)
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 3 {
+		t.Errorf("backend called %d times, want 3", n)
+	}
+}
+
+func TestRequestCoalescing_ScopedByCaller(t *testing.T) {
+	r, _ := NewRouter(WithRequestCoalescing())
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	r.AddBackend(context.Background(), gatedEmbedder(&calls, started, release, nil))
+
+	body := `{"model":"test-model","input":"hello"}`
+	recs := []<-chan *httptest.ResponseRecorder{postAsync(t, r, "/v1/embeddings", body, withAPIKey("key-a"))}
+	<-started
+	recs = append(recs,
+		postAsync(t, r, "/v1/embeddings", body, withAPIKey("key-b")),
+		postAsync(t, r, "/v1/embeddings", body, withAPIKey("key-a"), withHeader(ProjectHeader, "proj-2")),
+	)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for _, rec := range recs {
+		<-rec
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("backend called %d times, want 3", n)
+	}
+}
+
+func TestRequestCoalescing_LeaderDeadlineLeavesCallRunning(t *testing.T) {
+	r, _ := NewRouter(WithRequestCoalescing())
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	b := gatedEmbedder(&calls, started, release, nil)
+	gated := b.embeddingsFn
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		resp, err := gated(ctx, req)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return resp, err
+	}
+	r.AddBackend(context.Background(), b)
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	postEmbeddingAsync(r, ctx, "hello", &wg)
+	<-started
+	follower := postEmbeddingAsync(r, context.Background(), "hello", &wg)
+
+	// The request that started the call times out before it returns
+	<-ctx.Done()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if follower.Code != http.StatusOK || !strings.Contains(follower.Body.String(), `"embedding":[5]`) {
+		t.Errorf("follower status = %d, body = %s", follower.Code, follower.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package oairouter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultDeepHealthCheckTimeout is how long a deep health check waits for
+// its one-token response before failing the backend.
+const DefaultDeepHealthCheckTimeout = 10 * time.Second
+
+// DeepHealth is the outcome of a backend's last deep health check, which
+// asks the backend for a token rather than only its model list.
+type DeepHealth struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// deepHealthCheck configures the deep health check.
+type deepHealthCheck struct {
+	model    string // Model asked for a token; empty for each backend's first model
+	interval time.Duration
+}
+
+// isHealthy reports whether b passed its last health check, isn't held back
+// for warmup, and didn't fail its last deep health check.
+func (r *BackendRegistry) isHealthy(b Backend) bool {
+	if !b.IsHealthy() || r.warmingUp(b) {
+		return false
+	}
+	deep, ok := r.DeepHealth(b.ID())
+	return !ok || deep.Healthy
+}
+
+// DeepHealth returns the result of backendID's last deep health check. The
+// boolean is false if it hasn't had one.
+func (r *BackendRegistry) DeepHealth(backendID string) (DeepHealth, bool) {
+	v, ok := r.deepHealth.Load(backendID)
+	if !ok {
+		return DeepHealth{}, false
+	}
+	return v.(DeepHealth), true
+}
+
+// recordDeepHealth stores the result of b's deep health check, unless b was
+// unregistered or replaced while it ran.
+func (r *BackendRegistry) recordDeepHealth(b Backend, result DeepHealth) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.backends[b.ID()] == b {
+		r.deepHealth.Store(b.ID(), result)
+	}
+}
+
+func (r *Router) deepHealthCheckLoop(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.deepHealth.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.runDeepHealthChecks(ctx)
+			r.updateReadiness()
+		}
+	}
+}
+
+// runDeepHealthChecks asks every backend serving the deep health check's
+// model for one token, concurrently, and records which answered within
+// deepHealthTimeout. A backend that fails is reported unhealthy until it
+// passes a later deep check.
+func (r *Router) runDeepHealthChecks(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range r.registry.AllBackends() {
+		if r.deepHealth.model != "" && !r.registry.ServesModel(b.ID(), r.deepHealth.model) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := r.deepHealthCheck(ctx, b)
+			if ctx.Err() == nil {
+				r.registry.recordDeepHealth(b, result)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deepHealthCheck runs one deep health check of b.
+func (r *Router) deepHealthCheck(ctx context.Context, b Backend) DeepHealth {
+	ctx, cancel := context.WithTimeout(ctx, r.deepHealthTimeout)
+	defer cancel()
+
+	start := time.Now()
+	model := r.deepHealth.model
+	var err error
+	if model == "" {
+		model, err = firstModel(ctx, b)
+	}
+	if err == nil {
+		err = sendOneToken(ctx, b, model)
+	}
+	result := DeepHealth{
+		Healthy:   err == nil,
+		CheckedAt: start,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		r.logger.Warn("deep health check failed", "backend", b.ID(), "model", model, "latency_ms", result.LatencyMs, "error", err)
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package oairouter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// wedgedBackend lists its model but hangs on inference while wedged is set.
+func wedgedBackend(id, model string, wedged *atomic.Bool) *mockBackend {
+	b := modelBackend(id, model, true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		if wedged.Load() {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &types.ChatCompletionResponse{ID: id, Model: req.Model}, nil
+	}
+	return b
+}
+
+func TestDeepHealthCheck_MarksHangingBackendUnhealthy(t *testing.T) {
+	r, err := NewRouter(
+		WithDeepHealthCheck("llama-3", time.Minute),
+		WithDeepHealthCheckTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wedged atomic.Bool
+	wedged.Store(true)
+	r.AddBackend(context.Background(), wedgedBackend("backend-a", "llama-3", &wedged))
+	r.AddBackend(context.Background(), modelBackend("backend-b", "llama-3", true))
+
+	r.runDeepHealthChecks(context.Background())
+
+	info, _ := r.registry.BackendInfo("backend-a")
+	if info.Healthy || info.DeepHealth == nil || info.DeepHealth.Healthy || info.DeepHealth.Error == "" {
+		t.Errorf("wedged backend info = %+v, deep = %+v", info, info.DeepHealth)
+	}
+	if info, _ := r.registry.BackendInfo("backend-b"); !info.Healthy || info.DeepHealth == nil || !info.DeepHealth.Healthy {
+		t.Errorf("working backend info = %+v", info)
+	}
+	for range 5 {
+		if id, _ := servedBy(t, r, "llama-3"); id != "backend-b" {
+			t.Fatalf("request served by %s, want backend-b", id)
+		}
+	}
+
+	// It is healthy again once it passes a deep check
+	wedged.Store(false)
+	r.runDeepHealthChecks(context.Background())
+	if info, _ := r.registry.BackendInfo("backend-a"); !info.Healthy || !info.DeepHealth.Healthy {
+		t.Errorf("recovered backend info = %+v", info)
+	}
+}
+
+func TestDeepHealthCheck_OnlyChecksBackendsServingModel(t *testing.T) {
+	r, _ := NewRouter(WithDeepHealthCheck("llama-3", time.Minute))
+	var calls atomic.Int64
+	other := modelBackend("backend-other", "mistral", true)
+	other.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		calls.Add(1)
+		return &types.ChatCompletionResponse{}, nil
+	}
+	r.AddBackend(context.Background(), other)
+
+	r.runDeepHealthChecks(context.Background())
+	if n := calls.Load(); n != 0 {
+		t.Errorf("backend not serving the model was checked %d times", n)
+	}
+	if _, ok := r.registry.DeepHealth("backend-other"); ok {
+		t.Error("deep health recorded for an unchecked backend")
+	}
+}
+
+func TestDeepHealthCheck_FirstModelWhenUnset(t *testing.T) {
+	r, _ := NewRouter(WithDeepHealthCheck("", time.Minute))
+	var model string
+	b := modelBackend("backend-a", "mistral", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		model = req.Model
+		return &types.ChatCompletionResponse{}, nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	r.runDeepHealthChecks(context.Background())
+	if model != "mistral" {
+		t.Errorf("deep check asked %q, want mistral", model)
+	}
+	r.RemoveBackend("backend-a")
+	if _, ok := r.registry.DeepHealth("backend-a"); ok {
+		t.Error("deep health kept for a removed backend")
+	}
+}
+
+func TestWithDeepHealthCheck_Invalid(t *testing.T) {
+	if _, err := NewRouter(WithDeepHealthCheck("llama-3", 0)); err == nil {
+		t.Error("accepted a zero interval")
+	}
+	if _, err := NewRouter(WithDeepHealthCheckTimeout(-time.Second)); err == nil {
+		t.Error("accepted a negative timeout")
+	}
+}
@@ -0,0 +1,48 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestDefaultModel_AppliedWhenOmitted(t *testing.T) {
+	var gotModel string
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		gotModel = req.Model
+		return &types.ChatCompletionResponse{Model: req.Model}, nil
+	}
+	r, _ := NewRouter(WithDefaultModel("test-model"))
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, `{"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if gotModel != "test-model" {
+		t.Errorf("backend received model %q, want test-model", gotModel)
+	}
+}
+
+func TestDefaultModel_ExplicitModelKept(t *testing.T) {
+	r, _ := NewRouter(WithDefaultModel("test-model"))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	rec := postChat(t, r, `{"model":"other-model","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for an unknown explicit model", rec.Code)
+	}
+}
+
+func TestDefaultModel_UnsetRejectsMissingModel(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	rec := postChat(t, r, `{"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,25 @@
+package discovery
+
+import (
+	"net/http"
+
+	"github.com/stevemurr/oairouter/backends"
+)
+
+// HTTPClientFactory returns the HTTP client a discovered backend uses for
+// its requests and health checks, e.g. one with a tuned transport or a proxy.
+// It is called once per backend; returning nil keeps the backend's default
+// client. Clients may be shared between backends.
+type HTTPClientFactory func(backendID string) *http.Client
+
+// backendOptions returns the options that apply a client factory's client to
+// the backend with the given ID.
+func (f HTTPClientFactory) backendOptions(backendID string) []backends.GenericBackendOption {
+	if f == nil {
+		return nil
+	}
+	if c := f(backendID); c != nil {
+		return []backends.GenericBackendOption{backends.WithHTTPClient(c)}
+	}
+	return nil
+}
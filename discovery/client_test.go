@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stevemurr/oairouter"
+)
+
+// recordingTransport answers every request with an empty model list and
+// records the requested hosts, without touching the network.
+type recordingTransport struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.hosts = append(t.hosts, req.URL.Host)
+	t.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object":"list","data":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+// recordingFactory hands out one client backed by transport and records the
+// backend IDs it was asked for.
+func recordingFactory(transport http.RoundTripper) (HTTPClientFactory, *[]string) {
+	var ids []string
+	return func(backendID string) *http.Client {
+		ids = append(ids, backendID)
+		return &http.Client{Transport: transport}
+	}, &ids
+}
+
+func TestDockerDiscoverer_HTTPClientFactory(t *testing.T) {
+	transport := &recordingTransport{}
+	factory, ids := recordingFactory(transport)
+	d := &DockerDiscoverer{
+		labels: LabelConfig{
+			Prefix:         "oairouter.",
+			EnabledKey:     "enabled",
+			BackendTypeKey: "backend",
+			PortKey:        "port",
+			APIKey:         "api",
+			DefaultHost:    "gpu1",
+		},
+		newClient: factory,
+	}
+
+	for _, c := range []types.Container{
+		{ID: "abc", Names: []string{"/vllm"}, Labels: map[string]string{"oairouter.enabled": "true", "oairouter.backend": "vllm", "oairouter.port": "8000"}},
+		{ID: "def", Names: []string{"/ollama"}, Labels: map[string]string{"oairouter.enabled": "true", "oairouter.backend": "ollama", "oairouter.port": "11434", "oairouter.api": "native"}},
+	} {
+		backend, ok := d.containerToBackend(c)
+		if !ok {
+			t.Fatalf("container %s not discovered", c.Names[0])
+		}
+		// Health checks go through the factory's client
+		if err := backend.HealthCheck(context.Background()); err != nil {
+			t.Fatalf("%s: health check: %v", backend.ID(), err)
+		}
+	}
+
+	if want := []string{"vllm-vllm", "ollama-ollama"}; strings.Join(*ids, ",") != strings.Join(want, ",") {
+		t.Errorf("factory called for %v, want %v", *ids, want)
+	}
+	if want := "gpu1:8000,gpu1:11434"; strings.Join(transport.hosts, ",") != want {
+		t.Errorf("transport saw %v, want %s", transport.hosts, want)
+	}
+}
+
+func TestDNSDiscoverer_HTTPClientFactory(t *testing.T) {
+	transport := &recordingTransport{}
+	factory, ids := recordingFactory(transport)
+	resolver := &fakeResolver{records: []*net.SRV{{Target: "gpu1.example.com.", Port: 8000}}}
+
+	d, err := NewDNSDiscoverer("_llm._tcp.example.com",
+		WithDNSResolver(resolver),
+		WithDNSBackendType(oairouter.BackendVLLM),
+		WithDNSHTTPClientFactory(factory),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := d.Discover(context.Background())
+	if err != nil || len(found) != 1 {
+		t.Fatalf("Discover() = %v, %v", found, err)
+	}
+	if err := found[0].HealthCheck(context.Background()); err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if len(*ids) != 1 || (*ids)[0] != "vllm-gpu1.example.com-8000" {
+		t.Errorf("factory called for %v", *ids)
+	}
+	if len(transport.hosts) != 1 || transport.hosts[0] != "gpu1.example.com:8000" {
+		t.Errorf("transport saw %v", transport.hosts)
+	}
+}
@@ -0,0 +1,226 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/backends"
+)
+
+// SRVResolver resolves DNS SRV records. *net.Resolver satisfies this interface.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSDiscoverer finds LLM backends by periodically resolving a DNS SRV name.
+// Each SRV target host:port becomes a GenericBackend.
+type DNSDiscoverer struct {
+	name         string // Full SRV name, e.g. "_llm._tcp.example.com"
+	resolver     SRVResolver
+	backendType  oairouter.BackendType
+	scheme       string
+	pollInterval time.Duration
+	newClient    HTTPClientFactory
+
+	mu    sync.Mutex
+	known map[string]oairouter.Backend // "host:port" -> backend
+}
+
+// DNSOption configures the DNS discoverer.
+type DNSOption func(*DNSDiscoverer)
+
+// WithDNSResolver uses a custom SRV resolver (useful for testing).
+func WithDNSResolver(r SRVResolver) DNSOption {
+	return func(d *DNSDiscoverer) {
+		d.resolver = r
+	}
+}
+
+// WithDNSBackendType sets the backend type for discovered targets (default: generic).
+func WithDNSBackendType(t oairouter.BackendType) DNSOption {
+	return func(d *DNSDiscoverer) {
+		d.backendType = t
+	}
+}
+
+// WithDNSPollInterval sets how often the SRV name is re-resolved (default: 30s).
+func WithDNSPollInterval(interval time.Duration) DNSOption {
+	return func(d *DNSDiscoverer) {
+		d.pollInterval = interval
+	}
+}
+
+// WithDNSScheme sets the URL scheme used for discovered targets (default: http).
+func WithDNSScheme(scheme string) DNSOption {
+	return func(d *DNSDiscoverer) {
+		d.scheme = scheme
+	}
+}
+
+// WithDNSHTTPClientFactory sets the HTTP client each discovered backend uses,
+// e.g. to tune connection pooling or route through a proxy.
+func WithDNSHTTPClientFactory(f HTTPClientFactory) DNSOption {
+	return func(d *DNSDiscoverer) {
+		d.newClient = f
+	}
+}
+
+// NewDNSDiscoverer creates a discoverer for the given SRV name, e.g. "_llm._tcp.example.com".
+func NewDNSDiscoverer(name string, opts ...DNSOption) (*DNSDiscoverer, error) {
+	if name == "" {
+		return nil, fmt.Errorf("SRV name is required")
+	}
+
+	d := &DNSDiscoverer{
+		name:         name,
+		resolver:     net.DefaultResolver,
+		backendType:  oairouter.BackendGeneric,
+		scheme:       "http",
+		pollInterval: 30 * time.Second,
+		known:        make(map[string]oairouter.Backend),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.pollInterval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+
+	return d, nil
+}
+
+func (d *DNSDiscoverer) Name() string {
+	return "dns"
+}
+
+func (d *DNSDiscoverer) Discover(ctx context.Context) ([]oairouter.Backend, error) {
+	targets, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var foundBackends []oairouter.Backend
+	for _, addr := range targets {
+		backend, ok := d.known[addr]
+		if !ok {
+			backend, err = d.targetToBackend(addr)
+			if err != nil {
+				continue
+			}
+			d.known[addr] = backend
+		}
+		foundBackends = append(foundBackends, backend)
+	}
+
+	return foundBackends, nil
+}
+
+func (d *DNSDiscoverer) Watch(ctx context.Context) (<-chan oairouter.DiscoveryEvent, error) {
+	eventsChan := make(chan oairouter.DiscoveryEvent, 10)
+
+	go func() {
+		defer close(eventsChan)
+
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, event := range d.poll(ctx) {
+					select {
+					case eventsChan <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return eventsChan, nil
+}
+
+// poll re-resolves the SRV name and returns events for targets that appeared
+// or disappeared since the last resolution. Resolution failures keep the
+// existing backends in place.
+func (d *DNSDiscoverer) poll(ctx context.Context) []oairouter.DiscoveryEvent {
+	targets, err := d.resolve(ctx)
+	if err != nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var events []oairouter.DiscoveryEvent
+	current := make(map[string]bool, len(targets))
+
+	for _, addr := range targets {
+		current[addr] = true
+		if _, ok := d.known[addr]; ok {
+			continue
+		}
+		backend, err := d.targetToBackend(addr)
+		if err != nil {
+			continue
+		}
+		d.known[addr] = backend
+		events = append(events, oairouter.DiscoveryEvent{Type: oairouter.EventAdded, Backend: backend})
+	}
+
+	for addr, backend := range d.known {
+		if !current[addr] {
+			delete(d.known, addr)
+			events = append(events, oairouter.DiscoveryEvent{Type: oairouter.EventRemoved, Backend: backend})
+		}
+	}
+
+	return events
+}
+
+// resolve looks up the SRV name and returns the targets as "host:port" strings.
+func (d *DNSDiscoverer) resolve(ctx context.Context) ([]string, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve SRV %s: %w", d.name, err)
+	}
+
+	targets := make([]string, 0, len(records))
+	seen := make(map[string]bool, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		if host == "" {
+			continue
+		}
+		addr := net.JoinHostPort(host, fmt.Sprint(srv.Port))
+		if !seen[addr] {
+			seen[addr] = true
+			targets = append(targets, addr)
+		}
+	}
+	return targets, nil
+}
+
+// targetToBackend creates a backend for a "host:port" target.
+func (d *DNSDiscoverer) targetToBackend(addr string) (oairouter.Backend, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%s-%s-%s", d.backendType, host, port)
+	return backends.NewBackend(id, d.backendType, fmt.Sprintf("%s://%s", d.scheme, addr), d.newClient.backendOptions(id)...)
+}
@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stevemurr/oairouter"
+)
+
+// fakeResolver returns a configurable set of SRV records.
+type fakeResolver struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return name, r.records, r.err
+}
+
+func (r *fakeResolver) set(records []*net.SRV, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = records
+	r.err = err
+}
+
+func TestDNSDiscoverer_Discover(t *testing.T) {
+	resolver := &fakeResolver{records: []*net.SRV{
+		{Target: "gpu1.example.com.", Port: 8000},
+		{Target: "gpu2.example.com.", Port: 8000},
+		{Target: "gpu1.example.com.", Port: 8000}, // duplicate record
+	}}
+
+	d, err := NewDNSDiscoverer("_llm._tcp.example.com",
+		WithDNSResolver(resolver),
+		WithDNSBackendType(oairouter.BackendVLLM),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 backends, got %d", len(found))
+	}
+	if found[0].ID() != "vllm-gpu1.example.com-8000" {
+		t.Errorf("backend.ID() = %s, want vllm-gpu1.example.com-8000", found[0].ID())
+	}
+	if found[0].BaseURL().String() != "http://gpu1.example.com:8000" {
+		t.Errorf("backend.BaseURL() = %s", found[0].BaseURL())
+	}
+	if found[0].Type() != oairouter.BackendVLLM {
+		t.Errorf("backend.Type() = %s, want vllm", found[0].Type())
+	}
+}
+
+func TestDNSDiscoverer_PollEmitsChanges(t *testing.T) {
+	resolver := &fakeResolver{records: []*net.SRV{
+		{Target: "gpu1.example.com.", Port: 8000},
+		{Target: "gpu2.example.com.", Port: 8000},
+	}}
+
+	d, err := NewDNSDiscoverer("_llm._tcp.example.com", WithDNSResolver(resolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := d.Discover(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged records must not churn the registry
+	if events := d.poll(ctx); len(events) != 0 {
+		t.Errorf("expected no events for unchanged records, got %d", len(events))
+	}
+
+	// gpu2 disappears, gpu3 appears
+	resolver.set([]*net.SRV{
+		{Target: "gpu1.example.com.", Port: 8000},
+		{Target: "gpu3.example.com.", Port: 8000},
+	}, nil)
+
+	events := d.poll(ctx)
+	got := make(map[oairouter.EventType]string)
+	for _, e := range events {
+		got[e.Type] = e.Backend.ID()
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if got[oairouter.EventAdded] != "generic-gpu3.example.com-8000" {
+		t.Errorf("added = %s", got[oairouter.EventAdded])
+	}
+	if got[oairouter.EventRemoved] != "generic-gpu2.example.com-8000" {
+		t.Errorf("removed = %s", got[oairouter.EventRemoved])
+	}
+}
+
+func TestDNSDiscoverer_ResolutionFailureKeepsBackends(t *testing.T) {
+	resolver := &fakeResolver{records: []*net.SRV{{Target: "gpu1.example.com.", Port: 8000}}}
+
+	d, err := NewDNSDiscoverer("_llm._tcp.example.com", WithDNSResolver(resolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	d.Discover(ctx)
+
+	resolver.set(nil, errors.New("SERVFAIL"))
+	if events := d.poll(ctx); len(events) != 0 {
+		t.Errorf("expected no events on resolution failure, got %d", len(events))
+	}
+
+	// Recovery with the same records is still a no-op
+	resolver.set([]*net.SRV{{Target: "gpu1.example.com.", Port: 8000}}, nil)
+	if events := d.poll(ctx); len(events) != 0 {
+		t.Errorf("expected no events after recovery, got %d", len(events))
+	}
+}
@@ -2,7 +2,9 @@ package discovery
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -23,9 +25,23 @@ type LabelConfig struct {
 	EnabledKey     string // Key for enabled flag, e.g., "enabled"
 	BackendTypeKey string // Key for backend type, e.g., "backend"
 	PortKey        string // Key for port, e.g., "port"
-	ModelKey       string // Key for model ID, e.g., "model"
+	ModelKey       string // Key for model IDs served before /v1/models responds, e.g., "model"
 	URLKey         string // Key for full URL override, e.g., "url"
+	APIKey         string // Key for API flavor, e.g., "api"; "native" selects Ollama's /api endpoints
+	SkipVerifyKey  string // Key for skipping TLS certificate verification, e.g., "tls_skip_verify"
+	TierKey        string // Key for the routing tier, e.g., "tier"; lower tiers are preferred
+	TagsKey        string // Key for backend tags, e.g., "tags"; several may be separated by commas
+	APIPrefixKey   string // Key for the path the API is mounted under, e.g., "api_prefix"; default "/v1"
+	MaxTokensKey   string // Key for the cap on requests' max_tokens, e.g., "max_tokens"
 	DefaultHost    string // Default host when URL not specified, e.g., "localhost"
+
+	// DefaultMaxTokensKey is the key for the max_tokens given to requests
+	// that set none, e.g., "default_max_tokens"
+	DefaultMaxTokensKey string
+
+	// NetworkKey is the key for the Docker network a container is reached
+	// on in NetworkModeBridge, e.g., "network"
+	NetworkKey string
 }
 
 // DockerDiscoverer finds LLM backends running in Docker containers.
@@ -34,8 +50,25 @@ type DockerDiscoverer struct {
 	client    *client.Client
 	labels    LabelConfig
 	ownClient bool
+	hostAlias string // Prefixes backend IDs when watching several hosts
+	network   NetworkMode
+	netName   string // Network containers are reached on in bridge mode, if set
+	newClient HTTPClientFactory
 }
 
+// NetworkMode selects how the router reaches discovered containers.
+type NetworkMode string
+
+const (
+	// NetworkModeHost connects to the container's published port on
+	// LabelConfig.DefaultHost. Use it when the router runs on the Docker host.
+	NetworkModeHost NetworkMode = "host"
+
+	// NetworkModeBridge connects to the container's IP address on its Docker
+	// network. Use it when the router runs as a container on the same network.
+	NetworkModeBridge NetworkMode = "bridge"
+)
+
 // DockerOption configures the Docker discoverer.
 type DockerOption func(*DockerDiscoverer)
 
@@ -47,6 +80,47 @@ func WithDockerClient(c *client.Client) DockerOption {
 	}
 }
 
+// WithNetworkMode sets how container URLs are built. The default is
+// NetworkModeHost. In NetworkModeBridge, the port label (or the backend
+// type's default port) is the container's own port rather than a published one.
+// NewDockerDiscoverer rejects other modes.
+func WithNetworkMode(mode NetworkMode) DockerOption {
+	return func(d *DockerDiscoverer) {
+		d.network = mode
+	}
+}
+
+// WithNetwork reaches containers on the Docker network with the given name
+// in NetworkModeBridge, instead of the first of their networks by name. A
+// container's network label (see LabelConfig.NetworkKey) takes precedence.
+func WithNetwork(name string) DockerOption {
+	return func(d *DockerDiscoverer) {
+		d.netName = name
+	}
+}
+
+// WithHTTPClientFactory sets the HTTP client each discovered backend uses,
+// e.g. to tune connection pooling or route through a proxy.
+func WithHTTPClientFactory(f HTTPClientFactory) DockerOption {
+	return func(d *DockerDiscoverer) {
+		d.newClient = f
+	}
+}
+
+// HostAliasSeparator separates the host alias from the rest of a backend ID.
+const HostAliasSeparator = ":"
+
+// WithHostAlias namespaces discovered backend IDs as "<name>:<type>-<container>"
+// so several discoverers, each watching a different Docker host, can run
+// containers with the same name without their backends colliding. Pair it
+// with WithDockerClient for the host and a LabelConfig whose DefaultHost is
+// the host's address.
+func WithHostAlias(name string) DockerOption {
+	return func(d *DockerDiscoverer) {
+		d.hostAlias = name
+	}
+}
+
 // NewDockerDiscoverer creates a new Docker discoverer with the given label configuration.
 // Containers must have the label "{Prefix}{EnabledKey}" set to "true" to be discovered.
 func NewDockerDiscoverer(labels LabelConfig, opts ...DockerOption) (*DockerDiscoverer, error) {
@@ -58,6 +132,11 @@ func NewDockerDiscoverer(labels LabelConfig, opts ...DockerOption) (*DockerDisco
 	for _, opt := range opts {
 		opt(d)
 	}
+	switch d.network {
+	case "", NetworkModeHost, NetworkModeBridge:
+	default:
+		return nil, fmt.Errorf("unknown network mode %q", d.network)
+	}
 
 	if d.client == nil {
 		c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -71,6 +150,9 @@ func NewDockerDiscoverer(labels LabelConfig, opts ...DockerOption) (*DockerDisco
 }
 
 func (d *DockerDiscoverer) Name() string {
+	if d.hostAlias != "" {
+		return "docker" + HostAliasSeparator + d.hostAlias
+	}
 	return "docker"
 }
 
@@ -143,6 +225,9 @@ func (d *DockerDiscoverer) handleDockerEvent(ctx context.Context, event events.M
 		Labels: containerJSON.Config.Labels,
 		State:  containerJSON.State.Status,
 	}
+	if containerJSON.NetworkSettings != nil {
+		c.NetworkSettings = &types.SummaryNetworkSettings{Networks: containerJSON.NetworkSettings.Networks}
+	}
 
 	backend, ok := d.containerToBackend(c)
 	if !ok {
@@ -187,13 +272,43 @@ func (d *DockerDiscoverer) containerToBackend(c types.Container) (oairouter.Back
 	// 4. Build backend ID from container name
 	name := d.containerName(c)
 	id := fmt.Sprintf("%s-%s", backendType, name)
+	if d.hostAlias != "" {
+		id = d.hostAlias + HostAliasSeparator + id
+	}
 
-	// 5. Create backend
-	backend, err := backends.NewGenericBackend(
-		id,
-		baseURL,
-		backends.WithBackendType(backendType),
-	)
+	// 5. Seed models from the model label so routing works before the
+	// backend's model list is reachable
+	opts := d.newClient.backendOptions(id)
+	if models := d.modelLabel(c); len(models) > 0 {
+		opts = append(opts, backends.WithSeedModels(models...))
+	}
+	if d.tlsSkipVerify(c) {
+		opts = append(opts, backends.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	}
+	if tier, ok := d.tier(c); ok {
+		opts = append(opts, backends.WithTier(tier))
+	}
+	if prefix, ok := d.apiPrefix(c); ok {
+		opts = append(opts, backends.WithAPIPrefix(prefix))
+	}
+	if n, ok := d.positiveLabel(c, d.labels.MaxTokensKey); ok {
+		opts = append(opts, backends.WithMaxTokensCap(n))
+	}
+	if n, ok := d.positiveLabel(c, d.labels.DefaultMaxTokensKey); ok {
+		opts = append(opts, backends.WithDefaultMaxTokens(n))
+	}
+	if tags := d.listLabel(c, d.labels.TagsKey); len(tags) > 0 {
+		opts = append(opts, backends.WithTags(tags...))
+	}
+
+	// 6. Create backend
+	var backend oairouter.Backend
+	var err error
+	if backendType == oairouter.BackendOllama && d.apiFlavor(c) == "native" {
+		backend, err = backends.NewOllamaBackend(id, baseURL, opts...)
+	} else {
+		backend, err = backends.NewBackend(id, backendType, baseURL, opts...)
+	}
 	if err != nil {
 		return nil, false
 	}
@@ -201,8 +316,76 @@ func (d *DockerDiscoverer) containerToBackend(c types.Container) (oairouter.Back
 	return backend, true
 }
 
+// modelLabel returns the model IDs in the container's model label, which may
+// list several separated by commas.
+func (d *DockerDiscoverer) modelLabel(c types.Container) []string {
+	return d.listLabel(c, d.labels.ModelKey)
+}
+
+// listLabel returns the comma-separated values of the container's key label,
+// if key is configured.
+func (d *DockerDiscoverer) listLabel(c types.Container, key string) []string {
+	if key == "" {
+		return nil
+	}
+	var values []string
+	for _, v := range strings.Split(c.Labels[d.labels.Prefix+key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// apiFlavor returns the container's API flavor label, if configured.
+func (d *DockerDiscoverer) apiFlavor(c types.Container) string {
+	if d.labels.APIKey == "" {
+		return ""
+	}
+	return c.Labels[d.labels.Prefix+d.labels.APIKey]
+}
+
+// tlsSkipVerify reports whether the container is labeled to skip TLS
+// certificate verification, if configured.
+func (d *DockerDiscoverer) tlsSkipVerify(c types.Container) bool {
+	if d.labels.SkipVerifyKey == "" {
+		return false
+	}
+	return c.Labels[d.labels.Prefix+d.labels.SkipVerifyKey] == "true"
+}
+
+// tier returns the container's routing tier label, if configured and a
+// valid integer.
+func (d *DockerDiscoverer) tier(c types.Container) (int, bool) {
+	if d.labels.TierKey == "" {
+		return 0, false
+	}
+	tier, err := strconv.Atoi(c.Labels[d.labels.Prefix+d.labels.TierKey])
+	return tier, err == nil
+}
+
+// positiveLabel returns the container's label for key, such as its
+// max_tokens cap, if configured and a positive integer.
+func (d *DockerDiscoverer) positiveLabel(c types.Container, key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(c.Labels[d.labels.Prefix+key])
+	return n, err == nil && n > 0
+}
+
+// apiPrefix returns the container's API prefix label, if configured and set.
+func (d *DockerDiscoverer) apiPrefix(c types.Container) (string, bool) {
+	if d.labels.APIPrefixKey == "" {
+		return "", false
+	}
+	prefix, ok := c.Labels[d.labels.Prefix+d.labels.APIPrefixKey]
+	return prefix, ok
+}
+
 // getBaseURL returns the base URL for the container.
-// If URLKey label is set, uses that directly. Otherwise constructs from DefaultHost + port.
+// If URLKey label is set, uses that directly. Otherwise constructs from the
+// container's host (see containerHost) + port.
 func (d *DockerDiscoverer) getBaseURL(c types.Container, backendType oairouter.BackendType) string {
 	// Check for full URL override
 	if d.labels.URLKey != "" {
@@ -221,7 +404,48 @@ func (d *DockerDiscoverer) getBaseURL(c types.Container, backendType oairouter.B
 		}
 	}
 
-	return fmt.Sprintf("http://%s:%d", d.labels.DefaultHost, port)
+	return fmt.Sprintf("http://%s:%d", d.containerHost(c), port)
+}
+
+// containerHost returns the host to reach the container at: DefaultHost in
+// host mode, or in bridge mode the container's IP address. The address is
+// taken from the network named by the container's network label, else the
+// one set with WithNetwork, else the first of its networks by name that has
+// an address. A container with no address there yet falls back to its name,
+// which Docker's DNS resolves on user-defined networks.
+func (d *DockerDiscoverer) containerHost(c types.Container) string {
+	if d.network != NetworkModeBridge {
+		return d.labels.DefaultHost
+	}
+
+	network := d.netName
+	if d.labels.NetworkKey != "" {
+		if name := c.Labels[d.labels.Prefix+d.labels.NetworkKey]; name != "" {
+			network = name
+		}
+	}
+	if network != "" {
+		if c.NetworkSettings != nil {
+			if ep := c.NetworkSettings.Networks[network]; ep != nil && ep.IPAddress != "" {
+				return ep.IPAddress
+			}
+		}
+		return d.containerName(c)
+	}
+
+	if c.NetworkSettings != nil {
+		names := make([]string, 0, len(c.NetworkSettings.Networks))
+		for name := range c.NetworkSettings.Networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if ep := c.NetworkSettings.Networks[name]; ep != nil && ep.IPAddress != "" {
+				return ep.IPAddress
+			}
+		}
+	}
+	return d.containerName(c)
 }
 
 // containerName extracts a clean name from the container.
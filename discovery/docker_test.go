@@ -1,10 +1,15 @@
 package discovery
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
 	"github.com/stevemurr/oairouter"
+	"github.com/stevemurr/oairouter/backends"
 )
 
 func TestDefaultPortForType(t *testing.T) {
@@ -142,8 +147,8 @@ func TestContainerToBackend(t *testing.T) {
 		{
 			name: "uses container ID when no name",
 			container: types.Container{
-				ID:     "abc123def456789",
-				Names:  []string{},
+				ID:    "abc123def456789",
+				Names: []string{},
 				Labels: map[string]string{
 					"oairouter.enabled": "true",
 				},
@@ -272,6 +277,58 @@ func TestGetBaseURL(t *testing.T) {
 	}
 }
 
+func TestGetBaseURL_NetworkMode(t *testing.T) {
+	labels := LabelConfig{Prefix: "oairouter.", PortKey: "port", NetworkKey: "network", DefaultHost: "localhost"}
+	onNetworks := func(ips map[string]string) types.Container {
+		c := types.Container{ID: "abc123def456", Names: []string{"/vllm"}, Labels: map[string]string{"oairouter.port": "8000"}}
+		c.NetworkSettings = &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{}}
+		for name, ip := range ips {
+			c.NetworkSettings.Networks[name] = &network.EndpointSettings{IPAddress: ip}
+		}
+		return c
+	}
+
+	labeled := func(network string, ips map[string]string) types.Container {
+		c := onNetworks(ips)
+		c.Labels["oairouter.network"] = network
+		return c
+	}
+	both := map[string]string{"alpha": "172.18.0.5", "zeta": "10.0.0.9"}
+
+	tests := []struct {
+		name      string
+		mode      NetworkMode
+		network   string
+		container types.Container
+		want      string
+	}{
+		{"host mode uses default host", NetworkModeHost, "", onNetworks(map[string]string{"llm": "172.18.0.5"}), "http://localhost:8000"},
+		{"bridge mode uses container IP", NetworkModeBridge, "", onNetworks(map[string]string{"llm": "172.18.0.5"}), "http://172.18.0.5:8000"},
+		{"bridge mode picks first network by name", NetworkModeBridge, "", onNetworks(map[string]string{"zeta": "10.0.0.9", "alpha": "172.18.0.5", "empty": ""}), "http://172.18.0.5:8000"},
+		{"bridge mode without IP uses name", NetworkModeBridge, "", onNetworks(nil), "http://vllm:8000"},
+		{"bridge mode uses the configured network", NetworkModeBridge, "zeta", onNetworks(both), "http://10.0.0.9:8000"},
+		{"network label overrides the configured network", NetworkModeBridge, "zeta", labeled("alpha", both), "http://172.18.0.5:8000"},
+		{"network label without the network uses name", NetworkModeBridge, "", labeled("llm", both), "http://vllm:8000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DockerDiscoverer{labels: labels}
+			WithNetworkMode(tt.mode)(d)
+			WithNetwork(tt.network)(d)
+			if got := d.getBaseURL(tt.container, oairouter.BackendVLLM); got != tt.want {
+				t.Errorf("getBaseURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDockerDiscoverer_UnknownNetworkMode(t *testing.T) {
+	if _, err := NewDockerDiscoverer(LabelConfig{}, WithNetworkMode("overlay")); err == nil {
+		t.Error("accepted an unknown network mode")
+	}
+}
+
 func TestContainerName(t *testing.T) {
 	d := &DockerDiscoverer{}
 
@@ -315,3 +372,347 @@ func TestContainerName(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerToBackend_LMStudio(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:         "oairouter.",
+		EnabledKey:     "enabled",
+		BackendTypeKey: "backend",
+		DefaultHost:    "localhost",
+	}}
+
+	backend, ok := d.containerToBackend(types.Container{
+		ID:    "abc123def456",
+		Names: []string{"/lms"},
+		Labels: map[string]string{
+			"oairouter.enabled": "true",
+			"oairouter.backend": "lmstudio",
+		},
+	})
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+	if _, isLMStudio := backend.(*backends.LMStudioBackend); !isLMStudio {
+		t.Errorf("backend is %T, want *backends.LMStudioBackend", backend)
+	}
+	if backend.BaseURL().String() != "http://localhost:1234" {
+		t.Errorf("backend.BaseURL() = %s, want http://localhost:1234", backend.BaseURL())
+	}
+}
+
+func TestContainerToBackend_Anthropic(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:         "oairouter.",
+		EnabledKey:     "enabled",
+		BackendTypeKey: "backend",
+		PortKey:        "port",
+		DefaultHost:    "localhost",
+	}}
+
+	backend, ok := d.containerToBackend(types.Container{
+		ID:    "abc123def456",
+		Names: []string{"/claude-proxy"},
+		Labels: map[string]string{
+			"oairouter.enabled": "true",
+			"oairouter.backend": "anthropic",
+			"oairouter.port":    "4000",
+		},
+	})
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+	if _, isAnthropic := backend.(*backends.AnthropicBackend); !isAnthropic {
+		t.Errorf("backend is %T, want *backends.AnthropicBackend", backend)
+	}
+	if backend.ID() != "anthropic-claude-proxy" {
+		t.Errorf("backend.ID() = %s, want anthropic-claude-proxy", backend.ID())
+	}
+}
+
+func TestContainerToBackend_OllamaNativeAPI(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:         "oairouter.",
+		EnabledKey:     "enabled",
+		BackendTypeKey: "backend",
+		APIKey:         "api",
+		DefaultHost:    "localhost",
+	}}
+
+	container := func(labels map[string]string) types.Container {
+		labels["oairouter.enabled"] = "true"
+		labels["oairouter.backend"] = "ollama"
+		return types.Container{ID: "abc123def456", Names: []string{"/ollama"}, Labels: labels}
+	}
+
+	native, ok := d.containerToBackend(container(map[string]string{"oairouter.api": "native"}))
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+	if _, isNative := native.(*backends.OllamaBackend); !isNative {
+		t.Errorf("api=native backend is %T, want *backends.OllamaBackend", native)
+	}
+
+	compat, ok := d.containerToBackend(container(map[string]string{}))
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+	if _, isGeneric := compat.(*backends.GenericBackend); !isGeneric {
+		t.Errorf("default backend is %T, want *backends.GenericBackend", compat)
+	}
+}
+
+func TestContainerToBackend_HostAlias(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":"list","data":[{"id":"llama","object":"model"}]}`))
+	}))
+	defer srv.Close()
+
+	labels := LabelConfig{
+		Prefix:         "oairouter.",
+		EnabledKey:     "enabled",
+		BackendTypeKey: "backend",
+		URLKey:         "url",
+	}
+	container := types.Container{
+		ID:    "abc123def456",
+		Names: []string{"/vllm"},
+		Labels: map[string]string{
+			"oairouter.enabled": "true",
+			"oairouter.backend": "vllm",
+			"oairouter.url":     srv.URL,
+		},
+	}
+
+	hostA := &DockerDiscoverer{labels: labels}
+	WithHostAlias("gpu-a")(hostA)
+	hostB := &DockerDiscoverer{labels: labels}
+	WithHostAlias("gpu-b")(hostB)
+
+	a, _ := hostA.containerToBackend(container)
+	b, _ := hostB.containerToBackend(container)
+	if a.ID() != "gpu-a:vllm-vllm" || b.ID() != "gpu-b:vllm-vllm" {
+		t.Fatalf("IDs = %q, %q", a.ID(), b.ID())
+	}
+	if hostA.Name() != "docker:gpu-a" {
+		t.Errorf("Name() = %q, want docker:gpu-a", hostA.Name())
+	}
+
+	registry := oairouter.NewBackendRegistry()
+	registry.SetModelRetry(oairouter.Backoff{})
+	ctx := context.Background()
+	registry.Register(ctx, a)
+	registry.Register(ctx, b)
+
+	// Rediscovering the same container on a host replaces its backend
+	again, _ := hostA.containerToBackend(container)
+	registry.Register(ctx, again)
+
+	if n := len(registry.AllBackends()); n != 2 {
+		t.Errorf("registry has %d backends, want 2", n)
+	}
+	if n := len(registry.HealthyBackendsForModel("llama")); n != 2 {
+		t.Errorf("model served by %d backends, want 2", n)
+	}
+
+	// Removing one host's container leaves the other's
+	registry.Unregister(a.ID())
+	if _, ok := registry.LookupByID(b.ID()); !ok {
+		t.Error("other host's backend was removed")
+	}
+}
+
+func TestContainerToBackend_SeedsModelLabel(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:         "oairouter.",
+		EnabledKey:     "enabled",
+		BackendTypeKey: "backend",
+		ModelKey:       "model",
+		DefaultHost:    "127.0.0.1",
+		PortKey:        "port",
+	}}
+
+	backend, ok := d.containerToBackend(types.Container{
+		ID:    "abc123def456",
+		Names: []string{"/vllm"},
+		Labels: map[string]string{
+			"oairouter.enabled": "true",
+			"oairouter.backend": "vllm",
+			"oairouter.model":   "meta-llama/Llama-3-8B, meta-llama/Llama-3-70B",
+			"oairouter.port":    "1", // Nothing listening: the model list can't be fetched
+		},
+	})
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+
+	registry := oairouter.NewBackendRegistry()
+	registry.SetModelRetry(oairouter.Backoff{})
+	registry.Register(context.Background(), backend)
+
+	for _, model := range []string{"meta-llama/Llama-3-8B", "meta-llama/Llama-3-70B"} {
+		if _, ok := registry.LookupByModel(model); !ok {
+			t.Errorf("seeded model %s is not routable", model)
+		}
+	}
+}
+
+func TestContainerToBackend_TLSSkipVerifyLabel(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer srv.Close()
+
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:        "oairouter.",
+		EnabledKey:    "enabled",
+		URLKey:        "url",
+		SkipVerifyKey: "tls_skip_verify",
+	}}
+	for _, skip := range []string{"true", ""} {
+		backend, ok := d.containerToBackend(types.Container{
+			ID:    "abc123def456",
+			Names: []string{"/vllm"},
+			Labels: map[string]string{
+				"oairouter.enabled":         "true",
+				"oairouter.url":             srv.URL,
+				"oairouter.tls_skip_verify": skip,
+			},
+		})
+		if !ok {
+			t.Fatal("expected backend to be discovered")
+		}
+		err := backend.HealthCheck(context.Background())
+		if skip == "true" && err != nil {
+			t.Errorf("expected the self-signed certificate to be accepted, got %v", err)
+		}
+		if skip == "" && err == nil {
+			t.Error("expected the self-signed certificate to be rejected without the label")
+		}
+	}
+}
+
+func TestContainerToBackend_TierLabel(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:      "oairouter.",
+		EnabledKey:  "enabled",
+		TierKey:     "tier",
+		DefaultHost: "localhost",
+	}}
+	for label, want := range map[string]int{"1": 1, "": 0, "fast": 0} {
+		backend, ok := d.containerToBackend(types.Container{
+			ID:     "abc123def456",
+			Names:  []string{"/vllm"},
+			Ports:  []types.Port{{PrivatePort: 8000, PublicPort: 8000}},
+			Labels: map[string]string{"oairouter.enabled": "true", "oairouter.tier": label},
+		})
+		if !ok {
+			t.Fatal("expected backend to be discovered")
+		}
+		if got := backend.(oairouter.Tiered).Tier(); got != want {
+			t.Errorf("tier label %q: Tier() = %d, want %d", label, got, want)
+		}
+	}
+}
+
+func TestContainerToBackend_MaxTokensLabel(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:       "oairouter.",
+		EnabledKey:   "enabled",
+		MaxTokensKey: "max_tokens",
+		DefaultHost:  "localhost",
+	}}
+	for label, want := range map[string]int{"2048": 2048, "": 0, "lots": 0, "-1": 0} {
+		backend, ok := d.containerToBackend(types.Container{
+			ID:     "abc123def456",
+			Names:  []string{"/vllm"},
+			Ports:  []types.Port{{PrivatePort: 8000, PublicPort: 8000}},
+			Labels: map[string]string{"oairouter.enabled": "true", "oairouter.max_tokens": label},
+		})
+		if !ok {
+			t.Fatal("expected backend to be discovered")
+		}
+		if got := backend.Capabilities().MaxTokensCap; got != want {
+			t.Errorf("max_tokens label %q: MaxTokensCap = %d, want %d", label, got, want)
+		}
+	}
+}
+
+func TestContainerToBackend_DefaultMaxTokensLabel(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:              "oairouter.",
+		EnabledKey:          "enabled",
+		DefaultMaxTokensKey: "default_max_tokens",
+		DefaultHost:         "localhost",
+	}}
+	for label, want := range map[string]int{"512": 512, "": 0, "-8": 0} {
+		backend, ok := d.containerToBackend(types.Container{
+			ID:     "abc123def456",
+			Names:  []string{"/vllm"},
+			Ports:  []types.Port{{PrivatePort: 8000, PublicPort: 8000}},
+			Labels: map[string]string{"oairouter.enabled": "true", "oairouter.default_max_tokens": label},
+		})
+		if !ok {
+			t.Fatal("expected backend to be discovered")
+		}
+		if got := backend.Capabilities().DefaultMaxTokens; got != want {
+			t.Errorf("default_max_tokens label %q: DefaultMaxTokens = %d, want %d", label, got, want)
+		}
+	}
+}
+
+func TestContainerToBackend_TagsLabel(t *testing.T) {
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:      "oairouter.",
+		EnabledKey:  "enabled",
+		TagsKey:     "tags",
+		DefaultHost: "localhost",
+	}}
+	backend, ok := d.containerToBackend(types.Container{
+		ID:     "abc123def456",
+		Names:  []string{"/vllm"},
+		Ports:  []types.Port{{PrivatePort: 8000, PublicPort: 8000}},
+		Labels: map[string]string{"oairouter.enabled": "true", "oairouter.tags": "team-a, gpu,"},
+	})
+	if !ok {
+		t.Fatal("expected backend to be discovered")
+	}
+	if got := backend.(oairouter.Tagged).Tags(); len(got) != 2 || got[0] != "team-a" || got[1] != "gpu" {
+		t.Errorf("Tags() = %q, want [team-a gpu]", got)
+	}
+}
+
+func TestContainerToBackend_APIPrefixLabel(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer srv.Close()
+
+	d := &DockerDiscoverer{labels: LabelConfig{
+		Prefix:       "oairouter.",
+		EnabledKey:   "enabled",
+		URLKey:       "url",
+		APIPrefixKey: "api_prefix",
+	}}
+	for label, want := range map[string]string{"/openai/v1": "/openai/v1/models", "": "/v1/models"} {
+		labels := map[string]string{"oairouter.enabled": "true", "oairouter.url": srv.URL}
+		if label != "" {
+			labels["oairouter.api_prefix"] = label
+		}
+		backend, ok := d.containerToBackend(types.Container{
+			ID:     "abc123def456",
+			Names:  []string{"/gateway"},
+			Labels: labels,
+		})
+		if !ok {
+			t.Fatal("expected backend to be discovered")
+		}
+		if _, err := backend.Models(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if path != want {
+			t.Errorf("api_prefix label %q: models requested at %q, want %q", label, path, want)
+		}
+	}
+}
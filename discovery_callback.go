@@ -0,0 +1,36 @@
+package oairouter
+
+import "context"
+
+// discoveryCallbackBuffer is how many events can wait for the discovery
+// callback before new ones are dropped.
+const discoveryCallbackBuffer = 256
+
+// notifyDiscovery queues event for the discovery callback, if one is set.
+// It never blocks discovery: when the callback has fallen too far behind,
+// the event is dropped and a warning logged.
+func (r *Router) notifyDiscovery(event DiscoveryEvent) {
+	if r.discoveryCallback == nil {
+		return
+	}
+	select {
+	case r.discoveryEvents <- event:
+	default:
+		r.logger.Warn("discovery callback is behind, dropping event", "backend", event.Backend.ID(), "event", event.Type)
+	}
+}
+
+// runDiscoveryCallback passes queued events to the discovery callback, one
+// at a time and in order, until ctx is canceled.
+func (r *Router) runDiscoveryCallback(ctx context.Context) {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-r.discoveryEvents:
+			r.discoveryCallback(event)
+		}
+	}
+}
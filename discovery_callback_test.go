@@ -0,0 +1,95 @@
+package oairouter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// staticDiscoverer discovers a fixed set of backends, then sends the events
+// written to its channel.
+type staticDiscoverer struct {
+	initial []Backend
+	events  chan DiscoveryEvent
+}
+
+func (d *staticDiscoverer) Name() string { return "static" }
+func (d *staticDiscoverer) Discover(ctx context.Context) ([]Backend, error) {
+	return d.initial, nil
+}
+func (d *staticDiscoverer) Watch(ctx context.Context) (<-chan DiscoveryEvent, error) {
+	return d.events, nil
+}
+
+func TestDiscoveryCallback_ReceivesEvents(t *testing.T) {
+	d := &staticDiscoverer{
+		initial: []Backend{newMockBackend("backend-a", true)},
+		events:  make(chan DiscoveryEvent),
+	}
+	received := make(chan DiscoveryEvent, 10)
+	r, err := NewRouter(
+		WithDiscoverer(d),
+		WithHealthCheckInterval(time.Hour),
+		WithDiscoveryCallback(func(e DiscoveryEvent) { received <- e }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop(context.Background())
+
+	b := newMockBackend("backend-b", true)
+	d.events <- DiscoveryEvent{Type: EventAdded, Backend: b}
+	d.events <- DiscoveryEvent{Type: EventUpdated, Backend: b}
+	d.events <- DiscoveryEvent{Type: EventRemoved, Backend: b}
+
+	want := []struct {
+		typ EventType
+		id  string
+	}{
+		{EventAdded, "backend-a"},
+		{EventAdded, "backend-b"},
+		{EventUpdated, "backend-b"},
+		{EventRemoved, "backend-b"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-received:
+			if e.Type != w.typ || e.Backend.ID() != w.id {
+				t.Errorf("got %s %s, want %s %s", e.Type, e.Backend.ID(), w.typ, w.id)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s %s", w.typ, w.id)
+		}
+	}
+}
+
+func TestDiscoveryCallback_DoesNotBlockDiscovery(t *testing.T) {
+	d := &staticDiscoverer{events: make(chan DiscoveryEvent)}
+	block := make(chan struct{})
+	r, _ := NewRouter(
+		WithDiscoverer(d),
+		WithHealthCheckInterval(time.Hour),
+		WithDiscoveryCallback(func(DiscoveryEvent) { <-block }),
+	)
+	r.Start(context.Background())
+	defer r.Stop(context.Background())
+	defer close(block)
+
+	// Far more events than the callback buffer holds
+	for i := range discoveryCallbackBuffer * 2 {
+		select {
+		case d.events <- DiscoveryEvent{Type: EventUpdated, Backend: newMockBackend("backend-a", true)}:
+		case <-time.After(time.Second):
+			t.Fatalf("watch loop blocked at event %d", i)
+		}
+	}
+}
+
+func TestDiscoveryCallback_RequiresFunc(t *testing.T) {
+	if _, err := NewRouter(WithDiscoveryCallback(nil)); err == nil {
+		t.Error("expected an error for a nil callback")
+	}
+}
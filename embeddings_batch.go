@@ -0,0 +1,102 @@
+package oairouter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// embeddingBatches splits a list input into batches of at most size inputs.
+// It returns nil if the input fits in one batch or isn't a list of inputs; a
+// flat list of numbers is a single pre-tokenized input and is never split.
+func embeddingBatches(input any, size int) [][]any {
+	inputs, ok := input.([]any)
+	if !ok || len(inputs) <= size {
+		return nil
+	}
+	if _, tokens := inputs[0].(float64); tokens {
+		return nil
+	}
+
+	batches := make([][]any, 0, (len(inputs)+size-1)/size)
+	for start := 0; start < len(inputs); start += size {
+		end := min(start+size, len(inputs))
+		batches = append(batches, inputs[start:end])
+	}
+	return batches
+}
+
+// batchEmbeddings serves an embeddings request whose input list exceeds the
+// batch size as concurrent requests to the same backend. Embeddings are
+// re-indexed in input order and usage is summed. If any batch fails, the
+// remaining batches are canceled and the whole request fails.
+func (r *Router) batchEmbeddings(ctx context.Context, b Backend, req *types.EmbeddingsRequest, batches [][]any) (*types.EmbeddingsResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]*types.EmbeddingsResponse, len(batches))
+
+	// The first failure cancels the other batches; their errors are ignored
+	var firstErr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		single := *req
+		single.Input = batch
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := b.Embeddings(ctx, &single)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if resp == nil || len(resp.Data) != len(batch) {
+				fail(fmt.Errorf("embeddings batch %d: backend returned an embedding count that doesn't match its %d inputs", i, len(batch)))
+				return
+			}
+			responses[i] = resp
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	merged := &types.EmbeddingsResponse{
+		Object: "list",
+		Model:  responses[0].Model,
+	}
+	offset := 0
+	for i, resp := range responses {
+		for _, d := range resp.Data {
+			d.Index += offset
+			merged.Data = append(merged.Data, d)
+		}
+		offset += len(batches[i])
+
+		if resp.Usage != nil {
+			if merged.Usage == nil {
+				merged.Usage = &types.Usage{}
+			}
+			merged.Usage.PromptTokens += resp.Usage.PromptTokens
+			merged.Usage.CompletionTokens += resp.Usage.CompletionTokens
+			merged.Usage.TotalTokens += resp.Usage.TotalTokens
+		}
+	}
+	sort.SliceStable(merged.Data, func(i, j int) bool { return merged.Data[i].Index < merged.Data[j].Index })
+
+	return merged, nil
+}
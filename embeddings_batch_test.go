@@ -0,0 +1,123 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// embedBackend embeds "in-<k>" as [k], failing any batch containing failOn.
+func embedBackend(calls *atomic.Int64, failOn string) *mockBackend {
+	b := newMockBackend("embedder", true)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		calls.Add(1)
+		inputs := req.Input.([]any)
+
+		// Later batches answer first to exercise reordering
+		first, _ := strconv.Atoi(strings.TrimPrefix(inputs[0].(string), "in-"))
+		time.Sleep(time.Duration(10-first%10) * time.Millisecond)
+
+		resp := &types.EmbeddingsResponse{Object: "list", Model: req.Model, Usage: &types.Usage{}}
+		for i, in := range inputs {
+			if in == failOn {
+				return nil, errors.New("input too large")
+			}
+			k, _ := strconv.Atoi(strings.TrimPrefix(in.(string), "in-"))
+			resp.Data = append(resp.Data, types.EmbeddingData{Object: "embedding", Embedding: []float64{float64(k)}, Index: i})
+			resp.Usage.PromptTokens++
+			resp.Usage.TotalTokens++
+		}
+		return resp, nil
+	}
+	return b
+}
+
+func postEmbeddings(r *Router, input string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	body := `{"model":"test-model","input":` + input + `}`
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	return rec
+}
+
+func inputList(n int) string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("%q", fmt.Sprintf("in-%d", i))
+	}
+	return "[" + strings.Join(inputs, ",") + "]"
+}
+
+func TestEmbeddingBatching_MergesInOrder(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithEmbeddingBatchSize(3))
+	r.AddBackend(context.Background(), embedBackend(&calls, ""))
+
+	rec := postEmbeddings(r, inputList(10))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("backend called %d times, want 4 batches", n)
+	}
+
+	var resp types.EmbeddingsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 10 {
+		t.Fatalf("got %d embeddings, want 10", len(resp.Data))
+	}
+	for i, d := range resp.Data {
+		if d.Index != i || d.Embedding[0] != float64(i) {
+			t.Errorf("data[%d] = index %d embedding %v, want index %d embedding [%d]", i, d.Index, d.Embedding, i, i)
+		}
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 10 || resp.Usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v, want 10 prompt and total tokens", resp.Usage)
+	}
+}
+
+func TestEmbeddingBatching_PartialFailure(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithEmbeddingBatchSize(3))
+	r.AddBackend(context.Background(), embedBackend(&calls, "in-7"))
+
+	rec := postEmbeddings(r, inputList(10))
+	if rec.Code == http.StatusOK {
+		t.Fatalf("status = 200, want an error when one batch fails")
+	}
+	if !strings.Contains(rec.Body.String(), "input too large") {
+		t.Errorf("body = %s, want the failing batch's error", rec.Body.String())
+	}
+}
+
+func TestEmbeddingBatching_SmallOrTokenInputsUnsplit(t *testing.T) {
+	var calls atomic.Int64
+	b := newMockBackend("embedder", true)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		calls.Add(1)
+		return &types.EmbeddingsResponse{Object: "list"}, nil
+	}
+	r, _ := NewRouter(WithEmbeddingBatchSize(3))
+	r.AddBackend(context.Background(), b)
+
+	for _, input := range []string{`"one string"`, `["a","b","c"]`, `[101,2023,2003,2004,2005]`} {
+		calls.Store(0)
+		if rec := postEmbeddings(r, input); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", input, rec.Code, rec.Body.String())
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("%s: backend called %d times, want 1", input, n)
+		}
+	}
+}
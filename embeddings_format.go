@@ -0,0 +1,16 @@
+package oairouter
+
+import "github.com/stevemurr/oairouter/types"
+
+// convertEmbeddingsFormat encodes resp's embeddings in the encoding_format
+// req asked for, whichever the backend returned, when
+// WithEmbeddingsFormatConversion is set.
+func convertEmbeddingsFormat(r *Router, req *types.EmbeddingsRequest, resp *types.EmbeddingsResponse) {
+	if !r.convertEmbeddings {
+		return
+	}
+	base64 := req.EncodingFormat == "base64"
+	for i := range resp.Data {
+		resp.Data[i].Base64 = base64
+	}
+}
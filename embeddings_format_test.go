@@ -0,0 +1,70 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// embeddingsFormatRouter returns a router whose backend answers embeddings
+// requests with [1, -2.5, 0.125], base64 encoded if backendBase64 is set.
+func embeddingsFormatRouter(t *testing.T, backendBase64 bool, opts ...Option) *Router {
+	t.Helper()
+	b := newMockBackend("backend-a", true)
+	b.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		return &types.EmbeddingsResponse{
+			Object: "list",
+			Model:  req.Model,
+			Data:   []types.EmbeddingData{{Object: "embedding", Embedding: []float64{1, -2.5, 0.125}, Base64: backendBase64}},
+		}, nil
+	}
+	return newTestRouter(t, []Backend{b}, opts...)
+}
+
+// embeddingInFormat requests an embedding in format and returns the response body.
+func embeddingInFormat(t *testing.T, r *Router, format string) string {
+	t.Helper()
+	body := `{"model":"test-model","input":"hi","encoding_format":"` + format + `"}`
+	rec := postJSON(t, r, "/v1/embeddings", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+const (
+	floatEmbedding  = `"embedding":[1,-2.5,0.125]`
+	base64Embedding = `"embedding":"AACAPwAAIMAAAAA+"`
+)
+
+func TestEmbeddingsFormatConversion(t *testing.T) {
+	tests := []struct {
+		name          string
+		backendBase64 bool
+		format        string
+		want          string
+	}{
+		{"floats to base64", false, "base64", base64Embedding},
+		{"base64 to floats", true, "float", floatEmbedding},
+		{"base64 kept", true, "base64", base64Embedding},
+		{"floats kept", false, "float", floatEmbedding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := embeddingsFormatRouter(t, tt.backendBase64, WithEmbeddingsFormatConversion(true))
+			if body := embeddingInFormat(t, r, tt.format); !strings.Contains(body, tt.want) {
+				t.Errorf("body = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddingsFormat_BackendFormatKeptByDefault(t *testing.T) {
+	r := embeddingsFormatRouter(t, false)
+	if body := embeddingInFormat(t, r, "base64"); !strings.Contains(body, floatEmbedding) {
+		t.Errorf("body = %s, want the backend's floats", body)
+	}
+}
@@ -0,0 +1,159 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// maxErrorBodySize bounds how much of an upstream error body is read.
+const maxErrorBodySize = 64 << 10
+
+// upstreamErrorHeaders are the upstream response headers kept on a
+// BackendHTTPError, to identify the failed request or say when to retry.
+var upstreamErrorHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id", "Request-Id"}
+
+// BackendHTTPError is returned by backends when the upstream server responds
+// with a non-success status. Use errors.As to inspect it.
+type BackendHTTPError struct {
+	Op         string          // Operation that failed, e.g. "chat completion"
+	StatusCode int             // Upstream HTTP status code
+	Status     string          // Upstream HTTP status line, e.g. "400 Bad Request"
+	Header     http.Header     // Upstream headers useful for debugging, e.g. X-Request-Id
+	Body       []byte          // Raw upstream response body (truncated)
+	APIError   *types.APIError // Parsed OpenAI-style error body, if present
+}
+
+// NewBackendHTTPError builds a BackendHTTPError from an upstream response,
+// reading (but not closing) its body.
+func NewBackendHTTPError(op string, resp *http.Response) *BackendHTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	e := &BackendHTTPError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     make(http.Header),
+		Body:       body,
+	}
+	for _, key := range upstreamErrorHeaders {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			e.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+
+	var apiErr types.APIError
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		e.APIError = &apiErr
+	}
+
+	return e
+}
+
+func (e *BackendHTTPError) Error() string {
+	return fmt.Sprintf("%s failed: %s - %s", e.Op, e.Status, string(e.Body))
+}
+
+// clientStatus maps the upstream status to the status returned to the client.
+// Client errors are forwarded as-is, except authentication failures, which
+// describe the router's credentials for the backend rather than the client's.
+func (e *BackendHTTPError) clientStatus() int {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized,
+		e.StatusCode == http.StatusForbidden,
+		e.StatusCode == http.StatusProxyAuthRequired:
+		return http.StatusBadGateway
+	case e.StatusCode >= 400 && e.StatusCode < 600:
+		return e.StatusCode
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeBackendError writes the error response for an error returned by a
+// backend, keeping an upstream 429's Retry-After. Queue errors get a 503 with
+// Retry-After. With WithUpstreamErrors
+// set, an upstream error response is forwarded as is: its status, debugging
+// headers, and body.
+func (r *Router) writeBackendError(w http.ResponseWriter, err error) {
+	var httpErr *BackendHTTPError
+	if r.upstreamErrors && errors.As(err, &httpErr) {
+		for key, values := range httpErr.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(httpErr.StatusCode)
+		w.Write(httpErr.Body)
+		return
+	}
+
+	rerr := backendRouterError(err)
+	if isQueueError(err) && r.requestQueue != nil {
+		w.Header().Set("Retry-After", r.requestQueue.retryAfter())
+	}
+	if rerr.StatusCode == http.StatusTooManyRequests && errors.As(err, &httpErr) {
+		if retryAfter := httpErr.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+	}
+	types.WriteError(w, rerr.StatusCode, rerr.APIError)
+}
+
+// backendRouterError converts an error returned by a backend into the
+// RouterError written to the client. A RouterError is passed through as is.
+func backendRouterError(err error) *types.RouterError {
+	var routerErr *types.RouterError
+	if errors.As(err, &routerErr) {
+		return routerErr
+	}
+
+	if isQueueError(err) {
+		return types.NewRouterError(http.StatusServiceUnavailable, types.ServerError(err.Error()), err)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return types.NewRouterError(http.StatusGatewayTimeout, types.ServerError("backend request timed out"), err)
+	}
+
+	var httpErr *BackendHTTPError
+	if !errors.As(err, &httpErr) {
+		return types.NewRouterError(http.StatusInternalServerError, types.ServerError("backend error: "+err.Error()), err)
+	}
+
+	status := httpErr.clientStatus()
+	if httpErr.APIError != nil && status == httpErr.StatusCode {
+		return types.NewRouterError(status, httpErr.APIError, err)
+	}
+
+	message := "backend error: " + httpErr.Status
+	if len(httpErr.Body) > 0 {
+		message += " - " + string(httpErr.Body)
+	}
+	if httpErr.APIError != nil {
+		message = "backend error: " + httpErr.APIError.Error.Message
+	}
+
+	var apiErr *types.APIError
+	switch {
+	case status == http.StatusTooManyRequests:
+		apiErr = types.NewAPIError(message, types.ErrorTypeRateLimit, nil)
+	case status == http.StatusNotFound:
+		apiErr = types.NewAPIError(message, types.ErrorTypeNotFound, nil)
+	case status >= 400 && status < 500:
+		apiErr = types.InvalidRequestError(message)
+	default:
+		apiErr = types.ServerError(message)
+	}
+	return types.NewRouterError(status, apiErr, err)
+}
+
+// imagesUnsupportedError is returned when an image generation request is
+// routed to a backend that isn't an ImageGenerator.
+func imagesUnsupportedError(model string) *types.RouterError {
+	return types.NewRouterError(http.StatusBadRequest,
+		types.InvalidParamError("model "+model+" does not support image generation", "model"), nil)
+}
@@ -0,0 +1,167 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func upstreamResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestBackendHTTPError_StatusMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantType    string
+		wantMessage string
+	}{
+		{
+			name:        "openai error body is forwarded",
+			err:         NewBackendHTTPError("chat completion", upstreamResponse(400, `{"error":{"message":"messages must not be empty","type":"invalid_request_error","param":"messages","code":null}}`)),
+			wantStatus:  http.StatusBadRequest,
+			wantType:    types.ErrorTypeInvalidRequest,
+			wantMessage: "messages must not be empty",
+		},
+		{
+			name:        "rate limit without body",
+			err:         NewBackendHTTPError("chat completion", upstreamResponse(429, "slow down")),
+			wantStatus:  http.StatusTooManyRequests,
+			wantType:    types.ErrorTypeRateLimit,
+			wantMessage: "backend error: Too Many Requests - slow down",
+		},
+		{
+			name:       "upstream auth failure is a gateway error",
+			err:        NewBackendHTTPError("chat completion", upstreamResponse(401, `{"error":{"message":"bad key","type":"authentication_error"}}`)),
+			wantStatus: http.StatusBadGateway,
+			wantType:   types.ErrorTypeServer,
+		},
+		{
+			name:       "upstream server error",
+			err:        NewBackendHTTPError("chat completion", upstreamResponse(503, "overloaded")),
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   types.ErrorTypeServer,
+		},
+		{
+			name:       "transport error",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantType:   types.ErrorTypeServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newMockBackend("backend-a", true)
+			b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+				return nil, tt.err
+			}
+			r, _ := NewRouter()
+			r.AddBackend(context.Background(), b)
+
+			rec := postChat(t, r, testChatBody)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var apiErr types.APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if apiErr.Error.Type != tt.wantType {
+				t.Errorf("error type %q, want %q", apiErr.Error.Type, tt.wantType)
+			}
+			if tt.wantMessage != "" && apiErr.Error.Message != tt.wantMessage {
+				t.Errorf("error message %q, want %q", apiErr.Error.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestBackendHTTPError_ErrorsAs(t *testing.T) {
+	var err error = NewBackendHTTPError("embeddings", upstreamResponse(422, "bad input"))
+	wrapped := errors.Join(errors.New("context"), err)
+
+	var httpErr *BackendHTTPError
+	if !errors.As(wrapped, &httpErr) {
+		t.Fatal("expected errors.As to find BackendHTTPError")
+	}
+	if httpErr.StatusCode != 422 || string(httpErr.Body) != "bad input" {
+		t.Errorf("unexpected error fields: %+v", httpErr)
+	}
+}
+
+func TestBackendHTTPError_KeepsDebugHeaders(t *testing.T) {
+	resp := upstreamResponse(422, `{"detail":"bad"}`)
+	resp.Header = http.Header{
+		"X-Request-Id":     {"req-123"},
+		"Content-Type":     {"application/json"},
+		"Set-Cookie":       {"session=secret"},
+		"Www-Authenticate": {"Bearer"},
+	}
+	err := NewBackendHTTPError("chat completion", resp)
+
+	want := http.Header{"X-Request-Id": {"req-123"}, "Content-Type": {"application/json"}}
+	if !reflect.DeepEqual(err.Header, want) {
+		t.Errorf("Header = %v, want %v", err.Header, want)
+	}
+}
+
+func TestUpstreamErrors_ForwardsResponse(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		resp := upstreamResponse(422, `{"detail":[{"loc":["body","messages"],"msg":"field required"}]}`)
+		resp.Header = http.Header{"X-Request-Id": {"req-123"}, "Content-Type": {"application/json"}}
+		upstreamErr := NewBackendHTTPError("chat completion", resp)
+
+		b := newMockBackend("backend-a", true)
+		b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+			return nil, upstreamErr
+		}
+		b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+			return nil, upstreamErr
+		}
+		r, _ := NewRouter(WithUpstreamErrors(true))
+		r.AddBackend(context.Background(), b)
+
+		body := fmt.Sprintf(`{"model":"test-model","stream":%t,"messages":[{"role":"user","content":"hi"}]}`, stream)
+		rec := postChat(t, r, body)
+		if rec.Code != 422 {
+			t.Errorf("stream=%t: status = %d, want 422", stream, rec.Code)
+		}
+		if rec.Body.String() != string(upstreamErr.Body) {
+			t.Errorf("stream=%t: body = %s, want the upstream body", stream, rec.Body.String())
+		}
+		if rec.Header().Get("X-Request-Id") != "req-123" {
+			t.Errorf("stream=%t: X-Request-Id = %q", stream, rec.Header().Get("X-Request-Id"))
+		}
+	}
+}
+
+func TestUpstreamErrors_OtherErrorsMapped(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, errors.New("connection refused")
+	}
+	r, _ := NewRouter(WithUpstreamErrors(true))
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, testChatBody)
+	var apiErr types.APIError
+	if rec.Code != http.StatusInternalServerError || json.Unmarshal(rec.Body.Bytes(), &apiErr) != nil {
+		t.Errorf("expected an OpenAI error for a transport failure, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
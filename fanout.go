@@ -0,0 +1,141 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// DefaultFanOutNLimit is the largest n a chat request may ask for when
+// WithFanOutN is enabled, unless set by WithFanOutNLimit.
+const DefaultFanOutNLimit = 16
+
+// errFanOutNoChoices is returned when no fanned-out request returned a
+// response or an error.
+var errFanOutNoChoices = errors.New("fan-out requests returned no choices")
+
+// fanOutChatCompletion serves an n>1 chat request as n parallel n=1 requests,
+// starting with the selected backend and round-robining over the model's
+// other healthy backends. Choices are merged and re-indexed in request order.
+// Requests asking for more than the fan-out limit are rejected.
+func (r *Router) fanOutChatCompletion(ctx context.Context, primary Backend, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	n := *req.N
+	if n > r.fanOutNLimit {
+		return nil, types.NewRouterError(http.StatusBadRequest,
+			types.InvalidParamError(fmt.Sprintf("n must be at most %d", r.fanOutNLimit), "n"), nil)
+	}
+
+	candidates := []Backend{primary}
+	if !backendPinned(ctx) {
+		for _, b := range r.registry.HealthyBackendsForModelOp(req.Model, OperationChat) {
+			if b.ID() != primary.ID() {
+				candidates = append(candidates, b)
+			}
+		}
+	}
+
+	type result struct {
+		resp *types.ChatCompletionResponse
+		err  error
+	}
+	results := make([]result, n)
+
+	// held is the primary's slot, taken by the caller. Requests to the
+	// primary share it one at a time unless they can take a slot of their own
+	// without waiting, so none of them queues behind the caller.
+	held := make(chan struct{}, 1)
+	held <- struct{}{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		single := *req
+		one := 1
+		single.N = &one
+
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+			release, err := r.fanOutSlot(ctx, b, primary, held)
+			if err != nil {
+				results[i] = result{err: err}
+				return
+			}
+			defer release()
+			resp, err := chatCompletion(ctx, b, &single)
+			results[i] = result{resp: resp, err: err}
+		}(i, candidates[i%len(candidates)])
+	}
+	wg.Wait()
+
+	var merged *types.ChatCompletionResponse
+	var firstErr error
+	for _, res := range results {
+		if res.err != nil || res.resp == nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+
+		if merged == nil {
+			copied := *res.resp
+			copied.Choices = make([]types.Choice, 0, n)
+			copied.Usage = nil
+			merged = &copied
+		}
+
+		for _, choice := range res.resp.Choices {
+			choice.Index = len(merged.Choices)
+			merged.Choices = append(merged.Choices, choice)
+		}
+
+		if res.resp.Usage != nil {
+			if merged.Usage == nil {
+				// The prompt is shared, so count it once
+				merged.Usage = &types.Usage{PromptTokens: res.resp.Usage.PromptTokens}
+			}
+			merged.Usage.CompletionTokens += res.resp.Usage.CompletionTokens
+			merged.Usage.TotalTokens = merged.Usage.PromptTokens + merged.Usage.CompletionTokens
+		}
+	}
+
+	if merged == nil && firstErr == nil {
+		return nil, errFanOutNoChoices
+	}
+	if merged == nil || (firstErr != nil && r.fanOutNRequireAll) {
+		return nil, firstErr
+	}
+	if firstErr != nil {
+		r.logger.Warn("fan-out returned partial choices", "model", req.Model, "requested", n, "returned", len(merged.Choices), "error", firstErr)
+	}
+
+	return merged, nil
+}
+
+// fanOutSlot takes a slot on b for one fanned-out request. Requests to
+// primary use held, the slot the caller already has, when no other slot is
+// free; requests to other backends go through acquireSlot.
+func (r *Router) fanOutSlot(ctx context.Context, b, primary Backend, held chan struct{}) (release func(), err error) {
+	if b.ID() != primary.ID() {
+		return r.acquireSlot(ctx, b)
+	}
+	releaseHeld := func() { held <- struct{}{} }
+	select {
+	case <-held:
+		return releaseHeld, nil
+	default:
+	}
+	if release, ok := r.tryAcquireSlot(b); ok {
+		return release, nil
+	}
+	select {
+	case <-held:
+		return releaseHeld, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
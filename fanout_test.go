@@ -0,0 +1,185 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// singleChoiceBackend returns a mock that rejects n>1 and answers n=1 with one choice.
+func singleChoiceBackend(id string, calls *atomic.Int64, fail bool) *mockBackend {
+	b := newMockBackend(id, true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		calls.Add(1)
+		if req.N != nil && *req.N > 1 {
+			return nil, errors.New("n>1 not supported")
+		}
+		if fail {
+			return nil, errors.New("backend failed")
+		}
+		return &types.ChatCompletionResponse{
+			ID:      "chatcmpl-" + id,
+			Object:  "chat.completion",
+			Model:   req.Model,
+			Choices: []types.Choice{{Message: types.ChatMessage{Role: "assistant", Content: "from " + id}, FinishReason: "stop"}},
+			Usage:   &types.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		}, nil
+	}
+	return b
+}
+
+func TestFanOutN_MergesChoices(t *testing.T) {
+	var calls atomic.Int64
+	r, err := NewRouter(WithFanOutN(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), singleChoiceBackend("backend-a", &calls, false))
+	r.AddBackend(context.Background(), singleChoiceBackend("backend-b", &calls, false))
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 backend calls, got %d", calls.Load())
+	}
+	if len(resp.Choices) != 3 {
+		t.Fatalf("expected 3 choices, got %d", len(resp.Choices))
+	}
+	for i, c := range resp.Choices {
+		if c.Index != i {
+			t.Errorf("choice %d has index %d", i, c.Index)
+		}
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 15 {
+		t.Errorf("unexpected merged usage %+v", resp.Usage)
+	}
+}
+
+func TestFanOutN_PartialFailure(t *testing.T) {
+	var calls atomic.Int64
+
+	for _, requireAll := range []bool{false, true} {
+		r, err := NewRouter(WithFanOutN(true), WithFanOutNRequireAll(requireAll))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddBackend(context.Background(), singleChoiceBackend("backend-a", &calls, false))
+		r.AddBackend(context.Background(), singleChoiceBackend("backend-b", &calls, true))
+
+		rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":4}`)
+
+		if requireAll {
+			if rec.Code == http.StatusOK {
+				t.Error("expected an error when all choices are required")
+			}
+			continue
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp types.ChatCompletionResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if len(resp.Choices) != 2 {
+			t.Errorf("expected 2 surviving choices, got %d", len(resp.Choices))
+		}
+	}
+}
+
+func TestFanOutN_DisabledPassesThrough(t *testing.T) {
+	var calls atomic.Int64
+	r, err := NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), singleChoiceBackend("backend-a", &calls, false))
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":3}`)
+	if rec.Code == http.StatusOK {
+		t.Error("expected the n>1 request to reach the backend unchanged and fail")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a single backend call, got %d", calls.Load())
+	}
+}
+
+func TestFanOutN_RejectsOverLimit(t *testing.T) {
+	var calls atomic.Int64
+	r := newTestRouter(t, []Backend{singleChoiceBackend("backend-a", &calls, false)}, WithFanOutN(true), WithFanOutNLimit(4))
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":5}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("backend called %d times, want 0", calls.Load())
+	}
+	if rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":4}`); rec.Code != http.StatusOK {
+		t.Errorf("n at the limit: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestFanOutN_NoResponses(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, nil
+	}
+	r := newTestRouter(t, []Backend{b}, WithFanOutN(true))
+
+	if rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":2}`); rec.Code == http.StatusOK {
+		t.Errorf("status = %d, body = %s; want an error", rec.Code, rec.Body)
+	}
+}
+
+func TestStreamingN_RejectedWithoutCapability(t *testing.T) {
+	streamed := func(id string, caps ...Capability) *mockBackend {
+		b := newMockBackend(id, true)
+		b.caps = caps
+		b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+			return streamOf(`{"choices":[{"index":0,"delta":{"content":"hi"}}]}`), nil
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		backend  *mockBackend
+		body     string
+		rejected bool
+	}{
+		{"unsupported n=2", streamed("a", CapabilityChat, CapabilityStreaming), `"stream":true,"n":2`, true},
+		{"unsupported n=1", streamed("a", CapabilityChat, CapabilityStreaming), `"stream":true,"n":1`, false},
+		{"supported n=2", streamed("a", CapabilityChat, CapabilityStreaming, CapabilityStreamingN), `"stream":true,"n":2`, false},
+		{"all capabilities n=2", streamed("a"), `"stream":true,"n":2`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewRouter()
+			r.AddBackend(context.Background(), tt.backend)
+
+			rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],`+tt.body+`}`)
+			if tt.rejected {
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"param":"n"`) {
+					t.Errorf("expected 400 naming n, got %d: %s", rec.Code, rec.Body.String())
+				}
+				return
+			}
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "[DONE]") {
+				t.Errorf("expected the stream, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+)
+
+// OpenAI's headers naming the organization and project a request is billed
+// to. They are forwarded to backends by default.
+const (
+	OrganizationHeader = "OpenAI-Organization"
+	ProjectHeader      = "OpenAI-Project"
+)
+
+// DefaultForwardedHeaders are the client request headers passed on to
+// backends unless WithForwardedHeaders sets others.
+var DefaultForwardedHeaders = []string{OrganizationHeader, ProjectHeader}
+
+type forwardedHeadersKey struct{}
+
+// ContextWithForwardedHeaders returns a copy of ctx carrying headers for
+// backends to send with requests made under it. The router adds the client's
+// forwarded headers this way, so they follow the request through retries,
+// rerouting, and hedging.
+func ContextWithForwardedHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, forwardedHeadersKey{}, h)
+}
+
+// ForwardedHeaders returns the headers ctx carries for backends, or nil.
+// Callers must not modify the result.
+func ForwardedHeaders(ctx context.Context) http.Header {
+	h, _ := ctx.Value(forwardedHeadersKey{}).(http.Header)
+	return h
+}
+
+// OrgFromContext returns the OpenAI-Organization header of the client request
+// ctx belongs to, if it sent one and the header is forwarded.
+func OrgFromContext(ctx context.Context) string {
+	return ForwardedHeaders(ctx).Get(OrganizationHeader)
+}
+
+// ProjectFromContext returns the OpenAI-Project header of the client request
+// ctx belongs to, if it sent one and the header is forwarded.
+func ProjectFromContext(ctx context.Context) string {
+	return ForwardedHeaders(ctx).Get(ProjectHeader)
+}
+
+// forwardHeaders returns req with the forwarded headers it sent added to its
+// context. req is returned as is if it sent none.
+func (r *Router) forwardHeaders(req *http.Request) *http.Request {
+	var h http.Header
+	for _, name := range r.forwardedHeaders {
+		if values := req.Header.Values(name); len(values) > 0 {
+			if h == nil {
+				h = make(http.Header, len(r.forwardedHeaders))
+			}
+			h[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if h == nil {
+		return req
+	}
+	return req.WithContext(ContextWithForwardedHeaders(req.Context(), h))
+}
@@ -0,0 +1,52 @@
+package oairouter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// orgBackend records the organization and project of the requests it serves.
+func orgBackend(got *[2]string) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		got[0], got[1] = OrgFromContext(ctx), ProjectFromContext(ctx)
+		return &types.ChatCompletionResponse{ID: "chat"}, nil
+	}
+	return b
+}
+
+func TestForwardedHeaders_Default(t *testing.T) {
+	var logged RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { logged = l }))
+	var got [2]string
+	r.AddBackend(context.Background(), orgBackend(&got))
+
+	postChat(t, r, testChatBody, withHeader("OpenAI-Organization", "org-1"), withHeader("OpenAI-Project", "proj-1"))
+	if got != [2]string{"org-1", "proj-1"} {
+		t.Errorf("backend saw organization and project %q", got)
+	}
+	if logged.Organization != "org-1" || logged.Project != "proj-1" {
+		t.Errorf("logged organization %q, project %q", logged.Organization, logged.Project)
+	}
+}
+
+func TestForwardedHeaders_Configured(t *testing.T) {
+	r, _ := NewRouter(WithForwardedHeaders("OpenAI-Project"))
+	var got [2]string
+	r.AddBackend(context.Background(), orgBackend(&got))
+
+	postChat(t, r, testChatBody, withHeader("OpenAI-Organization", "org-1"), withHeader("OpenAI-Project", "proj-1"))
+	if got != [2]string{"", "proj-1"} {
+		t.Errorf("backend saw organization and project %q, want only the project", got)
+	}
+
+	r, _ = NewRouter(WithForwardedHeaders())
+	got = [2]string{}
+	r.AddBackend(context.Background(), orgBackend(&got))
+	postChat(t, r, testChatBody, withHeader("OpenAI-Organization", "org-1"), withHeader("OpenAI-Project", "proj-1"))
+	if got != [2]string{} {
+		t.Errorf("backend saw organization and project %q with forwarding disabled", got)
+	}
+}
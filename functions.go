@@ -0,0 +1,288 @@
+package oairouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// Older clients declare tools as functions and function_call, and get back a
+// message's function_call instead of its tool_calls. Chat requests are sent
+// to each backend in the form it takes: tools and tool_choice unless it only
+// supports CapabilityFunctions. Responses are returned in the form the client
+// used. Earlier calls and their results in the conversation are converted
+// too: an assistant's function_call to tool_calls and role "function"
+// results to role "tool", or back.
+
+// usesFunctions reports whether req declares its tools in the deprecated
+// form. Requests with both forms are served as tools.
+func usesFunctions(req *types.ChatCompletionRequest) bool {
+	return len(req.Tools) == 0 && (len(req.Functions) > 0 || req.FunctionCall != nil)
+}
+
+// takesFunctions reports whether b only takes tools in the deprecated form.
+func takesFunctions(b Backend) bool {
+	caps := b.Capabilities()
+	return !caps.SupportsTools && caps.SupportsFunctions
+}
+
+// checkToolSupport rejects a request declaring tools, in either form, that b
+// can take in neither form.
+func checkToolSupport(b Backend, req *types.ChatCompletionRequest) *types.RouterError {
+	if len(req.Tools) == 0 && len(req.Functions) == 0 {
+		return nil
+	}
+	if takesFunctions(b) {
+		return nil
+	}
+	return checkCapabilities(b, req.Model, []Capability{CapabilityTools}, -1)
+}
+
+// chatRequestFor returns req in the form b takes its tools. req is returned
+// as is if it needs no change, and is never modified.
+func chatRequestFor(b Backend, req *types.ChatCompletionRequest) *types.ChatCompletionRequest {
+	legacy := usesFunctions(req)
+	sameForm := takesFunctions(b) == legacy && (legacy || len(req.Functions) == 0 && req.FunctionCall == nil)
+	messages, converted := messagesFor(req.Messages, takesFunctions(b))
+	if sameForm && !converted {
+		return req
+	}
+
+	out := *req
+	out.Messages = messages
+	if sameForm {
+		return &out
+	}
+	if legacy {
+		out.Tools = make([]types.Tool, len(req.Functions))
+		for i, fn := range req.Functions {
+			out.Tools[i] = types.Tool{Type: "function", Function: fn}
+		}
+		out.ToolChoice = toolChoiceFromFunctionCall(req.FunctionCall)
+	}
+	out.Functions, out.FunctionCall = nil, nil
+
+	if takesFunctions(b) && len(out.Tools) > 0 {
+		for _, tool := range out.Tools {
+			if tool.Type == "function" {
+				out.Functions = append(out.Functions, tool.Function)
+			}
+		}
+		out.FunctionCall = functionCallFromToolChoice(out.ToolChoice)
+		out.Tools, out.ToolChoice = nil, nil
+	}
+	return &out
+}
+
+// messagesFor returns messages with earlier calls and their results in the
+// deprecated form if legacy is set, and in the tools form otherwise. It
+// reports false, returning messages as is, if none needed converting.
+func messagesFor(messages []types.ChatMessage, legacy bool) ([]types.ChatMessage, bool) {
+	var out []types.ChatMessage
+	convert := func(i int) *types.ChatMessage {
+		if out == nil {
+			out = append([]types.ChatMessage(nil), messages...)
+		}
+		return &out[i]
+	}
+
+	if legacy {
+		// A function result names its function rather than its call's ID
+		names := make(map[string]string)
+		for i, msg := range messages {
+			switch {
+			case len(msg.ToolCalls) > 0:
+				for _, call := range msg.ToolCalls {
+					names[call.ID] = call.Function.Name
+				}
+				// The deprecated form has room for one call
+				m := convert(i)
+				fn := msg.ToolCalls[0].Function
+				m.FunctionCall, m.ToolCalls = &fn, nil
+			case msg.Role == "tool":
+				m := convert(i)
+				m.Role, m.Name, m.ToolCallID = "function", names[msg.ToolCallID], ""
+			}
+		}
+	} else {
+		// Function calls have no IDs, so each is given one from its
+		// position, and its result is paired with the latest call to the
+		// same function
+		ids := make(map[string]string)
+		for i, msg := range messages {
+			switch {
+			case msg.FunctionCall != nil:
+				id := "call_" + strconv.Itoa(i)
+				ids[msg.FunctionCall.Name] = id
+				m := convert(i)
+				m.ToolCalls = []types.ToolCall{{ID: id, Type: "function", Function: *msg.FunctionCall}}
+				m.FunctionCall = nil
+			case msg.Role == "function":
+				m := convert(i)
+				m.Role, m.ToolCallID, m.Name = "tool", ids[msg.Name], ""
+			}
+		}
+	}
+
+	if out == nil {
+		return messages, false
+	}
+	return out, true
+}
+
+// toolChoiceFromFunctionCall converts a function_call to the equivalent
+// tool_choice: "none" and "auto" are kept, and {"name": ...} selects that
+// function.
+func toolChoiceFromFunctionCall(call any) any {
+	if named, ok := call.(map[string]any); ok {
+		return map[string]any{"type": "function", "function": map[string]any{"name": named["name"]}}
+	}
+	return call
+}
+
+// functionCallFromToolChoice converts a tool_choice to the equivalent
+// function_call. "required" has none, so it becomes "auto".
+func functionCallFromToolChoice(choice any) any {
+	switch choice := choice.(type) {
+	case string:
+		if choice == "required" {
+			return "auto"
+		}
+		return choice
+	case map[string]any:
+		if fn, ok := choice["function"].(map[string]any); ok {
+			return map[string]any{"name": fn["name"]}
+		}
+	}
+	return choice
+}
+
+// chatCompletion serves req on b in the form b takes its tools, returning
+// the response in the form req used.
+func chatCompletion(ctx context.Context, b Backend, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	resp, err := b.ChatCompletion(ctx, chatRequestFor(b, req))
+	if err != nil || resp == nil || usesFunctions(req) == takesFunctions(b) {
+		return resp, err
+	}
+	for i := range resp.Choices {
+		c := &resp.Choices[i]
+		if usesFunctions(req) {
+			c.FinishReason = toFunctionCall(&c.Message.ToolCalls, &c.Message.FunctionCall, c.FinishReason)
+		} else {
+			c.FinishReason = toToolCalls(&c.Message.ToolCalls, &c.Message.FunctionCall, c.FinishReason, newToolCallID())
+		}
+	}
+	return resp, nil
+}
+
+// chatCompletionStream streams req from b in the form b takes its tools,
+// rewriting the chunks to the form req used.
+func chatCompletionStream(ctx context.Context, b Backend, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+	events, err := b.ChatCompletionStream(ctx, chatRequestFor(b, req))
+	if err != nil || usesFunctions(req) == takesFunctions(b) {
+		return events, err
+	}
+
+	legacy, id := usesFunctions(req), newToolCallID()
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		for event := range events {
+			if event.Data != "" {
+				event.Data = convertChatChunk(event.Data, legacy, id)
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				go drainEvents(events)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// convertChatChunk rewrites the tool calls in a chat chunk to function calls
+// if legacy is set, and function calls to tool calls with id otherwise.
+// Chunks without either are returned unchanged.
+func convertChatChunk(data string, legacy bool, id string) string {
+	if !strings.Contains(data, `"tool_calls"`) && !strings.Contains(data, `"function_call"`) {
+		return data
+	}
+	var chunk types.ChatCompletionChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return data
+	}
+	for i := range chunk.Choices {
+		c := &chunk.Choices[i]
+		var finish string
+		if c.FinishReason != nil {
+			finish = *c.FinishReason
+		}
+		if legacy {
+			finish = toFunctionCall(&c.Delta.ToolCalls, &c.Delta.FunctionCall, finish)
+		} else {
+			callID := id
+			if c.Delta.FunctionCall != nil && c.Delta.FunctionCall.Name == "" {
+				callID = "" // Only the call's first delta carries its ID
+			}
+			finish = toToolCalls(&c.Delta.ToolCalls, &c.Delta.FunctionCall, finish, callID)
+			for j := range c.Delta.ToolCalls {
+				index := 0
+				c.Delta.ToolCalls[j].Index = &index
+			}
+		}
+		if c.FinishReason != nil {
+			c.FinishReason = &finish
+		}
+	}
+	converted, err := json.Marshal(chunk)
+	if err != nil {
+		return data
+	}
+	return string(converted)
+}
+
+// toFunctionCall moves the first of toolCalls to functionCall, as the
+// deprecated form has room for one call, and returns finishReason with
+// tool_calls renamed.
+func toFunctionCall(toolCalls *[]types.ToolCall, functionCall **types.ToolCallFunction, finishReason string) string {
+	for _, call := range *toolCalls {
+		if call.Index == nil || *call.Index == 0 {
+			fn := call.Function
+			*functionCall = &fn
+			break
+		}
+	}
+	*toolCalls = nil
+	if finishReason == "tool_calls" {
+		return "function_call"
+	}
+	return finishReason
+}
+
+// toToolCalls moves functionCall to toolCalls as a call with id, and returns
+// finishReason with function_call renamed.
+func toToolCalls(toolCalls *[]types.ToolCall, functionCall **types.ToolCallFunction, finishReason, id string) string {
+	if *functionCall != nil {
+		*toolCalls = []types.ToolCall{{ID: id, Type: "function", Function: **functionCall}}
+		*functionCall = nil
+	}
+	if finishReason == "function_call" {
+		return "tool_calls"
+	}
+	return finishReason
+}
+
+// newToolCallID returns a random ID for a tool call converted from a
+// function call, which has none.
+func newToolCallID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
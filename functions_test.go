@@ -0,0 +1,267 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+const (
+	legacyChatBody = `{"model":"test-model","messages":[{"role":"user","content":"weather?"}],` +
+		`"functions":[{"name":"get_weather","parameters":{"type":"object"}}],"function_call":{"name":"get_weather"}}`
+	toolsChatBody = `{"model":"test-model","messages":[{"role":"user","content":"weather?"}],` +
+		`"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}],` +
+		`"tool_choice":{"type":"function","function":{"name":"get_weather"}}}`
+)
+
+var weatherCall = types.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}
+
+// functionsBackend records the chat request it receives and answers with a
+// call to get_weather, in the deprecated form if legacy is set.
+func functionsBackend(legacy bool, got **types.ChatCompletionRequest) *mockBackend {
+	b := newMockBackend("a", true)
+	b.caps = []Capability{CapabilityChat, CapabilityStreaming, CapabilityTools}
+	if legacy {
+		b.caps = []Capability{CapabilityChat, CapabilityStreaming, CapabilityFunctions}
+	}
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		*got = req
+		msg := types.ChatMessage{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "call_1", Type: "function", Function: weatherCall}}}
+		finish := "tool_calls"
+		if legacy {
+			fn := weatherCall
+			msg = types.ChatMessage{Role: "assistant", FunctionCall: &fn}
+			finish = "function_call"
+		}
+		return &types.ChatCompletionResponse{ID: "a", Choices: []types.Choice{{Message: msg, FinishReason: finish}}}, nil
+	}
+	return b
+}
+
+func decodeChatResponse(t *testing.T, body string) types.ChatCompletionResponse {
+	t.Helper()
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || len(resp.Choices) != 1 {
+		t.Fatalf("response %s: %v", body, err)
+	}
+	return resp
+}
+
+func TestFunctions_LegacyClientToToolsBackend(t *testing.T) {
+	r, _ := NewRouter()
+	var got *types.ChatCompletionRequest
+	r.AddBackend(context.Background(), functionsBackend(false, &got))
+
+	rec := postChat(t, r, legacyChatBody)
+	if got == nil {
+		t.Fatalf("request not forwarded: %d %s", rec.Code, rec.Body)
+	}
+	if len(got.Functions) != 0 || got.FunctionCall != nil {
+		t.Errorf("backend got functions %+v, function_call %v", got.Functions, got.FunctionCall)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("backend got tools %+v", got.Tools)
+	}
+	wantChoice := map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}
+	if !reflect.DeepEqual(got.ToolChoice, wantChoice) {
+		t.Errorf("backend got tool_choice %v, want %v", got.ToolChoice, wantChoice)
+	}
+
+	choice := decodeChatResponse(t, rec.Body.String()).Choices[0]
+	if choice.FinishReason != "function_call" || len(choice.Message.ToolCalls) != 0 ||
+		choice.Message.FunctionCall == nil || *choice.Message.FunctionCall != weatherCall {
+		t.Errorf("client got %s", rec.Body)
+	}
+}
+
+func TestFunctions_ToolsClientToLegacyBackend(t *testing.T) {
+	r, _ := NewRouter()
+	var got *types.ChatCompletionRequest
+	r.AddBackend(context.Background(), functionsBackend(true, &got))
+
+	rec := postChat(t, r, toolsChatBody)
+	if got == nil {
+		t.Fatalf("request not forwarded: %d %s", rec.Code, rec.Body)
+	}
+	if len(got.Tools) != 0 || got.ToolChoice != nil {
+		t.Errorf("backend got tools %+v, tool_choice %v", got.Tools, got.ToolChoice)
+	}
+	if len(got.Functions) != 1 || got.Functions[0].Name != "get_weather" {
+		t.Errorf("backend got functions %+v", got.Functions)
+	}
+	if want := map[string]any{"name": "get_weather"}; !reflect.DeepEqual(got.FunctionCall, want) {
+		t.Errorf("backend got function_call %v, want %v", got.FunctionCall, want)
+	}
+
+	choice := decodeChatResponse(t, rec.Body.String()).Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.FunctionCall != nil || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("client got %s", rec.Body)
+	}
+	if call := choice.Message.ToolCalls[0]; !strings.HasPrefix(call.ID, "call_") || call.Type != "function" || call.Function != weatherCall {
+		t.Errorf("tool call = %+v", call)
+	}
+}
+
+// secondTurn returns the chat request a client sends after the first turn
+// of body was answered with reply, adding the call's result.
+func secondTurn(t *testing.T, body, reply string, result types.ChatMessage) string {
+	t.Helper()
+	var req types.ChatCompletionRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	req.Messages = append(req.Messages, decodeChatResponse(t, reply).Choices[0].Message, result)
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFunctions_LegacyHistoryToToolsBackend(t *testing.T) {
+	var got *types.ChatCompletionRequest
+	r := newTestRouter(t, []Backend{functionsBackend(false, &got)})
+
+	first := postChat(t, r, legacyChatBody)
+	postChat(t, r, secondTurn(t, legacyChatBody, first.Body.String(),
+		types.ChatMessage{Role: "function", Name: "get_weather", Content: "sunny"}))
+	if got == nil || len(got.Messages) != 3 {
+		t.Fatalf("backend got %+v", got)
+	}
+	call, result := got.Messages[1], got.Messages[2]
+	if call.FunctionCall != nil || len(call.ToolCalls) != 1 || call.ToolCalls[0].ID == "" || call.ToolCalls[0].Function != weatherCall {
+		t.Errorf("backend got call %+v", call)
+	}
+	if result.Role != "tool" || result.Name != "" || len(call.ToolCalls) != 1 || result.ToolCallID != call.ToolCalls[0].ID {
+		t.Errorf("backend got result %+v for call %+v", result, call)
+	}
+}
+
+func TestFunctions_ToolsHistoryToLegacyBackend(t *testing.T) {
+	var got *types.ChatCompletionRequest
+	r := newTestRouter(t, []Backend{functionsBackend(true, &got)})
+
+	first := postChat(t, r, toolsChatBody)
+	callID := decodeChatResponse(t, first.Body.String()).Choices[0].Message.ToolCalls[0].ID
+	postChat(t, r, secondTurn(t, toolsChatBody, first.Body.String(),
+		types.ChatMessage{Role: "tool", ToolCallID: callID, Content: "sunny"}))
+	if got == nil || len(got.Messages) != 3 {
+		t.Fatalf("backend got %+v", got)
+	}
+	call, result := got.Messages[1], got.Messages[2]
+	if len(call.ToolCalls) != 0 || call.FunctionCall == nil || *call.FunctionCall != weatherCall {
+		t.Errorf("backend got call %+v", call)
+	}
+	if result.Role != "function" || result.Name != "get_weather" || result.ToolCallID != "" {
+		t.Errorf("backend got result %+v", result)
+	}
+}
+
+func TestFunctions_SameFormPassedThrough(t *testing.T) {
+	r, _ := NewRouter()
+	var got *types.ChatCompletionRequest
+	r.AddBackend(context.Background(), functionsBackend(true, &got))
+
+	rec := postChat(t, r, legacyChatBody)
+	if got == nil || len(got.Functions) != 1 || len(got.Tools) != 0 {
+		t.Fatalf("backend got %+v", got)
+	}
+	if choice := decodeChatResponse(t, rec.Body.String()).Choices[0]; choice.FinishReason != "function_call" || choice.Message.FunctionCall == nil {
+		t.Errorf("client got %s", rec.Body)
+	}
+}
+
+func TestFunctions_ChoiceConversion(t *testing.T) {
+	tests := []struct {
+		toolChoice, functionCall any
+	}{
+		{"auto", "auto"},
+		{"none", "none"},
+		{map[string]any{"type": "function", "function": map[string]any{"name": "f"}}, map[string]any{"name": "f"}},
+	}
+	for _, tt := range tests {
+		if got := functionCallFromToolChoice(tt.toolChoice); !reflect.DeepEqual(got, tt.functionCall) {
+			t.Errorf("functionCallFromToolChoice(%v) = %v, want %v", tt.toolChoice, got, tt.functionCall)
+		}
+		if got := toolChoiceFromFunctionCall(tt.functionCall); !reflect.DeepEqual(got, tt.toolChoice) {
+			t.Errorf("toolChoiceFromFunctionCall(%v) = %v, want %v", tt.functionCall, got, tt.toolChoice)
+		}
+	}
+	if got := functionCallFromToolChoice("required"); got != "auto" {
+		t.Errorf(`functionCallFromToolChoice("required") = %v, want "auto"`, got)
+	}
+}
+
+func TestFunctions_StreamToLegacyClient(t *testing.T) {
+	r, _ := NewRouter()
+	b := newMockBackend("a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]},"finish_reason":null}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		), nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, strings.Replace(legacyChatBody, `{"model"`, `{"stream":true,"model"`, 1))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`"function_call":{"name":"get_weather","arguments":""}`,
+		`"function_call":{"name":"","arguments":"{}"}`,
+		`"finish_reason":"function_call"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream lacks %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `"tool_calls"`) {
+		t.Errorf("stream has tool_calls:\n%s", body)
+	}
+}
+
+func TestFunctions_StreamFromLegacyBackend(t *testing.T) {
+	r, _ := NewRouter()
+	b := newMockBackend("a", true)
+	b.caps = []Capability{CapabilityChat, CapabilityStreaming, CapabilityFunctions}
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","function_call":{"name":"get_weather","arguments":""}},"finish_reason":null}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"function_call":{"arguments":"{}"}},"finish_reason":null}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"function_call"}]}`,
+		), nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, strings.Replace(toolsChatBody, `{"model"`, `{"stream":true,"model"`, 1))
+	var calls []types.ToolCall
+	var finish string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("chunk %s: %v", data, err)
+		}
+		calls = append(calls, chunk.Choices[0].Delta.ToolCalls...)
+		if chunk.Choices[0].Delta.FunctionCall != nil {
+			t.Errorf("chunk has function_call: %s", data)
+		}
+		if f := chunk.Choices[0].FinishReason; f != nil {
+			finish = *f
+		}
+	}
+	if len(calls) != 2 || !strings.HasPrefix(calls[0].ID, "call_") || calls[0].Function.Name != "get_weather" ||
+		calls[1].ID != "" || calls[1].Function.Arguments != "{}" || *calls[1].Index != 0 {
+		t.Errorf("tool call deltas = %+v", calls)
+	}
+	if finish != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", finish)
+	}
+}
@@ -2,11 +2,17 @@ module github.com/stevemurr/oairouter
 
 go 1.22
 
-require github.com/docker/docker v27.4.1+incompatible
+require (
+	github.com/docker/docker v27.4.1+incompatible
+	github.com/redis/go-redis/v9 v9.7.3
+	golang.org/x/sync v0.10.0
+)
 
 require (
 	github.com/Microsoft/go-winio v0.4.14 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/containerd/log v0.1.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/distribution/reference v0.6.0 // indirect
 	github.com/docker/go-connections v0.5.0 // indirect
 	github.com/docker/go-units v0.5.0 // indirect
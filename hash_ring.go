@@ -0,0 +1,111 @@
+package oairouter
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// hashRingReplicas is the number of points each backend gets on the
+	// ring. More points spread sessions more evenly between backends.
+	hashRingReplicas = 160
+
+	// maxCachedRings bounds the ring cache; it is emptied when full, since
+	// rings for backend sets that no longer exist are never used again.
+	maxCachedRings = 64
+)
+
+// hashRing maps keys to members by consistent hashing: each member owns the
+// arcs of the ring ending at its points, so adding or removing one of N
+// members moves only about 1/N of the keys.
+type hashRing struct {
+	points  []uint64 // Sorted
+	owners  []int    // owners[i] is the index in members of points[i]
+	members []string
+}
+
+func newHashRing(members []string) *hashRing {
+	h := &hashRing{members: members}
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	points := make([]point, 0, len(members)*hashRingReplicas)
+	for i, m := range members {
+		for v := range hashRingReplicas {
+			points = append(points, point{ringHash(m + "#" + strconv.Itoa(v)), i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	h.points = make([]uint64, len(points))
+	h.owners = make([]int, len(points))
+	for i, p := range points {
+		h.points[i], h.owners[i] = p.hash, p.owner
+	}
+	return h
+}
+
+// walk calls visit with each member's index in ring order from key's
+// position, each member once, until visit returns false.
+func (h *hashRing) walk(key string, visit func(member int) bool) {
+	if len(h.points) == 0 {
+		return
+	}
+	hash := ringHash(key)
+	start := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= hash })
+
+	seen := make([]bool, len(h.members))
+	remaining := len(h.members)
+	for i := 0; i < len(h.points) && remaining > 0; i++ {
+		owner := h.owners[(start+i)%len(h.points)]
+		if seen[owner] {
+			continue
+		}
+		seen[owner] = true
+		remaining--
+		if !visit(owner) {
+			return
+		}
+	}
+}
+
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// FNV alone clusters similar strings such as "backend-a#1" and
+	// "backend-a#2"; a final mix spreads them around the ring.
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// ringCache reuses the rings built for each set of backends.
+type ringCache struct {
+	mu    sync.Mutex
+	rings map[string]*hashRing // sorted member IDs joined by NUL -> ring
+}
+
+// get returns the ring for members, which must be sorted.
+func (c *ringCache) get(members []string) *hashRing {
+	key := strings.Join(members, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ring, ok := c.rings[key]; ok {
+		return ring
+	}
+	if c.rings == nil || len(c.rings) >= maxCachedRings {
+		c.rings = make(map[string]*hashRing)
+	}
+	ring := newHashRing(members)
+	c.rings[key] = ring
+	return ring
+}
@@ -0,0 +1,59 @@
+package oairouter
+
+import (
+	"context"
+	"time"
+)
+
+// healthCheckState tracks consecutive failures and the next due check for
+// each unhealthy backend. Healthy backends are checked every interval.
+type healthCheckState struct {
+	failures map[string]int
+	next     map[string]time.Time
+}
+
+func newHealthCheckState() *healthCheckState {
+	return &healthCheckState{
+		failures: make(map[string]int),
+		next:     make(map[string]time.Time),
+	}
+}
+
+// runHealthChecks checks every backend that is due at now. A backend that
+// fails is next checked after an exponentially growing delay, starting at
+// the health check interval and bounded by the backoff's Max, so dead
+// backends aren't hammered; a success returns it to the normal interval.
+func (r *Router) runHealthChecks(ctx context.Context, state *healthCheckState, now time.Time) {
+	backoff := r.healthCheckBackoff
+	if backoff.Initial <= 0 {
+		backoff.Initial = r.healthCheckInterval
+	}
+
+	current := make(map[string]bool)
+	for _, b := range r.registry.AllBackends() {
+		id := b.ID()
+		current[id] = true
+		if now.Before(state.next[id]) {
+			continue
+		}
+
+		if err := b.HealthCheck(ctx); err != nil {
+			n := state.failures[id]
+			delay := backoff.Delay(n)
+			state.failures[id] = n + 1
+			state.next[id] = now.Add(delay)
+			r.logger.Debug("health check failed", "backend", id, "error", err, "failures", n+1, "next_check", delay)
+			continue
+		}
+		delete(state.failures, id)
+		delete(state.next, id)
+	}
+
+	// Forget removed backends
+	for id := range state.failures {
+		if !current[id] {
+			delete(state.failures, id)
+			delete(state.next, id)
+		}
+	}
+}
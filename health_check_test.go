@@ -0,0 +1,78 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// checkedBackend counts health checks and fails them while down is set.
+type checkedBackend struct {
+	*mockBackend
+	checks atomic.Int64
+	down   atomic.Bool
+}
+
+func (b *checkedBackend) HealthCheck(ctx context.Context) error {
+	b.checks.Add(1)
+	if b.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestHealthChecks_BackOffUnhealthyBackends(t *testing.T) {
+	interval := 10 * time.Second
+	r, _ := NewRouter(
+		WithHealthCheckInterval(interval),
+		WithHealthCheckBackoff(Backoff{Max: 40 * time.Second}),
+	)
+	up := &checkedBackend{mockBackend: newMockBackend("backend-up", true)}
+	dead := &checkedBackend{mockBackend: newMockBackend("backend-dead", true)}
+	dead.down.Store(true)
+	r.AddBackend(context.Background(), up)
+	r.AddBackend(context.Background(), dead)
+
+	// Tick every interval for 150s: the dead backend is checked at 0, then
+	// after delays of 10s, 20s, 40s, 40s (capped)
+	state := newHealthCheckState()
+	start := time.Now()
+	for tick := 0; tick <= 15; tick++ {
+		r.runHealthChecks(context.Background(), state, start.Add(time.Duration(tick)*interval))
+	}
+
+	if n := up.checks.Load(); n != 16 {
+		t.Errorf("healthy backend checked %d times, want 16", n)
+	}
+	// Checks at 0, 10, 30, 70, 110, 150
+	if n := dead.checks.Load(); n != 6 {
+		t.Errorf("dead backend checked %d times, want 6", n)
+	}
+
+	// Once it recovers, the backend returns to the normal interval
+	dead.down.Store(false)
+	now := start.Add(190 * time.Second)
+	r.runHealthChecks(context.Background(), state, now)
+	r.runHealthChecks(context.Background(), state, now.Add(interval))
+	if n := dead.checks.Load(); n != 8 {
+		t.Errorf("recovered backend checked %d times, want 8", n)
+	}
+}
+
+func TestHealthChecks_ForgetsRemovedBackends(t *testing.T) {
+	r, _ := NewRouter()
+	dead := &checkedBackend{mockBackend: newMockBackend("backend-dead", true)}
+	dead.down.Store(true)
+	r.AddBackend(context.Background(), dead)
+
+	state := newHealthCheckState()
+	r.runHealthChecks(context.Background(), state, time.Now())
+	r.RemoveBackend("backend-dead")
+	r.runHealthChecks(context.Background(), state, time.Now())
+
+	if len(state.failures) != 0 || len(state.next) != 0 {
+		t.Errorf("state kept removed backend: %+v", state)
+	}
+}
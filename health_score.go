@@ -0,0 +1,212 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// HealthScorer is optionally implemented by backends that compute their own
+// health score. Scores range from 0 (unusable) to 1 (fully healthy).
+type HealthScorer interface {
+	HealthScore() float64
+}
+
+const (
+	// healthWindow is the number of recent requests used for error rate and latency.
+	healthWindow = 64
+
+	// healthLatencyRef is the p95 latency that halves the latency signal.
+	healthLatencyRef = time.Second
+
+	// healthSaturationRef is the in-flight count that halves the saturation signal.
+	healthSaturationRef = 8
+
+	// minSelectionWeight keeps low-scoring backends selectable occasionally,
+	// so their score can recover.
+	minSelectionWeight = 0.01
+)
+
+// Weights of each signal in the blended health score; they sum to 1.
+const (
+	healthWeightErrors     = 0.4
+	healthWeightLatency    = 0.2
+	healthWeightSaturation = 0.2
+	healthWeightProbe      = 0.2
+)
+
+// healthStats is a ring buffer of a backend's recent request outcomes.
+type healthStats struct {
+	mu        sync.Mutex
+	latencies [healthWindow]time.Duration
+	failed    [healthWindow]bool
+	n, next   int
+
+	lastFailure time.Time // When the last failed request was recorded
+}
+
+func (s *healthStats) record(latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latencies[s.next] = latency
+	s.failed[s.next] = failed
+	if failed {
+		s.lastFailure = time.Now()
+	}
+	s.next = (s.next + 1) % healthWindow
+	if s.n < healthWindow {
+		s.n++
+	}
+}
+
+// lastFailed returns when the last failed request was recorded, or the zero
+// time if none has failed.
+func (s *healthStats) lastFailed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastFailure
+}
+
+// snapshot returns the error rate and p95 latency of the recorded requests.
+func (s *healthStats) snapshot() (errorRate float64, p95 time.Duration, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.n == 0 {
+		return 0, 0, 0
+	}
+
+	failures := 0
+	latencies := make([]time.Duration, s.n)
+	for i := 0; i < s.n; i++ {
+		latencies[i] = s.latencies[i]
+		if s.failed[i] {
+			failures++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	idx := int(math.Ceil(0.95*float64(s.n))) - 1
+	return float64(failures) / float64(s.n), latencies[idx], s.n
+}
+
+// RecordOutcome records the latency and result of a request to a backend for
+// health scoring and latency-based selection. Client errors (4xx) describe
+// the request rather than the backend and are not recorded; server errors,
+// timeouts, and transport failures count as failed requests.
+func (r *BackendRegistry) RecordOutcome(backendID string, latency time.Duration, err error) {
+	if err != nil && !isBackendFailure(err) {
+		return
+	}
+	r.healthStatsFor(backendID).record(latency, err != nil)
+	if err == nil {
+		r.recordLatency(backendID, latency)
+	}
+}
+
+// isBackendFailure reports whether err reflects on the backend's health: a
+// 5xx response, a timeout, or a transport error.
+func isBackendFailure(err error) bool {
+	if isQueueError(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var httpErr *BackendHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	var routerErr *types.RouterError
+	if errors.As(err, &routerErr) {
+		return routerErr.StatusCode >= 500
+	}
+	return true
+}
+
+func (r *BackendRegistry) healthStatsFor(backendID string) *healthStats {
+	if stats, ok := r.health.Load(backendID); ok {
+		return stats.(*healthStats)
+	}
+	stats, _ := r.health.LoadOrStore(backendID, new(healthStats))
+	return stats.(*healthStats)
+}
+
+// HealthScore returns a backend's health score between 0 and 1, or 0 if the
+// backend isn't registered. Backends implementing HealthScorer report their
+// own score; otherwise the score blends the recent error rate, p95 latency,
+// in-flight saturation, and the last health check result.
+func (r *BackendRegistry) HealthScore(backendID string) float64 {
+	b, ok := r.LookupByID(backendID)
+	if !ok {
+		return 0
+	}
+	return r.healthScore(b)
+}
+
+func (r *BackendRegistry) healthScore(b Backend) float64 {
+	if scorer, ok := b.(HealthScorer); ok {
+		return math.Max(0, math.Min(1, scorer.HealthScore()))
+	}
+
+	errorRate, p95, n := r.healthStatsFor(b.ID()).snapshot()
+
+	errorScore, latencyScore := 1.0, 1.0
+	if n > 0 {
+		errorScore = 1 - errorRate
+		latencyScore = float64(healthLatencyRef) / float64(healthLatencyRef+p95)
+	}
+	saturationScore := healthSaturationRef / (healthSaturationRef + float64(r.InFlight(b.ID())))
+	probeScore := 0.0
+	if r.isHealthy(b) {
+		probeScore = 1
+	}
+
+	return healthWeightErrors*errorScore +
+		healthWeightLatency*latencyScore +
+		healthWeightSaturation*saturationScore +
+		healthWeightProbe*probeScore
+}
+
+// LookupByModelWeighted picks a healthy backend serving a model at random,
+// weighted by health score, so better backends get proportionally more
+// traffic without excluding the rest. It falls back to LookupByModel when no
+// serving backend is healthy.
+func (r *BackendRegistry) LookupByModelWeighted(modelID string) (Backend, bool) {
+	return r.LookupByModelWeightedForOp(modelID, OperationAny)
+}
+
+// LookupByModelWeightedForOp is LookupByModelWeighted restricted to backends
+// that can perform op.
+func (r *BackendRegistry) LookupByModelWeightedForOp(modelID string, op Operation) (Backend, bool) {
+	healthy := r.preferTier(r.HealthyBackendsForModelOp(modelID, op))
+	if len(healthy) == 0 {
+		return r.LookupByModelForOp(modelID, op)
+	}
+	if len(healthy) == 1 {
+		return healthy[0], true
+	}
+
+	weights := make([]float64, len(healthy))
+	total := 0.0
+	for i, b := range healthy {
+		weights[i] = math.Max(r.healthScore(b), minSelectionWeight)
+		total += weights[i]
+	}
+
+	pick := rand.Float64() * total
+	for i, w := range weights {
+		if pick < w {
+			return healthy[i], true
+		}
+		pick -= w
+	}
+	return healthy[len(healthy)-1], true
+}
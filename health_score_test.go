@@ -0,0 +1,164 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+type fixedScoreBackend struct {
+	*mockBackend
+	score float64
+}
+
+func (b *fixedScoreBackend) HealthScore() float64 { return b.score }
+
+func TestHealthScore_DegradedScoresLower(t *testing.T) {
+	reg := NewBackendRegistry()
+	reg.Register(context.Background(), newMockBackend("clean", true))
+	reg.Register(context.Background(), newMockBackend("degraded", true))
+
+	for i := 0; i < 20; i++ {
+		reg.RecordOutcome("clean", 50*time.Millisecond, nil)
+
+		var err error
+		if i%2 == 0 {
+			err = errors.New("upstream error")
+		}
+		reg.RecordOutcome("degraded", 3*time.Second, err)
+	}
+
+	clean, degraded := reg.HealthScore("clean"), reg.HealthScore("degraded")
+	if degraded >= clean {
+		t.Errorf("degraded score %.3f >= clean score %.3f", degraded, clean)
+	}
+	if clean < 0.9 || clean > 1 {
+		t.Errorf("clean score = %.3f, want close to 1", clean)
+	}
+	if degraded < 0 || degraded > 0.7 {
+		t.Errorf("degraded score = %.3f, want well below 1", degraded)
+	}
+}
+
+func TestHealthScore_Signals(t *testing.T) {
+	reg := NewBackendRegistry()
+	reg.Register(context.Background(), newMockBackend("b", true))
+
+	if got := reg.HealthScore("b"); got != 1 {
+		t.Errorf("unused healthy backend score = %.3f, want 1", got)
+	}
+
+	// Saturation lowers the score
+	release := reg.Acquire("b")
+	loaded := reg.HealthScore("b")
+	release()
+	if loaded >= 1 {
+		t.Errorf("score with in-flight request = %.3f, want < 1", loaded)
+	}
+
+	// A failed health check lowers the score
+	b, _ := reg.LookupByID("b")
+	b.(*mockBackend).SetHealthy(false)
+	if got := reg.HealthScore("b"); got > 1-healthWeightProbe {
+		t.Errorf("unhealthy backend score = %.3f, want <= %.3f", got, 1-healthWeightProbe)
+	}
+
+	if got := reg.HealthScore("missing"); got != 0 {
+		t.Errorf("unregistered backend score = %.3f, want 0", got)
+	}
+}
+
+func TestHealthScore_BackendOverride(t *testing.T) {
+	reg := NewBackendRegistry()
+	reg.Register(context.Background(), &fixedScoreBackend{mockBackend: newMockBackend("custom", true), score: 0.25})
+
+	if got := reg.HealthScore("custom"); got != 0.25 {
+		t.Errorf("score = %.3f, want the backend's own 0.25", got)
+	}
+}
+
+func TestLookupByModelWeighted_PrefersHigherScore(t *testing.T) {
+	reg := NewBackendRegistry()
+	reg.Register(context.Background(), &fixedScoreBackend{mockBackend: newMockBackend("good", true), score: 0.9})
+	reg.Register(context.Background(), &fixedScoreBackend{mockBackend: newMockBackend("poor", true), score: 0.1})
+
+	picks := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		b, ok := reg.LookupByModelWeighted("test-model")
+		if !ok {
+			t.Fatal("no backend selected")
+		}
+		picks[b.ID()]++
+	}
+
+	// Soft weighting: the poor backend still gets some traffic (~10%)
+	if picks["good"] < 1600 || picks["poor"] == 0 {
+		t.Errorf("picks = %v, want ~90%% good with some poor", picks)
+	}
+}
+
+func TestHealthEndpoint_Scores(t *testing.T) {
+	r, _ := NewRouter(WithHealthScoring(true))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var status struct {
+		HealthScores map[string]float64 `json:"health_scores"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if score, ok := status.HealthScores["backend-a"]; !ok || score != 1 {
+		t.Errorf("health_scores = %v, want backend-a: 1", status.HealthScores)
+	}
+}
+
+func TestRouter_RecordsOutcomes(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, errors.New("boom")
+	}
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, testChatBody)
+
+	errorRate, _, n := r.registry.healthStatsFor("backend-a").snapshot()
+	if n != 1 || errorRate != 1 {
+		t.Errorf("recorded %d outcomes with error rate %.2f, want 1 failed outcome", n, errorRate)
+	}
+}
+
+func TestRecordOutcome_IgnoresClientErrors(t *testing.T) {
+	reg := NewBackendRegistry()
+	reg.Register(context.Background(), newMockBackend("b", true))
+
+	clientErr := &BackendHTTPError{Op: "chat completion", StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+	for i := 0; i < 10; i++ {
+		reg.RecordOutcome("b", 10*time.Millisecond, clientErr)
+		reg.RecordOutcome("b", 10*time.Millisecond, types.NewRouterError(http.StatusUnprocessableEntity, types.InvalidRequestError("bad"), nil))
+	}
+	if _, _, n := reg.healthStatsFor("b").snapshot(); n != 0 {
+		t.Errorf("recorded %d outcomes for client errors, want 0", n)
+	}
+
+	failures := []error{
+		&BackendHTTPError{Op: "chat completion", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+		context.DeadlineExceeded,
+		errors.New("connection refused"),
+	}
+	for _, err := range failures {
+		reg.RecordOutcome("b", 10*time.Millisecond, err)
+	}
+	if errorRate, _, n := reg.healthStatsFor("b").snapshot(); n != len(failures) || errorRate != 1 {
+		t.Errorf("recorded %d outcomes with error rate %.2f, want %d failed outcomes", n, errorRate, len(failures))
+	}
+}
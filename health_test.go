@@ -0,0 +1,58 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// failingHealthBackend reports a fixed health check error.
+type failingHealthBackend struct {
+	*mockBackend
+	lastErr string
+}
+
+func (b *failingHealthBackend) LastHealthError() string { return b.lastErr }
+
+func getHealth(t *testing.T, r *Router, target string) map[string]json.RawMessage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestHealth_Verbose(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+	r.AddBackend(context.Background(), &failingHealthBackend{
+		mockBackend: newMockBackend("backend-b", false),
+		lastErr:     "health check failed: 503 Service Unavailable - loading model",
+	})
+
+	if _, ok := getHealth(t, r, "/health")["backends"]; ok {
+		t.Error("default /health should not list backends")
+	}
+
+	var backends []backendHealth
+	if err := json.Unmarshal(getHealth(t, r, "/health?verbose=true")["backends"], &backends); err != nil {
+		t.Fatal(err)
+	}
+	want := []backendHealth{
+		{ID: "backend-a", Type: BackendGeneric, Healthy: true},
+		{ID: "backend-b", Type: BackendGeneric, Healthy: false, LastError: "health check failed: 503 Service Unavailable - loading model"},
+	}
+	if len(backends) != len(want) {
+		t.Fatalf("backends = %+v", backends)
+	}
+	for i := range want {
+		if backends[i] != want[i] {
+			t.Errorf("backends[%d] = %+v, want %+v", i, backends[i], want[i])
+		}
+	}
+}
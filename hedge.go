@@ -0,0 +1,111 @@
+package oairouter
+
+import (
+	"context"
+	"time"
+)
+
+// ServedByTypeHeader is set on hedged responses to the type of the backend
+// that produced the response.
+const ServedByTypeHeader = "X-Served-By-Type"
+
+// reliabilityHedge races a preferred backend type against a fallback type.
+type reliabilityHedge struct {
+	primary  BackendType
+	fallback BackendType
+	delay    time.Duration
+}
+
+// hedgeBackends returns the primary-type backend to send a request to first
+// and the fallback-type backend to race against it, among those that can
+// perform op. The selected backend is
+// kept as primary when it has the primary type. fallback is nil when the
+// model has no healthy backend of both types, in which case the request
+// shouldn't be hedged.
+func (r *Router) hedgeBackends(model string, op Operation, selected Backend) (primary, fallback Backend) {
+	h := r.reliabilityHedge
+
+	primary = selected
+	for _, b := range r.registry.HealthyBackendsForModelOp(model, op) {
+		if primary.Type() != h.primary && b.Type() == h.primary {
+			primary = b
+		}
+		if fallback == nil && b.Type() == h.fallback {
+			fallback = b
+		}
+	}
+
+	if primary.Type() != h.primary {
+		return selected, nil
+	}
+	return primary, fallback
+}
+
+// hedgeExecute sends primaryReq to primary and, if it hasn't succeeded within
+// the hedge delay, fallbackReq to fallback as well. The first successful response wins and the
+// other request is canceled. A primary failure starts the fallback at once.
+// The fallback takes its slot through the request queue. It returns the
+// backend that served the response, or the first error if both fail.
+func hedgeExecute[Req any, Resp any](r *Router, ctx context.Context, primary, fallback Backend, primaryReq, fallbackReq *Req, execute func(Backend, context.Context, *Req) (*Resp, error)) (*Resp, Backend, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp    *Resp
+		backend Backend
+		err     error
+	}
+	results := make(chan result, 2)
+
+	// The primary's in-flight slot is held by the caller.
+	go func() {
+		resp, err := execute(primary, ctx, primaryReq)
+		results <- result{resp, primary, err}
+	}()
+
+	pending := 1
+	fallbackStarted := false
+	startFallback := func() {
+		fallbackStarted = true
+		pending++
+		go func() {
+			release, err := r.acquireSlot(ctx, fallback)
+			if err != nil {
+				results <- result{nil, fallback, err}
+				return
+			}
+			defer release()
+			resp, err := execute(fallback, ctx, fallbackReq)
+			results <- result{resp, fallback, err}
+		}()
+	}
+
+	timer := time.NewTimer(r.reliabilityHedge.delay)
+	defer timer.Stop()
+
+	var firstErr *result
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				startFallback()
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.resp, res.backend, nil
+			}
+			if firstErr == nil || isQueueError(firstErr.err) {
+				// A fallback that got no slot says less than a backend's error
+				firstErr = &res
+			}
+			if !fallbackStarted {
+				startFallback()
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr.backend, firstErr.err
+			}
+		}
+	}
+}
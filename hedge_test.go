@@ -0,0 +1,136 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// hedgeBackend returns a backend of type typ that answers with its own ID
+// after latency, or fails when err is set.
+func hedgeBackend(id string, typ BackendType, latency time.Duration, err error) (*mockBackend, *atomic.Bool) {
+	canceled := new(atomic.Bool)
+	b := newMockBackend(id, true)
+	b.typ = typ
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			canceled.Store(true)
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &types.ChatCompletionResponse{ID: id}, nil
+	}
+	return b, canceled
+}
+
+// hedgeOption hedges requests from Ollama to generic backends after 20ms.
+var hedgeOption = WithReliabilityHedge(BackendOllama, BackendGeneric, 20*time.Millisecond)
+
+func servedID(t *testing.T, body []byte) string {
+	t.Helper()
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, body)
+	}
+	return resp.ID
+}
+
+func TestReliabilityHedge_FastFallbackWins(t *testing.T) {
+	local, localCanceled := hedgeBackend("local", BackendOllama, 2*time.Second, nil)
+	cloud, _ := hedgeBackend("cloud", BackendGeneric, 0, nil)
+	r := newTestRouter(t, []Backend{local, cloud}, hedgeOption)
+
+	start := time.Now()
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged request took %v, should not wait for the slow primary", elapsed)
+	}
+	if id := servedID(t, rec.Body.Bytes()); id != "cloud" {
+		t.Errorf("served by %q, want cloud", id)
+	}
+	if got := rec.Header().Get(ServedByTypeHeader); got != string(BackendGeneric) {
+		t.Errorf("%s = %q, want %q", ServedByTypeHeader, got, BackendGeneric)
+	}
+
+	// The losing primary request is canceled.
+	deadline := time.Now().Add(time.Second)
+	for !localCanceled.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !localCanceled.Load() {
+		t.Error("slow primary request was not canceled")
+	}
+}
+
+func TestReliabilityHedge_PrimaryWinsWithinDelay(t *testing.T) {
+	local, _ := hedgeBackend("local", BackendOllama, 0, nil)
+	cloud, _ := hedgeBackend("cloud", BackendGeneric, 0, nil)
+	var cloudCalls atomic.Int64
+	cloudFn := cloud.chatFn
+	cloud.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		cloudCalls.Add(1)
+		return cloudFn(ctx, req)
+	}
+	r := newTestRouter(t, []Backend{cloud, local}, hedgeOption)
+
+	rec := postChat(t, r, testChatBody)
+	if id := servedID(t, rec.Body.Bytes()); id != "local" {
+		t.Errorf("served by %q, want local", id)
+	}
+	if got := rec.Header().Get(ServedByTypeHeader); got != string(BackendOllama) {
+		t.Errorf("%s = %q, want %q", ServedByTypeHeader, got, BackendOllama)
+	}
+	if n := cloudCalls.Load(); n != 0 {
+		t.Errorf("fallback called %d times, want 0", n)
+	}
+}
+
+func TestReliabilityHedge_PrimaryErrorStartsFallback(t *testing.T) {
+	local, _ := hedgeBackend("local", BackendOllama, 0, errors.New("connection refused"))
+	cloud, _ := hedgeBackend("cloud", BackendGeneric, 0, nil)
+	r, err := NewRouter(WithReliabilityHedge(BackendOllama, BackendGeneric, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), local)
+	r.AddBackend(context.Background(), cloud)
+
+	rec := postChat(t, r, testChatBody)
+	if id := servedID(t, rec.Body.Bytes()); id != "cloud" {
+		t.Errorf("served by %q, want cloud", id)
+	}
+}
+
+func TestReliabilityHedge_BothFail(t *testing.T) {
+	local, _ := hedgeBackend("local", BackendOllama, 0, errors.New("local down"))
+	cloud, _ := hedgeBackend("cloud", BackendGeneric, 0, errors.New("cloud down"))
+	r := newTestRouter(t, []Backend{local, cloud}, hedgeOption)
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "local down") {
+		t.Errorf("body = %s, want the primary's error", rec.Body.String())
+	}
+}
+
+func TestWithReliabilityHedge_SameType(t *testing.T) {
+	if _, err := NewRouter(WithReliabilityHedge(BackendOllama, BackendOllama, time.Second)); err == nil {
+		t.Error("expected error for identical primary and fallback types")
+	}
+}
@@ -0,0 +1,94 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testChatBody is a minimal chat request for test-model, the model mock
+// backends serve by default.
+const testChatBody = `{"model":"test-model","messages":[{"role":"user","content":"hi"}]}`
+
+// chatBody returns a minimal chat request for model.
+func chatBody(model string) string {
+	return `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}]}`
+}
+
+// newTestRouter returns a router built with opts that serves backends,
+// failing the test if an option is rejected.
+func newTestRouter(t *testing.T, backends []Backend, opts ...Option) *Router {
+	t.Helper()
+	r, err := NewRouter(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range backends {
+		r.AddBackend(context.Background(), b)
+	}
+	return r
+}
+
+// requestOption adjusts a request sent by postJSON.
+type requestOption func(*http.Request) *http.Request
+
+// withHeader sets a request header; an empty value leaves it unset.
+func withHeader(name, value string) requestOption {
+	return func(req *http.Request) *http.Request {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+}
+
+// withAPIKey sends key as the request's bearer token; an empty key sends
+// none.
+func withAPIKey(key string) requestOption {
+	if key == "" {
+		return withHeader("Authorization", "")
+	}
+	return withHeader("Authorization", "Bearer "+key)
+}
+
+// withContext sends the request with ctx.
+func withContext(ctx context.Context) requestOption {
+	return func(req *http.Request) *http.Request {
+		return req.WithContext(ctx)
+	}
+}
+
+// postJSON sends body to path on r and returns the recorded response.
+func postJSON(t *testing.T, r *Router, path, body string, opts ...requestOption) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for _, opt := range opts {
+		req = opt(req)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// postChat sends body to r's chat completions endpoint.
+func postChat(t *testing.T, r *Router, body string, opts ...requestOption) *httptest.ResponseRecorder {
+	t.Helper()
+	return postJSON(t, r, "/v1/chat/completions", body, opts...)
+}
+
+// postAsync sends body to path on r in the background.
+func postAsync(t *testing.T, r *Router, path, body string, opts ...requestOption) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- postJSON(t, r, path, body, opts...)
+	}()
+	return done
+}
+
+// postChatAsync sends body to r's chat completions endpoint in the
+// background.
+func postChatAsync(t *testing.T, r *Router, body string, opts ...requestOption) <-chan *httptest.ResponseRecorder {
+	return postAsync(t, r, "/v1/chat/completions", body, opts...)
+}
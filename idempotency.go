@@ -0,0 +1,91 @@
+package oairouter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// IdempotencyKeyHeader lets clients mark retries of the same request. A
+// repeated key is answered with the stored response instead of being re-run.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a repeated
+// idempotency key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyRecord is a response stored for an idempotency key, with the
+// hash of the request that produced it.
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Response    []byte `json:"response"`
+}
+
+// idempotentRequest is a request being served under an idempotency key.
+type idempotentRequest struct {
+	key  string // Cache key, scoped by endpoint and caller
+	hash string // Hash of the request, see requestCacheKey
+}
+
+// idempotencyCacheKey scopes a client's idempotency key to an endpoint and
+// the caller's API key, so callers can't see each other's responses. The
+// key is hashed so arbitrary client values make safe, bounded cache keys.
+func idempotencyCacheKey(endpoint, caller, key string) string {
+	sum := sha256.Sum256([]byte(caller + "\x00" + key))
+	return "idempotency:" + endpoint + ":" + hex.EncodeToString(sum[:])
+}
+
+// startIdempotent looks up the response stored for the request's
+// idempotency key, whose request hashed to hash. It writes the stored
+// response, a 422 if the key was used with a different request, or a 409 if
+// a request with the key is still being served, and reports true. Otherwise
+// it marks the key in flight until releaseIdempotent is called.
+func (r *Router) startIdempotent(w http.ResponseWriter, req *http.Request, endpoint, hash string) (*idempotentRequest, bool) {
+	ir := &idempotentRequest{
+		key:  idempotencyCacheKey(endpoint, requestAPIKey(req), req.Header.Get(IdempotencyKeyHeader)),
+		hash: hash,
+	}
+	if _, busy := r.idempotencyInFlight.LoadOrStore(ir.key, struct{}{}); busy {
+		types.WriteError(w, http.StatusConflict, types.InvalidRequestError("a request with this Idempotency-Key is already being processed"))
+		return nil, true
+	}
+
+	data, ok, err := r.idempotencyCache.Get(req.Context(), ir.key)
+	if err != nil {
+		r.logger.Warn("idempotency cache get failed", "error", err)
+		return ir, false
+	}
+	var record idempotencyRecord
+	if !ok || json.Unmarshal(data, &record) != nil {
+		return ir, false
+	}
+	r.idempotencyInFlight.Delete(ir.key)
+	if record.RequestHash != hash {
+		types.WriteError(w, http.StatusUnprocessableEntity,
+			types.InvalidRequestError("Idempotency-Key was already used with a different request body"))
+		return nil, true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.Write(record.Response)
+	return nil, true
+}
+
+// storeIdempotent stores data, a successful response, under ir's key.
+func (r *Router) storeIdempotent(req *http.Request, ir *idempotentRequest, data []byte) {
+	record, err := json.Marshal(idempotencyRecord{RequestHash: ir.hash, Response: data})
+	if err == nil {
+		err = r.idempotencyCache.Set(req.Context(), ir.key, record, r.idempotencyTTL)
+	}
+	if err != nil {
+		r.logger.Warn("idempotency cache set failed", "error", err)
+	}
+}
+
+// releaseIdempotent lets the next request with ir's key be served.
+func (r *Router) releaseIdempotent(ir *idempotentRequest) {
+	r.idempotencyInFlight.Delete(ir.key)
+}
@@ -0,0 +1,169 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func countingChatBackend(calls *atomic.Int64) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		n := calls.Add(1)
+		return &types.ChatCompletionResponse{ID: fmt.Sprintf("resp-%d", n), Object: "chat.completion"}, nil
+	}
+	return b
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithIdempotency(time.Minute))
+	r.AddBackend(context.Background(), countingChatBackend(&calls))
+
+	first := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "retry-123"))
+	second := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "retry-123"))
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("backend called %d times, want 1", n)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("replayed body %q differs from original %q", second.Body.String(), first.Body.String())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("original response marked as replayed")
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("%s = %q, want true", IdempotentReplayedHeader, second.Header().Get(IdempotentReplayedHeader))
+	}
+
+	// A different key runs the request again
+	postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "retry-456"))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("backend called %d times after a new key, want 2", n)
+	}
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithIdempotency(time.Minute))
+	r.AddBackend(context.Background(), countingChatBackend(&calls))
+
+	postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, ""))
+	postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, ""))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("backend called %d times, want 2", n)
+	}
+}
+
+func TestIdempotency_ErrorsNotStored(t *testing.T) {
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("transient failure")
+		}
+		return &types.ChatCompletionResponse{ID: "ok"}, nil
+	}
+	r, _ := NewRouter(WithIdempotency(time.Minute))
+	r.AddBackend(context.Background(), b)
+
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k")); rec.Code == http.StatusOK {
+		t.Fatal("first request should fail")
+	}
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k")); rec.Code != http.StatusOK || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry after failure: status = %d, replayed = %q; want a fresh 200", rec.Code, rec.Header().Get(IdempotentReplayedHeader))
+	}
+}
+
+func TestIdempotency_StreamingNotStored(t *testing.T) {
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		calls.Add(1)
+		return streamOf(`{"choices":[{"delta":{"content":"hi"}}]}`), nil
+	}
+	r, _ := NewRouter(WithIdempotency(time.Minute))
+	r.AddBackend(context.Background(), b)
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	postChat(t, r, body, withHeader(IdempotencyKeyHeader, "k"))
+	postChat(t, r, body, withHeader(IdempotencyKeyHeader, "k"))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("backend streamed %d times, want 2", n)
+	}
+}
+
+func TestIdempotency_ScopedByAPIKey(t *testing.T) {
+	var calls atomic.Int64
+	r := newTestRouter(t, []Backend{countingChatBackend(&calls)}, WithIdempotency(time.Minute))
+
+	first := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"), withAPIKey("key-a"))
+	other := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"), withAPIKey("key-b"))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("backend called %d times, want 2", n)
+	}
+	if other.Header().Get(IdempotentReplayedHeader) != "" || other.Body.String() == first.Body.String() {
+		t.Error("another API key was replayed the first caller's response")
+	}
+}
+
+func TestIdempotency_DifferentBodyRejected(t *testing.T) {
+	var calls atomic.Int64
+	r := newTestRouter(t, []Backend{countingChatBackend(&calls)}, WithIdempotency(time.Minute))
+
+	postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"))
+	body := `{"model":"test-model","messages":[{"role":"user","content":"something else"}]}`
+	rec := postChat(t, r, body, withHeader(IdempotencyKeyHeader, "k"))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422", rec.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("backend called %d times, want 1", n)
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicateConflicts(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		close(started)
+		<-release
+		return &types.ChatCompletionResponse{ID: "ok"}, nil
+	}
+	r := newTestRouter(t, []Backend{b}, WithIdempotency(time.Minute))
+
+	done := postChatAsync(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"))
+	<-started
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k")); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate in flight: status = %d, want 409", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d", rec.Code)
+	}
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k")); rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("retry after completion was not replayed")
+	}
+}
+
+func TestIdempotency_ReplayAuthorized(t *testing.T) {
+	var calls atomic.Int64
+	var revoked atomic.Bool
+	authorize := func(apiKey, model string) bool { return !revoked.Load() }
+	r := newTestRouter(t, []Backend{countingChatBackend(&calls)}, WithIdempotency(time.Minute), WithModelAuthorizer(authorize))
+
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"), withAPIKey("key-a")); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	revoked.Store(true)
+	if rec := postChat(t, r, testChatBody, withHeader(IdempotencyKeyHeader, "k"), withAPIKey("key-a")); rec.Code != http.StatusForbidden {
+		t.Errorf("replay after access was revoked: status = %d, want 403", rec.Code)
+	}
+}
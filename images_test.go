@@ -0,0 +1,72 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// imageBackend serves image generation for image-model.
+type imageBackend struct {
+	*mockBackend
+}
+
+func (b *imageBackend) GenerateImages(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	return &types.ImageGenerationResponse{
+		Created: 1700000000,
+		Data:    []types.ImageData{{B64JSON: "aGVsbG8=", RevisedPrompt: req.Prompt}, {URL: "https://example.com/cat.png"}},
+	}, nil
+}
+
+func postImages(r *Router, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(body)))
+	return rec
+}
+
+func TestImageGenerations_RoutesByModel(t *testing.T) {
+	r, _ := NewRouter()
+	chat := newMockBackend("chat", true)
+	images := &imageBackend{mockBackend: newMockBackend("images", true)}
+	images.models = []string{"image-model"}
+	r.AddBackend(context.Background(), chat)
+	r.AddBackend(context.Background(), images)
+
+	rec := postImages(r, `{"model":"image-model","prompt":"a cat","n":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp types.ImageGenerationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].B64JSON != "aGVsbG8=" || resp.Data[1].URL != "https://example.com/cat.png" {
+		t.Errorf("unexpected images: %+v", resp.Data)
+	}
+}
+
+func TestImageGenerations_BackendWithoutSupport(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), newMockBackend("chat", true))
+
+	rec := postImages(r, `{"model":"test-model","prompt":"a cat"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "image generation") {
+		t.Errorf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stats := r.Stats(); stats.Errors != 0 {
+		t.Errorf("expected the rejection not to count as a backend error, got %d", stats.Errors)
+	}
+}
+
+func TestImageGenerations_Validation(t *testing.T) {
+	r, _ := NewRouter()
+	rec := postImages(r, `{"model":"image-model"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"param":"prompt"`) {
+		t.Errorf("expected a prompt validation error, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,499 @@
+// Package jsonschema validates decoded JSON values against a JSON Schema.
+//
+// It implements the subset of JSON Schema used by OpenAI structured outputs:
+// type, enum, const, properties, required, additionalProperties, items,
+// prefixItems, min/maxItems, min/maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, anyOf, oneOf, allOf, not, and local
+// $ref pointers such as "#/$defs/address". Other keywords, such as format and
+// description, are accepted and ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError reports where a value first failed to match its schema.
+type ValidationError struct {
+	Path    string // Location in the value, e.g. "$.items[2].price"
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a compiled JSON schema.
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile prepares a schema for validation. schema is a decoded JSON value
+// (a map[string]any or a bool), or any value that marshals to one.
+func Compile(schema any) (*Schema, error) {
+	switch schema.(type) {
+	case map[string]any, bool:
+	default:
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+		switch schema.(type) {
+		case map[string]any, bool:
+		default:
+			return nil, fmt.Errorf("invalid schema: expected an object or boolean, got %T", schema)
+		}
+	}
+
+	s := &Schema{root: schema, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.compilePatterns(schema); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compilePatterns compiles every pattern keyword up front so bad schemas are
+// rejected by Compile rather than during validation.
+func (s *Schema) compilePatterns(node any) error {
+	switch n := node.(type) {
+	case map[string]any:
+		if p, ok := n["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("invalid schema: pattern %q: %w", p, err)
+			}
+			s.patterns[p] = re
+		}
+		for _, v := range n {
+			if err := s.compilePatterns(v); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if err := s.compilePatterns(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks a value decoded by encoding/json against the schema. It
+// returns a *ValidationError for the first mismatch found, or for a value
+// that takes more than maxValidationSteps to check.
+func (s *Schema) Validate(value any) error {
+	v := &validator{Schema: s}
+	return v.validate(s.root, value, "$", 0)
+}
+
+// ValidateJSON decodes data and validates it.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Path: "$", Message: "invalid JSON: " + err.Error()}
+	}
+	return s.Validate(value)
+}
+
+// maxRefDepth bounds $ref nesting, which stops cycles such as {"$ref": "#"}.
+const maxRefDepth = 64
+
+// maxValidationSteps bounds how many schema nodes one Validate checks a value
+// against. Nested anyOf and oneOf try every alternative at every level, so
+// without a bound a small schema and value can take exponential time.
+const maxValidationSteps = 100_000
+
+// validator carries the state of one Validate call.
+type validator struct {
+	*Schema
+	steps int
+}
+
+// exhausted reports whether the validation has used up its steps. A
+// combinator seeing a failed alternative checks it, so running out fails the
+// whole validation rather than counting as a mismatch.
+func (s *validator) exhausted() bool {
+	return s.steps > maxValidationSteps
+}
+
+func (s *validator) validate(schema any, value any, path string, depth int) error {
+	s.steps++
+	if s.exhausted() {
+		return fail(path, "schema too complex to validate")
+	}
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return fail(path, "no value is allowed here")
+		}
+		return nil
+	}
+	node, ok := schema.(map[string]any)
+	if !ok {
+		return fail(path, fmt.Sprintf("invalid schema node %T", schema))
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			return fail(path, "$ref nesting too deep")
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			return fail(path, err.Error())
+		}
+		if err := s.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := node["type"]; ok {
+		if err := checkType(t, value, path); err != nil {
+			return err
+		}
+	}
+	if c, ok := node["const"]; ok && !equal(c, value) {
+		return fail(path, fmt.Sprintf("expected %s, got %s", describe(c), describe(value)))
+	}
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if equal(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail(path, fmt.Sprintf("%s is not one of %s", describe(value), describe(enum)))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if err := s.validateObject(node, v, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := s.validateArray(node, v, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := s.validateString(node, v, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(node, v, path); err != nil {
+			return err
+		}
+	}
+
+	return s.validateCombinators(node, value, path, depth)
+}
+
+func (s *validator) validateObject(node map[string]any, obj map[string]any, path string, depth int) error {
+	if required, ok := node["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				return fail(path, fmt.Sprintf("missing required property %q", name))
+			}
+		}
+	}
+
+	props, _ := node["properties"].(map[string]any)
+	additional, hasAdditional := node["additionalProperties"]
+
+	// Sorted so the first error reported is deterministic
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := propertyPath(path, name)
+		if sub, ok := props[name]; ok {
+			if err := s.validate(sub, obj[name], child, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			return fail(child, "additional property is not allowed")
+		}
+		if err := s.validate(additional, obj[name], child, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *validator) validateArray(node map[string]any, arr []any, path string, depth int) error {
+	if n, ok := number(node["minItems"]); ok && float64(len(arr)) < n {
+		return fail(path, fmt.Sprintf("expected at least %v items, got %d", n, len(arr)))
+	}
+	if n, ok := number(node["maxItems"]); ok && float64(len(arr)) > n {
+		return fail(path, fmt.Sprintf("expected at most %v items, got %d", n, len(arr)))
+	}
+
+	prefix, _ := node["prefixItems"].([]any)
+	for i, item := range arr {
+		child := path + "[" + strconv.Itoa(i) + "]"
+		if i < len(prefix) {
+			if err := s.validate(prefix[i], item, child, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if items, ok := node["items"]; ok {
+			if err := s.validate(items, item, child, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *validator) validateString(node map[string]any, str string, path string) error {
+	length := float64(len([]rune(str)))
+	if n, ok := number(node["minLength"]); ok && length < n {
+		return fail(path, fmt.Sprintf("expected at least %v characters, got %v", n, length))
+	}
+	if n, ok := number(node["maxLength"]); ok && length > n {
+		return fail(path, fmt.Sprintf("expected at most %v characters, got %v", n, length))
+	}
+	if p, ok := node["pattern"].(string); ok && !s.patterns[p].MatchString(str) {
+		return fail(path, fmt.Sprintf("%q does not match pattern %q", str, p))
+	}
+	return nil
+}
+
+func validateNumber(node map[string]any, n float64, path string) error {
+	if lo, ok := number(node["minimum"]); ok && n < lo {
+		return fail(path, fmt.Sprintf("%v is less than the minimum %v", n, lo))
+	}
+	if hi, ok := number(node["maximum"]); ok && n > hi {
+		return fail(path, fmt.Sprintf("%v is greater than the maximum %v", n, hi))
+	}
+	if lo, ok := number(node["exclusiveMinimum"]); ok && n <= lo {
+		return fail(path, fmt.Sprintf("%v must be greater than %v", n, lo))
+	}
+	if hi, ok := number(node["exclusiveMaximum"]); ok && n >= hi {
+		return fail(path, fmt.Sprintf("%v must be less than %v", n, hi))
+	}
+	return nil
+}
+
+func (s *validator) validateCombinators(node map[string]any, value any, path string, depth int) error {
+	if all, ok := node["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := s.validate(sub, value, path, depth); err != nil {
+				return err
+			}
+		}
+	}
+	if alts, ok := node["anyOf"].([]any); ok {
+		var first error
+		matched := false
+		for _, sub := range alts {
+			err := s.validate(sub, value, path, depth)
+			if err == nil {
+				matched = true
+				break
+			}
+			if s.exhausted() {
+				return err
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		if !matched && first != nil {
+			return fail(path, "does not match any allowed schema (first mismatch: "+first.Error()+")")
+		}
+	}
+	if one, ok := node["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range one {
+			err := s.validate(sub, value, path, depth)
+			if s.exhausted() {
+				return err
+			}
+			if err == nil {
+				// A second match already fails, so the rest needn't be tried
+				if matches++; matches > 1 {
+					break
+				}
+			}
+		}
+		if matches != 1 {
+			return fail(path, fmt.Sprintf("expected exactly one matching schema in oneOf, matched %d", matches))
+		}
+	}
+	if not, ok := node["not"]; ok {
+		err := s.validate(not, value, path, depth)
+		if s.exhausted() {
+			return err
+		}
+		if err == nil {
+			return fail(path, "matches a schema it must not match")
+		}
+	}
+	return nil
+}
+
+// resolve follows a local JSON pointer reference such as "#/$defs/item".
+func (s *Schema) resolve(ref string) (any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+	node := s.root
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			next, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// checkType validates the type keyword, which is a name or a list of names.
+func checkType(t any, value any, path string) error {
+	var names []string
+	switch tt := t.(type) {
+	case string:
+		names = []string{tt}
+	case []any:
+		for _, n := range tt {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	for _, name := range names {
+		if hasType(name, value) {
+			return nil
+		}
+	}
+	return fail(path, fmt.Sprintf("expected %s, got %s", strings.Join(names, " or "), typeName(value)))
+}
+
+func hasType(name string, value any) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// number reads a numeric keyword.
+func number(v any) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+// equal compares decoded JSON values.
+func equal(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// describe renders a value compactly for error messages.
+func describe(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 64 {
+		return string(data[:61]) + "..."
+	}
+	return string(data)
+}
+
+// propertyPath appends a property name to a path, quoting names that aren't
+// plain identifiers.
+func propertyPath(path, name string) string {
+	plain := name != ""
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return path + "." + name
+	}
+	return path + "[" + strconv.Quote(name) + "]"
+}
+
+func fail(path, message string) *ValidationError {
+	return &ValidationError{Path: path, Message: message}
+}
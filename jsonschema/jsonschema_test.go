@@ -0,0 +1,174 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"email": {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"address": {"$ref": "#/$defs/address"},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"zip": {"type": "string"}},
+			"required": ["zip"]
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	var raw any
+	if err := json.Unmarshal([]byte(personSchema), &raw); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := Compile(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		wantPath string // empty means valid
+	}{
+		{"valid", `{"name":"Ada","age":36,"email":null,"role":"admin","address":{"zip":"02139"},"tags":["a"]}`, ""},
+		{"minimal", `{"name":"Ada","age":36}`, ""},
+		{"not an object", `"Ada"`, "$"},
+		{"missing required", `{"name":"Ada"}`, "$"},
+		{"wrong type", `{"name":"Ada","age":"36"}`, "$.age"},
+		{"fractional integer", `{"name":"Ada","age":36.5}`, "$.age"},
+		{"below minimum", `{"name":"Ada","age":-1}`, "$.age"},
+		{"empty string", `{"name":"","age":1}`, "$.name"},
+		{"pattern", `{"name":"Ada","age":1,"email":"nope"}`, "$.email"},
+		{"enum", `{"name":"Ada","age":1,"role":"root"}`, "$.role"},
+		{"additional property", `{"name":"Ada","age":1,"extra":true}`, "$.extra"},
+		{"ref", `{"name":"Ada","age":1,"address":{}}`, "$.address"},
+		{"nested ref type", `{"name":"Ada","age":1,"address":{"zip":2139}}`, "$.address.zip"},
+		{"array item", `{"name":"Ada","age":1,"tags":["a",2]}`, "$.tags[1]"},
+		{"max items", `{"name":"Ada","age":1,"tags":["a","b","c"]}`, "$.tags"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.ValidateJSON([]byte(tt.value))
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("expected valid, got %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+			if verr.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q (%v)", verr.Path, tt.wantPath, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	schema, err := Compile(map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "number", "exclusiveMaximum": 10.0},
+		},
+		"not": map[string]any{"const": "forbidden"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for value, valid := range map[string]bool{`"ok"`: true, `5`: true, `10`: false, `true`: false, `"forbidden"`: false} {
+		if err := schema.ValidateJSON([]byte(value)); (err == nil) != valid {
+			t.Errorf("%s: valid = %v, want %v (%v)", value, err == nil, valid, err)
+		}
+	}
+
+	oneOf, _ := Compile(map[string]any{"oneOf": []any{
+		map[string]any{"type": "integer"},
+		map[string]any{"type": "number"},
+	}})
+	if err := oneOf.ValidateJSON([]byte(`1`)); err == nil {
+		t.Error("oneOf: expected an error when both schemas match")
+	}
+	if err := oneOf.ValidateJSON([]byte(`1.5`)); err != nil {
+		t.Errorf("oneOf: expected 1.5 to match only number, got %v", err)
+	}
+}
+
+func TestValidate_BoundsCombinatorWork(t *testing.T) {
+	// Every level tries both alternatives, 2^64 paths without a bound
+	schema, err := Compile(map[string]any{
+		"$defs": map[string]any{
+			"a": map[string]any{"anyOf": []any{
+				map[string]any{"$ref": "#/$defs/a"},
+				map[string]any{"oneOf": []any{map[string]any{"$ref": "#/$defs/a"}, false}},
+			}},
+		},
+		"$ref": "#/$defs/a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- schema.ValidateJSON([]byte(`1`)) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "too complex") {
+			t.Errorf("error = %v, want the validation to give up", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("validation didn't give up")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	if _, err := Compile(map[string]any{"pattern": "("}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+	if _, err := Compile("string"); err == nil {
+		t.Error("expected an error for a non-object schema")
+	}
+
+	schema, _ := Compile(map[string]any{"$ref": "#/$defs/missing"})
+	if err := schema.Validate(1.0); err == nil {
+		t.Error("expected an error for an unresolvable $ref")
+	}
+
+	recursive, _ := Compile(map[string]any{"$ref": "#"})
+	if err := recursive.Validate(1.0); err == nil {
+		t.Error("expected an error for a $ref cycle")
+	}
+}
+
+func TestValidate_RecursiveSchema(t *testing.T) {
+	schema, err := Compile(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name":     map[string]any{"type": "string"},
+			"children": map[string]any{"type": "array", "items": map[string]any{"$ref": "#"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.ValidateJSON([]byte(`{"name":"root","children":[{"name":"a","children":[{"name":3}]}]}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Path != "$.children[0].children[0].name" {
+		t.Errorf("expected an error at the nested name, got %v", err)
+	}
+}
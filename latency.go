@@ -0,0 +1,101 @@
+package oairouter
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyEWMAAlpha is the weight of each new latency sample in a
+	// backend's moving average.
+	latencyEWMAAlpha = 0.2
+
+	// fastestExploreRate is the share of LookupByModelFastest picks made at
+	// random, so backends without latency history, or whose average is
+	// stale, get measured.
+	fastestExploreRate = 0.05
+)
+
+// latencyEWMA is an exponentially weighted moving average of a backend's
+// successful response latency.
+type latencyEWMA struct {
+	mu    sync.Mutex
+	value float64 // Nanoseconds
+	set   bool
+}
+
+func (e *latencyEWMA) record(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.set {
+		e.value, e.set = float64(latency), true
+		return
+	}
+	e.value += latencyEWMAAlpha * (float64(latency) - e.value)
+}
+
+func (e *latencyEWMA) load() (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Duration(e.value), e.set
+}
+
+// recordLatency feeds a successful request's latency into the backend's
+// moving average. Failures are left out, since a backend that fails fast
+// would otherwise look fastest.
+func (r *BackendRegistry) recordLatency(backendID string, latency time.Duration) {
+	e, ok := r.latency.Load(backendID)
+	if !ok {
+		e, _ = r.latency.LoadOrStore(backendID, new(latencyEWMA))
+	}
+	e.(*latencyEWMA).record(latency)
+}
+
+// LatencyEWMA returns the moving average of a backend's response latency.
+// For streams, latency is the time to the first event. ok is false if the
+// backend hasn't completed a request yet.
+func (r *BackendRegistry) LatencyEWMA(backendID string) (avg time.Duration, ok bool) {
+	e, ok := r.latency.Load(backendID)
+	if !ok {
+		return 0, false
+	}
+	return e.(*latencyEWMA).load()
+}
+
+// LookupByModelFastest picks the healthy backend serving a model with the
+// lowest latency average. A small share of picks are random, so backends
+// with no history get measured. It falls back to LookupByModel when no
+// serving backend is healthy.
+func (r *BackendRegistry) LookupByModelFastest(modelID string) (Backend, bool) {
+	return r.LookupByModelFastestForOp(modelID, OperationAny)
+}
+
+// LookupByModelFastestForOp is LookupByModelFastest restricted to backends
+// that can perform op.
+func (r *BackendRegistry) LookupByModelFastestForOp(modelID string, op Operation) (Backend, bool) {
+	healthy := r.preferTier(r.HealthyBackendsForModelOp(modelID, op))
+	if len(healthy) == 0 {
+		return r.LookupByModelForOp(modelID, op)
+	}
+	if len(healthy) == 1 {
+		return healthy[0], true
+	}
+	if rand.Float64() < fastestExploreRate {
+		return healthy[rand.IntN(len(healthy))], true
+	}
+
+	var fastest Backend
+	var best time.Duration
+	for _, b := range healthy {
+		avg, ok := r.LatencyEWMA(b.ID())
+		if ok && (fastest == nil || avg < best) {
+			fastest, best = b, avg
+		}
+	}
+	if fastest == nil {
+		// Nothing measured yet
+		return healthy[0], true
+	}
+	return fastest, true
+}
@@ -0,0 +1,68 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLatencyEWMA(t *testing.T) {
+	reg := NewBackendRegistry()
+	if _, ok := reg.LatencyEWMA("a"); ok {
+		t.Error("expected no average before any request")
+	}
+
+	reg.RecordOutcome("a", 100*time.Millisecond, nil)
+	reg.RecordOutcome("a", 200*time.Millisecond, nil)
+	reg.RecordOutcome("a", time.Millisecond, context.DeadlineExceeded) // failures are ignored
+	// 100ms + 0.2 * (200ms - 100ms)
+	if avg, ok := reg.LatencyEWMA("a"); !ok || avg != 120*time.Millisecond {
+		t.Errorf("LatencyEWMA() = %v, %v, want 120ms", avg, ok)
+	}
+}
+
+func TestLookupByModelFastest(t *testing.T) {
+	reg := NewBackendRegistry()
+	for _, id := range []string{"slow", "fast", "new"} {
+		reg.Register(context.Background(), newMockBackend(id, true))
+	}
+	reg.RecordOutcome("slow", 500*time.Millisecond, nil)
+	reg.RecordOutcome("fast", 50*time.Millisecond, nil)
+
+	picks := map[string]int{}
+	for range 2000 {
+		b, ok := reg.LookupByModelFastest("test-model")
+		if !ok {
+			t.Fatal("no backend")
+		}
+		picks[b.ID()]++
+	}
+	if picks["fast"] < 1800 {
+		t.Errorf("expected the fastest backend to serve most requests, got %v", picks)
+	}
+	if picks["new"] == 0 {
+		t.Errorf("expected the unmeasured backend to be explored, got %v", picks)
+	}
+}
+
+func TestFastestRouting_ReportedInAdmin(t *testing.T) {
+	r, err := NewRouter(WithFastestRouting(true), WithAdmin(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	w := adminDo(r, http.MethodGet, "/admin/backends/backend-a", "", "")
+	var info BackendInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.LatencyEWMAMs == nil {
+		t.Errorf("expected latency_ewma_ms after a request, got %+v", info)
+	}
+}
@@ -0,0 +1,19 @@
+package oairouter
+
+import (
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// shedLoad reports whether WithLoadShedding's check says the router is
+// overloaded, writing a 503 if so.
+func (r *Router) shedLoad(w http.ResponseWriter) bool {
+	if r.loadShedding == nil || !r.loadShedding() {
+		return false
+	}
+	r.counters.shed.Add(1)
+	w.Header().Set("Retry-After", "1")
+	types.WriteError(w, http.StatusServiceUnavailable, types.ServerError("router is overloaded, try again later"))
+	return true
+}
@@ -0,0 +1,71 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestLoadShedding_RejectsWhileOverloaded(t *testing.T) {
+	var overloaded atomic.Bool
+	var calls atomic.Int64
+	r, err := NewRouter(WithLoadShedding(overloaded.Load))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+
+	overloaded.Store(true)
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After = %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if calls.Load() != 0 {
+		t.Error("shed request reached the backend")
+	}
+
+	overloaded.Store(false)
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Errorf("status = %d after load dropped, want 200", rec.Code)
+	}
+	if n := r.Stats().ShedRequests; n != 1 {
+		t.Errorf("ShedRequests = %d, want 1", n)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	r, _ := NewRouter(WithMaxInFlight(1))
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		close(started)
+		<-unblock
+		return &types.ChatCompletionResponse{ID: "backend-a"}, nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	first := postChatAsync(t, r, testChatBody)
+	<-started
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d with one request in flight, want 503", rec.Code)
+	}
+	close(unblock)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Errorf("first request: status = %d", rec.Code)
+	}
+	if n := r.registry.TotalInFlight(); n != 0 {
+		t.Errorf("TotalInFlight() = %d after requests finished", n)
+	}
+}
+
+func TestLoadShedding_Options(t *testing.T) {
+	for _, opt := range []Option{WithLoadShedding(nil), WithMaxInFlight(0)} {
+		if _, err := NewRouter(opt); err == nil {
+			t.Error("expected an error")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package oairouter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// LogprobsPolicy controls how requests for log probabilities are handled.
+type LogprobsPolicy string
+
+const (
+	// LogprobsAllow forwards logprobs fields unchanged (the default).
+	LogprobsAllow LogprobsPolicy = "allow"
+
+	// LogprobsStrip removes logprobs fields from the outbound request.
+	LogprobsStrip LogprobsPolicy = "strip"
+
+	// LogprobsReject returns 400 when logprobs are requested from a backend
+	// without the logprobs capability.
+	LogprobsReject LogprobsPolicy = "reject"
+)
+
+func (p LogprobsPolicy) valid() bool {
+	switch p {
+	case LogprobsAllow, LogprobsStrip, LogprobsReject:
+		return true
+	}
+	return false
+}
+
+// logprobsPolicyFor returns the effective policy for a backend.
+func (r *Router) logprobsPolicyFor(b Backend) LogprobsPolicy {
+	if p, ok := r.backendLogprobs[b.ID()]; ok {
+		return p
+	}
+	if r.logprobsPolicy != "" {
+		return r.logprobsPolicy
+	}
+	return LogprobsAllow
+}
+
+// applyChatLogprobsPolicy strips or rejects logprobs/top_logprobs on a chat request.
+func (r *Router) applyChatLogprobsPolicy(b Backend, req *types.ChatCompletionRequest) *types.RouterError {
+	requested := (req.Logprobs != nil && *req.Logprobs) || req.TopLogprobs != nil
+
+	switch r.logprobsPolicyFor(b) {
+	case LogprobsStrip:
+		req.Logprobs = nil
+		req.TopLogprobs = nil
+	case LogprobsReject:
+		if requested && !backendSupports(b, CapabilityLogprobs) {
+			return logprobsUnsupportedError(req.Model)
+		}
+	}
+	return nil
+}
+
+// applyCompletionLogprobsPolicy strips or rejects logprobs on a completion request.
+func (r *Router) applyCompletionLogprobsPolicy(b Backend, req *types.CompletionRequest) *types.RouterError {
+	switch r.logprobsPolicyFor(b) {
+	case LogprobsStrip:
+		req.Logprobs = nil
+	case LogprobsReject:
+		if req.Logprobs != nil && !backendSupports(b, CapabilityLogprobs) {
+			return logprobsUnsupportedError(req.Model)
+		}
+	}
+	return nil
+}
+
+func logprobsUnsupportedError(model string) *types.RouterError {
+	return types.NewRouterError(
+		http.StatusBadRequest,
+		types.InvalidParamError(fmt.Sprintf("logprobs are not supported for model %s", model), "logprobs"),
+		nil,
+	)
+}
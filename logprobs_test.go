@@ -0,0 +1,132 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// logprobsRecorder returns a mock backend that records the last chat and completion requests.
+func logprobsRecorder(caps []Capability) (*mockBackend, *types.ChatCompletionRequest, *types.CompletionRequest) {
+	var chat types.ChatCompletionRequest
+	var comp types.CompletionRequest
+	b := newMockBackend("backend-a", true)
+	b.caps = caps
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		chat = *req
+		return &types.ChatCompletionResponse{Object: "chat.completion"}, nil
+	}
+	b.completionFn = func(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+		comp = *req
+		return &types.CompletionResponse{Object: "text_completion"}, nil
+	}
+	return b, &chat, &comp
+}
+
+const logprobsChatBody = `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"logprobs":true,"top_logprobs":5}`
+const logprobsCompletionBody = `{"model":"test-model","prompt":"hi","logprobs":3}`
+
+func TestLogprobsPolicy_Allow(t *testing.T) {
+	b, chat, comp := logprobsRecorder([]Capability{CapabilityChat, CapabilityCompletions})
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	if rec := postChat(t, r, logprobsChatBody); rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if chat.Logprobs == nil || !*chat.Logprobs || chat.TopLogprobs == nil || *chat.TopLogprobs != 5 {
+		t.Errorf("expected logprobs to pass through, got logprobs=%v top_logprobs=%v", chat.Logprobs, chat.TopLogprobs)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(logprobsCompletionBody)))
+	if comp.Logprobs == nil || *comp.Logprobs != 3 {
+		t.Errorf("expected completion logprobs to pass through, got %v", comp.Logprobs)
+	}
+}
+
+func TestLogprobsPolicy_Strip(t *testing.T) {
+	b, chat, comp := logprobsRecorder(nil)
+	r, err := NewRouter(WithLogprobsPolicy(LogprobsStrip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), b)
+
+	if rec := postChat(t, r, logprobsChatBody); rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if chat.Logprobs != nil || chat.TopLogprobs != nil {
+		t.Errorf("expected logprobs to be stripped, got logprobs=%v top_logprobs=%v", chat.Logprobs, chat.TopLogprobs)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(logprobsCompletionBody)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if comp.Logprobs != nil {
+		t.Errorf("expected completion logprobs to be stripped, got %v", *comp.Logprobs)
+	}
+}
+
+func TestLogprobsPolicy_Reject(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     []Capability
+		body     string
+		wantCode int
+	}{
+		{"unsupported backend", []Capability{CapabilityChat}, logprobsChatBody, http.StatusBadRequest},
+		{"supported backend", []Capability{CapabilityChat, CapabilityLogprobs}, logprobsChatBody, http.StatusOK},
+		{"not requested", []Capability{CapabilityChat}, testChatBody, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, _ := logprobsRecorder(tt.caps)
+			r, _ := NewRouter(WithLogprobsPolicy(LogprobsReject))
+			r.AddBackend(context.Background(), b)
+
+			rec := postChat(t, r, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusBadRequest {
+				return
+			}
+
+			var apiErr types.APIError
+			json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if apiErr.Error.Type != types.ErrorTypeInvalidRequest || apiErr.Error.Param == nil || *apiErr.Error.Param != "logprobs" {
+				t.Errorf("unexpected error body: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLogprobsPolicy_BackendOverride(t *testing.T) {
+	b, chat, _ := logprobsRecorder(nil)
+	r, err := NewRouter(
+		WithLogprobsPolicy(LogprobsAllow),
+		WithBackendLogprobsPolicy("backend-a", LogprobsStrip),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, logprobsChatBody)
+	if chat.Logprobs != nil {
+		t.Error("expected per-backend strip policy to override the global allow policy")
+	}
+
+	if _, err := NewRouter(WithLogprobsPolicy("drop")); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,51 @@
+package oairouter
+
+import "github.com/stevemurr/oairouter/types"
+
+// MaxTokensClampedHeader is set on responses whose token limit was lowered to
+// the serving backend's MaxTokensCap, to the cap it was lowered to.
+const MaxTokensClampedHeader = "X-Max-Tokens-Clamped"
+
+// clampMaxTokens lowers the token limit at limit to backend's MaxTokensCap,
+// or sets it to the backend's DefaultMaxTokens if it is unset. It returns the
+// cap if the limit was lowered, and 0 otherwise.
+func clampMaxTokens(backend Backend, limit **int) int {
+	caps := backend.Capabilities()
+	if *limit == nil {
+		if n := caps.DefaultMaxTokens; n > 0 {
+			if caps.MaxTokensCap > 0 {
+				n = min(n, caps.MaxTokensCap)
+			}
+			*limit = &n
+		}
+		return 0
+	}
+	if n := caps.MaxTokensCap; n > 0 && **limit > n {
+		*limit = &n
+		return n
+	}
+	return 0
+}
+
+// normalizeMaxTokens sends the request's token limit under the name backend
+// accepts: max_completion_tokens if it supports
+// CapabilityMaxCompletionTokens, max_tokens otherwise. Clients may send
+// either; if they send both, max_completion_tokens wins.
+func (r *Router) normalizeMaxTokens(backend Backend, req *types.ChatCompletionRequest) {
+	limit := req.MaxCompletionTokens
+	if limit == nil {
+		limit = req.MaxTokens
+	} else if req.MaxTokens != nil {
+		r.logger.Warn("request set both max_tokens and max_completion_tokens; using max_completion_tokens",
+			"model", req.Model, "max_tokens", *req.MaxTokens, "max_completion_tokens", *limit)
+	}
+	if limit == nil {
+		return
+	}
+
+	if backendSupports(backend, CapabilityMaxCompletionTokens) {
+		req.MaxCompletionTokens, req.MaxTokens = limit, nil
+	} else {
+		req.MaxTokens, req.MaxCompletionTokens = limit, nil
+	}
+}
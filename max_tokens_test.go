@@ -0,0 +1,162 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestNormalizeMaxTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		newName       bool // Backend supports max_completion_tokens
+		maxTokens     int  // Expected forwarded values; 0 means unset
+		maxCompletion int
+	}{
+		{"max_tokens to new-name backend", `"max_tokens":10`, true, 0, 10},
+		{"max_completion_tokens to old-name backend", `"max_completion_tokens":10`, false, 10, 0},
+		{"max_tokens to old-name backend", `"max_tokens":10`, false, 10, 0},
+		{"both prefer max_completion_tokens", `"max_tokens":5,"max_completion_tokens":10`, false, 10, 0},
+		{"neither", `"temperature":0`, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewRouter()
+			b := newMockBackend("a", true)
+			b.caps = []Capability{CapabilityChat}
+			if tt.newName {
+				b.caps = append(b.caps, CapabilityMaxCompletionTokens)
+			}
+			var got *types.ChatCompletionRequest
+			b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+				got = req
+				return &types.ChatCompletionResponse{ID: "a"}, nil
+			}
+			r.AddBackend(context.Background(), b)
+
+			postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],`+tt.body+`}`)
+			if got == nil {
+				t.Fatal("request not forwarded")
+			}
+			if v := intOrZero(got.MaxTokens); v != tt.maxTokens {
+				t.Errorf("max_tokens = %d, want %d", v, tt.maxTokens)
+			}
+			if v := intOrZero(got.MaxCompletionTokens); v != tt.maxCompletion {
+				t.Errorf("max_completion_tokens = %d, want %d", v, tt.maxCompletion)
+			}
+		})
+	}
+}
+
+func TestMaxTokensCap_Chat(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		newName       bool // Backend supports max_completion_tokens
+		defaultMax    int
+		maxTokens     int // Expected forwarded values; 0 means unset
+		maxCompletion int
+		clamped       string // Expected X-Max-Tokens-Clamped
+	}{
+		{"over the cap", `"max_tokens":100000`, false, 0, 512, 0, "512"},
+		{"under the cap", `"max_tokens":100`, false, 0, 100, 0, ""},
+		{"max_completion_tokens over the cap", `"max_completion_tokens":100000`, true, 0, 0, 512, "512"},
+		{"both, max_completion_tokens over the cap", `"max_tokens":5,"max_completion_tokens":100000`, false, 0, 512, 0, "512"},
+		{"unset", `"temperature":0`, false, 0, 0, 0, ""},
+		{"unset with a default", `"temperature":0`, false, 256, 256, 0, ""},
+		{"unset with a default over the cap", `"temperature":0`, false, 4096, 512, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewRouter()
+			b := newMockBackend("a", true)
+			b.maxTokens, b.defaultMax = 512, tt.defaultMax
+			if !tt.newName {
+				b.caps = []Capability{CapabilityChat}
+			}
+			var got *types.ChatCompletionRequest
+			b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+				got = req
+				return &types.ChatCompletionResponse{ID: "a"}, nil
+			}
+			r.AddBackend(context.Background(), b)
+
+			rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],`+tt.body+`}`)
+			if got == nil {
+				t.Fatal("request not forwarded")
+			}
+			if v := intOrZero(got.MaxTokens); v != tt.maxTokens {
+				t.Errorf("max_tokens = %d, want %d", v, tt.maxTokens)
+			}
+			if v := intOrZero(got.MaxCompletionTokens); v != tt.maxCompletion {
+				t.Errorf("max_completion_tokens = %d, want %d", v, tt.maxCompletion)
+			}
+			if h := rec.Header().Get(MaxTokensClampedHeader); h != tt.clamped {
+				t.Errorf("%s = %q, want %q", MaxTokensClampedHeader, h, tt.clamped)
+			}
+		})
+	}
+}
+
+func TestMaxTokensCap_Completion(t *testing.T) {
+	r, _ := NewRouter()
+	b := newMockBackend("a", true)
+	b.maxTokens = 512
+	var got *types.CompletionRequest
+	b.completionFn = func(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+		got = req
+		return &types.CompletionResponse{ID: "a"}, nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions",
+		strings.NewReader(`{"model":"test-model","prompt":"hi","max_tokens":100000}`)))
+	if rec.Code != http.StatusOK || got == nil {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if v := intOrZero(got.MaxTokens); v != 512 {
+		t.Errorf("max_tokens = %d, want 512", v)
+	}
+	if h := rec.Header().Get(MaxTokensClampedHeader); h != "512" {
+		t.Errorf("%s = %q, want 512", MaxTokensClampedHeader, h)
+	}
+}
+
+func intOrZero(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func TestMaxTokensCap_RetryClampedPerBackend(t *testing.T) {
+	var limited atomic.Int64
+	small := rateLimitedBackend("small", "30", &limited)
+	small.maxTokens = 512
+	var got *types.ChatCompletionRequest
+	large := newMockBackend("large", true)
+	large.caps = []Capability{CapabilityChat}
+	large.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		got = req
+		return &types.ChatCompletionResponse{ID: "large"}, nil
+	}
+	r := newTestRouter(t, []Backend{small, large})
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"max_tokens":4096}`)
+	if rec.Code != http.StatusOK || got == nil || limited.Load() != 1 {
+		t.Fatalf("status = %d, 429s = %d", rec.Code, limited.Load())
+	}
+	if v := intOrZero(got.MaxTokens); v != 4096 {
+		t.Errorf("max_tokens sent to the uncapped backend = %d, want 4096", v)
+	}
+	if h := rec.Header().Get(MaxTokensClampedHeader); h != "" {
+		t.Errorf("%s = %q for an unclamped response", MaxTokensClampedHeader, h)
+	}
+}
@@ -0,0 +1,133 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// traceMiddleware records name on the way in and out of each request.
+func traceMiddleware(name string, trace *[]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			*trace = append(*trace, name+" in")
+			next.ServeHTTP(w, req)
+			*trace = append(*trace, name+" out")
+		})
+	}
+}
+
+// flushCounter is a middleware's wrapped ResponseWriter, passing flushes on.
+type flushCounter struct {
+	http.ResponseWriter
+	flushes int
+}
+
+func (w *flushCounter) Flush() {
+	w.flushes++
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func TestMiddleware_AppliedInOrder(t *testing.T) {
+	var trace []string
+	r, err := NewRouter(
+		WithMiddleware(traceMiddleware("a", &trace)),
+		WithMiddleware(traceMiddleware("b", &trace)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got, want := strings.Join(trace, ", "), "a in, b in, b out, a out"; got != want {
+		t.Errorf("trace = %q, want %q", got, want)
+	}
+}
+
+func TestMiddleware_CanRejectRequests(t *testing.T) {
+	r, _ := NewRouter(WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				types.WriteError(w, http.StatusUnauthorized, types.InvalidRequestError("missing credentials"))
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}))
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+
+	if w := postChat(t, r, chatBody("llama-3")); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without credentials = %d, want 401", w.Code)
+	}
+	if w := postChat(t, r, chatBody("llama-3"), withAPIKey("key")); w.Code != http.StatusOK {
+		t.Errorf("status with credentials = %d, want 200", w.Code)
+	}
+}
+
+func TestMiddleware_StreamsFlushThroughWrappedWriter(t *testing.T) {
+	var counter *flushCounter
+	r, _ := NewRouter(WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			counter = &flushCounter{ResponseWriter: w}
+			next.ServeHTTP(counter, req)
+		})
+	}))
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`), nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, streamChatBody)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"content":"Hi"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if counter.flushes == 0 {
+		t.Error("stream wasn't flushed through the middleware's writer")
+	}
+}
+
+// statusRecorder is a logging middleware's wrapper, which exposes the writer
+// it wraps instead of implementing http.Flusher.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestMiddleware_StreamsThroughUnwrappingWriter(t *testing.T) {
+	r, _ := NewRouter(WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(&statusRecorder{ResponseWriter: w}, req)
+		})
+	}))
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`), nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, streamChatBody)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"content":"Hi"`) || !rec.Flushed {
+		t.Errorf("status = %d, flushed = %v, body = %s", rec.Code, rec.Flushed, rec.Body)
+	}
+}
+
+func TestWithMiddleware_RejectsNil(t *testing.T) {
+	if _, err := NewRouter(WithMiddleware(nil)); err == nil {
+		t.Error("NewRouter accepted nil middleware")
+	}
+}
@@ -0,0 +1,40 @@
+package oairouter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// requestAPIKey returns the bearer token a request was sent with, if any.
+func requestAPIKey(req *http.Request) string {
+	key, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return key
+}
+
+// modelAllowed reports whether WithModelAuthorizer lets the request's API
+// key use model. Every model is allowed if no authorizer is set.
+func (r *Router) modelAllowed(req *http.Request, model string) bool {
+	return r.modelAuthorizer == nil || r.modelAuthorizer(requestAPIKey(req), model)
+}
+
+// allowedModels returns the models the request's API key may use.
+func (r *Router) allowedModels(req *http.Request, models []types.Model) []types.Model {
+	if r.modelAuthorizer == nil {
+		return models
+	}
+	key := requestAPIKey(req)
+	allowed := models[:0:0]
+	for _, m := range models {
+		if r.modelAuthorizer(key, m.ID) {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
+
+// modelForbiddenError is returned for a model the API key may not use.
+func modelForbiddenError(model string) *types.APIError {
+	return types.NewAPIError("this API key does not have access to model "+model, types.ErrorTypePermission, nil)
+}
@@ -0,0 +1,98 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// keyModels lets "key-a" use model-a and "key-b" use both models.
+func keyModels(apiKey, model string) bool {
+	return apiKey == "key-b" || (apiKey == "key-a" && model == "model-a")
+}
+
+// modelAuthBackends serve model-a and model-b, answering with their IDs.
+func modelAuthBackends() []Backend {
+	return []Backend{modelBackend("backend-a", "model-a", true), modelBackend("backend-b", "model-b", true)}
+}
+
+func TestModelAuthorizer_RejectsForbiddenModels(t *testing.T) {
+	r := newTestRouter(t, modelAuthBackends(), WithModelAuthorizer(keyModels))
+	tests := []struct {
+		key, model string
+		want       int
+	}{
+		{"key-a", "model-a", http.StatusOK},
+		{"key-a", "model-b", http.StatusForbidden},
+		{"key-b", "model-b", http.StatusOK},
+		{"", "model-a", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := postChat(t, r, chatBody(tt.model), withAPIKey(tt.key))
+		if w.Code != tt.want {
+			t.Errorf("key %q, model %s: status = %d, want %d", tt.key, tt.model, w.Code, tt.want)
+		}
+		if w.Code == http.StatusForbidden {
+			var apiErr types.APIError
+			json.Unmarshal(w.Body.Bytes(), &apiErr)
+			if apiErr.Error.Type != types.ErrorTypePermission {
+				t.Errorf("error type = %q, want %q", apiErr.Error.Type, types.ErrorTypePermission)
+			}
+		}
+	}
+}
+
+func TestModelAuthorizer_FiltersModels(t *testing.T) {
+	r := newTestRouter(t, modelAuthBackends(), WithModelAuthorizer(keyModels))
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer key-a")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp types.ModelsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != "model-a" {
+		t.Errorf("models = %+v, want only model-a", resp.Data)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/models/model-b", nil)
+	req.Header.Set("Authorization", "Bearer key-a")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("forbidden model lookup status = %d, want 404", w.Code)
+	}
+}
+
+func TestModelAuthorizer_SkipsForbiddenFallbacks(t *testing.T) {
+	r := newTestRouter(t, modelAuthBackends(), WithModelAuthorizer(keyModels), WithModelFallback("model-c", []string{"model-b", "model-a"}))
+
+	w := postChat(t, r, chatBody("model-c"), withAPIKey("key-a"))
+	if w.Code != http.StatusOK || w.Header().Get(ModelFallbackHeader) != "model-a" {
+		t.Errorf("status = %d, %s = %q; want 200 from model-a", w.Code, ModelFallbackHeader, w.Header().Get(ModelFallbackHeader))
+	}
+}
+
+func TestWithModelAuthorizer_Nil(t *testing.T) {
+	if _, err := NewRouter(WithModelAuthorizer(nil)); err == nil {
+		t.Error("expected an error for a nil authorizer")
+	}
+}
+
+func TestModelAuthorizer_AppliesToBatchRequests(t *testing.T) {
+	r := newTestRouter(t, modelAuthBackends(), WithModelAuthorizer(keyModels), WithBatches(nil, 1))
+	body := `{"endpoint":"/v1/chat/completions","requests":[
+		{"custom_id":"b","body":{"model":"model-b","messages":[{"role":"user","content":"hi"}]}}
+	]}`
+	for key, want := range map[string]int{"key-a": http.StatusForbidden, "key-b": http.StatusOK} {
+		var created types.Batch
+		json.Unmarshal(adminDo(r, http.MethodPost, "/v1/batches", body, key).Body.Bytes(), &created)
+		batch := waitBatch(t, r, created.ID, types.BatchStatusCompleted)
+		if len(batch.Results) != 1 || batch.Results[0].Response.StatusCode != want {
+			t.Errorf("%s: batch results = %+v, want status %d", key, batch.Results, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package oairouter
+
+import (
+	"context"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// modelCacheEntry is the cached model list of one backend.
+type modelCacheEntry struct {
+	backend Backend // The instance the list was fetched from
+	models  []types.Model
+	fetched time.Time     // Zero until a fetch succeeds
+	flight  *modelsFlight // Refresh in progress, if any
+}
+
+// modelsFlight is a single Models call shared by every caller that needs the
+// backend's list while it runs.
+type modelsFlight struct {
+	done   chan struct{}
+	models []types.Model
+	err    error
+}
+
+// SetModelCacheTTL configures how long a backend's model list is reused by
+// AllModels and AvailableModels before it is fetched again. Model lists
+// fetched by health checks or registration also fill the cache. Zero, the
+// default, fetches on every listing; concurrent listings still share one
+// request per backend.
+func (r *BackendRegistry) SetModelCacheTTL(ttl time.Duration) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	r.modelCacheTTL = ttl
+}
+
+// cachedModels returns b's model list from the cache if it is fresh, or
+// fetches it. Concurrent callers for the same backend share one fetch, which
+// is bounded by timeout and not canceled when a caller gives up.
+func (r *BackendRegistry) cachedModels(ctx context.Context, b Backend, timeout time.Duration) ([]types.Model, error) {
+	r.cacheMu.Lock()
+	e := r.modelCacheEntryFor(b)
+	if models, ok := r.freshModels(e); ok {
+		r.cacheMu.Unlock()
+		return models, nil
+	}
+	f := e.flight
+	if f == nil {
+		f = &modelsFlight{done: make(chan struct{})}
+		e.flight = f
+		go r.fetchModels(context.WithoutCancel(ctx), b, timeout, e, f)
+	}
+	r.cacheMu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.models, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchModels runs one flight and stores a successful result in e.
+func (r *BackendRegistry) fetchModels(ctx context.Context, b Backend, timeout time.Duration, e *modelCacheEntry, f *modelsFlight) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	f.models, f.err = b.Models(ctx)
+
+	r.cacheMu.Lock()
+	e.flight = nil
+	if f.err == nil {
+		e.models, e.fetched = f.models, time.Now()
+	}
+	r.cacheMu.Unlock()
+	close(f.done)
+}
+
+// freshModels returns e's models if they are younger than the TTL. A newer
+// list recorded by the backend itself, e.g. during a health check, is
+// adopted first (must hold cacheMu).
+func (r *BackendRegistry) freshModels(e *modelCacheEntry) ([]types.Model, bool) {
+	if r.modelCacheTTL <= 0 {
+		return nil, false
+	}
+	if rep, ok := e.backend.(ModelsReporter); ok {
+		if models, at := rep.LastModels(); at.After(e.fetched) {
+			e.models, e.fetched = models, at
+		}
+	}
+	if e.fetched.IsZero() || time.Since(e.fetched) >= r.modelCacheTTL {
+		return nil, false
+	}
+	return e.models, true
+}
+
+// modelCacheEntryFor returns b's cache entry, starting a new one if b's ID
+// was last used by a different instance (must hold cacheMu).
+func (r *BackendRegistry) modelCacheEntryFor(b Backend) *modelCacheEntry {
+	e, ok := r.modelCache[b.ID()]
+	if !ok || e.backend != b {
+		e = &modelCacheEntry{backend: b}
+		r.modelCache[b.ID()] = e
+	}
+	return e
+}
+
+// storeModels records a model list fetched outside the cache, e.g. during
+// registration.
+func (r *BackendRegistry) storeModels(b Backend, models []types.Model) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	e := r.modelCacheEntryFor(b)
+	e.models, e.fetched = models, time.Now()
+}
+
+// forgetModels drops a backend's cached model list.
+func (r *BackendRegistry) forgetModels(backendID string) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	delete(r.modelCache, backendID)
+}
@@ -0,0 +1,147 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// countModelCalls makes b's Models calls countable.
+func countModelCalls(b *mockBackend) *atomic.Int64 {
+	var calls atomic.Int64
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		calls.Add(1)
+		return []types.Model{{ID: "test-model"}}, nil
+	}
+	return &calls
+}
+
+func TestModelCache_ReusesFreshList(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelCacheTTL(time.Minute)
+	ctx := context.Background()
+
+	b := newMockBackend("backend-a", true)
+	calls := countModelCalls(b)
+	r.Register(ctx, b)
+
+	for range 3 {
+		if models := r.AllModels(ctx); len(models) != 1 {
+			t.Fatalf("expected 1 model, got %d", len(models))
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected only the registration fetch, got %d Models calls", n)
+	}
+}
+
+func TestModelCache_RefreshesStaleList(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelCacheTTL(10 * time.Millisecond)
+	ctx := context.Background()
+
+	b := newMockBackend("backend-a", true)
+	calls := countModelCalls(b)
+	r.Register(ctx, b)
+
+	time.Sleep(20 * time.Millisecond)
+	r.AvailableModels(ctx, "")
+	r.AvailableModels(ctx, "")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected one refresh after the TTL, got %d Models calls", n)
+	}
+}
+
+func TestModelCache_DisabledFetchesEveryTime(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	b := newMockBackend("backend-a", true)
+	calls := countModelCalls(b)
+	r.Register(ctx, b)
+
+	r.AllModels(ctx)
+	r.AllModels(ctx)
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected a fetch per listing without a TTL, got %d Models calls", n)
+	}
+}
+
+func TestModelCache_ConcurrentListingsShareOneFetch(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelRetry(Backoff{})
+	r.SetModelCacheTTL(time.Minute)
+	ctx := context.Background()
+
+	b := newMockBackend("backend-a", true)
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		return nil, errors.New("not ready")
+	}
+	r.Register(ctx, b)
+
+	var calls atomic.Int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return []types.Model{{ID: "test-model"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if models := r.AllModels(ctx); len(models) != 1 {
+				t.Errorf("expected 1 model, got %d", len(models))
+			}
+		}()
+	}
+	<-started
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 shared Models call, got %d", n)
+	}
+}
+
+// reportingBackend remembers a model list fetched elsewhere, as GenericBackend
+// does during health checks.
+type reportingBackend struct {
+	*mockBackend
+	last   []types.Model
+	lastAt time.Time
+}
+
+func (b *reportingBackend) LastModels() ([]types.Model, time.Time) { return b.last, b.lastAt }
+
+func TestModelCache_AdoptsReportedModels(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelCacheTTL(time.Minute)
+	ctx := context.Background()
+
+	mock := newMockBackend("backend-a", true)
+	calls := countModelCalls(mock)
+	b := &reportingBackend{mockBackend: mock}
+	r.Register(ctx, b)
+
+	// A health check fetched a newer list after registration
+	b.last, b.lastAt = []types.Model{{ID: "new-model"}}, time.Now().Add(time.Second)
+
+	models := r.AllModels(ctx)
+	if len(models) != 1 || models[0].ID != "new-model" {
+		t.Errorf("expected the reported new-model, got %+v", models)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected no fetch beyond registration, got %d Models calls", n)
+	}
+}
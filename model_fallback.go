@@ -0,0 +1,28 @@
+package oairouter
+
+import "net/http"
+
+// ModelFallbackHeader is set on responses served by a fallback model, to the
+// model that served the request.
+const ModelFallbackHeader = "X-Model-Fallback"
+
+// fallbackModel returns the model configured with WithModelFallback to serve
+// a request for model instead: the first in its chain with a healthy backend
+// that can perform op. ok is false if model has a healthy backend itself, or
+// none of its fallbacks does, so the request is routed as usual.
+func (r *Router) fallbackModel(req *http.Request, model string, op Operation) (string, bool) {
+	chain, ok := r.modelFallbacks[model]
+	if !ok || len(r.registry.HealthyBackendsForModelOp(model, op)) > 0 {
+		return "", false
+	}
+	// A pinned backend is served as requested
+	if r.backendOverride && req.Header.Get(BackendIDHeader) != "" {
+		return "", false
+	}
+	for _, fallback := range chain {
+		if r.modelAllowed(req, fallback) && len(r.registry.HealthyBackendsForModelOp(fallback, op)) > 0 {
+			return fallback, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// modelBackend serves model, echoing the requested model in its responses.
+func modelBackend(id, model string, healthy bool) *mockBackend {
+	b := newMockBackend(id, healthy)
+	b.models = []string{model}
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: id, Model: req.Model}, nil
+	}
+	return b
+}
+
+func TestModelFallback_UsesFirstHealthyModel(t *testing.T) {
+	r, err := NewRouter(WithModelFallback("gpt-4", []string{"gpt-4-mini", "llama-3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	primary := modelBackend("openai", "gpt-4", false)
+	r.AddBackend(ctx, primary)
+	r.AddBackend(ctx, modelBackend("mini", "gpt-4-mini", false))
+	r.AddBackend(ctx, modelBackend("local", "llama-3", true))
+
+	rec := postChat(t, r, `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var resp types.ChatCompletionResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.ID != "local" || resp.Model != "llama-3" {
+		t.Errorf("served by %q as %q, want local as llama-3", resp.ID, resp.Model)
+	}
+	if got := rec.Header().Get(ModelFallbackHeader); got != "llama-3" {
+		t.Errorf("%s = %q, want llama-3", ModelFallbackHeader, got)
+	}
+
+	// Once the model is healthy again it serves its own requests
+	primary.SetHealthy(true)
+	rec = postChat(t, r, `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.ID != "openai" || rec.Header().Get(ModelFallbackHeader) != "" {
+		t.Errorf("served by %q with %s %q, want openai without fallback", resp.ID, ModelFallbackHeader, rec.Header().Get(ModelFallbackHeader))
+	}
+}
+
+func TestModelFallback_UnregisteredModel(t *testing.T) {
+	r, _ := NewRouter(WithModelFallback("gpt-4", []string{"llama-3"}))
+	r.AddBackend(context.Background(), modelBackend("local", "llama-3", true))
+
+	rec := postChat(t, r, `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(ModelFallbackHeader) != "llama-3" {
+		t.Errorf("status = %d, %s = %q; want 200 from llama-3", rec.Code, ModelFallbackHeader, rec.Header().Get(ModelFallbackHeader))
+	}
+}
+
+func TestModelFallback_NotOnBackendError(t *testing.T) {
+	r, _ := NewRouter(WithModelFallback("gpt-4", []string{"llama-3"}))
+	ctx := context.Background()
+	primary := modelBackend("openai", "gpt-4", true)
+	primary.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, errors.New("upstream failed")
+	}
+	r.AddBackend(ctx, primary)
+	r.AddBackend(ctx, modelBackend("local", "llama-3", true))
+
+	rec := postChat(t, r, `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code == http.StatusOK || rec.Header().Get(ModelFallbackHeader) != "" {
+		t.Errorf("status = %d, %s = %q; want the backend's error without fallback", rec.Code, ModelFallbackHeader, rec.Header().Get(ModelFallbackHeader))
+	}
+}
+
+func TestWithModelFallback_Invalid(t *testing.T) {
+	for _, fallbacks := range [][]string{nil, {""}, {"gpt-4"}} {
+		if _, err := NewRouter(WithModelFallback("gpt-4", fallbacks)); err == nil {
+			t.Errorf("fallbacks %q: expected an error", fallbacks)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package oairouter
+
+import "github.com/stevemurr/oairouter/types"
+
+// ModelMetadata describes a model for /v1/models beyond what its backends
+// report. Zero fields keep what the backends report.
+type ModelMetadata struct {
+	// ContextLength is the model's context window, in tokens
+	ContextLength int
+
+	// OwnedBy names the organization that owns the model
+	OwnedBy string
+
+	// Capabilities replaces the capabilities derived from the backends
+	// serving the model
+	Capabilities []Capability
+}
+
+// applyModelMetadata merges the metadata registered with WithModelMetadata
+// into each of models.
+func (r *Router) applyModelMetadata(models []types.Model) {
+	if len(r.modelMetadata) == 0 {
+		return
+	}
+	for i := range models {
+		if meta, ok := r.modelMetadataFor(models[i].ID); ok {
+			meta.apply(&models[i])
+		}
+	}
+}
+
+// modelMetadataFor returns the metadata registered for id. A type-qualified
+// model takes the metadata of the model it qualifies unless it has its own.
+func (r *Router) modelMetadataFor(id string) (ModelMetadata, bool) {
+	meta, ok := r.modelMetadata[id]
+	if !ok && r.typeQualifiedModels {
+		if bare, _, qualified := splitQualifiedModel(id); qualified {
+			meta, ok = r.modelMetadata[bare]
+		}
+	}
+	return meta, ok
+}
+
+// apply overrides model's fields with the metadata's non-zero ones.
+func (meta ModelMetadata) apply(model *types.Model) {
+	if meta.ContextLength > 0 {
+		model.ContextLength = meta.ContextLength
+	}
+	if meta.OwnedBy != "" {
+		model.OwnedBy = meta.OwnedBy
+	}
+	if meta.Capabilities != nil {
+		model.Capabilities = make([]string, len(meta.Capabilities))
+		for i, c := range meta.Capabilities {
+			model.Capabilities[i] = string(c)
+		}
+	}
+}
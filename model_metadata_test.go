@@ -0,0 +1,97 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// metadataRouter serves llama-3, which has metadata, and qwen, which reports
+// its own, from one backend.
+func metadataRouter(t *testing.T, opts ...Option) *Router {
+	t.Helper()
+	b := newMockBackend("a", true)
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		return []types.Model{
+			{ID: "llama-3", Object: "model", OwnedBy: "vllm", ContextLength: 8192},
+			{ID: "qwen", Object: "model", OwnedBy: "vllm", ContextLength: 32768},
+		}, nil
+	}
+	return newTestRouter(t, []Backend{b}, append([]Option{
+		WithModelMetadata("llama-3", ModelMetadata{ContextLength: 131072, Capabilities: []Capability{CapabilityChat}}),
+	}, opts...)...)
+}
+
+func listModels(t *testing.T, r *Router, query string) map[string]types.Model {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models"+query, nil))
+	var resp types.ModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	models := make(map[string]types.Model)
+	for _, m := range resp.Data {
+		models[m.ID] = m
+	}
+	return models
+}
+
+func TestModelMetadata_List(t *testing.T) {
+	r := metadataRouter(t)
+	models := listModels(t, r, "")
+
+	llama := models["llama-3"]
+	if llama.ContextLength != 131072 || llama.OwnedBy != "vllm" || !slices.Equal(llama.Capabilities, []string{"chat"}) {
+		t.Errorf("llama-3 = %+v, want metadata merged over the backend's", llama)
+	}
+	qwen := models["qwen"]
+	if qwen.ContextLength != 32768 || qwen.OwnedBy != "vllm" || !slices.Contains(qwen.Capabilities, "embeddings") {
+		t.Errorf("qwen = %+v, want the backend's fields", qwen)
+	}
+
+	// The capability filter uses the capabilities the metadata declares
+	models = listModels(t, r, "?capability=embeddings")
+	if _, ok := models["llama-3"]; ok || len(models) != 1 {
+		t.Errorf("embeddings models = %v, want only qwen", models)
+	}
+}
+
+func TestModelMetadata_GetModel(t *testing.T) {
+	r := metadataRouter(t, WithModelMetadata("qwen", ModelMetadata{OwnedBy: "alibaba"}))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models/qwen", nil))
+	var model types.Model
+	if err := json.Unmarshal(rec.Body.Bytes(), &model); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if model.ID != "qwen" || model.OwnedBy != "alibaba" || model.ContextLength != 32768 {
+		t.Errorf("model = %+v", model)
+	}
+}
+
+func TestModelMetadata_TypeQualified(t *testing.T) {
+	r := metadataRouter(t, WithTypeQualifiedModels(true))
+
+	if m := listModels(t, r, "")["llama-3@generic"]; m.ContextLength != 131072 {
+		t.Errorf("llama-3@generic = %+v, want llama-3's metadata", m)
+	}
+}
+
+func TestWithModelMetadata_Invalid(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"empty ID":           WithModelMetadata("", ModelMetadata{}),
+		"negative context":   WithModelMetadata("m", ModelMetadata{ContextLength: -1}),
+		"unknown capability": WithModelMetadata("m", ModelMetadata{Capabilities: []Capability{"vision"}}),
+	} {
+		if _, err := NewRouter(opt); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
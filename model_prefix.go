@@ -0,0 +1,59 @@
+package oairouter
+
+import (
+	"slices"
+	"strings"
+)
+
+// modelPrefixRoute sends models starting with prefix to backends tagged tag.
+type modelPrefixRoute struct {
+	prefix string
+	tag    string
+	strip  bool // Remove the prefix from the model sent to the backend
+}
+
+// matchModelPrefix returns the prefix route for model with the longest
+// matching prefix.
+func (r *Router) matchModelPrefix(model string) (modelPrefixRoute, bool) {
+	var best modelPrefixRoute
+	found := false
+	for _, route := range r.modelPrefixes {
+		if strings.HasPrefix(model, route.prefix) && (!found || len(route.prefix) > len(best.prefix)) {
+			best, found = route, true
+		}
+	}
+	return best, found
+}
+
+// hasTag reports whether b implements Tagged with tag among its tags.
+func hasTag(b Backend, tag string) bool {
+	tagged, ok := b.(Tagged)
+	return ok && slices.Contains(tagged.Tags(), tag)
+}
+
+// LookupByModelTaggedForOp is LookupByModelForOp restricted to backends
+// tagged tag.
+func (r *BackendRegistry) LookupByModelTaggedForOp(modelID, tag string, op Operation) (Backend, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var healthy []Backend
+	var fallback Backend
+	for _, bid := range r.backendIDsForOp(modelID, op) {
+		backend, ok := r.backends[bid]
+		if !ok || !hasTag(backend, tag) {
+			continue
+		}
+		if r.isHealthy(backend) {
+			healthy = append(healthy, backend)
+		} else if fallback == nil {
+			fallback = backend
+		}
+	}
+	if preferred := r.preferTier(healthy); len(preferred) > 0 {
+		return preferred[0], true
+	}
+
+	// No healthy backend found, return the first one anyway
+	return fallback, fallback != nil
+}
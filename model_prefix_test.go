@@ -0,0 +1,107 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// taggedBackend is a modelBackend implementing Tagged.
+type taggedBackend struct {
+	*mockBackend
+	tags []string
+}
+
+func (b *taggedBackend) Tags() []string { return b.tags }
+
+func newTaggedBackend(id, model string, healthy bool, tags ...string) *taggedBackend {
+	return &taggedBackend{mockBackend: modelBackend(id, model, healthy), tags: tags}
+}
+
+// servedBy sends a chat request for model and returns the ID of the backend
+// that served it and the model it was sent.
+func servedBy(t *testing.T, r *Router, model string) (string, string) {
+	t.Helper()
+	w := postChat(t, r, chatBody(model))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, body = %s", model, w.Code, w.Body)
+	}
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.ID, resp.Model
+}
+
+func TestModelPrefixRoute_StripsAndRoutesByTag(t *testing.T) {
+	r, err := NewRouter(
+		WithStrippedModelPrefixRoute("teamA/", "team-a"),
+		WithStrippedModelPrefixRoute("teamB/", "team-b"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r.AddBackend(ctx, newTaggedBackend("backend-a", "llama-3", true, "team-a"))
+	r.AddBackend(ctx, newTaggedBackend("backend-b", "llama-3", true, "team-b", "gpu"))
+	r.AddBackend(ctx, modelBackend("backend-untagged", "llama-3", true))
+
+	for model, want := range map[string]string{"teamA/llama-3": "backend-a", "teamB/llama-3": "backend-b"} {
+		if id, sent := servedBy(t, r, model); id != want || sent != "llama-3" {
+			t.Errorf("%s: served by %s as %q, want %s as llama-3", model, id, sent, want)
+		}
+	}
+	if id, _ := servedBy(t, r, "llama-3"); id != "backend-a" {
+		t.Errorf("unprefixed model served by %s, want normal routing", id)
+	}
+	if w := postChat(t, r, chatBody("teamC/llama-3")); w.Code != http.StatusNotFound {
+		t.Errorf("unrouted prefix status = %d, want 404", w.Code)
+	}
+}
+
+func TestModelPrefixRoute_LongestMatchAndKeepsPrefix(t *testing.T) {
+	r, _ := NewRouter(
+		WithModelPrefixRoute("team", "shared"),
+		WithModelPrefixRoute("teamA/", "team-a"),
+	)
+	ctx := context.Background()
+	r.AddBackend(ctx, newTaggedBackend("backend-shared", "teamA/llama-3", true, "shared"))
+	r.AddBackend(ctx, newTaggedBackend("backend-a", "teamA/llama-3", true, "team-a"))
+
+	if id, sent := servedBy(t, r, "teamA/llama-3"); id != "backend-a" || sent != "teamA/llama-3" {
+		t.Errorf("served by %s as %q, want backend-a as teamA/llama-3", id, sent)
+	}
+}
+
+func TestModelPrefixRoute_NoTaggedBackend(t *testing.T) {
+	r, _ := NewRouter(WithStrippedModelPrefixRoute("teamA/", "team-a"))
+	r.AddBackend(context.Background(), newTaggedBackend("backend-b", "llama-3", true, "team-b"))
+
+	if w := postChat(t, r, chatBody("teamA/llama-3")); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestModelPrefixRoute_AuthorizesPrefixedModel(t *testing.T) {
+	r, _ := NewRouter(
+		WithStrippedModelPrefixRoute("teamA/", "team-a"),
+		WithModelAuthorizer(func(apiKey, model string) bool { return model == "teamA/llama-3" }),
+	)
+	r.AddBackend(context.Background(), newTaggedBackend("backend-a", "llama-3", true, "team-a"))
+
+	if w := postChat(t, r, chatBody("teamA/llama-3"), withAPIKey("key")); w.Code != http.StatusOK {
+		t.Errorf("prefixed model status = %d, want 200", w.Code)
+	}
+}
+
+func TestWithModelPrefixRoute_Validation(t *testing.T) {
+	if _, err := NewRouter(WithModelPrefixRoute("", "team-a")); err == nil {
+		t.Error("expected an error for an empty prefix")
+	}
+	if _, err := NewRouter(WithStrippedModelPrefixRoute("teamA/", "")); err == nil {
+		t.Error("expected an error for an empty tag")
+	}
+}
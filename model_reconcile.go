@@ -0,0 +1,94 @@
+package oairouter
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// reconcileModels fetches b's model list and brings its model mappings in
+// line with it, returning the models added and removed. Mappings are left as
+// they are if the fetch fails or b was unregistered or replaced meanwhile.
+func (r *BackendRegistry) reconcileModels(ctx context.Context, b Backend) (added, removed []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.modelFetchTimeout)
+	defer cancel()
+	models, err := b.Models(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.backends[b.ID()] != b {
+		return nil, nil, nil
+	}
+	r.storeModels(b, models)
+	r.cancelModelRetry(b.ID())
+
+	listed := make(map[string]bool, len(models))
+	for _, model := range models {
+		listed[model.ID] = true
+		if !slices.Contains(r.models[model.ID], b.ID()) {
+			r.addModelMapping(model.ID, b.ID())
+			added = append(added, model.ID)
+		}
+	}
+	for modelID, backendIDs := range r.models {
+		if listed[modelID] || !slices.Contains(backendIDs, b.ID()) {
+			continue
+		}
+		removed = append(removed, modelID)
+		remaining := slices.DeleteFunc(slices.Clone(backendIDs), func(id string) bool { return id == b.ID() })
+		if len(remaining) == 0 {
+			delete(r.models, modelID)
+		} else {
+			r.models[modelID] = remaining
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed, nil
+}
+
+func (r *Router) modelReconcileLoop(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.modelReconcile)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcileModels(ctx)
+			r.updateReadiness()
+		}
+	}
+}
+
+// reconcileModels refreshes every backend's model mappings from its current
+// model list, concurrently. Models a backend stopped listing, e.g. because
+// they were unloaded, are no longer routed to it; each change is logged and
+// reported to the discovery callback as an EventUpdated.
+func (r *Router) reconcileModels(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range r.registry.AllBackends() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added, removed, err := r.registry.reconcileModels(ctx, b)
+			if err != nil {
+				r.logger.Debug("model reconcile skipped", "backend", b.ID(), "error", err)
+				return
+			}
+			if len(added) == 0 && len(removed) == 0 {
+				return
+			}
+			r.logger.Info("backend models updated", "id", b.ID(), "event", EventUpdated, "added", added, "removed", removed)
+			r.notifyDiscovery(DiscoveryEvent{Type: EventUpdated, Backend: b})
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,132 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// listingBackend lists whatever models are set on it, or fails with err.
+type listingBackend struct {
+	*mockBackend
+	mu     sync.Mutex
+	listed []string
+	err    error
+}
+
+func newListingBackend(id string, models ...string) *listingBackend {
+	b := &listingBackend{mockBackend: newMockBackend(id, true), listed: models}
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.err != nil {
+			return nil, b.err
+		}
+		models := make([]types.Model, len(b.listed))
+		for i, id := range b.listed {
+			models[i] = types.Model{ID: id, Object: "model"}
+		}
+		return models, nil
+	}
+	return b
+}
+
+func (b *listingBackend) list(err error, models ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listed, b.err = models, err
+}
+
+func servingBackends(r *Router, model string) []string {
+	var ids []string
+	for _, b := range r.registry.HealthyBackendsForModelOp(model, OperationAny) {
+		ids = append(ids, b.ID())
+	}
+	return ids
+}
+
+func TestReconcileModels_DropsUnlistedModels(t *testing.T) {
+	r, _ := NewRouter()
+	a := newListingBackend("backend-a", "llama-3", "mistral")
+	r.AddBackend(context.Background(), a)
+	r.AddBackend(context.Background(), newListingBackend("backend-b", "llama-3"))
+
+	a.list(nil, "llama-3", "qwen")
+	r.reconcileModels(context.Background())
+
+	if ids := servingBackends(r, "mistral"); len(ids) != 0 {
+		t.Errorf("unloaded model still served by %v", ids)
+	}
+	if ids := servingBackends(r, "qwen"); len(ids) != 1 || ids[0] != "backend-a" {
+		t.Errorf("newly listed model served by %v", ids)
+	}
+	if ids := servingBackends(r, "llama-3"); len(ids) != 2 {
+		t.Errorf("llama-3 served by %v, want both backends", ids)
+	}
+	if w := postChat(t, r, chatBody("mistral")); w.Code != 404 {
+		t.Errorf("request for unloaded model: status = %d, want 404", w.Code)
+	}
+
+	// A backend that lists nothing serves nothing
+	a.list(nil)
+	r.reconcileModels(context.Background())
+	if ids := servingBackends(r, "qwen"); len(ids) != 0 {
+		t.Errorf("qwen still served by %v", ids)
+	}
+	if ids := servingBackends(r, "llama-3"); len(ids) != 1 || ids[0] != "backend-b" {
+		t.Errorf("llama-3 served by %v, want backend-b", ids)
+	}
+}
+
+func TestReconcileModels_KeepsMappingsWhenListingFails(t *testing.T) {
+	r, _ := NewRouter()
+	a := newListingBackend("backend-a", "llama-3")
+	r.AddBackend(context.Background(), a)
+
+	a.list(errors.New("connection refused"))
+	r.reconcileModels(context.Background())
+	if ids := servingBackends(r, "llama-3"); len(ids) != 1 {
+		t.Errorf("llama-3 served by %v after a failed listing", ids)
+	}
+}
+
+func TestWithModelReconcileInterval_ReportsUpdates(t *testing.T) {
+	received := make(chan DiscoveryEvent, 10)
+	r, err := NewRouter(
+		WithModelReconcileInterval(10*time.Millisecond),
+		WithHealthCheckInterval(time.Hour),
+		WithDiscoveryCallback(func(e DiscoveryEvent) { received <- e }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newListingBackend("backend-a", "llama-3", "mistral")
+	r.AddBackend(context.Background(), a)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop(context.Background())
+
+	a.list(nil, "llama-3")
+	select {
+	case e := <-received:
+		if e.Type != EventUpdated || e.Backend.ID() != "backend-a" {
+			t.Errorf("got %s %s, want updated backend-a", e.Type, e.Backend.ID())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the update")
+	}
+	if ids := servingBackends(r, "mistral"); len(ids) != 0 {
+		t.Errorf("unloaded model still served by %v", ids)
+	}
+}
+
+func TestWithModelReconcileInterval_Invalid(t *testing.T) {
+	if _, err := NewRouter(WithModelReconcileInterval(0)); err == nil {
+		t.Error("accepted a zero interval")
+	}
+}
@@ -1,9 +1,15 @@
 package oairouter
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
+
+	"github.com/stevemurr/oairouter/types"
+	"golang.org/x/sync/singleflight"
 )
 
 // Option configures the Router.
@@ -33,6 +39,51 @@ func WithHealthCheckInterval(d time.Duration) Option {
 	}
 }
 
+// WithModelReconcileInterval refetches every backend's model list each
+// interval and updates the model mappings to match, so a model a backend
+// stops listing, e.g. because it was unloaded, is no longer routed to it,
+// and one it starts listing is. Backends whose list can't be fetched keep
+// their mappings. Changes are logged and passed to the discovery callback as
+// EventUpdated.
+func WithModelReconcileInterval(d time.Duration) Option {
+	return func(r *Router) error {
+		if d <= 0 {
+			return fmt.Errorf("model reconcile interval must be positive")
+		}
+		r.modelReconcile = d
+		return nil
+	}
+}
+
+// WithDeepHealthCheck asks every backend serving model for one token each
+// interval, on top of the regular health check, which only lists models and
+// passes while inference hangs. A backend that errors or doesn't answer
+// within DefaultDeepHealthCheckTimeout (see WithDeepHealthCheckTimeout) is
+// reported unhealthy until it passes a later deep check. An empty model
+// checks each backend with its first model. interval should be longer than
+// the regular health check's, as each check runs inference.
+func WithDeepHealthCheck(model string, interval time.Duration) Option {
+	return func(r *Router) error {
+		if interval <= 0 {
+			return fmt.Errorf("deep health check interval must be positive")
+		}
+		r.deepHealth = &deepHealthCheck{model: model, interval: interval}
+		return nil
+	}
+}
+
+// WithDeepHealthCheckTimeout sets how long a deep health check waits for
+// its token before failing the backend.
+func WithDeepHealthCheckTimeout(d time.Duration) Option {
+	return func(r *Router) error {
+		if d <= 0 {
+			return fmt.Errorf("deep health check timeout must be positive")
+		}
+		r.deepHealthTimeout = d
+		return nil
+	}
+}
+
 // WithDefaultBackend sets a fallback backend ID when model not found.
 func WithDefaultBackend(backendID string) Option {
 	return func(r *Router) error {
@@ -41,6 +92,16 @@ func WithDefaultBackend(backendID string) Option {
 	}
 }
 
+// WithDefaultModel sets the model used for requests that omit the model
+// field. Unlike WithDefaultBackend, the default is routed like any other
+// model, so it may be served by several backends.
+func WithDefaultModel(model string) Option {
+	return func(r *Router) error {
+		r.defaultModel = model
+		return nil
+	}
+}
+
 // WithDiscoverer adds a backend discoverer.
 func WithDiscoverer(d Discoverer) Option {
 	return func(r *Router) error {
@@ -49,14 +110,958 @@ func WithDiscoverer(d Discoverer) Option {
 	}
 }
 
-// WithSessionAffinity enables session affinity via the X-Session-ID header.
+// WithSessionAffinity enables session affinity via the X-Session-ID header
+// (or the header set with WithSessionHeader).
 // When enabled, requests with the same session ID are consistently routed to
 // the same backend using consistent hashing. If the preferred backend is
 // unhealthy, the request falls back to another healthy backend and sets
-// the X-Session-Broken response header to indicate session state may be lost.
+// the X-Session-Broken and X-Session-Rebalanced response headers to indicate
+// session state may be lost. Requests without the header are routed as if
+// session affinity were off: by WithRoutingPolicy, WithHealthScoring or
+// WithFastestRouting if set, in that order of precedence, or else to the
+// first available backend. Session affinity in turn takes precedence over
+// all three for requests with the header.
 func WithSessionAffinity(enabled bool) Option {
 	return func(r *Router) error {
 		r.sessionAffinity = enabled
 		return nil
 	}
 }
+
+// WithSessionHeader enables session affinity keyed on the given request
+// header instead of X-Session-ID, e.g. a conversation ID header set by the
+// client. Keeping a conversation on one backend lets it reuse its prefix cache.
+func WithSessionHeader(name string) Option {
+	return func(r *Router) error {
+		if name == "" {
+			return fmt.Errorf("session header name must not be empty")
+		}
+		r.sessionHeader = http.CanonicalHeaderKey(name)
+		r.sessionAffinity = true
+		return nil
+	}
+}
+
+// WithSessionStore enables session affinity with sessions pinned to backends
+// in s, e.g. NewMemorySessionStore(time.Hour), instead of mapped by hashing
+// alone. A new session is pinned to the backend hashing picks, and stays
+// there while the pin lasts, even as backends are added. If its backend
+// becomes unhealthy or is removed, the session is re-pinned and the response
+// carries X-Session-Broken.
+func WithSessionStore(s SessionStore) Option {
+	return func(r *Router) error {
+		if s == nil {
+			return fmt.Errorf("session store must not be nil")
+		}
+		r.sessionStore = s
+		r.sessionAffinity = true
+		return nil
+	}
+}
+
+// WithEmbeddingsCache caches embeddings responses for ttl, keyed by the full
+// request. Any Cache implementation may be used; pass a shared cache such as
+// rediscache to share hits across router replicas. A nil cache selects an
+// in-memory cache.
+func WithEmbeddingsCache(c Cache, ttl time.Duration) Option {
+	return func(r *Router) error {
+		if c == nil {
+			c = NewMemoryCache()
+		}
+		r.embeddingsCache = c
+		r.embeddingsCacheTTL = ttl
+		return nil
+	}
+}
+
+// WithEmbeddingsFormatConversion returns embeddings in the encoding_format
+// the request asked for, float or base64, converting them when the backend
+// returned the other, e.g. because it doesn't support base64. base64
+// embeddings are little-endian float32 values, so converting floats to
+// base64 rounds them to float32. By default embeddings are returned in the
+// form the backend sent.
+func WithEmbeddingsFormatConversion(enabled bool) Option {
+	return func(r *Router) error {
+		r.convertEmbeddings = enabled
+		return nil
+	}
+}
+
+// WithRequestCoalescing has concurrent identical non-streaming requests,
+// those with the same body for the same endpoint, share one backend call:
+// the first is sent and the others receive its response, or its error.
+// Only requests with the same API key and forwarded headers are coalesced,
+// and those pinned to a backend only with those pinned to the same one. A
+// client giving up or timing out doesn't cancel the shared call for the
+// others waiting on it.
+func WithRequestCoalescing() Option {
+	return func(r *Router) error {
+		r.coalescing = new(singleflight.Group)
+		r.coalescingCalls = make(map[string]*coalescedCall)
+		return nil
+	}
+}
+
+// WithServerTiming sets a Server-Timing header on non-streaming responses
+// served by a backend, breaking down the time spent selecting the backend
+// (lookup), waiting on it (backend, described by its ID), and handling the
+// request in total. Browsers show it in their developer tools.
+func WithServerTiming() Option {
+	return func(r *Router) error {
+		r.serverTiming = true
+		return nil
+	}
+}
+
+// WithSeedVerification is a debugging aid for reproducible outputs. Responses
+// to requests with a seed get the seed sent to the backend in X-Seed and,
+// when not streamed, the backend's system_fingerprint in
+// X-System-Fingerprint. A response without a system_fingerprint suggests
+// the backend ignored the seed; it is logged as a warning and counted per
+// backend in /v1/router/stats as missing_system_fingerprints.
+func WithSeedVerification(enabled bool) Option {
+	return func(r *Router) error {
+		r.seedVerification = enabled
+		return nil
+	}
+}
+
+// WithModelRetry configures how model fetches are retried for backends that
+// are registered before they are ready. Pass a zero Backoff to disable retries.
+func WithModelRetry(b Backoff) Option {
+	return func(r *Router) error {
+		r.registry.SetModelRetry(b)
+		return nil
+	}
+}
+
+// WithModelFetchTimeout bounds how long /v1/models waits for each backend's
+// model list. Backends are queried concurrently; one that doesn't answer in
+// time is left out of the listing. Defaults to DefaultModelFetchTimeout.
+func WithModelFetchTimeout(d time.Duration) Option {
+	return func(r *Router) error {
+		if d <= 0 {
+			return fmt.Errorf("model fetch timeout must be positive")
+		}
+		r.registry.SetModelFetchTimeout(d)
+		return nil
+	}
+}
+
+// WithModelCacheTTL caches each backend's model list for ttl, so /v1/models
+// only queries backends whose list is stale. Lists fetched by health checks
+// refresh the cache too. Concurrent listings share one request per backend
+// whether or not caching is enabled.
+func WithModelCacheTTL(ttl time.Duration) Option {
+	return func(r *Router) error {
+		if ttl < 0 {
+			return fmt.Errorf("model cache ttl must not be negative")
+		}
+		r.registry.SetModelCacheTTL(ttl)
+		return nil
+	}
+}
+
+// WithFanOutN splits non-streaming chat requests with n>1 into n parallel
+// n=1 requests spread across the model's healthy backends, merging the
+// resulting choices into one response. Useful for backends that only handle
+// n=1 efficiently. By default, choices from successful requests are returned
+// even if some requests fail; see WithFanOutNRequireAll.
+func WithFanOutN(enabled bool) Option {
+	return func(r *Router) error {
+		r.fanOutN = enabled
+		return nil
+	}
+}
+
+// WithFanOutNRequireAll makes a fanned-out request fail if any of its n=1
+// requests fails, instead of returning the partial set of choices.
+func WithFanOutNRequireAll(requireAll bool) Option {
+	return func(r *Router) error {
+		r.fanOutNRequireAll = requireAll
+		return nil
+	}
+}
+
+// WithFanOutNLimit sets the largest n a request may ask for when WithFanOutN
+// is enabled; requests asking for more get a 400. The default is
+// DefaultFanOutNLimit.
+func WithFanOutNLimit(max int) Option {
+	return func(r *Router) error {
+		if max <= 0 {
+			return fmt.Errorf("fan-out n limit must be positive")
+		}
+		r.fanOutNLimit = max
+		return nil
+	}
+}
+
+// WithLogprobsPolicy sets how requests for log probabilities are handled for
+// all backends: forwarded (LogprobsAllow), removed (LogprobsStrip), or
+// rejected with a 400 when the backend lacks CapabilityLogprobs (LogprobsReject).
+func WithLogprobsPolicy(p LogprobsPolicy) Option {
+	return func(r *Router) error {
+		if !p.valid() {
+			return fmt.Errorf("invalid logprobs policy: %q", p)
+		}
+		r.logprobsPolicy = p
+		return nil
+	}
+}
+
+// WithBackendLogprobsPolicy overrides the logprobs policy for one backend.
+func WithBackendLogprobsPolicy(backendID string, p LogprobsPolicy) Option {
+	return func(r *Router) error {
+		if !p.valid() {
+			return fmt.Errorf("invalid logprobs policy: %q", p)
+		}
+		if r.backendLogprobs == nil {
+			r.backendLogprobs = make(map[string]LogprobsPolicy)
+		}
+		r.backendLogprobs[backendID] = p
+		return nil
+	}
+}
+
+// WithLocalTokenCounting appends an estimated usage chunk to streams whose
+// backend finishes without reporting usage. Counts come from the tokenizer
+// package's heuristic and are marked with "estimated": true.
+func WithLocalTokenCounting(enabled bool) Option {
+	return func(r *Router) error {
+		r.localTokenCounting = enabled
+		return nil
+	}
+}
+
+// WithStreamUsage sets stream_options.include_usage on every streamed chat
+// request, so backends report the tokens each stream used, and logs the
+// counts; they are also recorded in request logs. With strip, the usage
+// chunk is dropped from streams whose client didn't ask for it. Backends
+// that ignore the option stream as before, with nothing logged.
+func WithStreamUsage(strip bool) Option {
+	return func(r *Router) error {
+		r.streamUsage = true
+		r.streamUsageStrip = strip
+		return nil
+	}
+}
+
+// WithAdmin enables the /admin endpoints for inspecting the backend registry.
+// If token is non-empty, requests must send it as "Authorization: Bearer <token>".
+// The endpoints expose backend URLs, so only leave token empty when the
+// router isn't reachable by untrusted clients.
+func WithAdmin(token string) Option {
+	return func(r *Router) error {
+		r.adminEnabled = true
+		r.adminToken = token
+		return nil
+	}
+}
+
+// WithStatsAuth requires the WithAdmin token for GET /v1/router/stats, which
+// is otherwise open to anyone who can reach the router. The stats name every
+// backend and model, so enable it when untrusted clients can reach the
+// router. It has no effect unless WithAdmin sets a token.
+func WithStatsAuth(enabled bool) Option {
+	return func(r *Router) error {
+		r.statsAuth = enabled
+		return nil
+	}
+}
+
+// WithReliabilityHedge hedges non-streaming requests across backend types.
+// When a model is served by healthy backends of both types, the request goes
+// to a primaryType backend (e.g. a local server) first; if it hasn't succeeded
+// within delay, the same request is sent to a fallbackType backend (e.g. a
+// cloud endpoint) and the first success wins. The losing request is canceled,
+// and the winning type is reported in the X-Served-By-Type response header.
+func WithReliabilityHedge(primaryType, fallbackType BackendType, delay time.Duration) Option {
+	return func(r *Router) error {
+		if primaryType == fallbackType {
+			return fmt.Errorf("hedge primary and fallback types must differ")
+		}
+		if delay < 0 {
+			return fmt.Errorf("hedge delay must not be negative")
+		}
+		r.reliabilityHedge = &reliabilityHedge{primary: primaryType, fallback: fallbackType, delay: delay}
+		return nil
+	}
+}
+
+// WithBackendFactory sets how backends registered through POST /admin/backends
+// are constructed, typically backends.Factory().
+func WithBackendFactory(f BackendFactory) Option {
+	return func(r *Router) error {
+		r.backendFactory = f
+		return nil
+	}
+}
+
+// WithTypeQualifiedModels exposes each model once per serving backend type as
+// "<model>@<type>" (e.g. "llama-3@vllm"), alongside the merged model ID, so
+// clients can pick between backend types serving the same model. A qualified
+// request is only routed to backends of that type, with the qualifier removed.
+//
+// The qualifier is the text after the last '@', so model IDs containing '/'
+// keep working, including in GET /v1/models/{model...}, whose wildcard
+// captures the rest of the path (e.g. /v1/models/meta-llama/Llama-3@vllm).
+// A model ID that a backend advertises with an '@' in it is matched verbatim
+// rather than treated as qualified.
+func WithTypeQualifiedModels(enabled bool) Option {
+	return func(r *Router) error {
+		r.typeQualifiedModels = enabled
+		r.registry.SetTypeQualifiedModels(enabled)
+		return nil
+	}
+}
+
+// WithMaxRequestTimeout caps the deadline clients can request with the
+// X-Request-Timeout header, and applies d to requests without one. A request
+// that runs out of time fails with 504 Gateway Timeout.
+func WithMaxRequestTimeout(d time.Duration) Option {
+	return func(r *Router) error {
+		if d <= 0 {
+			return fmt.Errorf("max request timeout must be positive")
+		}
+		r.maxRequestTimeout = d
+		return nil
+	}
+}
+
+// WithBackendTLS sets how the router reaches the HTTPS backend with backendID,
+// however it is registered: caBundle holds PEM certificates to trust instead
+// of the system roots, e.g. an internal CA, and insecureSkipVerify disables
+// certificate verification altogether. Backends must implement TLSConfigurer,
+// as those in the backends package do.
+func WithBackendTLS(backendID string, caBundle []byte, insecureSkipVerify bool) Option {
+	return func(r *Router) error {
+		if backendID == "" {
+			return fmt.Errorf("backend ID must not be empty")
+		}
+		cfg, err := backendTLSConfig(caBundle, insecureSkipVerify)
+		if err != nil {
+			return fmt.Errorf("TLS for backend %s: %w", backendID, err)
+		}
+		if r.backendTLS == nil {
+			r.backendTLS = make(map[string]*tls.Config)
+		}
+		r.backendTLS[backendID] = cfg
+		return nil
+	}
+}
+
+// WithDiscoveryCallback calls fn for each backend added, removed, or updated
+// by discovery, once the registry reflects the change, e.g. to update an
+// external dashboard. Backends that fail to register are not reported. fn is
+// called from a single goroutine in event order and never blocks discovery;
+// if it falls too far behind, events are dropped with a warning.
+func WithDiscoveryCallback(fn func(DiscoveryEvent)) Option {
+	return func(r *Router) error {
+		if fn == nil {
+			return fmt.Errorf("discovery callback must not be nil")
+		}
+		r.discoveryCallback = fn
+		r.discoveryEvents = make(chan DiscoveryEvent, discoveryCallbackBuffer)
+		return nil
+	}
+}
+
+// WithCanary splits model's traffic between the backends in weights, keyed by
+// backend ID, choosing one at random per request in proportion to its weight,
+// e.g. {"stable": 90, "canary": 10}. A key of the form "other-model@backend"
+// sends the requests drawn for it to backend as other-model, e.g. to try a
+// new model under the name clients already use. Backends that are unhealthy
+// or don't serve the model they are keyed for are left out of the draw. The
+// split takes precedence over session affinity and routing policies, and can
+// be changed at runtime with SetCanary or PUT /admin/canaries/{model}.
+func WithCanary(model string, weights map[string]float64) Option {
+	return func(r *Router) error {
+		return r.SetCanary(model, weights)
+	}
+}
+
+// WithShadowBackend mirrors sampleRate (0 to 1) of chat completion requests to
+// the backend with backendID once the real request has been served, e.g. to
+// try a new model on production traffic. Mirrored requests are sent without
+// streaming, their responses are discarded, and only their latency and errors
+// are logged; they never affect the client's response. Requests routed to the
+// shadow backend itself aren't mirrored.
+func WithShadowBackend(backendID string, sampleRate float64) Option {
+	return func(r *Router) error {
+		if backendID == "" {
+			return fmt.Errorf("shadow backend ID must not be empty")
+		}
+		if sampleRate <= 0 || sampleRate > 1 {
+			return fmt.Errorf("shadow sample rate must be in (0, 1]")
+		}
+		r.shadow = &shadowTraffic{backendID: backendID, sampleRate: sampleRate}
+		return nil
+	}
+}
+
+// WithShadowTimeout bounds each request mirrored by WithShadowBackend.
+// Defaults to DefaultShadowTimeout.
+func WithShadowTimeout(d time.Duration) Option {
+	return func(r *Router) error {
+		if d <= 0 {
+			return fmt.Errorf("shadow timeout must be positive")
+		}
+		r.shadowTimeout = d
+		return nil
+	}
+}
+
+// WithShadowModel sends requests mirrored by WithShadowBackend for model,
+// e.g. a candidate served under a different name, instead of the model the
+// real request resolved to.
+func WithShadowModel(model string) Option {
+	return func(r *Router) error {
+		if model == "" {
+			return fmt.Errorf("shadow model must not be empty")
+		}
+		r.shadowModel = model
+		return nil
+	}
+}
+
+// WithShadowConcurrency caps how many requests mirrored by WithShadowBackend
+// are in flight at once, DefaultShadowConcurrency by default. Requests
+// sampled while the cap is reached aren't mirrored, so a slow shadow backend
+// can't pile up goroutines.
+func WithShadowConcurrency(n int) Option {
+	return func(r *Router) error {
+		if n <= 0 {
+			return fmt.Errorf("shadow concurrency must be positive")
+		}
+		r.shadowConcurrency = n
+		return nil
+	}
+}
+
+// WithRoutingPolicy selects backends with p, e.g. NewRoundRobin() or a custom
+// strategy based on cost or region, instead of the default first-available
+// selection. It takes precedence over WithHealthScoring. Requests pinned by
+// session affinity, a type-qualified model, or X-Backend-ID bypass it.
+func WithRoutingPolicy(p RoutingPolicy) Option {
+	return func(r *Router) error {
+		r.routingPolicy = p
+		return nil
+	}
+}
+
+// WithScoredRouting spreads requests over a model's healthy backends in
+// proportion to the scores the scorer build returns gives them, e.g. one
+// combining the registry's built-in scorers:
+//
+//	oairouter.WithScoredRouting(func(reg *oairouter.BackendRegistry) oairouter.Scorer {
+//		return oairouter.CombineScorers(reg.LatencyScorer(), reg.FailureRecencyScorer(time.Minute))
+//	})
+//
+// build is called once, with the router's registry. It sets the routing
+// policy to ScoredPolicy of that scorer, so it replaces an earlier
+// WithRoutingPolicy and is replaced by a later one.
+func WithScoredRouting(build func(*BackendRegistry) Scorer) Option {
+	return func(r *Router) error {
+		if build == nil {
+			return fmt.Errorf("scored routing needs a scorer")
+		}
+		scorer := build(r.registry)
+		if scorer == nil {
+			return fmt.Errorf("scored routing needs a scorer")
+		}
+		r.routingPolicy = ScoredPolicy(scorer)
+		return nil
+	}
+}
+
+// WithResponseFormatValidation checks non-streaming chat responses against
+// the request's response_format, for backends that ignore it. Each choice's
+// content must be a JSON object for json_object, or match the schema for
+// json_schema. A mismatch fails the request with a 502 naming the failing
+// path in the content; see WithResponseFormatRetry to retry first.
+func WithResponseFormatValidation() Option {
+	return func(r *Router) error {
+		r.responseFormatCheck = true
+		return nil
+	}
+}
+
+// WithResponseFormatRetry retries a request once when its response fails
+// response_format validation, before returning an error. The retry goes to
+// another backend serving the model if one can take the request, else to the
+// same one. It has no effect without WithResponseFormatValidation.
+func WithResponseFormatRetry(enabled bool) Option {
+	return func(r *Router) error {
+		r.responseFormatRetry = enabled
+		return nil
+	}
+}
+
+// WithBackendOverrideHeader lets clients send a request to a specific backend
+// with the X-Backend-ID header, e.g. to compare two replicas. Model routing,
+// hedging, fan-out and stream rerouting are skipped for such requests. An
+// unknown backend gets a 404, and one that doesn't serve the requested model
+// a 400. Only enable this where clients are trusted to pick backends.
+func WithBackendOverrideHeader() Option {
+	return func(r *Router) error {
+		r.backendOverride = true
+		return nil
+	}
+}
+
+// WithEmbeddingBatchSize splits embeddings requests with more than n inputs
+// into batches of at most n, sent concurrently to the selected backend. The
+// responses are merged in input order with summed usage; if any batch fails
+// the whole request fails. Zero disables batching.
+func WithEmbeddingBatchSize(n int) Option {
+	return func(r *Router) error {
+		if n < 0 {
+			return fmt.Errorf("embedding batch size must not be negative")
+		}
+		r.embeddingBatchSize = n
+		return nil
+	}
+}
+
+// WithHealthScoring selects among a model's healthy backends at random,
+// weighted by each backend's health score, instead of always using the first
+// healthy one. The score blends the recent rate of server errors, timeouts,
+// and transport failures, p95 latency, in-flight saturation, and the last
+// health check; backends can supply their own by implementing HealthScorer.
+// Scores are reported in /health.
+func WithHealthScoring(enabled bool) Option {
+	return func(r *Router) error {
+		r.healthScoring = enabled
+		return nil
+	}
+}
+
+// WithFastestRouting selects the healthy backend with the lowest moving
+// average of response latency (time to first event, for streams) instead of
+// the first healthy one. A small share of requests go to a random backend so
+// new or recovering backends get measured. Averages are reported in
+// /admin/backends. WithRoutingPolicy and WithHealthScoring take precedence.
+func WithFastestRouting(enabled bool) Option {
+	return func(r *Router) error {
+		r.fastestRouting = enabled
+		return nil
+	}
+}
+
+// WithStreamCompression gzips streamed responses for clients whose
+// Accept-Encoding allows it. Each event is flushed as a complete gzip block,
+// so events still arrive as they are generated. Non-streaming responses are
+// unaffected.
+func WithStreamCompression(enabled bool) Option {
+	return func(r *Router) error {
+		r.streamCompression = enabled
+		return nil
+	}
+}
+
+// WithToolCallValidation reassembles the tool calls in streamed chat
+// responses and logs a warning when one is truncated or malformed: missing
+// its ID or function name, or with arguments that aren't valid JSON by the
+// end of the stream. Streams are passed through unchanged; affected streams
+// are counted per backend in /v1/router/stats as incomplete_tool_calls.
+func WithToolCallValidation(enabled bool) Option {
+	return func(r *Router) error {
+		r.toolCallValidation = enabled
+		return nil
+	}
+}
+
+// WithRequestQueue queues requests for a backend at its concurrency limit
+// (see ConcurrencyLimiter) instead of sending them anyway. Up to maxQueue
+// requests wait, first come first served, for up to maxWait each; a request
+// arriving to a full queue or waiting longer gets a 503 with Retry-After. A
+// queued request whose client disconnects leaves the queue. Every backend
+// call goes through the queue, including hedges, reroutes, fan-out, and
+// shadow requests; a reroute or hedge that gets no slot is skipped. Requests
+// don't wait out a backend's 429 rate limit in the queue; with no other
+// replica, the 429 is forwarded.
+func WithRequestQueue(maxQueue int, maxWait time.Duration) Option {
+	return func(r *Router) error {
+		if maxQueue <= 0 {
+			return fmt.Errorf("request queue size must be positive")
+		}
+		if maxWait <= 0 {
+			return fmt.Errorf("request queue wait must be positive")
+		}
+		r.requestQueue = newRequestQueue(r.registry, maxQueue, maxWait)
+		return nil
+	}
+}
+
+// WithUpstreamErrors forwards a backend's error responses to the client as
+// is, with the upstream status code, body, and request ID headers, instead of
+// mapping them to an OpenAI error. It is meant for debugging: upstream
+// authentication failures and other details the router normally hides reach
+// clients.
+func WithUpstreamErrors(enabled bool) Option {
+	return func(r *Router) error {
+		r.upstreamErrors = enabled
+		return nil
+	}
+}
+
+// WithMaxRequestBytes limits request bodies to n bytes. Larger requests are
+// rejected with 413 as soon as the limit is read past, so an oversized body is
+// never held in memory.
+func WithMaxRequestBytes(n int64) Option {
+	return func(r *Router) error {
+		if n <= 0 {
+			return fmt.Errorf("max request bytes must be positive")
+		}
+		r.maxRequestBytes = n
+		return nil
+	}
+}
+
+// WithLoadShedding consults overloaded before each API request and rejects
+// the request with 503 and Retry-After while it returns true, before the
+// body is read or a backend chosen. overloaded can check anything, e.g.
+// goroutine count or memory use; it is called concurrently and should be
+// fast. Rejected requests are counted in /v1/router/stats as shed_requests.
+func WithLoadShedding(overloaded func() bool) Option {
+	return func(r *Router) error {
+		if overloaded == nil {
+			return fmt.Errorf("load shedding func must not be nil")
+		}
+		r.loadShedding = overloaded
+		return nil
+	}
+}
+
+// WithMaxInFlight sheds load once max requests are in flight across all
+// backends. It is WithLoadShedding with a check of the router's own load,
+// and replaces any check set with it.
+func WithMaxInFlight(max int) Option {
+	return func(r *Router) error {
+		if max <= 0 {
+			return fmt.Errorf("max in-flight requests must be positive")
+		}
+		limit := int64(max)
+		r.loadShedding = func() bool { return r.registry.TotalInFlight() >= limit }
+		return nil
+	}
+}
+
+// WithModelMetadata describes model in /v1/models and /v1/models/{id},
+// e.g. its context length and owner. Fields set in meta override what the
+// model's backends report; the rest are kept. meta.Capabilities replaces the
+// capabilities derived from the backends, and ?capability= filters by it.
+func WithModelMetadata(id string, meta ModelMetadata) Option {
+	return func(r *Router) error {
+		if id == "" {
+			return fmt.Errorf("model metadata needs a model ID")
+		}
+		if meta.ContextLength < 0 {
+			return fmt.Errorf("context length for model %q must not be negative", id)
+		}
+		for _, c := range meta.Capabilities {
+			if !ValidCapability(c) {
+				return fmt.Errorf("unknown capability %q for model %q", c, id)
+			}
+		}
+		if r.modelMetadata == nil {
+			r.modelMetadata = make(map[string]ModelMetadata)
+		}
+		meta.Capabilities = slices.Clone(meta.Capabilities)
+		r.modelMetadata[id] = meta
+		return nil
+	}
+}
+
+// WithModelFallback routes requests for model to the first of fallbacks with
+// a healthy backend when model has none, e.g. "gpt-4" to "gpt-4-mini", then
+// "llama-3". The response names the model that served it and carries an
+// X-Model-Fallback header. Requests are only redirected before a backend is
+// chosen; a backend's error is returned as usual rather than retried.
+func WithModelFallback(model string, fallbacks []string) Option {
+	return func(r *Router) error {
+		if model == "" || len(fallbacks) == 0 {
+			return fmt.Errorf("model fallback needs a model and at least one fallback")
+		}
+		for _, fallback := range fallbacks {
+			if fallback == "" || fallback == model {
+				return fmt.Errorf("model fallbacks for %q must be other, non-empty models", model)
+			}
+		}
+		if r.modelFallbacks == nil {
+			r.modelFallbacks = make(map[string][]string)
+		}
+		r.modelFallbacks[model] = append([]string(nil), fallbacks...)
+		return nil
+	}
+}
+
+// WithModelPrefixRoute sends requests for models starting with prefix, e.g.
+// "teamA/", only to backends tagged backendTag (see Tagged). The model is
+// forwarded as requested; use WithStrippedModelPrefixRoute to remove the
+// prefix first. When several prefixes match, the longest wins. Prefix-routed
+// requests stay on the chosen backend: they aren't hedged, fanned out, or
+// rerouted to backends without the tag.
+func WithModelPrefixRoute(prefix string, backendTag string) Option {
+	return modelPrefixRouteOption(prefix, backendTag, false)
+}
+
+// WithStrippedModelPrefixRoute is WithModelPrefixRoute, but forwards the
+// model without prefix, so "teamA/llama-3" is served as "llama-3" by the
+// backends tagged backendTag.
+func WithStrippedModelPrefixRoute(prefix string, backendTag string) Option {
+	return modelPrefixRouteOption(prefix, backendTag, true)
+}
+
+func modelPrefixRouteOption(prefix, backendTag string, strip bool) Option {
+	return func(r *Router) error {
+		if prefix == "" || backendTag == "" {
+			return fmt.Errorf("model prefix route needs a prefix and a backend tag")
+		}
+		r.modelPrefixes = slices.DeleteFunc(r.modelPrefixes, func(route modelPrefixRoute) bool {
+			return route.prefix == prefix
+		})
+		r.modelPrefixes = append(r.modelPrefixes, modelPrefixRoute{prefix: prefix, tag: backendTag, strip: strip})
+		return nil
+	}
+}
+
+// WithBatches serves the batch API: POST /v1/batches runs a batch of chat,
+// completions, or embeddings requests in the background, and
+// GET /v1/batches/{id} reports its progress and results. Requests are listed
+// inline rather than uploaded as a file, and at most concurrency of them,
+// across all batches, are in flight at once. Batch state is kept in store;
+// a nil store selects an in-memory one.
+func WithBatches(store BatchStore, concurrency int) Option {
+	return func(r *Router) error {
+		if concurrency <= 0 {
+			return fmt.Errorf("batch concurrency must be positive")
+		}
+		if store == nil {
+			store = NewMemoryBatchStore()
+		}
+		r.batches = newBatchRunner(store, concurrency)
+		return nil
+	}
+}
+
+// WithModelAuthorizer limits which models each API key may use. authorized
+// is called with the request's bearer token ("" if none) and the model it
+// would be served by; requests it rejects get 403 permission_error, and
+// /v1/models lists only the models it allows. It is called concurrently and
+// should be fast.
+func WithModelAuthorizer(authorized func(apiKey, model string) bool) Option {
+	return func(r *Router) error {
+		if authorized == nil {
+			return fmt.Errorf("model authorizer must not be nil")
+		}
+		r.modelAuthorizer = authorized
+		return nil
+	}
+}
+
+// WithForwardedHeaders sets the client request headers passed on to
+// backends, replacing DefaultForwardedHeaders (OpenAI-Organization and
+// OpenAI-Project). Call it with no names to forward none. Headers a backend
+// sets itself, such as its API key, take precedence.
+func WithForwardedHeaders(names ...string) Option {
+	return func(r *Router) error {
+		r.forwardedHeaders = append([]string(nil), names...)
+		return nil
+	}
+}
+
+// WithIdempotency stores successful non-streaming responses for ttl, keyed by
+// the request's Idempotency-Key header, endpoint, and API key. A request
+// repeating a key within ttl gets the stored response, marked with
+// Idempotent-Replayed, without reaching a backend; one repeating it with a
+// different body gets a 422, and one arriving while the first is still being
+// served gets a 409. Requests without the header are unaffected.
+func WithIdempotency(ttl time.Duration) Option {
+	return func(r *Router) error {
+		if ttl <= 0 {
+			return fmt.Errorf("idempotency ttl must be positive")
+		}
+		r.idempotencyCache = NewMemoryCache()
+		r.idempotencyTTL = ttl
+		return nil
+	}
+}
+
+// WithAuditSink copies every streamed chat and completion response to a
+// per-request sink, e.g. a file in an audit log. open is called with the
+// request's X-Request-ID (generated if the client didn't send one, and
+// returned in the response) and may return nil to skip a request. The sink
+// receives the same SSE events as the client, including the [DONE]
+// terminator, and is closed when the stream ends. Chat events pass through
+// the log redactor first (see WithLogRedactor); disable it to record them
+// verbatim. Writes happen on a separate goroutine, so a slow sink never holds
+// up the client.
+func WithAuditSink(open AuditSinkFunc) Option {
+	return func(r *Router) error {
+		r.auditSink = open
+		return nil
+	}
+}
+
+// WithStreamAggregation parses every chunk of streamed chat and completion
+// responses as types.ChatCompletionChunk or types.CompletionChunk and calls
+// fn with their accumulated text and usage once the stream ends, e.g. to
+// account usage per client. fn runs on the request's goroutine after the
+// last chunk is sent. Streams are passed through unchanged either way;
+// chunks that don't parse are counted and logged, not dropped.
+func WithStreamAggregation(fn StreamAggregateFunc) Option {
+	return func(r *Router) error {
+		r.streamAggregation = fn
+		return nil
+	}
+}
+
+// WithWarmup sends each newly registered backend a request in the
+// background, so the first client request doesn't wait for a cold model to
+// load. A nil fn uses DefaultWarmup. The warmup's duration is logged; by
+// default the backend is routed to while it warms up (see
+// WithWarmupRequired).
+func WithWarmup(fn WarmupFunc) Option {
+	return func(r *Router) error {
+		if fn == nil {
+			fn = DefaultWarmup
+		}
+		r.warmup = fn
+		return nil
+	}
+}
+
+// WithWarmupRequired treats newly registered backends as unhealthy until
+// their warmup succeeds, retrying failed warmups with DefaultWarmupBackoff.
+// Backends replacing a registered backend with the same ID are routed to
+// straight away. It has no effect without WithWarmup.
+func WithWarmupRequired(enabled bool) Option {
+	return func(r *Router) error {
+		r.warmupRequired = enabled
+		return nil
+	}
+}
+
+// WithUnknownFieldPassthrough forwards chat, completion, embeddings, and image
+// generation request members the router has no field for, such as vendor
+// parameters like repetition_penalty, to the backend as sent. By default they
+// are dropped when the request is decoded.
+func WithUnknownFieldPassthrough(enabled bool) Option {
+	return func(r *Router) error {
+		r.unknownFields = enabled
+		return nil
+	}
+}
+
+// WithMiddleware wraps the router's handlers in mw, e.g. to add logging,
+// authentication, or metrics. Middleware is applied in the order given: the
+// first is outermost, seeing each request first and its response last. It
+// runs after the router's request size limit and shutdown checks, and isn't
+// applied to the requests of batch jobs, which already passed through it.
+// Streamed responses need the http.ResponseWriter mw passes on to implement
+// http.Flusher, or to have an Unwrap method returning the writer it wraps.
+func WithMiddleware(mw func(http.Handler) http.Handler) Option {
+	return func(r *Router) error {
+		if mw == nil {
+			return fmt.Errorf("middleware must not be nil")
+		}
+		r.middleware = append(r.middleware, mw)
+		return nil
+	}
+}
+
+// WithRequestLogger calls fn once for every chat, completion, and embeddings
+// request after its response has been written, including streams and
+// requests rejected before reaching a backend. fn runs on the request's
+// goroutine, so it should hand slow work off elsewhere. Bodies aren't
+// captured unless WithRequestLogBodies is also set.
+func WithRequestLogger(fn func(RequestLog)) Option {
+	return func(r *Router) error {
+		r.requestLogger = fn
+		return nil
+	}
+}
+
+// WithAccessLog logs one line per chat, completion, and embeddings request at
+// Info level on the router's logger once the response is finished. Each line
+// has the keys endpoint, model, backend, stream, status and latency_ms, plus
+// prompt_tokens and completion_tokens when the response reported usage (for
+// streams, from the final usage chunk).
+func WithAccessLog(enabled bool) Option {
+	return func(r *Router) error {
+		r.accessLog = enabled
+		return nil
+	}
+}
+
+// WithRequestLogBodies includes up to maxBytes of the request body and the
+// non-streaming response body in each RequestLog. Bodies may contain
+// sensitive data, so capture is off by default, and chat request bodies are
+// passed through the log redactor (see WithLogRedactor).
+func WithRequestLogBodies(maxBytes int) Option {
+	return func(r *Router) error {
+		if maxBytes < 0 {
+			return fmt.Errorf("request log body limit must not be negative")
+		}
+		r.requestLogBodyLimit = maxBytes
+		return nil
+	}
+}
+
+// WithLogRedactor sets how chat request bodies are redacted before they are
+// included in request logs. fn receives a copy of the request, so the request
+// sent to the backend is unaffected. Streamed chat chunks copied to the audit
+// sink are redacted too, with their deltas passed to fn as messages, and
+// logged backend errors, including those of shadow and batch requests, leave
+// out upstream error bodies. The default, RedactChatRequest, hashes the user
+// field and blanks message contents; nil disables redaction.
+func WithLogRedactor(fn func(*types.ChatCompletionRequest)) Option {
+	return func(r *Router) error {
+		r.logRedactor = fn
+		return nil
+	}
+}
+
+// WithGracefulShutdown makes Stop wait up to timeout for in-flight requests,
+// including long streams, to finish before shutting down. While Stop is
+// waiting, new requests are rejected with a 503 so load balancers move on.
+func WithGracefulShutdown(timeout time.Duration) Option {
+	return func(r *Router) error {
+		if timeout <= 0 {
+			return fmt.Errorf("graceful shutdown timeout must be positive")
+		}
+		r.gracefulTimeout = timeout
+		return nil
+	}
+}
+
+// WithReadinessGrace makes GET /ready report ready once d has passed since
+// Start, even if no backend has indexed models yet, so slow-starting backends
+// don't keep the router out of rotation. The router still isn't ready while
+// every registered backend is unhealthy.
+func WithReadinessGrace(d time.Duration) Option {
+	return func(r *Router) error {
+		if d < 0 {
+			return fmt.Errorf("readiness grace must not be negative")
+		}
+		r.readinessGrace = d
+		return nil
+	}
+}
+
+// WithHealthCheckBackoff sets how health checks of unhealthy backends are
+// spaced out. After each consecutive failure the delay doubles, starting at
+// b.Initial (or the health check interval if zero) up to b.Max; checks still
+// run on the health check ticker, so delays round up to whole intervals.
+// MaxAttempts is ignored. The default is DefaultHealthCheckBackoff; set Max
+// to the interval to check unhealthy backends every interval.
+func WithHealthCheckBackoff(b Backoff) Option {
+	return func(r *Router) error {
+		if b.Initial < 0 || b.Max < 0 {
+			return fmt.Errorf("health check backoff must not be negative")
+		}
+		r.healthCheckBackoff = b
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// typedBackend returns a backend of the given type serving models that
+// records the model ID it receives.
+func typedBackend(id string, typ BackendType, models []string, gotModel *string) *mockBackend {
+	b := newMockBackend(id, true)
+	b.typ = typ
+	b.models = models
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		*gotModel = req.Model
+		return &types.ChatCompletionResponse{ID: id, Model: req.Model}, nil
+	}
+	return b
+}
+
+func qualifiedRouter(t *testing.T, enabled bool) (*Router, *string, *string) {
+	t.Helper()
+	var vllmModel, ollamaModel string
+	r := newTestRouter(t, []Backend{
+		typedBackend("vllm-1", BackendVLLM, []string{"meta/llama-3"}, &vllmModel),
+		typedBackend("ollama-1", BackendOllama, []string{"meta/llama-3", "tag@v2"}, &ollamaModel),
+	}, WithTypeQualifiedModels(enabled))
+	return r, &vllmModel, &ollamaModel
+}
+
+func TestTypeQualifiedModels_Routing(t *testing.T) {
+	r, vllmModel, ollamaModel := qualifiedRouter(t, true)
+
+	for _, tt := range []struct {
+		model   string
+		backend string
+		got     *string
+	}{
+		{"meta/llama-3@ollama", "ollama-1", ollamaModel},
+		{"meta/llama-3@vllm", "vllm-1", vllmModel},
+	} {
+		rec := postChat(t, r, `{"model":"`+tt.model+`","messages":[{"role":"user","content":"hi"}]}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", tt.model, rec.Code, rec.Body.String())
+		}
+		if id := servedID(t, rec.Body.Bytes()); id != tt.backend {
+			t.Errorf("%s: served by %q, want %q", tt.model, id, tt.backend)
+		}
+		if *tt.got != "meta/llama-3" {
+			t.Errorf("%s: backend received model %q, want the unqualified ID", tt.model, *tt.got)
+		}
+	}
+}
+
+func TestTypeQualifiedModels_UnknownType(t *testing.T) {
+	r, _, _ := qualifiedRouter(t, true)
+
+	if rec := postChat(t, r, `{"model":"meta/llama-3@llamacpp","messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for a type that doesn't serve the model", rec.Code)
+	}
+}
+
+func TestTypeQualifiedModels_VerbatimIDWins(t *testing.T) {
+	r, _, ollamaModel := qualifiedRouter(t, true)
+
+	rec := postChat(t, r, `{"model":"tag@v2","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if *ollamaModel != "tag@v2" {
+		t.Errorf("backend received model %q, want tag@v2", *ollamaModel)
+	}
+}
+
+func TestTypeQualifiedModels_Disabled(t *testing.T) {
+	r, _, _ := qualifiedRouter(t, false)
+
+	if rec := postChat(t, r, `{"model":"meta/llama-3@vllm","messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 when qualified IDs are disabled", rec.Code)
+	}
+}
+
+func TestTypeQualifiedModels_List(t *testing.T) {
+	r, _, _ := qualifiedRouter(t, true)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	var resp types.ModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range resp.Data {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+
+	want := []string{"meta/llama-3", "meta/llama-3@ollama", "meta/llama-3@vllm", "tag@v2", "tag@v2@ollama"}
+	if len(ids) != len(want) {
+		t.Fatalf("models = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("models = %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestTypeQualifiedModels_GetModel(t *testing.T) {
+	r, _, _ := qualifiedRouter(t, true)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models/meta/llama-3@vllm", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var model types.Model
+	if err := json.Unmarshal(rec.Body.Bytes(), &model); err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != "meta/llama-3@vllm" {
+		t.Errorf("model ID = %q, want meta/llama-3@vllm", model.ID)
+	}
+}
+
+func TestSplitQualifiedModel(t *testing.T) {
+	tests := []struct {
+		id        string
+		wantModel string
+		wantType  BackendType
+		wantOK    bool
+	}{
+		{"llama-3@vllm", "llama-3", BackendVLLM, true},
+		{"org/llama-3@ollama", "org/llama-3", BackendOllama, true},
+		{"a@b@vllm", "a@b", BackendVLLM, true},
+		{"llama-3", "llama-3", "", false},
+		{"llama-3@", "llama-3@", "", false},
+		{"@vllm", "@vllm", "", false},
+	}
+	for _, tt := range tests {
+		model, typ, ok := splitQualifiedModel(tt.id)
+		if model != tt.wantModel || typ != tt.wantType || ok != tt.wantOK {
+			t.Errorf("splitQualifiedModel(%q) = %q, %q, %v; want %q, %q, %v", tt.id, model, typ, ok, tt.wantModel, tt.wantType, tt.wantOK)
+		}
+	}
+}
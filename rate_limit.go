@@ -0,0 +1,63 @@
+package oairouter
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRateLimitBackoff is how long a backend that answered 429 without a
+// usable Retry-After is passed over.
+const defaultRateLimitBackoff = time.Second
+
+// rateLimitDelay reports whether err is a 429 from a backend, and for how
+// long to pass the backend over: its Retry-After, or
+// defaultRateLimitBackoff.
+func rateLimitDelay(err error) (time.Duration, bool) {
+	var httpErr *BackendHTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if d, ok := parseRetryAfter(httpErr.Header.Get("Retry-After"), time.Now()); ok {
+		return d, true
+	}
+	return defaultRateLimitBackoff, true
+}
+
+// parseRetryAfter parses a Retry-After header, either delay seconds or an
+// HTTP date, into the time left to wait after now. ok is false if the
+// header is missing, invalid, or already past.
+func parseRetryAfter(header string, now time.Time) (d time.Duration, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		d = at.Sub(now)
+	}
+	return d, d > 0
+}
+
+// markRateLimited has lookups treat backendID as saturated for d, so
+// requests prefer its replicas.
+func (r *BackendRegistry) markRateLimited(backendID string, d time.Duration) {
+	r.rateLimits.Store(backendID, time.Now().Add(d))
+}
+
+// rateLimited reports whether backendID answered 429 and its Retry-After
+// hasn't passed yet.
+func (r *BackendRegistry) rateLimited(backendID string) bool {
+	until, ok := r.rateLimits.Load(backendID)
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until.(time.Time)) {
+		return true
+	}
+	r.rateLimits.CompareAndDelete(backendID, until)
+	return false
+}
@@ -0,0 +1,141 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// rateLimitedBackend answers chat requests with 429 and retryAfter, counting
+// them in calls.
+func rateLimitedBackend(id, retryAfter string, calls *atomic.Int64) *mockBackend {
+	b := newMockBackend(id, true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		calls.Add(1)
+		resp := upstreamResponse(http.StatusTooManyRequests, `{"error":{"message":"slow down","type":"rate_limit_error"}}`)
+		resp.Header = http.Header{"Retry-After": {retryAfter}}
+		return nil, NewBackendHTTPError("chat completion", resp)
+	}
+	return b
+}
+
+func TestRateLimit_RetriesOnAnotherReplica(t *testing.T) {
+	r, _ := NewRouter()
+	var limited, served atomic.Int64
+	r.AddBackend(context.Background(), rateLimitedBackend("backend-a", "30", &limited))
+	r.AddBackend(context.Background(), idBackend("backend-b", &served))
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusOK || limited.Load() != 1 || served.Load() != 1 {
+		t.Fatalf("status = %d, 429s = %d, served = %d", rec.Code, limited.Load(), served.Load())
+	}
+
+	// The rate-limited replica is passed over until its Retry-After
+	if !r.registry.rateLimited("backend-a") {
+		t.Error("backend-a not marked rate limited")
+	}
+	postChat(t, r, testChatBody)
+	if limited.Load() != 1 || served.Load() != 2 {
+		t.Errorf("second request: 429s = %d, served = %d, want backend-a skipped", limited.Load(), served.Load())
+	}
+}
+
+func TestRateLimit_ForwardsWhenAllLimited(t *testing.T) {
+	r, _ := NewRouter()
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), rateLimitedBackend("backend-a", "5", &calls))
+	r.AddBackend(context.Background(), rateLimitedBackend("backend-b", "7", &calls))
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusTooManyRequests || calls.Load() != 2 {
+		t.Fatalf("status = %d, calls = %d, want 429 after trying both", rec.Code, calls.Load())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" && got != "7" {
+		t.Errorf("Retry-After = %q, want the upstream value", got)
+	}
+}
+
+func TestRateLimit_ForwardedThroughRequestQueue(t *testing.T) {
+	r, _ := NewRouter(WithRequestQueue(10, 2*time.Second))
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), rateLimitedBackend("backend-a", "1", &calls))
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		rec := postChat(t, r, testChatBody)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d: status = %d, want the backend's 429", i, rec.Code)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("request %d waited %v in the queue for a rate-limited backend", i, elapsed)
+		}
+	}
+	if calls.Load() != 2 || r.requestQueue.Len() != 0 {
+		t.Errorf("calls = %d, queued = %d, want 2 and 0", calls.Load(), r.requestQueue.Len())
+	}
+}
+
+func TestRateLimit_PinnedBackendNotRetried(t *testing.T) {
+	r, _ := NewRouter(WithBackendOverrideHeader())
+	var limited, served atomic.Int64
+	r.AddBackend(context.Background(), rateLimitedBackend("backend-a", "1", &limited))
+	r.AddBackend(context.Background(), idBackend("backend-b", &served))
+
+	if rec := postChat(t, r, testChatBody, withHeader(BackendIDHeader, "backend-a")); rec.Code != http.StatusTooManyRequests || served.Load() != 0 {
+		t.Errorf("status = %d, served elsewhere = %d, want the 429", rec.Code, served.Load())
+	}
+}
+
+func TestRateLimit_RetryPreparedForNextReplica(t *testing.T) {
+	var limited atomic.Int64
+	var logprobs *bool
+	b := newMockBackend("backend-b", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		logprobs = req.Logprobs
+		return &types.ChatCompletionResponse{ID: "backend-b"}, nil
+	}
+	r := newTestRouter(t, []Backend{rateLimitedBackend("backend-a", "30", &limited), b},
+		WithBackendLogprobsPolicy("backend-a", LogprobsStrip))
+
+	// backend-a's policy strips logprobs, which backend-b must still get
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"logprobs":true}`
+	if rec := postChat(t, r, body); rec.Code != http.StatusOK || limited.Load() != 1 {
+		t.Fatalf("status = %d, 429s = %d", rec.Code, limited.Load())
+	}
+	if logprobs == nil || !*logprobs {
+		t.Error("backend-b got the request as prepared for backend-a")
+	}
+}
+
+func TestRateLimit_RetrySkipsIncapableReplica(t *testing.T) {
+	var limited, served atomic.Int64
+	incapable := idBackend("backend-b", &served)
+	incapable.maxContext = 1
+	r := newTestRouter(t, []Backend{rateLimitedBackend("backend-a", "30", &limited), incapable})
+
+	// The prompt doesn't fit backend-b's context window
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusTooManyRequests || served.Load() != 0 {
+		t.Errorf("status = %d, served by incapable replica = %d, want the 429", rec.Code, served.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for header, want := range map[string]time.Duration{
+		"3":                             3 * time.Second,
+		"Mon, 01 Jan 2024 00:00:10 GMT": 10 * time.Second,
+		"Sun, 31 Dec 2023 00:00:00 GMT": 0,
+		"0":                             0,
+		"soon":                          0,
+		"":                              0,
+	} {
+		d, ok := parseRetryAfter(header, now)
+		if d != want && ok || ok != (want > 0) {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v", header, d, ok, want)
+		}
+	}
+}
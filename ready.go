@@ -0,0 +1,67 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Ready reports whether the router can serve traffic: a healthy backend has
+// indexed models, or the readiness grace period since Start has passed and
+// not every backend is unhealthy. Readiness is lost again if all backends
+// become unhealthy.
+func (r *Router) Ready() bool {
+	return r.updateReadiness()
+}
+
+// updateReadiness recomputes readiness, logging transitions.
+func (r *Router) updateReadiness() bool {
+	registered, healthy, serving := 0, 0, false
+	for _, info := range r.registry.Snapshot() {
+		registered++
+		if info.Healthy {
+			healthy++
+			serving = serving || len(info.Models) > 0
+		}
+	}
+
+	ready := serving
+	if !ready && r.readinessGrace > 0 && time.Since(r.readinessStart()) >= r.readinessGrace {
+		ready = registered == 0 || healthy > 0
+	}
+
+	if r.ready.Swap(ready) != ready {
+		if ready {
+			r.logger.Info("router ready", "backends_healthy", healthy)
+		} else {
+			r.logger.Warn("router not ready", "backends_total", registered, "backends_healthy", healthy)
+		}
+	}
+	return ready
+}
+
+// readinessStart is when the grace period began: Start, or NewRouter for a
+// router that was never started.
+func (r *Router) readinessStart() time.Time {
+	if ns := r.startedAt.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return r.counters.start
+}
+
+// handleReady serves the readiness probe. Unlike /health, which reports
+// liveness, it returns 503 until the router can route requests.
+func (r *Router) handleReady(w http.ResponseWriter, req *http.Request) {
+	ready := r.Ready()
+
+	status := struct {
+		Status string `json:"status"`
+	}{Status: "ready"}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		status.Status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,76 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func getReady(t *testing.T, r *Router) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	return rec.Code
+}
+
+func TestReady_RequiresIndexedModels(t *testing.T) {
+	r, _ := NewRouter(WithModelRetry(Backoff{}))
+	if code := getReady(t, r); code != http.StatusServiceUnavailable {
+		t.Errorf("no backends: /ready = %d, want 503", code)
+	}
+
+	// Registered but not serving models yet
+	b := newMockBackend("backend-a", true)
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) { return nil, errors.New("loading") }
+	r.AddBackend(context.Background(), b)
+	if code := getReady(t, r); code != http.StatusServiceUnavailable {
+		t.Errorf("no models indexed: /ready = %d, want 503", code)
+	}
+
+	r.AddBackend(context.Background(), newMockBackend("backend-b", true))
+	if code := getReady(t, r); code != http.StatusOK {
+		t.Errorf("models indexed: /ready = %d, want 200", code)
+	}
+}
+
+func TestReady_FlipsWhenAllUnhealthy(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	r, _ := NewRouter(WithReadinessGrace(time.Nanosecond))
+	r.AddBackend(context.Background(), b)
+	if !r.Ready() {
+		t.Fatal("expected ready")
+	}
+
+	b.healthy.Store(false)
+	if code := getReady(t, r); code != http.StatusServiceUnavailable {
+		t.Errorf("all backends unhealthy: /ready = %d, want 503", code)
+	}
+
+	// /health keeps reporting liveness
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/health = %d, want 200", rec.Code)
+	}
+
+	b.healthy.Store(true)
+	if !r.Ready() {
+		t.Error("expected ready after recovery")
+	}
+}
+
+func TestReady_GracePeriod(t *testing.T) {
+	r, _ := NewRouter(WithReadinessGrace(30 * time.Millisecond))
+	if r.Ready() {
+		t.Fatal("ready before the grace period elapsed")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if !r.Ready() {
+		t.Error("not ready after the grace period")
+	}
+}
@@ -0,0 +1,121 @@
+package oairouter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// redactedContent replaces message contents in redacted request logs.
+const redactedContent = "[redacted]"
+
+// RedactChatRequest is the default log redactor. It replaces the user field
+// with a hash, so requests from one user can still be correlated, and message
+// contents and tool call arguments with "[redacted]".
+func RedactChatRequest(req *types.ChatCompletionRequest) {
+	if req.User != "" {
+		sum := sha256.Sum256([]byte(req.User))
+		req.User = "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	for i := range req.Messages {
+		m := &req.Messages[i]
+		if m.Content != nil {
+			m.Content = redactedContent
+		}
+		for j := range m.ToolCalls {
+			m.ToolCalls[j].Function.Arguments = redactedContent
+		}
+		if m.FunctionCall != nil {
+			m.FunctionCall.Arguments = redactedContent
+		}
+	}
+}
+
+// redactChatLog returns the body to log for a chat request: a copy of req
+// rewritten by the router's log redactor. req itself is left untouched.
+func redactChatLog(r *Router, req *types.ChatCompletionRequest) []byte {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+	var cp types.ChatCompletionRequest
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil
+	}
+	r.logRedactor(&cp)
+	data, _ = json.Marshal(&cp)
+	return data
+}
+
+// redactRequestLog replaces the raw request body captured for the request log
+// with the endpoint's redacted form. If the body couldn't be decoded it can't
+// be redacted, so it is dropped.
+func redactRequestLog[Req any, Resp any](r *Router, req *http.Request, apiReq *Req, decoded bool, cfg handlerConfig[Req, Resp]) {
+	if r.logRedactor == nil || cfg.redactLog == nil {
+		return
+	}
+	rec, ok := req.Context().Value(requestLogKey{}).(*requestRecorder)
+	if !ok || !rec.capture {
+		return
+	}
+	rec.reqBody = cappedBuffer{limit: rec.reqBody.limit}
+	if decoded {
+		rec.reqBody.Write(cfg.redactLog(r, apiReq))
+	}
+}
+
+// redactChatChunk returns the audit record for a streamed chat chunk: its
+// deltas rewritten by the router's log redactor, as if they were messages,
+// and its logprobs dropped. Events that aren't chunks can't be redacted, so
+// it returns "" for them.
+func redactChatChunk(r *Router, data string) string {
+	var chunk types.ChatCompletionChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || (len(chunk.Choices) == 0 && chunk.Usage == nil) {
+		return ""
+	}
+
+	req := types.ChatCompletionRequest{Messages: make([]types.ChatMessage, len(chunk.Choices))}
+	for i, c := range chunk.Choices {
+		m := &req.Messages[i]
+		m.Role, m.ToolCalls, m.FunctionCall = c.Delta.Role, c.Delta.ToolCalls, c.Delta.FunctionCall
+		if c.Delta.Content != "" {
+			m.Content = c.Delta.Content
+		}
+	}
+	r.logRedactor(&req)
+
+	for i := range chunk.Choices {
+		c, m := &chunk.Choices[i], req.Messages[i]
+		switch content := m.Content.(type) {
+		case string:
+			c.Delta.Content = content
+		case nil:
+			c.Delta.Content = ""
+		default:
+			c.Delta.Content = redactedContent
+		}
+		c.Delta.ToolCalls, c.Delta.FunctionCall = m.ToolCalls, m.FunctionCall
+		c.Logprobs = nil
+	}
+	out, err := json.Marshal(&chunk)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// logError returns err as it should appear in logs. With a log redactor set,
+// the body of an upstream error response, which often quotes the request, is
+// left out.
+func (r *Router) logError(err error) error {
+	var httpErr *BackendHTTPError
+	if r.logRedactor == nil || !errors.As(err, &httpErr) {
+		return err
+	}
+	return fmt.Errorf("%s failed: %s", httpErr.Op, httpErr.Status)
+}
@@ -0,0 +1,147 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+const piiChatBody = `{"model":"test-model","user":"alice@example.com","messages":[{"role":"user","content":"my SSN is 123-45-6789"}]}`
+
+func TestLogRedactor_DefaultRedactsLoggedBody(t *testing.T) {
+	var sent *types.ChatCompletionRequest
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		sent = req
+		return &types.ChatCompletionResponse{}, nil
+	}
+
+	var got RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { got = l }), WithRequestLogBodies(4096))
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, piiChatBody)
+
+	body := string(got.RequestBody)
+	if strings.Contains(body, "alice") || strings.Contains(body, "123-45-6789") {
+		t.Errorf("logged body leaks PII: %s", body)
+	}
+	var logged types.ChatCompletionRequest
+	if err := json.Unmarshal(got.RequestBody, &logged); err != nil {
+		t.Fatalf("logged body isn't JSON: %v", err)
+	}
+	if !strings.HasPrefix(logged.User, "sha256:") || logged.Messages[0].Content != redactedContent {
+		t.Errorf("logged request = %+v", logged)
+	}
+
+	if sent == nil || sent.User != "alice@example.com" || sent.Messages[0].Content != "my SSN is 123-45-6789" {
+		t.Errorf("backend received a modified request: %+v", sent)
+	}
+}
+
+func TestLogRedactor_Custom(t *testing.T) {
+	var got RequestLog
+	r, _ := NewRouter(
+		WithRequestLogger(func(l RequestLog) { got = l }),
+		WithRequestLogBodies(4096),
+		WithLogRedactor(func(req *types.ChatCompletionRequest) { req.User = "" }),
+	)
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	postChat(t, r, piiChatBody)
+
+	body := string(got.RequestBody)
+	if strings.Contains(body, "alice") || !strings.Contains(body, "123-45-6789") {
+		t.Errorf("custom redactor not applied: %s", body)
+	}
+}
+
+func TestLogRedactor_DropsUndecodableBody(t *testing.T) {
+	var got RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { got = l }), WithRequestLogBodies(4096))
+
+	postChat(t, r, `{"model":"test-model","user":"alice@example.com",`)
+
+	if len(got.RequestBody) != 0 {
+		t.Errorf("logged an unredacted body: %s", got.RequestBody)
+	}
+}
+
+func TestLogRedactor_RedactsAuditRecords(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"your SSN is 123-45-6789"}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"ssn\":\"123-45-6789\"}"}}]}}]}`,
+		), nil
+	}
+
+	sink := newRecordingSink()
+	r, _ := NewRouter(WithAuditSink(func(string) io.WriteCloser { return sink }))
+	r.AddBackend(context.Background(), b)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	select {
+	case <-sink.closed:
+	case <-time.After(time.Second):
+		t.Fatal("audit sink was not closed")
+	}
+	if !strings.Contains(w.Body.String(), "123-45-6789") {
+		t.Errorf("client stream was redacted: %s", w.Body.String())
+	}
+	record := sink.String()
+	if strings.Contains(record, "123-45-6789") {
+		t.Errorf("audit record leaks PII: %s", record)
+	}
+	if content, done := reassemble(t, record); content != redactedContent || !done {
+		t.Errorf("audit content = %q (done=%v), want %q with [DONE]", content, done, redactedContent)
+	}
+	if !strings.Contains(record, `"name":"lookup"`) {
+		t.Errorf("audit record lost the tool call: %s", record)
+	}
+}
+
+func TestLogRedactor_OmitsUpstreamErrorBodies(t *testing.T) {
+	upstream := &BackendHTTPError{
+		Op:         "chat completion",
+		StatusCode: http.StatusInternalServerError,
+		Status:     "500 Internal Server Error",
+		Body:       []byte(`{"error":{"message":"failed on input: my SSN is 123-45-6789"}}`),
+	}
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, upstream
+	}
+	shadow := newMockBackend("shadow", true)
+	shadow.chatFn = b.chatFn
+
+	sink := newRecordingSink()
+	r, _ := NewRouter(WithLogger(slog.New(slog.NewTextHandler(sink, nil))), WithShadowBackend("shadow", 1))
+	r.AddBackend(context.Background(), b)
+	r.AddBackend(context.Background(), shadow)
+
+	postChat(t, r, testChatBody)
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(sink.String(), "shadow request failed") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	logs := sink.String()
+	if strings.Contains(logs, "123-45-6789") {
+		t.Errorf("logs leak the upstream error body:\n%s", logs)
+	}
+	if !strings.Contains(logs, "500 Internal Server Error") || !strings.Contains(logs, "shadow request failed") {
+		t.Errorf("logs are missing the failures:\n%s", logs)
+	}
+}
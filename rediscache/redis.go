@@ -0,0 +1,58 @@
+// Package rediscache provides a Redis-backed implementation of oairouter.Cache,
+// allowing several router replicas to share cached responses.
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores entries in Redis.
+type Cache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithKeyPrefix namespaces all keys, e.g. "oairouter:".
+func WithKeyPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// New creates a cache using an existing Redis client.
+func New(client redis.UniversalClient, opts ...Option) *Cache {
+	c := &Cache{
+		client: client,
+		prefix: "oairouter:",
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Get implements oairouter.Cache.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implements oairouter.Cache.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,43 @@
+//go:build redis
+
+package rediscache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Run with: REDIS_ADDR=localhost:6379 go test -tags redis ./rediscache
+func TestCache_Integration(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	ctx := context.Background()
+	c := New(client, WithKeyPrefix("oairouter-test:"))
+
+	if _, ok, err := c.Get(ctx, "missing"); err != nil || ok {
+		t.Fatalf("Get(missing) = ok %v, err %v; want miss", ok, err)
+	}
+
+	if err := c.Set(ctx, "key", []byte("value"), time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := c.Get(ctx, "key")
+	if err != nil || !ok || string(value) != "value" {
+		t.Fatalf("Get(key) = %q, %v, %v; want \"value\", true, nil", value, ok, err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, ok, _ := c.Get(ctx, "key"); ok {
+		t.Error("expected entry to expire")
+	}
+}
@@ -2,10 +2,13 @@ package oairouter
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/stevemurr/oairouter/types"
 )
@@ -16,54 +19,327 @@ const SessionHeader = "X-Session-ID"
 // SessionBrokenHeader is set in responses when session affinity couldn't be maintained.
 const SessionBrokenHeader = "X-Session-Broken"
 
+// SessionRebalancedHeader is set alongside SessionBrokenHeader when a session
+// was moved to a different backend because its preferred backend was unhealthy.
+const SessionRebalancedHeader = "X-Session-Rebalanced"
+
+// ModelTypeDelimiter separates a model ID from a backend type in a
+// type-qualified model ID such as "llama-3@vllm".
+const ModelTypeDelimiter = "@"
+
+// DefaultModelFetchTimeout bounds each backend's Models call when listing
+// models across all backends.
+const DefaultModelFetchTimeout = 5 * time.Second
+
+// modelFetchWorkers is how many backends are asked for their models at once.
+const modelFetchWorkers = 8
+
+// ErrBackendExists is returned when registering a backend whose ID is taken.
+var ErrBackendExists = errors.New("backend already registered")
+
 // BackendRegistry manages model-to-backend routing.
 type BackendRegistry struct {
 	mu       sync.RWMutex
-	backends map[string]Backend   // backendID -> Backend
-	models   map[string][]string  // modelID -> []backendID (multiple backends may serve same model)
+	backends map[string]Backend  // backendID -> Backend
+	models   map[string][]string // modelID -> []backendID (multiple backends may serve same model)
+
+	typeQualifiedModels bool // List "model@type" IDs alongside merged model IDs
+
+	modelFetchTimeout time.Duration // Per-backend limit on Models calls when listing all models
+
+	cacheMu       sync.Mutex
+	modelCache    map[string]*modelCacheEntry // backendID -> last fetched model list
+	modelCacheTTL time.Duration
+
+	modelRetry Backoff
+	retries    map[string]context.CancelFunc // backendID -> cancels a pending model retry
+	notify     func(DiscoveryEvent)          // called when a retry indexes a backend's models
+	released   func(backendID string)        // called when an in-flight request finishes
+
+	inflight sync.Map // backendID -> *atomic.Int64 count of in-flight requests
+	health   sync.Map // backendID -> *healthStats of recent request outcomes
+	latency  sync.Map // backendID -> *latencyEWMA of successful requests
+
+	rings ringCache // Hash rings for session affinity
+
+	// rateLimits maps backendID -> the time.Time until which the backend is
+	// treated as saturated after answering 429
+	rateLimits sync.Map
+
+	// warming maps backendID -> the Backend treated as unhealthy until its
+	// warmup succeeds
+	warming sync.Map
+
+	// deepHealth maps backendID -> the DeepHealth of its last deep health
+	// check
+	deepHealth sync.Map
 }
 
 // NewBackendRegistry creates a new backend registry.
 func NewBackendRegistry() *BackendRegistry {
 	return &BackendRegistry{
-		backends: make(map[string]Backend),
-		models:   make(map[string][]string),
+		backends:   make(map[string]Backend),
+		models:     make(map[string][]string),
+		modelRetry: DefaultModelRetryBackoff,
+		retries:    make(map[string]context.CancelFunc),
+
+		modelFetchTimeout: DefaultModelFetchTimeout,
+		modelCache:        make(map[string]*modelCacheEntry),
 	}
 }
 
+// SetModelRetry configures how Register retries fetching models for a backend
+// that isn't ready yet. A zero Initial delay disables retries.
+func (r *BackendRegistry) SetModelRetry(b Backoff) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modelRetry = b
+}
+
+// SetModelFetchTimeout bounds each backend's Models call when AllModels and
+// AvailableModels list models across all backends. A backend that doesn't
+// answer in time is left out of that listing.
+func (r *BackendRegistry) SetModelFetchTimeout(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modelFetchTimeout = d
+}
+
+// SetTypeQualifiedModels controls whether AvailableModels also lists a
+// type-qualified ID ("model@type") for each backend type serving a model.
+func (r *BackendRegistry) SetTypeQualifiedModels(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.typeQualifiedModels = enabled
+}
+
 // Register adds a backend and indexes its models.
 func (r *BackendRegistry) Register(ctx context.Context, b Backend) error {
+	probeCapabilities(ctx, b)
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.register(ctx, b)
+	return nil
+}
+
+// RegisterNew registers a backend unless one with the same ID is already
+// registered, in which case it returns an error wrapping ErrBackendExists.
+func (r *BackendRegistry) RegisterNew(ctx context.Context, b Backend) error {
+	if _, exists := r.LookupByID(b.ID()); exists {
+		return fmt.Errorf("%w: %s", ErrBackendExists, b.ID())
+	}
+	probeCapabilities(ctx, b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.backends[b.ID()]; ok {
+		return fmt.Errorf("%w: %s", ErrBackendExists, b.ID())
+	}
+	r.register(ctx, b)
+	return nil
+}
+
+// register adds a backend and indexes its models (must hold lock).
+func (r *BackendRegistry) register(ctx context.Context, b Backend) {
 	r.backends[b.ID()] = b
+	r.cancelModelRetry(b.ID())
 
 	// Fetch and index models
 	models, err := b.Models(ctx)
 	if err != nil {
-		// Backend registered but models not available yet
-		return nil
+		// Backend registered but models not available yet; route its seeded
+		// models meanwhile and keep trying in the background
+		for _, model := range seedModels(b) {
+			r.addModelMapping(model.ID, b.ID())
+		}
+		r.startModelRetry(ctx, b)
+		return
 	}
 
+	r.storeModels(b, models)
 	for _, model := range models {
 		r.addModelMapping(model.ID, b.ID())
 	}
+}
+
+// Replace atomically swaps the registered backends for a new set. The new
+// model index is built before the lock is taken so lookups are never served
+// from a half-built index. It returns the backends that were removed; their
+// in-flight requests are left to finish (see WaitIdle).
+func (r *BackendRegistry) Replace(ctx context.Context, backends []Backend) []Backend {
+	newBackends := make(map[string]Backend, len(backends))
+	newModels := make(map[string][]string)
+	var pending []Backend
+
+	for _, b := range backends {
+		newBackends[b.ID()] = b
+		probeCapabilities(ctx, b)
+		models, err := b.Models(ctx)
+		if err != nil {
+			pending = append(pending, b)
+			models = seedModels(b)
+		} else {
+			r.storeModels(b, models)
+		}
+		for _, model := range models {
+			if !containsString(newModels[model.ID], b.ID()) {
+				newModels[model.ID] = append(newModels[model.ID], b.ID())
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var removed []Backend
+	for id, b := range r.backends {
+		if _, ok := newBackends[id]; !ok {
+			removed = append(removed, b)
+			r.forgetBackendState(id)
+		}
+	}
+	for id := range r.retries {
+		r.cancelModelRetry(id)
+	}
+
+	r.backends = newBackends
+	r.models = newModels
+
+	// Backends whose models weren't ready yet are retried in the background
+	for _, b := range pending {
+		r.startModelRetry(ctx, b)
+	}
+
+	return removed
+}
+
+// Acquire records an in-flight request to a backend. The returned function
+// must be called when the request completes.
+func (r *BackendRegistry) Acquire(backendID string) (release func()) {
+	counter := r.inflightCounter(backendID)
+	counter.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if counter.Add(-1) == 0 && !r.registered(backendID) {
+				// The backend was removed while busy; see forgetInflight
+				r.inflight.CompareAndDelete(backendID, counter)
+			}
+			if r.released != nil {
+				r.released(backendID)
+			}
+		})
+	}
+}
 
+// InFlight returns the number of in-flight requests to a backend.
+func (r *BackendRegistry) InFlight(backendID string) int64 {
+	if counter, ok := r.inflight.Load(backendID); ok {
+		return counter.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// TotalInFlight returns the number of in-flight requests across all
+// backends.
+func (r *BackendRegistry) TotalInFlight() int64 {
+	var total int64
+	r.inflight.Range(func(_, counter any) bool {
+		total += counter.(*atomic.Int64).Load()
+		return true
+	})
+	return total
+}
+
+// WaitIdle blocks until a backend has no in-flight requests or ctx is done.
+func (r *BackendRegistry) WaitIdle(ctx context.Context, backendID string) error {
+	v, ok := r.inflight.Load(backendID)
+	if !ok {
+		return nil
+	}
+	counter := v.(*atomic.Int64)
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for counter.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
 
+// forgetInflight drops a removed backend's in-flight counter if it is idle.
+// A busy one is dropped by the release that idles it, so WaitIdle can still
+// wait on it (must hold lock).
+func (r *BackendRegistry) forgetInflight(backendID string) {
+	if counter, ok := r.inflight.Load(backendID); ok && counter.(*atomic.Int64).Load() == 0 {
+		r.inflight.CompareAndDelete(backendID, counter)
+	}
+}
+
+// registered reports whether a backend with id is registered.
+func (r *BackendRegistry) registered(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.backends[id]
+	return ok
+}
+
+func (r *BackendRegistry) inflightCounter(backendID string) *atomic.Int64 {
+	if counter, ok := r.inflight.Load(backendID); ok {
+		return counter.(*atomic.Int64)
+	}
+	counter, _ := r.inflight.LoadOrStore(backendID, new(atomic.Int64))
+	return counter.(*atomic.Int64)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // Unregister removes a backend and its model mappings.
 func (r *BackendRegistry) Unregister(id string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	delete(r.backends, id)
+	r.cancelModelRetry(id)
+	r.forgetBackendState(id)
+
+	r.removeModelMappings(id)
+}
+
+// forgetBackendState drops the per-backend state kept outside the backend
+// and model maps, so a backend re-added under the same ID starts fresh
+// (must hold lock).
+func (r *BackendRegistry) forgetBackendState(id string) {
+	r.warming.Delete(id)
+	r.deepHealth.Delete(id)
+	r.health.Delete(id)
+	r.latency.Delete(id)
+	r.rateLimits.Delete(id)
+	r.forgetModels(id)
+	r.forgetInflight(id)
+}
 
-	// Remove model mappings for this backend
+// removeModelMappings removes a backend from the model index (must hold lock).
+func (r *BackendRegistry) removeModelMappings(backendID string) {
 	for modelID, backendIDs := range r.models {
 		filtered := make([]string, 0, len(backendIDs))
 		for _, bid := range backendIDs {
-			if bid != id {
+			if bid != backendID {
 				filtered = append(filtered, bid)
 			}
 		}
@@ -75,6 +351,85 @@ func (r *BackendRegistry) Unregister(id string) {
 	}
 }
 
+// startModelRetry retries fetching a backend's models with exponential backoff
+// until they are indexed, the attempts run out, or the backend is unregistered
+// (must hold lock).
+func (r *BackendRegistry) startModelRetry(ctx context.Context, b Backend) {
+	if r.modelRetry.Initial <= 0 {
+		return
+	}
+
+	// Detach from the caller's context, which may be a short-lived request
+	retryCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.retries[b.ID()] = cancel
+	go r.retryModels(retryCtx, b, r.modelRetry)
+}
+
+// cancelModelRetry stops a pending model retry for a backend (must hold lock).
+func (r *BackendRegistry) cancelModelRetry(id string) {
+	if cancel, ok := r.retries[id]; ok {
+		cancel()
+		delete(r.retries, id)
+	}
+}
+
+func (r *BackendRegistry) retryModels(ctx context.Context, b Backend, backoff Backoff) {
+	for attempt := 0; backoff.MaxAttempts == 0 || attempt < backoff.MaxAttempts; attempt++ {
+		timer := time.NewTimer(backoff.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		models, err := b.Models(ctx)
+		if err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		// The backend may have been unregistered or re-registered meanwhile
+		if ctx.Err() != nil || r.backends[b.ID()] != b {
+			r.mu.Unlock()
+			return
+		}
+		r.removeModelMappings(b.ID()) // Drop any seeded models
+		r.storeModels(b, models)
+		for _, model := range models {
+			r.addModelMapping(model.ID, b.ID())
+		}
+		r.cancelModelRetry(b.ID())
+		notify := r.notify
+		r.mu.Unlock()
+
+		if notify != nil {
+			notify(DiscoveryEvent{Type: EventUpdated, Backend: b})
+		}
+		return
+	}
+
+	r.mu.Lock()
+	if ctx.Err() == nil {
+		r.cancelModelRetry(b.ID())
+	}
+	r.mu.Unlock()
+}
+
+// Close stops any pending background model retries and warmups.
+func (r *BackendRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range r.retries {
+		r.cancelModelRetry(id)
+	}
+	r.warming.Range(func(id, _ any) bool {
+		r.warming.Delete(id)
+		return true
+	})
+}
+
 // addModelMapping adds a model -> backend mapping (must hold lock).
 func (r *BackendRegistry) addModelMapping(modelID, backendID string) {
 	backends := r.models[modelID]
@@ -87,23 +442,112 @@ func (r *BackendRegistry) addModelMapping(modelID, backendID string) {
 	r.models[modelID] = append(backends, backendID)
 }
 
+// backendIDsForModel returns the backends mapped to a model ID. Exact matches
+// always win; otherwise the most specific wildcard pattern is used (must hold lock).
+func (r *BackendRegistry) backendIDsForModel(modelID string) []string {
+	if backendIDs, ok := r.models[modelID]; ok && len(backendIDs) > 0 {
+		return backendIDs
+	}
+	if pattern, ok := r.lookupByModelPattern(modelID); ok {
+		return r.models[pattern]
+	}
+	return nil
+}
+
+// lookupByModelPattern finds the most specific wildcard model pattern matching
+// modelID. A pattern with more literal characters is more specific; ties go to
+// the pattern with fewer wildcards, then to the lexically smaller pattern so the
+// choice is deterministic (must hold lock).
+func (r *BackendRegistry) lookupByModelPattern(modelID string) (string, bool) {
+	best := ""
+	found := false
+	for pattern := range r.models {
+		if !strings.Contains(pattern, "*") || !matchModelPattern(pattern, modelID) {
+			continue
+		}
+		if !found || morePreciseModelPattern(pattern, best) {
+			best = pattern
+			found = true
+		}
+	}
+	return best, found
+}
+
+// morePreciseModelPattern reports whether pattern a is more specific than b.
+func morePreciseModelPattern(a, b string) bool {
+	wildA, wildB := strings.Count(a, "*"), strings.Count(b, "*")
+	litA, litB := len(a)-wildA, len(b)-wildB
+	if litA != litB {
+		return litA > litB
+	}
+	if wildA != wildB {
+		return wildA < wildB
+	}
+	return a < b
+}
+
+// matchModelPattern reports whether modelID matches pattern, where '*' matches
+// any sequence of characters (including '/').
+func matchModelPattern(pattern, modelID string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == modelID
+	}
+
+	// First part must be a prefix and the last a suffix
+	if !strings.HasPrefix(modelID, parts[0]) {
+		return false
+	}
+	rest := modelID[len(parts[0]):]
+	last := parts[len(parts)-1]
+	if len(rest) < len(last) || !strings.HasSuffix(rest, last) {
+		return false
+	}
+	rest = rest[:len(rest)-len(last)]
+
+	// Middle parts must appear in order
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return true
+}
+
 // LookupByModel finds the first healthy backend serving a specific model.
+// Backends may advertise wildcard model IDs (e.g. "llama-3-8b-ft-*"), which
+// are used only when no backend serves the exact model ID. Lower tiers are
+// preferred, and backends at their concurrency limit are passed over while
+// others are free; see Tiered and ConcurrencyLimiter.
 func (r *BackendRegistry) LookupByModel(modelID string) (Backend, bool) {
+	return r.LookupByModelForOp(modelID, OperationAny)
+}
+
+// LookupByModelForOp is LookupByModel restricted to backends that can perform
+// op. It reports false if no backend serving the model can.
+func (r *BackendRegistry) LookupByModelForOp(modelID string, op Operation) (Backend, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	backendIDs, ok := r.models[modelID]
-	if !ok || len(backendIDs) == 0 {
+	backendIDs := r.backendIDsForOp(modelID, op)
+	if len(backendIDs) == 0 {
 		return nil, false
 	}
 
-	// First-available: return the first healthy backend
+	// First-available: return the first healthy backend of the lowest tier,
+	// skipping backends at their concurrency limit unless all are
+	var healthy []Backend
 	for _, bid := range backendIDs {
 		backend, ok := r.backends[bid]
-		if ok && backend.IsHealthy() {
-			return backend, true
+		if ok && r.isHealthy(backend) {
+			healthy = append(healthy, backend)
 		}
 	}
+	if preferred := r.preferTier(healthy); len(preferred) > 0 {
+		return preferred[0], true
+	}
 
 	// No healthy backend found, return first one anyway (caller can handle unhealthy)
 	if backend, ok := r.backends[backendIDs[0]]; ok {
@@ -113,91 +557,203 @@ func (r *BackendRegistry) LookupByModel(modelID string) (Backend, bool) {
 	return nil, false
 }
 
-// LookupResult contains the backend lookup result with session affinity metadata.
-type LookupResult struct {
-	Backend       Backend
-	SessionBroken bool // True if preferred backend was unhealthy and fallback was used
+// servesOp reports whether any backend serving the model can perform op.
+func (r *BackendRegistry) servesOp(modelID string, op Operation) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.backendIDsForOp(modelID, op)) > 0
 }
 
-// LookupByModelWithSession finds a backend using session affinity via consistent hashing.
-// If sessionID is empty, falls back to first-healthy selection (LookupByModel behavior).
-// If the preferred backend (based on session hash) is unhealthy, falls back to another
-// healthy backend and sets SessionBroken=true in the result.
-func (r *BackendRegistry) LookupByModelWithSession(modelID, sessionID string) (LookupResult, bool) {
+// backendIDsForOp returns backendIDsForModel without the backends that can't
+// perform op. The caller must hold r.mu.
+func (r *BackendRegistry) backendIDsForOp(modelID string, op Operation) []string {
+	backendIDs := r.backendIDsForModel(modelID)
+	if op == OperationAny {
+		return backendIDs
+	}
+	var capable []string
+	for _, bid := range backendIDs {
+		if backend, ok := r.backends[bid]; ok && op.servedBy(backend) {
+			capable = append(capable, bid)
+		}
+	}
+	return capable
+}
+
+// splitQualifiedModel splits a type-qualified model ID at its last
+// ModelTypeDelimiter. ok is false if id has no non-empty qualifier.
+func splitQualifiedModel(id string) (modelID string, typ BackendType, ok bool) {
+	i := strings.LastIndex(id, ModelTypeDelimiter)
+	if i <= 0 || i == len(id)-len(ModelTypeDelimiter) {
+		return id, "", false
+	}
+	return id[:i], BackendType(id[i+len(ModelTypeDelimiter):]), true
+}
+
+// LookupByQualifiedModel resolves a type-qualified model ID ("llama-3@vllm")
+// to a backend of that type serving the model, preferring healthy backends.
+// It returns the unqualified model ID to send to the backend. A model ID that
+// a backend advertises verbatim is never treated as qualified.
+func (r *BackendRegistry) LookupByQualifiedModel(id string) (Backend, string, bool) {
+	modelID, typ, ok := splitQualifiedModel(id)
+	if !ok {
+		return nil, "", false
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	backendIDs, ok := r.models[modelID]
-	if !ok || len(backendIDs) == 0 {
-		return LookupResult{}, false
+	if _, exact := r.models[id]; exact {
+		return nil, "", false
 	}
 
-	// No session - use first-healthy selection
-	if sessionID == "" {
-		for _, bid := range backendIDs {
-			backend, ok := r.backends[bid]
-			if ok && backend.IsHealthy() {
-				return LookupResult{Backend: backend, SessionBroken: false}, true
-			}
+	var fallback Backend
+	for _, bid := range r.backendIDsForModel(modelID) {
+		backend, ok := r.backends[bid]
+		if !ok || backend.Type() != typ {
+			continue
 		}
-		// No healthy backend, return first anyway
-		if backend, ok := r.backends[backendIDs[0]]; ok {
-			return LookupResult{Backend: backend, SessionBroken: false}, true
+		if r.isHealthy(backend) {
+			return backend, modelID, true
+		}
+		if fallback == nil {
+			fallback = backend
 		}
-		return LookupResult{}, false
 	}
+	if fallback != nil {
+		return fallback, modelID, true
+	}
+	return nil, "", false
+}
 
-	// Get healthy backends for this model, sorted by ID for consistent ordering
-	healthyBackends := make([]Backend, 0, len(backendIDs))
-	var allBackends []Backend
-	for _, bid := range backendIDs {
-		backend, ok := r.backends[bid]
-		if !ok {
-			continue
-		}
-		allBackends = append(allBackends, backend)
-		if backend.IsHealthy() {
-			healthyBackends = append(healthyBackends, backend)
+// HealthyBackendsForModel returns the healthy backends serving a model,
+// in the order they were mapped.
+func (r *BackendRegistry) HealthyBackendsForModel(modelID string) []Backend {
+	return r.HealthyBackendsForModelOp(modelID, OperationAny)
+}
+
+// HealthyBackendsForModelOp is HealthyBackendsForModel restricted to backends
+// that can perform op.
+func (r *BackendRegistry) HealthyBackendsForModelOp(modelID string, op Operation) []Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var healthy []Backend
+	for _, bid := range r.backendIDsForOp(modelID, op) {
+		if backend, ok := r.backends[bid]; ok && r.isHealthy(backend) {
+			healthy = append(healthy, backend)
 		}
 	}
+	return healthy
+}
 
-	// Sort for consistent ordering (backends may be registered in different order)
-	sort.Slice(allBackends, func(i, j int) bool {
-		return allBackends[i].ID() < allBackends[j].ID()
-	})
-	sort.Slice(healthyBackends, func(i, j int) bool {
-		return healthyBackends[i].ID() < healthyBackends[j].ID()
-	})
+// ServesModel reports whether the backend is indexed for modelID, either
+// exactly or through a wildcard pattern.
+func (r *BackendRegistry) ServesModel(backendID, modelID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return containsString(r.backendIDsForModel(modelID), backendID)
+}
 
-	if len(allBackends) == 0 {
-		return LookupResult{}, false
+// LookupResult contains the backend lookup result with session affinity metadata.
+type LookupResult struct {
+	Backend       Backend
+	SessionBroken bool // True if preferred backend was unhealthy and fallback was used
+}
+
+// LookupByModelWithSession finds a backend using session affinity via a consistent-hash ring.
+// If sessionID is empty, falls back to first-available selection (LookupByModel behavior).
+// Sessions hash over the backends LookupByModel would choose from: the lowest
+// tier's healthy backends below their concurrency limit and not rate limited.
+// If the session's preferred backend in that tier can't take it, another does
+// and SessionBroken is set in the result.
+func (r *BackendRegistry) LookupByModelWithSession(modelID, sessionID string) (LookupResult, bool) {
+	return r.LookupByModelWithSessionForOp(modelID, sessionID, OperationAny)
+}
+
+// LookupByModelWithSessionForOp is LookupByModelWithSession restricted to
+// backends that can perform op.
+func (r *BackendRegistry) LookupByModelWithSessionForOp(modelID, sessionID string, op Operation) (LookupResult, bool) {
+	if sessionID == "" {
+		backend, ok := r.LookupByModelForOp(modelID, op)
+		return LookupResult{Backend: backend}, ok
 	}
 
-	// Compute preferred backend using consistent hashing over ALL backends
-	preferredIndex := hashSessionToIndex(sessionID, len(allBackends))
-	preferredBackend := allBackends[preferredIndex]
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	// If preferred backend is healthy, use it
-	if preferredBackend.IsHealthy() {
-		return LookupResult{Backend: preferredBackend, SessionBroken: false}, true
+	// Sort for consistent ordering (backends may be registered in different order)
+	var ids []string
+	for _, bid := range r.backendIDsForOp(modelID, op) {
+		if _, ok := r.backends[bid]; ok {
+			ids = append(ids, bid)
+		}
+	}
+	if len(ids) == 0 {
+		return LookupResult{}, false
 	}
+	sort.Strings(ids)
 
-	// Preferred backend unhealthy - fall back to a healthy one
-	if len(healthyBackends) > 0 {
-		// Use consistent hashing on healthy backends as fallback
-		fallbackIndex := hashSessionToIndex(sessionID, len(healthyBackends))
-		return LookupResult{Backend: healthyBackends[fallbackIndex], SessionBroken: true}, true
+	var healthy []Backend
+	for _, bid := range ids {
+		if backend := r.backends[bid]; r.isHealthy(backend) {
+			healthy = append(healthy, backend)
+		}
+	}
+	candidates := r.preferTier(healthy)
+	if len(candidates) == 0 {
+		// No healthy backends - return the session's preferred one anyway
+		var preferred Backend
+		r.rings.get(ids).walk(sessionID, func(i int) bool {
+			preferred = r.backends[ids[i]]
+			return false
+		})
+		return LookupResult{Backend: preferred, SessionBroken: true}, true
 	}
 
-	// No healthy backends - return preferred (unhealthy) backend anyway
-	return LookupResult{Backend: preferredBackend, SessionBroken: true}, true
+	// The session's home is its first backend on the hash ring over the
+	// lowest tier's backends. If the home can't take it, the first candidate
+	// on the ring over the candidates' tier does, so sessions of a failed or
+	// busy backend spread over the others while the rest stay put
+	home := r.firstOnTierRing(ids, sessionID, nil)
+	available := make(map[string]Backend, len(candidates))
+	for _, b := range candidates {
+		available[b.ID()] = b
+	}
+	chosen := available[r.firstOnTierRing(ids, sessionID, available)]
+	return LookupResult{Backend: chosen, SessionBroken: chosen.ID() != home}, true
 }
 
-// hashSessionToIndex uses FNV-1a hashing to consistently map a session ID to an index.
-func hashSessionToIndex(sessionID string, count int) int {
-	h := fnv.New32a()
-	h.Write([]byte(sessionID))
-	return int(h.Sum32() % uint32(count))
+// firstOnTierRing returns the first backend in ring order from sessionID
+// among ids, which must be sorted, that is in available, or any if available
+// is nil. Only backends of the lowest tier with one in available are on the
+// ring. The caller must hold r.mu.
+func (r *BackendRegistry) firstOnTierRing(ids []string, sessionID string, available map[string]Backend) string {
+	tier, found := 0, false
+	for _, bid := range ids {
+		if _, ok := available[bid]; available != nil && !ok {
+			continue
+		}
+		if t := backendTier(r.backends[bid]); !found || t < tier {
+			tier, found = t, true
+		}
+	}
+	var tierIDs []string
+	for _, bid := range ids {
+		if backendTier(r.backends[bid]) == tier {
+			tierIDs = append(tierIDs, bid)
+		}
+	}
+
+	var first string
+	r.rings.get(tierIDs).walk(sessionID, func(i int) bool {
+		if _, ok := available[tierIDs[i]]; available != nil && !ok {
+			return true
+		}
+		first = tierIDs[i]
+		return false
+	})
+	return first
 }
 
 // LookupByID finds a backend by its ID.
@@ -221,23 +777,185 @@ func (r *BackendRegistry) AllBackends() []Backend {
 	return backends
 }
 
+// BackendInfo is a point-in-time view of a registered backend.
+type BackendInfo struct {
+	ID       string      `json:"id"`
+	Type     BackendType `json:"type"`
+	BaseURL  string      `json:"base_url"`
+	Healthy  bool        `json:"healthy"`
+	InFlight int64       `json:"in_flight"`
+	Models   []string    `json:"models"`
+
+	// Tier and MaxConcurrency are set for backends implementing Tiered and
+	// ConcurrencyLimiter
+	Tier           int `json:"tier,omitempty"`
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+
+	// LatencyEWMAMs is the moving average of response latency in
+	// milliseconds, omitted until the backend completes a request
+	LatencyEWMAMs *float64 `json:"latency_ewma_ms,omitempty"`
+
+	// Tags is set for backends implementing Tagged
+	Tags []string `json:"tags,omitempty"`
+
+	// Warming is set while the backend is reported unhealthy until its
+	// warmup succeeds (see WithWarmupRequired)
+	Warming bool `json:"warming,omitempty"`
+
+	// DeepHealth is the backend's last deep health check, if it had one
+	// (see WithDeepHealthCheck)
+	DeepHealth *DeepHealth `json:"deep_health,omitempty"`
+}
+
+// Snapshot returns the state of every registered backend, sorted by ID.
+func (r *BackendRegistry) Snapshot() []BackendInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := r.modelsByBackend()
+	infos := make([]BackendInfo, 0, len(r.backends))
+	for _, b := range r.backends {
+		infos = append(infos, r.backendInfo(b, models[b.ID()]))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// SnapshotModel returns the state of every backend serving modelID, exactly
+// or through a wildcard pattern, sorted by ID.
+func (r *BackendRegistry) SnapshotModel(modelID string) []BackendInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := r.modelsByBackend()
+	var infos []BackendInfo
+	for _, id := range r.backendIDsForModel(modelID) {
+		if b, ok := r.backends[id]; ok {
+			infos = append(infos, r.backendInfo(b, models[id]))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// BackendInfo returns the state of a single backend.
+func (r *BackendRegistry) BackendInfo(id string) (BackendInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.backends[id]
+	if !ok {
+		return BackendInfo{}, false
+	}
+	return r.backendInfo(b, r.modelsByBackend()[id]), true
+}
+
+// modelsByBackend inverts the model index into backendID -> sorted model IDs (must hold lock).
+func (r *BackendRegistry) modelsByBackend() map[string][]string {
+	byBackend := make(map[string][]string)
+	for modelID, backendIDs := range r.models {
+		for _, id := range backendIDs {
+			byBackend[id] = append(byBackend[id], modelID)
+		}
+	}
+	for _, models := range byBackend {
+		sort.Strings(models)
+	}
+	return byBackend
+}
+
+// backendInfo builds the BackendInfo for b (must hold lock).
+func (r *BackendRegistry) backendInfo(b Backend, models []string) BackendInfo {
+	if models == nil {
+		models = []string{}
+	}
+	info := BackendInfo{
+		ID:       b.ID(),
+		Type:     b.Type(),
+		BaseURL:  b.BaseURL().String(),
+		Healthy:  r.isHealthy(b),
+		InFlight: r.InFlight(b.ID()),
+		Models:   models,
+	}
+	info.Tier = backendTier(b)
+	if tagged, ok := b.(Tagged); ok {
+		info.Tags = tagged.Tags()
+	}
+	info.Warming = r.warmingUp(b)
+	if deep, ok := r.DeepHealth(b.ID()); ok {
+		info.DeepHealth = &deep
+	}
+	if limiter, ok := b.(ConcurrencyLimiter); ok {
+		info.MaxConcurrency = limiter.MaxConcurrency()
+	}
+	if avg, ok := r.LatencyEWMA(b.ID()); ok {
+		ms := float64(avg.Microseconds()) / 1000
+		info.LatencyEWMAMs = &ms
+	}
+	return info
+}
+
+// backendModels is one backend's result from fetchAllModels.
+type backendModels struct {
+	backend Backend
+	models  []types.Model
+	err     error
+}
+
+// fetchAllModels gets every registered backend's models, from the model
+// cache when fresh. Backends are fetched concurrently, at most
+// modelFetchWorkers at a time and each bounded by modelFetchTimeout, so one
+// slow backend can't stall a listing. The lock is not held while fetching.
+// Results are ordered by backend ID.
+func (r *BackendRegistry) fetchAllModels(ctx context.Context) []backendModels {
+	r.mu.RLock()
+	results := make([]backendModels, 0, len(r.backends))
+	for _, b := range r.backends {
+		results = append(results, backendModels{backend: b})
+	}
+	timeout := r.modelFetchTimeout
+	r.mu.RUnlock()
+	sort.Slice(results, func(i, j int) bool { return results[i].backend.ID() < results[j].backend.ID() })
+
+	sem := make(chan struct{}, modelFetchWorkers)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *backendModels) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res.models, res.err = r.cachedModels(ctx, res.backend, timeout)
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
 // AllModels returns all available models across all backends.
 // It also updates the model index to ensure lookups work.
 func (r *BackendRegistry) AllModels(ctx context.Context) []types.Model {
+	results := r.fetchAllModels(ctx)
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	var allModels []types.Model
 	seen := make(map[string]bool)
 
-	for _, backend := range r.backends {
-		models, err := backend.Models(ctx)
-		if err != nil {
+	for _, res := range results {
+		if res.err != nil {
 			continue
 		}
-		for _, model := range models {
+		// Skip backends unregistered while their models were being fetched
+		if r.backends[res.backend.ID()] != res.backend {
+			continue
+		}
+		for _, model := range res.models {
 			// Update model index
-			r.addModelMapping(model.ID, backend.ID())
+			r.addModelMapping(model.ID, res.backend.ID())
 
 			if !seen[model.ID] {
 				seen[model.ID] = true
@@ -249,6 +967,72 @@ func (r *BackendRegistry) AllModels(ctx context.Context) []types.Model {
 	return allModels
 }
 
+// AvailableModels returns the models served by at least one healthy backend,
+// annotated with the capabilities their healthy backends provide. If capability
+// is non-empty, only models offering that capability are returned.
+// Like AllModels, it also updates the model index.
+func (r *BackendRegistry) AvailableModels(ctx context.Context, capability Capability) []types.Model {
+	results := r.fetchAllModels(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var available []types.Model
+	index := make(map[string]int)
+	caps := make(map[string]map[Capability]bool)
+
+	add := func(id string, model types.Model, backend Backend) {
+		if _, ok := index[id]; !ok {
+			model.ID = id
+			index[id] = len(available)
+			caps[id] = make(map[Capability]bool)
+			available = append(available, model)
+		}
+		for _, c := range allCapabilities {
+			if backendSupports(backend, c) {
+				caps[id][c] = true
+			}
+		}
+	}
+
+	for _, res := range results {
+		backend := res.backend
+		if res.err != nil || r.backends[backend.ID()] != backend {
+			continue
+		}
+		healthy := r.isHealthy(backend)
+		for _, model := range res.models {
+			// Update model index
+			r.addModelMapping(model.ID, backend.ID())
+
+			if !healthy {
+				continue
+			}
+			add(model.ID, model, backend)
+			if r.typeQualifiedModels {
+				add(model.ID+ModelTypeDelimiter+string(backend.Type()), model, backend)
+			}
+		}
+	}
+
+	filtered := available[:0]
+	for _, model := range available {
+		modelCaps := caps[model.ID]
+		if capability != "" && !modelCaps[capability] {
+			continue
+		}
+		model.Capabilities = nil
+		for _, c := range allCapabilities {
+			if modelCaps[c] {
+				model.Capabilities = append(model.Capabilities, string(c))
+			}
+		}
+		filtered = append(filtered, model)
+	}
+
+	return filtered
+}
+
 // RefreshModels updates the model index for a backend.
 func (r *BackendRegistry) RefreshModels(ctx context.Context, backendID string) error {
 	r.mu.Lock()
@@ -260,25 +1044,14 @@ func (r *BackendRegistry) RefreshModels(ctx context.Context, backendID string) e
 	}
 
 	// Remove existing mappings for this backend
-	for modelID, backendIDs := range r.models {
-		filtered := make([]string, 0, len(backendIDs))
-		for _, bid := range backendIDs {
-			if bid != backendID {
-				filtered = append(filtered, bid)
-			}
-		}
-		if len(filtered) == 0 {
-			delete(r.models, modelID)
-		} else {
-			r.models[modelID] = filtered
-		}
-	}
+	r.removeModelMappings(backendID)
 
 	// Fetch and re-index models
 	models, err := backend.Models(ctx)
 	if err != nil {
 		return err
 	}
+	r.storeModels(backend, models)
 
 	for _, model := range models {
 		r.addModelMapping(model.ID, backendID)
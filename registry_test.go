@@ -2,9 +2,13 @@ package oairouter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/url"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stevemurr/oairouter/types"
 )
@@ -13,6 +17,21 @@ import (
 type mockBackend struct {
 	id      string
 	healthy atomic.Bool
+	models  []string     // defaults to "test-model"
+	caps    []Capability // nil means all capabilities
+	typ     BackendType  // defaults to BackendGeneric
+
+	maxContext int // Capabilities().MaxContextTokens
+	maxTokens  int // Capabilities().MaxTokensCap
+	defaultMax int // Capabilities().DefaultMaxTokens
+
+	// Optional request hooks; a nil hook returns an empty response.
+	modelsFn           func(ctx context.Context) ([]types.Model, error)
+	chatFn             func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error)
+	chatStreamFn       func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error)
+	completionFn       func(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error)
+	completionStreamFn func(ctx context.Context, req *types.CompletionRequest) (<-chan StreamEvent, error)
+	embeddingsFn       func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error)
 }
 
 func newMockBackend(id string, healthy bool) *mockBackend {
@@ -21,28 +40,75 @@ func newMockBackend(id string, healthy bool) *mockBackend {
 	return b
 }
 
-func (b *mockBackend) ID() string                        { return b.id }
-func (b *mockBackend) Type() BackendType                 { return BackendGeneric }
-func (b *mockBackend) BaseURL() *url.URL                 { return &url.URL{Scheme: "http", Host: "localhost:8080"} }
+func (b *mockBackend) ID() string { return b.id }
+func (b *mockBackend) Type() BackendType {
+	if b.typ == "" {
+		return BackendGeneric
+	}
+	return b.typ
+}
+func (b *mockBackend) BaseURL() *url.URL { return &url.URL{Scheme: "http", Host: "localhost:8080"} }
 func (b *mockBackend) Models(ctx context.Context) ([]types.Model, error) {
-	return []types.Model{{ID: "test-model", Object: "model"}}, nil
+	if b.modelsFn != nil {
+		return b.modelsFn(ctx)
+	}
+	if len(b.models) == 0 {
+		return []types.Model{{ID: "test-model", Object: "model"}}, nil
+	}
+	models := make([]types.Model, len(b.models))
+	for i, id := range b.models {
+		models[i] = types.Model{ID: id, Object: "model"}
+	}
+	return models, nil
+}
+func (b *mockBackend) Supports(c Capability) bool {
+	if b.caps == nil {
+		return true
+	}
+	for _, bc := range b.caps {
+		if bc == c {
+			return true
+		}
+	}
+	return false
+}
+func (b *mockBackend) Capabilities() BackendCapabilities {
+	caps := CapabilitiesFrom(b.Supports)
+	caps.MaxContextTokens = b.maxContext
+	caps.MaxTokensCap, caps.DefaultMaxTokens = b.maxTokens, b.defaultMax
+	return caps
 }
 func (b *mockBackend) HealthCheck(ctx context.Context) error { return nil }
 func (b *mockBackend) IsHealthy() bool                       { return b.healthy.Load() }
 func (b *mockBackend) SetHealthy(h bool)                     { b.healthy.Store(h) }
 func (b *mockBackend) ChatCompletion(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+	if b.chatFn != nil {
+		return b.chatFn(ctx, req)
+	}
 	return nil, nil
 }
 func (b *mockBackend) ChatCompletionStream(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+	if b.chatStreamFn != nil {
+		return b.chatStreamFn(ctx, req)
+	}
 	return nil, nil
 }
 func (b *mockBackend) Completion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	if b.completionFn != nil {
+		return b.completionFn(ctx, req)
+	}
 	return nil, nil
 }
 func (b *mockBackend) CompletionStream(ctx context.Context, req *types.CompletionRequest) (<-chan StreamEvent, error) {
+	if b.completionStreamFn != nil {
+		return b.completionStreamFn(ctx, req)
+	}
 	return nil, nil
 }
 func (b *mockBackend) Embeddings(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+	if b.embeddingsFn != nil {
+		return b.embeddingsFn(ctx, req)
+	}
 	return nil, nil
 }
 
@@ -237,38 +303,504 @@ func TestLookupByModelWithSession_ModelNotFound(t *testing.T) {
 	}
 }
 
-func TestHashSessionToIndex_Deterministic(t *testing.T) {
-	// Test that the same session ID always produces the same index
-	sessionID := "test-session-id"
-	count := 5
-
-	firstResult := hashSessionToIndex(sessionID, count)
+func TestHashRing_Deterministic(t *testing.T) {
+	// Test that the same session ID always maps to the same member
+	members := []string{"backend-a", "backend-b", "backend-c", "backend-d", "backend-e"}
+	first := -1
+	newHashRing(members).walk("test-session-id", func(i int) bool { first = i; return false })
 	for i := 0; i < 100; i++ {
-		result := hashSessionToIndex(sessionID, count)
-		if result != firstResult {
-			t.Errorf("hash not deterministic: got %d, want %d", result, firstResult)
+		newHashRing(members).walk("test-session-id", func(i int) bool {
+			if i != first {
+				t.Errorf("hash not deterministic: got %d, want %d", i, first)
+			}
+			return false
+		})
+	}
+}
+
+func TestHashRing_WalkVisitsEachMemberOnce(t *testing.T) {
+	members := []string{"backend-a", "backend-b", "backend-c"}
+	visited := map[int]int{}
+	newHashRing(members).walk("session", func(i int) bool { visited[i]++; return true })
+	if len(visited) != len(members) {
+		t.Errorf("visited %v, want every member once", visited)
+	}
+	for i, n := range visited {
+		if n != 1 {
+			t.Errorf("member %d visited %d times", i, n)
 		}
 	}
+}
 
-	// Test that result is within bounds
-	if firstResult < 0 || firstResult >= count {
-		t.Errorf("hash result out of bounds: %d (count=%d)", firstResult, count)
+func TestHashRing_Distribution(t *testing.T) {
+	// Sessions should spread roughly evenly over the members
+	members := []string{"backend-a", "backend-b", "backend-c", "backend-d"}
+	ring := newHashRing(members)
+	counts := make([]int, len(members))
+	for i := 0; i < 4000; i++ {
+		ring.walk(fmt.Sprintf("session-%d", i), func(m int) bool { counts[m]++; return false })
+	}
+	for m, n := range counts {
+		if n < 600 || n > 1400 {
+			t.Errorf("%s got %d of 4000 sessions, want about 1000", members[m], n)
+		}
 	}
 }
 
-func TestHashSessionToIndex_DifferentSessions(t *testing.T) {
-	// Test that different session IDs produce different indices (at least some of the time)
-	count := 10
-	indices := make(map[int]bool)
+func TestLookupByModelWithSession_AddingBackendMovesFewSessions(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+	for _, id := range []string{"backend-a", "backend-b", "backend-c", "backend-d"} {
+		r.Register(ctx, newMockBackend(id, true))
+	}
 
-	for i := 0; i < 100; i++ {
-		sessionID := "session-" + string(rune('a'+i%26)) + string(rune('0'+i/26))
-		idx := hashSessionToIndex(sessionID, count)
-		indices[idx] = true
+	before := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		result, _ := r.LookupByModelWithSession("test-model", session)
+		before[session] = result.Backend.ID()
+	}
+
+	r.Register(ctx, newMockBackend("backend-e", true))
+	moved := 0
+	for session, id := range before {
+		result, _ := r.LookupByModelWithSession("test-model", session)
+		if result.Backend.ID() != id {
+			moved++
+			if result.Backend.ID() != "backend-e" {
+				t.Errorf("%s moved between existing backends, from %s to %s", session, id, result.Backend.ID())
+			}
+		}
+	}
+	// About 1/5 of sessions should move to the new backend
+	if moved > 350 {
+		t.Errorf("adding a fifth backend moved %d of 1000 sessions", moved)
+	}
+}
+
+func TestAvailableModels_ExcludesUnhealthyOnlyModels(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	healthy := newMockBackend("backend-a", true)
+	healthy.models = []string{"shared-model"}
+	unhealthy := newMockBackend("backend-b", false)
+	unhealthy.models = []string{"shared-model", "orphan-model"}
+
+	r.Register(ctx, healthy)
+	r.Register(ctx, unhealthy)
+
+	models := r.AvailableModels(ctx, "")
+	if len(models) != 1 {
+		t.Fatalf("expected 1 available model, got %d", len(models))
+	}
+	if models[0].ID != "shared-model" {
+		t.Errorf("expected shared-model, got %s", models[0].ID)
+	}
+
+	// The unhealthy backend's models must still be indexed for lookups
+	if _, ok := r.LookupByModel("orphan-model"); !ok {
+		t.Error("expected orphan-model to remain in the model index")
+	}
+}
+
+func TestAvailableModels_CapabilityFilter(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	chat := newMockBackend("backend-chat", true)
+	chat.models = []string{"llama", "shared"}
+	chat.caps = []Capability{CapabilityChat, CapabilityCompletions}
+	embed := newMockBackend("backend-embed", true)
+	embed.models = []string{"bge", "shared"}
+	embed.caps = []Capability{CapabilityEmbeddings}
+
+	r.Register(ctx, chat)
+	r.Register(ctx, embed)
+
+	byID := func(models []types.Model) map[string]types.Model {
+		m := make(map[string]types.Model)
+		for _, model := range models {
+			m[model.ID] = model
+		}
+		return m
+	}
+
+	all := byID(r.AvailableModels(ctx, ""))
+	if len(all) != 3 {
+		t.Fatalf("expected 3 models, got %d", len(all))
+	}
+	want := []string{"chat", "completions", "embeddings"}
+	if got := all["shared"].Capabilities; len(got) != len(want) {
+		t.Errorf("shared capabilities = %v, want %v", got, want)
+	}
+
+	embeddings := byID(r.AvailableModels(ctx, CapabilityEmbeddings))
+	if len(embeddings) != 2 {
+		t.Fatalf("expected 2 embeddings models, got %d", len(embeddings))
+	}
+	if _, ok := embeddings["llama"]; ok {
+		t.Error("chat-only model should not be listed for embeddings")
+	}
+}
+
+func TestAvailableModels_CapabilitiesFromHealthyBackendsOnly(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	chat := newMockBackend("backend-chat", true)
+	chat.caps = []Capability{CapabilityChat}
+	embed := newMockBackend("backend-embed", false)
+	embed.caps = []Capability{CapabilityEmbeddings}
+
+	r.Register(ctx, chat)
+	r.Register(ctx, embed)
+
+	if models := r.AvailableModels(ctx, CapabilityEmbeddings); len(models) != 0 {
+		t.Errorf("expected no embeddings models while embeddings backend is unhealthy, got %d", len(models))
+	}
+}
+
+func TestRegister_RetriesModelsWithBackoff(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelRetry(Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond})
+
+	updated := make(chan DiscoveryEvent, 1)
+	r.notify = func(e DiscoveryEvent) { updated <- e }
+
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		if calls.Add(1) < 3 {
+			return nil, errors.New("starting up")
+		}
+		return []types.Model{{ID: "late-model"}}, nil
+	}
+
+	if err := r.Register(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.LookupByModel("late-model"); ok {
+		t.Fatal("model should not be indexed before the backend is ready")
+	}
+
+	select {
+	case e := <-updated:
+		if e.Type != EventUpdated || e.Backend.ID() != "backend-a" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for models to be indexed")
+	}
+
+	if _, ok := r.LookupByModel("late-model"); !ok {
+		t.Error("expected late-model to be indexed after retry")
+	}
+}
+
+// seededBackend is a mockBackend that implements ModelSeeder.
+type seededBackend struct {
+	*mockBackend
+	seed []types.Model
+}
+
+func (b *seededBackend) SeedModels() []types.Model { return b.seed }
+
+func TestRegister_SeededModelsUntilFetched(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelRetry(Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond})
+
+	updated := make(chan DiscoveryEvent, 1)
+	r.notify = func(e DiscoveryEvent) { updated <- e }
+
+	var ready atomic.Bool
+	m := newMockBackend("backend-a", true)
+	m.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		if !ready.Load() {
+			return nil, errors.New("starting up")
+		}
+		return []types.Model{{ID: "real-model"}}, nil
+	}
+	b := &seededBackend{mockBackend: m, seed: []types.Model{{ID: "seed-model"}}}
+
+	r.Register(context.Background(), b)
+	if got, ok := r.LookupByModel("seed-model"); !ok || got.ID() != "backend-a" {
+		t.Fatal("expected seeded model to be routable before models are fetched")
 	}
 
-	// We should hit multiple different indices
-	if len(indices) < 3 {
-		t.Errorf("expected more distribution, only got %d different indices", len(indices))
+	ready.Store(true)
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for models to be indexed")
+	}
+
+	if _, ok := r.LookupByModel("real-model"); !ok {
+		t.Error("expected fetched model to be indexed")
+	}
+	if _, ok := r.LookupByModel("seed-model"); ok {
+		t.Error("fetched models should replace the seed")
+	}
+}
+
+func TestRegister_FetchedModelsIgnoreSeed(t *testing.T) {
+	r := NewBackendRegistry()
+	b := &seededBackend{mockBackend: newMockBackend("backend-a", true), seed: []types.Model{{ID: "seed-model"}}}
+
+	r.Register(context.Background(), b)
+	if _, ok := r.LookupByModel("seed-model"); ok {
+		t.Error("seed should be unused when models can be fetched")
+	}
+	if _, ok := r.LookupByModel("test-model"); !ok {
+		t.Error("expected fetched model to be indexed")
+	}
+}
+
+func TestRegister_RetryStopsOnUnregister(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelRetry(Backoff{Initial: 5 * time.Millisecond, Max: 5 * time.Millisecond})
+	r.notify = func(e DiscoveryEvent) { t.Errorf("unexpected event after unregister: %+v", e) }
+
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		calls.Add(1)
+		return nil, errors.New("starting up")
+	}
+
+	r.Register(context.Background(), b)
+	r.Unregister("backend-a")
+
+	time.Sleep(30 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected no retries after unregister, got %d Models calls", n)
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, w := range want {
+		if got := b.Delay(attempt); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+}
+
+func TestLookupByModel_WildcardPatterns(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	broad := newMockBackend("backend-broad", true)
+	broad.models = []string{"llama-*"}
+	narrow := newMockBackend("backend-narrow", true)
+	narrow.models = []string{"llama-3-8b-ft-*"}
+	exact := newMockBackend("backend-exact", true)
+	exact.models = []string{"llama-3-8b-ft-legal"}
+	namespaced := newMockBackend("backend-namespaced", true)
+	namespaced.models = []string{"org/*-instruct"}
+
+	for _, b := range []*mockBackend{broad, narrow, exact, namespaced} {
+		r.Register(ctx, b)
+	}
+
+	tests := []struct {
+		model     string
+		wantID    string
+		wantFound bool
+	}{
+		{"llama-3-8b-ft-legal", "backend-exact", true},    // exact match beats both patterns
+		{"llama-3-8b-ft-medical", "backend-narrow", true}, // more specific pattern wins
+		{"llama-2-13b", "backend-broad", true},
+		{"org/team/mistral-instruct", "backend-namespaced", true}, // '*' spans '/'
+		{"mistral-7b", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			b, ok := r.LookupByModel(tt.model)
+			if ok != tt.wantFound {
+				t.Fatalf("LookupByModel(%s) found = %v, want %v", tt.model, ok, tt.wantFound)
+			}
+			if ok && b.ID() != tt.wantID {
+				t.Errorf("LookupByModel(%s) = %s, want %s", tt.model, b.ID(), tt.wantID)
+			}
+		})
+	}
+}
+
+func TestLookupByModelPattern_AmbiguousIsDeterministic(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	// Both patterns have the same number of literal characters and wildcards
+	prefix := newMockBackend("backend-prefix", true)
+	prefix.models = []string{"qwen-*"}
+	suffix := newMockBackend("backend-suffix", true)
+	suffix.models = []string{"*-chat"}
+
+	r.Register(ctx, prefix)
+	r.Register(ctx, suffix)
+
+	for i := 0; i < 20; i++ {
+		b, ok := r.LookupByModel("qwen-chat")
+		if !ok {
+			t.Fatal("expected a pattern match")
+		}
+		if b.ID() != "backend-suffix" {
+			t.Fatalf("expected lexically smaller pattern to win, got %s", b.ID())
+		}
+	}
+}
+
+func TestMatchModelPattern(t *testing.T) {
+	tests := []struct {
+		pattern, model string
+		want           bool
+	}{
+		{"llama-*", "llama-3", true},
+		{"llama-*", "llama-", true},
+		{"llama-*", "mistral", false},
+		{"*-instruct", "qwen-instruct", true},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXcYYb", false},
+		{"ab*ba", "aba", false},
+		{"exact", "exact", true},
+	}
+	for _, tt := range tests {
+		if got := matchModelPattern(tt.pattern, tt.model); got != tt.want {
+			t.Errorf("matchModelPattern(%q, %q) = %v, want %v", tt.pattern, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestAllModels_SlowBackendTimesOut(t *testing.T) {
+	r := NewBackendRegistry()
+	r.SetModelRetry(Backoff{})
+	ctx := context.Background()
+
+	fast := newMockBackend("fast", true)
+	fast.models = []string{"fast-model"}
+	r.Register(ctx, fast)
+
+	slow := newMockBackend("slow", true)
+	r.Register(ctx, slow)
+	r.SetModelFetchTimeout(20 * time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+	fetching := make(chan struct{}, 1)
+	slow.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+		fetching <- struct{}{}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+			return nil, nil
+		}
+	}
+
+	done := make(chan []types.Model)
+	go func() { done <- r.AllModels(ctx) }()
+
+	// The registry stays usable while a listing waits on a slow backend
+	<-fetching
+	if _, ok := r.LookupByModel("fast-model"); !ok {
+		t.Fatal("lookup blocked or failed during listing")
+	}
+
+	select {
+	case models := <-done:
+		if len(models) != 1 || models[0].ID != "fast-model" {
+			t.Errorf("expected only fast-model, got %+v", models)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AllModels didn't time out the slow backend")
+	}
+}
+
+func TestAllModels_FetchesConcurrently(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+
+	var active, peak atomic.Int64
+	for i := range 2 * modelFetchWorkers {
+		b := newMockBackend(fmt.Sprintf("backend-%02d", i), true)
+		r.Register(ctx, b)
+		b.modelsFn = func(ctx context.Context) ([]types.Model, error) {
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			active.Add(-1)
+			return []types.Model{{ID: b.id + "-model"}}, nil
+		}
+	}
+
+	models := r.AvailableModels(ctx, "")
+	if len(models) != 2*modelFetchWorkers {
+		t.Fatalf("expected %d models, got %d", 2*modelFetchWorkers, len(models))
+	}
+	if p := peak.Load(); p < 2 || p > modelFetchWorkers {
+		t.Errorf("expected between 2 and %d concurrent fetches, got %d", modelFetchWorkers, p)
+	}
+	if _, ok := r.LookupByModel("backend-00-model"); !ok {
+		t.Error("listing should index models")
+	}
+}
+
+func TestUnregister_ForgetsInFlightCounter(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+	r.Register(ctx, newMockBackend("idle", true))
+	r.Register(ctx, newMockBackend("busy", true))
+
+	r.Acquire("idle")()
+	release := r.Acquire("busy")
+	r.Unregister("idle")
+	r.Unregister("busy")
+	if _, ok := r.inflight.Load("idle"); ok {
+		t.Error("idle backend's counter kept after Unregister")
+	}
+
+	// A busy backend's counter is kept for WaitIdle until its last release
+	waited := make(chan error, 1)
+	go func() { waited <- r.WaitIdle(ctx, "busy") }()
+	release()
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.inflight.Load("busy"); ok {
+		t.Error("busy backend's counter kept after its last release")
+	}
+}
+
+func TestReplace_ForgetsRemovedBackendState(t *testing.T) {
+	r := NewBackendRegistry()
+	ctx := context.Background()
+	r.Register(ctx, newMockBackend("backend-a", true))
+
+	r.healthStatsFor("backend-a").record(time.Second, true)
+	r.recordLatency("backend-a", time.Second)
+	r.markRateLimited("backend-a", time.Minute)
+
+	r.Replace(ctx, nil)
+	for name, m := range map[string]*sync.Map{"health": &r.health, "latency": &r.latency, "rate limit": &r.rateLimits} {
+		if _, ok := m.Load("backend-a"); ok {
+			t.Errorf("%s kept after Replace removed the backend", name)
+		}
+	}
+
+	// A backend re-added under the same ID starts fresh
+	r.Replace(ctx, []Backend{newMockBackend("backend-a", true)})
+	if r.rateLimited("backend-a") {
+		t.Error("re-added backend is still rate limited")
+	}
+	if _, ok := r.LatencyEWMA("backend-a"); ok {
+		t.Error("re-added backend kept its latency")
 	}
 }
@@ -0,0 +1,103 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestReplaceBackends_DrainsInFlight(t *testing.T) {
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	old := newMockBackend("backend-old", true)
+	old.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		close(started)
+		<-unblock
+		return &types.ChatCompletionResponse{ID: "from-old", Object: "chat.completion"}, nil
+	}
+
+	replacement := newMockBackend("backend-new", true)
+	replacement.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: "from-new", Object: "chat.completion"}, nil
+	}
+
+	r, err := NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(ctx, old)
+
+	// Start a request that stays in flight on the old backend
+	inFlight := make(chan int)
+	go func() {
+		rec := postChat(t, r, testChatBody)
+		inFlight <- rec.Code
+	}()
+	<-started
+
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- r.ReplaceBackends(ctx, []Backend{replacement})
+	}()
+
+	// New requests go to the replacement while the old one drains
+	deadline := time.After(time.Second)
+	for {
+		if _, ok := r.Backends().LookupByID("backend-new"); ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("replacement backend was never registered")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if _, ok := r.Backends().LookupByID("backend-old"); ok {
+		t.Error("old backend should be unregistered after the swap")
+	}
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "from-new") {
+		t.Errorf("expected new request to be served by the replacement, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case err := <-reloaded:
+		t.Fatalf("ReplaceBackends returned before draining: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(unblock)
+	if code := <-inFlight; code != http.StatusOK {
+		t.Errorf("in-flight request failed with %d", code)
+	}
+	if err := <-reloaded; err != nil {
+		t.Errorf("ReplaceBackends: %v", err)
+	}
+	if n := r.Backends().InFlight("backend-old"); n != 0 {
+		t.Errorf("expected old backend to be idle, have %d in flight", n)
+	}
+}
+
+func TestReplaceBackends_DrainTimeout(t *testing.T) {
+	r, _ := NewRouter()
+	old := newMockBackend("backend-old", true)
+	r.AddBackend(context.Background(), old)
+
+	release := r.Backends().Acquire("backend-old")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.ReplaceBackends(ctx, nil); err == nil {
+		t.Error("expected a drain timeout error")
+	}
+	if r.Backends().Count() != 0 {
+		t.Errorf("expected no backends after replacing with an empty set, have %d", r.Backends().Count())
+	}
+}
@@ -0,0 +1,189 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// RequestLog describes one completed API request. It is passed to the hook
+// set with WithRequestLogger.
+type RequestLog struct {
+	Endpoint  string        // Request path, e.g. /v1/chat/completions
+	Model     string        // Requested model
+	BackendID string        // Backend that served the request; empty if none was selected
+	Stream    bool          // Whether the response was streamed
+	Status    int           // HTTP status sent to the client
+	Latency   time.Duration // Time from receiving the request to finishing the response
+	Usage     *types.Usage  // Token usage reported in the response; nil if it had none
+
+	// The client's OpenAI-Organization and OpenAI-Project headers, if sent
+	Organization string
+	Project      string
+
+	// Bodies are only captured with WithRequestLogBodies, and are cut off at
+	// its limit. Streamed response bodies are never captured.
+	RequestBody  []byte
+	ResponseBody []byte
+	Truncated    bool // A captured body exceeded the limit
+}
+
+type requestLogKey struct{}
+
+// requestRecorder records the status and, optionally, the bodies of a request
+// for its RequestLog.
+type requestRecorder struct {
+	http.ResponseWriter
+	entry RequestLog
+	start time.Time
+
+	reqBody  cappedBuffer
+	respBody cappedBuffer
+	capture  bool
+}
+
+// startRequestLog wraps w and req to record the request. The returned request
+// carries the recorder so handlers can fill in routing details with
+// noteRequest.
+func (r *Router) startRequestLog(w http.ResponseWriter, req *http.Request) (*requestRecorder, *http.Request) {
+	rec := &requestRecorder{
+		ResponseWriter: w,
+		entry:          RequestLog{Endpoint: req.URL.Path},
+		start:          time.Now(),
+		reqBody:        cappedBuffer{limit: r.requestLogBodyLimit},
+		respBody:       cappedBuffer{limit: r.requestLogBodyLimit},
+		capture:        r.requestLogger != nil && r.requestLogBodyLimit > 0,
+	}
+	rec.entry.Organization = req.Header.Get(OrganizationHeader)
+	rec.entry.Project = req.Header.Get(ProjectHeader)
+	if rec.capture {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(req.Body, &rec.reqBody), req.Body}
+	}
+	return rec, req.WithContext(context.WithValue(req.Context(), requestLogKey{}, rec))
+}
+
+// finishRequestLog passes the completed entry to the request logger and
+// writes the access log line.
+func (r *Router) finishRequestLog(rec *requestRecorder) {
+	entry := rec.entry
+	entry.Latency = time.Since(rec.start)
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if rec.capture {
+		entry.RequestBody = rec.reqBody.buf
+		if !entry.Stream {
+			entry.ResponseBody = rec.respBody.buf
+		}
+		entry.Truncated = rec.reqBody.truncated || (!entry.Stream && rec.respBody.truncated)
+	}
+	if r.accessLog {
+		r.logAccess(entry)
+	}
+	if r.requestLogger != nil {
+		r.requestLogger(entry)
+	}
+}
+
+// logAccess writes one structured line for a completed request. The keys are
+// stable so the lines can be indexed by log pipelines.
+func (r *Router) logAccess(entry RequestLog) {
+	attrs := []slog.Attr{
+		slog.String("endpoint", entry.Endpoint),
+		slog.String("model", entry.Model),
+		slog.String("backend", entry.BackendID),
+		slog.Bool("stream", entry.Stream),
+		slog.Int("status", entry.Status),
+		slog.Float64("latency_ms", float64(entry.Latency.Microseconds())/1000),
+	}
+	if entry.Organization != "" {
+		attrs = append(attrs, slog.String("organization", entry.Organization))
+	}
+	if entry.Project != "" {
+		attrs = append(attrs, slog.String("project", entry.Project))
+	}
+	if entry.Usage != nil {
+		attrs = append(attrs,
+			slog.Int("prompt_tokens", entry.Usage.PromptTokens),
+			slog.Int("completion_tokens", entry.Usage.CompletionTokens),
+		)
+	}
+	r.logger.LogAttrs(context.Background(), slog.LevelInfo, "request completed", attrs...)
+}
+
+// noteRequest updates the request's log entry, if it is being logged.
+func noteRequest(req *http.Request, update func(*RequestLog)) {
+	if rec, ok := req.Context().Value(requestLogKey{}).(*requestRecorder); ok {
+		update(&rec.entry)
+	}
+}
+
+// noteStreamUsage records the usage carried by a streamed chunk, which
+// backends send in the final chunk when asked for it.
+func noteStreamUsage(req *http.Request, data string) {
+	rec, ok := req.Context().Value(requestLogKey{}).(*requestRecorder)
+	if !ok || !strings.Contains(data, `"usage"`) {
+		return
+	}
+	var chunk struct {
+		Usage *types.Usage `json:"usage"`
+	}
+	if json.Unmarshal([]byte(data), &chunk) == nil && chunk.Usage != nil {
+		rec.entry.Usage = chunk.Usage
+	}
+}
+
+func (rec *requestRecorder) WriteHeader(status int) {
+	if rec.entry.Status == 0 {
+		rec.entry.Status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *requestRecorder) Write(p []byte) (int, error) {
+	if rec.entry.Status == 0 {
+		rec.entry.Status = http.StatusOK
+	}
+	if rec.capture && !rec.entry.Stream {
+		rec.respBody.Write(p)
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming working through the recorder.
+func (rec *requestRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rec *requestRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+type cappedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); len(p) > room {
+		b.buf = append(b.buf, p[:max(room, 0)]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,201 @@
+package oairouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestRequestLogger_NonStreaming(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: "chatcmpl-1", Model: req.Model}, nil
+	}
+
+	var logs []RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { logs = append(logs, l) }))
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, testChatBody)
+
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	l := logs[0]
+	if l.Endpoint != "/v1/chat/completions" || l.Model != "test-model" || l.BackendID != "backend-a" || l.Status != http.StatusOK || l.Stream {
+		t.Errorf("log = %+v", l)
+	}
+	if l.Latency <= 0 {
+		t.Error("expected a positive latency")
+	}
+	if l.RequestBody != nil || l.ResponseBody != nil {
+		t.Error("bodies captured without WithRequestLogBodies")
+	}
+}
+
+func TestRequestLogger_CapturesTruncatedBodies(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: "chatcmpl-1", Model: req.Model}, nil
+	}
+
+	var got RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { got = l }), WithRequestLogBodies(16), WithLogRedactor(nil))
+	r.AddBackend(context.Background(), b)
+
+	body := testChatBody
+	rec := postChat(t, r, body)
+
+	if string(got.RequestBody) != body[:16] {
+		t.Errorf("RequestBody = %q, want %q", got.RequestBody, body[:16])
+	}
+	if string(got.ResponseBody) != rec.Body.String()[:16] {
+		t.Errorf("ResponseBody = %q, want %q", got.ResponseBody, rec.Body.String()[:16])
+	}
+	if !got.Truncated {
+		t.Error("expected Truncated")
+	}
+}
+
+func TestRequestLogger_Streaming(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"choices":[{"delta":{"content":"Hello"}}]}`), nil
+	}
+
+	var logs []RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { logs = append(logs, l) }), WithRequestLogBodies(1024))
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+
+	if !strings.Contains(rec.Body.String(), "Hello") || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream broken by request logging: %q", rec.Body.String())
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	if !logs[0].Stream || logs[0].BackendID != "backend-a" || logs[0].ResponseBody != nil {
+		t.Errorf("log = %+v", logs[0])
+	}
+	if len(logs[0].RequestBody) == 0 {
+		t.Error("expected the request body for a stream")
+	}
+}
+
+func TestRequestLogger_Rejected(t *testing.T) {
+	var got RequestLog
+	r, _ := NewRouter(WithRequestLogger(func(l RequestLog) { got = l }))
+
+	postChat(t, r, `{"model":"missing","messages":[{"role":"user","content":"hi"}]}`)
+
+	if got.Status != http.StatusNotFound || got.Model != "missing" || got.BackendID != "" {
+		t.Errorf("log = %+v", got)
+	}
+}
+
+func TestAccessLog_IncludesUsage(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: "chatcmpl-1", Usage: &types.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}}, nil
+	}
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"choices":[{"delta":{"content":"hi"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+		), nil
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r, _ := NewRouter(WithLogger(logger), WithAccessLog(true))
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, testChatBody)
+	postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		if entry["msg"] == "request completed" {
+			lines = append(lines, entry)
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d access log lines, want 2: %s", len(lines), buf.String())
+	}
+
+	want := []struct {
+		stream             bool
+		prompt, completion float64
+	}{{false, 7, 3}, {true, 5, 2}}
+	for i, w := range want {
+		l := lines[i]
+		if l["endpoint"] != "/v1/chat/completions" || l["model"] != "test-model" || l["backend"] != "backend-a" || l["status"] != float64(http.StatusOK) || l["stream"] != w.stream {
+			t.Errorf("line %d = %v", i, l)
+		}
+		if l["prompt_tokens"] != w.prompt || l["completion_tokens"] != w.completion {
+			t.Errorf("line %d tokens = %v/%v, want %v/%v", i, l["prompt_tokens"], l["completion_tokens"], w.prompt, w.completion)
+		}
+		if _, ok := l["latency_ms"].(float64); !ok {
+			t.Errorf("line %d has no latency_ms", i)
+		}
+	}
+}
+
+func TestAccessLog_RejectedRequest(t *testing.T) {
+	var buf bytes.Buffer
+	r, _ := NewRouter(WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))), WithAccessLog(true))
+
+	postChat(t, r, `{"model":"missing","messages":[{"role":"user","content":"hi"}]}`)
+
+	if !strings.Contains(buf.String(), `"msg":"request completed"`) || !strings.Contains(buf.String(), `"status":404`) {
+		t.Errorf("expected a 404 access log line, got %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "prompt_tokens") {
+		t.Errorf("expected no token counts without usage, got %s", buf.String())
+	}
+}
+
+func TestFailureLog_SummarizesCompletionPrompt(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.completionFn = func(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+		return nil, errors.New("boom")
+	}
+
+	var buf bytes.Buffer
+	r, _ := NewRouter(WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.AddBackend(context.Background(), b)
+
+	postJSON(t, r, "/v1/completions", `{"model":"test-model","prompt":[[1,2,3],[4]]}`)
+
+	var entry struct {
+		Msg    string `json:"msg"`
+		Prompt struct {
+			Kind   string `json:"kind"`
+			Count  int    `json:"count"`
+			Tokens int    `json:"tokens"`
+		} `json:"prompt"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if json.Unmarshal([]byte(line), &entry) == nil && entry.Msg == "completion failed" {
+			break
+		}
+	}
+	if entry.Msg != "completion failed" {
+		t.Fatalf("no failure log: %s", buf.String())
+	}
+	if p := entry.Prompt; p.Kind != "tokens" || p.Count != 2 || p.Tokens != 4 {
+		t.Errorf("logged prompt = %+v, want 2 token prompts with 4 tokens", p)
+	}
+}
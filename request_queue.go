@@ -0,0 +1,200 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	errQueueFull    = errors.New("request queue is full")
+	errQueueTimeout = errors.New("timed out waiting in request queue")
+)
+
+// requestQueue holds requests for backends at their concurrency limit until
+// a slot frees up. Waiters are served first come, first served per backend.
+type requestQueue struct {
+	registry *BackendRegistry
+	max      int
+	wait     time.Duration
+
+	mu      sync.Mutex
+	waiters []*queueWaiter // In arrival order, across all backends
+}
+
+// queueWaiter is a request waiting for a slot on a backend.
+type queueWaiter struct {
+	backendID string
+	ready     chan struct{} // Signaled when a slot may be free
+	signaled  bool          // ready was sent and not yet acted on
+}
+
+func newRequestQueue(registry *BackendRegistry, maxQueue int, maxWait time.Duration) *requestQueue {
+	return &requestQueue{registry: registry, max: maxQueue, wait: maxWait}
+}
+
+// acquire takes a slot on b, waiting in the queue while b is at its
+// concurrency limit. It returns errQueueFull if the queue has no room,
+// errQueueTimeout if no slot frees up in time, or ctx's error if the client
+// goes away first.
+func (q *requestQueue) acquire(ctx context.Context, b Backend) (release func(), err error) {
+	if release, ok := q.tryAcquire(b); ok {
+		return release, nil
+	}
+	q.mu.Lock()
+	if len(q.waiters) >= q.max {
+		q.mu.Unlock()
+		return nil, errQueueFull
+	}
+	waiter := &queueWaiter{backendID: b.ID(), ready: make(chan struct{}, 1)}
+	q.waiters = append(q.waiters, waiter)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-waiter.ready:
+			q.mu.Lock()
+			if q.registry.atLimit(b) {
+				// A request outside the queue took the slot first
+				waiter.signaled = false
+				q.mu.Unlock()
+				continue
+			}
+			q.removeLocked(waiter)
+			release = q.registry.Acquire(b.ID())
+			// More than one slot may have freed up
+			q.wakeLocked(b.ID())
+			q.mu.Unlock()
+			return release, nil
+		case <-timer.C:
+			q.abandon(waiter)
+			return nil, errQueueTimeout
+		case <-ctx.Done():
+			q.abandon(waiter)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAcquire takes a slot on b if it is below its concurrency limit and no
+// request is waiting for it.
+func (q *requestQueue) tryAcquire(b Backend) (release func(), ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.registry.atLimit(b) || q.hasWaiterLocked(b.ID()) {
+		return nil, false
+	}
+	return q.registry.Acquire(b.ID()), true
+}
+
+// wake signals the first waiting request for backendID. It is called
+// whenever a request to the backend finishes.
+func (q *requestQueue) wake(backendID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.wakeLocked(backendID)
+}
+
+func (q *requestQueue) wakeLocked(backendID string) {
+	for _, w := range q.waiters {
+		if w.backendID != backendID {
+			continue
+		}
+		if !w.signaled {
+			w.signaled = true
+			w.ready <- struct{}{}
+		}
+		return
+	}
+}
+
+// abandon removes a waiter that gave up, passing on a slot it was signaled
+// for to the next waiter.
+func (q *requestQueue) abandon(w *queueWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeLocked(w)
+	if w.signaled {
+		q.wakeLocked(w.backendID)
+	}
+}
+
+func (q *requestQueue) removeLocked(w *queueWaiter) {
+	for i, other := range q.waiters {
+		if other == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (q *requestQueue) hasWaiterLocked(backendID string) bool {
+	for _, w := range q.waiters {
+		if w.backendID == backendID {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of queued requests.
+func (q *requestQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// retryAfter returns the Retry-After value sent with a queue error.
+func (q *requestQueue) retryAfter() string {
+	return strconv.Itoa(max(int(math.Ceil(q.wait.Seconds())), 1))
+}
+
+// isQueueError reports whether err is a request that couldn't be queued or
+// waited too long. It says nothing about the backend.
+func isQueueError(err error) bool {
+	return errors.Is(err, errQueueFull) || errors.Is(err, errQueueTimeout)
+}
+
+// acquireSlot takes a slot on backend for one backend call, queuing it if
+// WithRequestQueue is set and the backend is at its concurrency limit. Every
+// call to a backend on a client's behalf, including hedges, reroutes, and
+// fan-out, takes its slot here. It fails only with a queue error or ctx's
+// error.
+func (r *Router) acquireSlot(ctx context.Context, backend Backend) (release func(), err error) {
+	if r.requestQueue == nil {
+		return r.registry.Acquire(backend.ID()), nil
+	}
+	return r.requestQueue.acquire(ctx, backend)
+}
+
+// tryAcquireSlot takes a slot on backend if one is free without queuing, and
+// reports whether it did. Without a request queue it always does.
+func (r *Router) tryAcquireSlot(backend Backend) (release func(), ok bool) {
+	if r.requestQueue == nil {
+		return r.registry.Acquire(backend.ID()), true
+	}
+	return r.requestQueue.tryAcquire(backend)
+}
+
+// acquireBackend takes a slot on backend for the request with acquireSlot. If
+// the request can't be queued or waits too long it writes a 503 with
+// Retry-After; if the client goes away it writes nothing. Either way ok is
+// false.
+func (r *Router) acquireBackend(w http.ResponseWriter, req *http.Request, backend Backend) (release func(), ok bool) {
+	release, err := r.acquireSlot(req.Context(), backend)
+	if err == nil {
+		return release, true
+	}
+	if req.Context().Err() != nil {
+		return nil, false
+	}
+	r.logger.Warn("request not queued", "backend", backend.ID(), "error", err)
+	r.writeBackendError(w, err)
+	return nil, false
+}
@@ -0,0 +1,217 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// queueRouter returns a router whose only backend serves one request at a
+// time, reporting each request it starts and holding it until unblock is
+// sent to.
+func queueRouter(t *testing.T, maxQueue int, maxWait time.Duration) (r *Router, started <-chan struct{}, unblock chan<- struct{}) {
+	t.Helper()
+	startedCh := make(chan struct{}, 10)
+	unblockCh := make(chan struct{})
+	b := newTieredBackend("backend-a", 0, 1)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		startedCh <- struct{}{}
+		<-unblockCh
+		return &types.ChatCompletionResponse{ID: "backend-a"}, nil
+	}
+	return newTestRouter(t, []Backend{b}, WithRequestQueue(maxQueue, maxWait)), startedCh, unblockCh
+}
+
+// waitQueued waits until n requests are queued.
+func waitQueued(t *testing.T, r *Router, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for r.requestQueue.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue length = %d, want %d", r.requestQueue.Len(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestQueue_WaitsForSlot(t *testing.T) {
+	r, started, unblock := queueRouter(t, 2, time.Second)
+
+	first := postChatAsync(t, r, testChatBody)
+	<-started
+	second := postChatAsync(t, r, testChatBody)
+	waitQueued(t, r, 1)
+
+	select {
+	case <-started:
+		t.Fatal("queued request reached the backend while it was at its limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unblock <- struct{}{}
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Fatalf("first: status = %d", rec.Code)
+	}
+	<-started
+	unblock <- struct{}{}
+	if rec := <-second; rec.Code != http.StatusOK {
+		t.Errorf("second: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequestQueue_FullQueueRejected(t *testing.T) {
+	r, started, unblock := queueRouter(t, 1, 1500*time.Millisecond)
+	defer close(unblock)
+
+	postChatAsync(t, r, testChatBody)
+	<-started
+	postChatAsync(t, r, testChatBody)
+	waitQueued(t, r, 1)
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
+
+func TestRequestQueue_WaitTimesOut(t *testing.T) {
+	r, started, unblock := queueRouter(t, 1, 20*time.Millisecond)
+	defer close(unblock)
+
+	postChatAsync(t, r, testChatBody)
+	<-started
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+	}
+	if n := r.requestQueue.Len(); n != 0 {
+		t.Errorf("expected the timed-out request to leave the queue, %d queued", n)
+	}
+}
+
+func TestRequestQueue_ClientCancellation(t *testing.T) {
+	r, started, unblock := queueRouter(t, 2, time.Second)
+
+	first := postChatAsync(t, r, testChatBody)
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := postChatAsync(t, r, testChatBody, withContext(ctx))
+	waitQueued(t, r, 1)
+	third := postChatAsync(t, r, testChatBody)
+	waitQueued(t, r, 2)
+
+	cancel()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("cancelled request kept waiting")
+	}
+	waitQueued(t, r, 1)
+
+	// The slot freed by the first request goes to the next waiter
+	unblock <- struct{}{}
+	<-first
+	<-started
+	unblock <- struct{}{}
+	if rec := <-third; rec.Code != http.StatusOK {
+		t.Errorf("third: status = %d", rec.Code)
+	}
+}
+
+func TestRequestQueue_UnlimitedBackendsNotQueued(t *testing.T) {
+	r, _ := NewRouter(WithRequestQueue(1, time.Second))
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+
+	for range 3 {
+		if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+	}
+}
+
+func TestRequestQueue_Options(t *testing.T) {
+	for _, opt := range []Option{
+		WithRequestQueue(0, time.Second),
+		WithRequestQueue(1, 0),
+	} {
+		if _, err := NewRouter(opt); err == nil {
+			t.Error("expected an error")
+		}
+	}
+}
+
+func TestRequestQueue_FanOutWaitsForSlots(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	b := newTieredBackend("backend-a", 0, 1)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &types.ChatCompletionResponse{Choices: []types.Choice{{Message: types.ChatMessage{Role: "assistant", Content: "hi"}}}}, nil
+	}
+	r := newTestRouter(t, []Backend{b}, WithRequestQueue(4, time.Second), WithFanOutN(true))
+
+	start := time.Now()
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"n":3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("fan-out took %v, waiting on the queue for the caller's own slot", elapsed)
+	}
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Choices) != 3 {
+		t.Errorf("got %d choices, want 3", len(resp.Choices))
+	}
+	if got := peak.Load(); got != 1 {
+		t.Errorf("backend served %d fanned-out requests at once, want 1", got)
+	}
+}
+
+func TestRequestQueue_HedgeFallbackWaitsForSlot(t *testing.T) {
+	local, _ := hedgeBackend("local", BackendOllama, 100*time.Millisecond, nil)
+	var cloudCalls atomic.Int32
+	cloud := newTieredBackend("cloud", 0, 1)
+	cloud.typ = BackendGeneric
+	cloud.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		cloudCalls.Add(1)
+		return &types.ChatCompletionResponse{ID: "cloud"}, nil
+	}
+	r := newTestRouter(t, []Backend{local, cloud}, hedgeOption, WithRequestQueue(4, 30*time.Millisecond))
+
+	// Another request holds the fallback's only slot
+	release := r.registry.Acquire("cloud")
+	defer release()
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if got := servedID(t, rec.Body.Bytes()); got != "local" {
+		t.Errorf("served by %q, want local", got)
+	}
+	if n := cloudCalls.Load(); n != 0 {
+		t.Errorf("fallback at its concurrency limit was called %d times", n)
+	}
+}
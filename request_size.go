@@ -0,0 +1,31 @@
+package oairouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// limitRequestBody caps how much of req's body handlers can read, if
+// WithMaxRequestBytes is set. Reads past the limit fail with
+// *http.MaxBytesError.
+func (r *Router) limitRequestBody(w http.ResponseWriter, req *http.Request) {
+	if r.maxRequestBytes > 0 && req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, r.maxRequestBytes)
+	}
+}
+
+// writeDecodeError writes the response for a request body that couldn't be
+// decoded: 413 if it was larger than WithMaxRequestBytes allows, 400
+// otherwise.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		types.WriteError(w, http.StatusRequestEntityTooLarge,
+			types.InvalidRequestError(fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit)))
+		return
+	}
+	types.WriteError(w, http.StatusBadRequest, types.InvalidRequestError("invalid request body: "+err.Error()))
+}
@@ -0,0 +1,50 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestMaxRequestBytes(t *testing.T) {
+	var calls atomic.Int64
+	r, err := NewRouter(WithMaxRequestBytes(1024), WithAdmin(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Fatalf("small request: status = %d", rec.Code)
+	}
+
+	large := `{"model":"test-model","messages":[{"role":"user","content":"` + strings.Repeat("x", 2048) + `"}]}`
+	rec := postChat(t, r, large)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large request: status = %d, want 413", rec.Code)
+	}
+	var apiErr types.APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Error.Type != types.ErrorTypeInvalidRequest {
+		t.Errorf("expected an invalid_request_error, got %s", rec.Body.String())
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected only the small request to reach the backend, got %d", calls.Load())
+	}
+
+	// Admin endpoints are limited too
+	body := `{"weights":{"` + strings.Repeat("x", 2048) + `":1}}`
+	if w := adminDo(r, http.MethodPut, "/admin/canaries/test-model", body, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("admin request: status = %d, want 413", w.Code)
+	}
+}
+
+func TestMaxRequestBytes_Invalid(t *testing.T) {
+	if _, err := NewRouter(WithMaxRequestBytes(0)); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,45 @@
+package oairouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// RequestTimeoutHeader lets a client set its own deadline for a request, as a
+// Go duration such as "30s" or "1m30s".
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// requestTimeout returns the timeout to apply to req: the client's
+// X-Request-Timeout, capped by the maximum set with WithMaxRequestTimeout, or
+// the maximum alone when the header is absent. Zero means no timeout.
+func (r *Router) requestTimeout(req *http.Request) (time.Duration, *types.RouterError) {
+	header := req.Header.Get(RequestTimeoutHeader)
+	if header == "" {
+		return r.maxRequestTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(header)
+	if err != nil || timeout <= 0 {
+		return 0, types.NewRouterError(http.StatusBadRequest,
+			types.InvalidRequestError(fmt.Sprintf("invalid %s header %q: expected a positive duration such as 30s", RequestTimeoutHeader, header)), err)
+	}
+	if r.maxRequestTimeout > 0 && timeout > r.maxRequestTimeout {
+		timeout = r.maxRequestTimeout
+	}
+	return timeout, nil
+}
+
+// withRequestTimeout applies the request's timeout to its context. The
+// returned cancel function must be called when the request is done.
+func (r *Router) withRequestTimeout(req *http.Request) (*http.Request, context.CancelFunc, *types.RouterError) {
+	timeout, rerr := r.requestTimeout(req)
+	if rerr != nil || timeout == 0 {
+		return req, func() {}, rerr
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), cancel, nil
+}
@@ -0,0 +1,96 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// deadlineBackend reports the deadline of each chat request it receives and
+// blocks until the request's context is done if block is set.
+func deadlineBackend(deadlines chan<- time.Duration, block bool) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		var remaining time.Duration
+		if d, ok := ctx.Deadline(); ok {
+			remaining = time.Until(d)
+		}
+		deadlines <- remaining
+		if block {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &types.ChatCompletionResponse{ID: "chatcmpl-1"}, nil
+	}
+	return b
+}
+
+func TestRequestTimeout_AppliedAndCapped(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	r, _ := NewRouter(WithMaxRequestTimeout(time.Minute))
+	r.AddBackend(context.Background(), deadlineBackend(deadlines, false))
+
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"30s", 25 * time.Second, 30 * time.Second},
+		{"1h", 55 * time.Second, time.Minute}, // capped
+		{"", 55 * time.Second, time.Minute},   // the maximum is the default
+	}
+	for _, tt := range tests {
+		if rec := postChat(t, r, testChatBody, withHeader(RequestTimeoutHeader, tt.header)); rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d", tt.header, rec.Code)
+		}
+		if d := <-deadlines; d < tt.min || d > tt.max {
+			t.Errorf("%q: deadline in %v, want between %v and %v", tt.header, d, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRequestTimeout_NoMaximum(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), deadlineBackend(deadlines, false))
+
+	postChat(t, r, testChatBody, withHeader(RequestTimeoutHeader, ""))
+	if d := <-deadlines; d != 0 {
+		t.Errorf("expected no deadline without the header, got %v", d)
+	}
+	postChat(t, r, testChatBody, withHeader(RequestTimeoutHeader, "2h"))
+	if d := <-deadlines; d < time.Hour {
+		t.Errorf("expected the client's 2h deadline, got %v", d)
+	}
+}
+
+func TestRequestTimeout_Invalid(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), deadlineBackend(deadlines, false))
+
+	for _, header := range []string{"soon", "30", "-5s", "0s"} {
+		rec := postChat(t, r, testChatBody, withHeader(RequestTimeoutHeader, header))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), RequestTimeoutHeader) {
+			t.Errorf("%q: expected 400, got %d: %s", header, rec.Code, rec.Body.String())
+		}
+	}
+	if len(deadlines) != 0 {
+		t.Error("invalid timeouts should not reach the backend")
+	}
+}
+
+func TestRequestTimeout_Expires(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), deadlineBackend(deadlines, true))
+
+	rec := postChat(t, r, testChatBody, withHeader(RequestTimeoutHeader, "20ms"))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d: %s", rec.Code, rec.Body.String())
+	}
+	<-deadlines
+}
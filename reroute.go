@@ -0,0 +1,48 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// errStreamErrorChunk is recorded against a backend whose stream opened with
+// an error event.
+var errStreamErrorChunk = errors.New("stream began with an error event")
+
+// isErrorChunk reports whether an SSE data payload is an error object such as
+// {"error":{"message":"..."}}, which some backends send with a 200 status
+// instead of failing the request.
+func isErrorChunk(data string) bool {
+	if !strings.HasPrefix(strings.TrimSpace(data), "{") || !strings.Contains(data, `"error"`) {
+		return false
+	}
+	var chunk struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return false
+	}
+	return len(chunk.Error) > 0 && string(chunk.Error) != "null"
+}
+
+// rerouteBackend returns a healthy backend for model that hasn't been tried
+// yet, can perform op, and isn't rate limited, marking it tried, or nil if
+// none is left. If typePinned, only backends of current's type qualify.
+func (r *Router) rerouteBackend(model string, op Operation, current Backend, typePinned bool, tried map[string]bool) Backend {
+	for _, b := range r.registry.HealthyBackendsForModelOp(model, op) {
+		if tried[b.ID()] || (typePinned && b.Type() != current.Type()) || r.registry.rateLimited(b.ID()) {
+			continue
+		}
+		tried[b.ID()] = true
+		return b
+	}
+	return nil
+}
+
+// drainEvents discards the rest of an abandoned stream so its producer can
+// finish.
+func drainEvents(events <-chan StreamEvent) {
+	for range events {
+	}
+}
@@ -0,0 +1,102 @@
+package oairouter
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+const errorChunk = `{"error":{"message":"model is overloaded","type":"server_error"}}`
+
+// firstCallFailsBackend streams an error chunk on the first call across all
+// backends sharing calls, and its own ID as content otherwise.
+func firstCallFailsBackend(id string, calls *atomic.Int64) *mockBackend {
+	b := newMockBackend(id, true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		if calls.Add(1) == 1 {
+			return streamOf(errorChunk), nil
+		}
+		return streamOf(`{"choices":[{"delta":{"content":"` + id + `"}}]}`), nil
+	}
+	return b
+}
+
+const streamChatBody = `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+
+func TestStream_ReroutesErrorFirstChunk(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), firstCallFailsBackend("backend-a", &calls))
+	r.AddBackend(context.Background(), firstCallFailsBackend("backend-b", &calls))
+
+	rec := postChat(t, r, streamChatBody)
+	body := rec.Body.String()
+
+	if calls.Load() != 2 {
+		t.Fatalf("stream calls = %d, want 2", calls.Load())
+	}
+	if strings.Contains(body, "overloaded") {
+		t.Errorf("error chunk was forwarded to the client:\n%s", body)
+	}
+	if !strings.Contains(body, "backend-") || !strings.Contains(body, "data: [DONE]") {
+		t.Errorf("expected rerouted content and [DONE], got:\n%s", body)
+	}
+}
+
+func TestStream_ErrorFirstChunkWithoutAlternative(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), firstCallFailsBackend("backend-a", &calls))
+
+	rec := postChat(t, r, streamChatBody)
+
+	if calls.Load() != 1 {
+		t.Errorf("stream calls = %d, want 1", calls.Load())
+	}
+	if !strings.Contains(rec.Body.String(), "data: "+errorChunk) {
+		t.Errorf("expected the error chunk to reach the client, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestStream_LaterErrorChunkNotRerouted(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter()
+	for _, id := range []string{"backend-a", "backend-b"} {
+		b := newMockBackend(id, true)
+		b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+			calls.Add(1)
+			return streamOf(`{"choices":[{"delta":{"content":"partial"}}]}`, errorChunk), nil
+		}
+		r.AddBackend(context.Background(), b)
+	}
+
+	rec := postChat(t, r, streamChatBody)
+
+	if calls.Load() != 1 {
+		t.Errorf("stream calls = %d, want 1", calls.Load())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "partial") || !strings.Contains(body, "overloaded") {
+		t.Errorf("expected content followed by the error chunk, got:\n%s", body)
+	}
+}
+
+func TestIsErrorChunk(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{errorChunk, true},
+		{`{"error":"boom"}`, true},
+		{`{"error":null,"choices":[]}`, false},
+		{`{"choices":[{"delta":{"content":"\"error\""}}]}`, false},
+		{`[DONE]`, false},
+	}
+	for _, tt := range tests {
+		if got := isErrorChunk(tt.data); got != tt.want {
+			t.Errorf("isErrorChunk(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package oairouter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/jsonschema"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// objectSchema is the schema a json_object response must match.
+var objectSchema, _ = jsonschema.Compile(map[string]any{"type": "object"})
+
+// chatResponseFormatCheck returns a check that the response to req honors its
+// response_format, or nil if there is nothing to check. An unusable
+// json_schema rejects the request before it reaches a backend.
+func chatResponseFormatCheck(r *Router, req *types.ChatCompletionRequest) (func(*types.ChatCompletionResponse) error, *types.RouterError) {
+	if !r.responseFormatCheck || req.ResponseFormat == nil {
+		return nil, nil
+	}
+
+	var schema *jsonschema.Schema
+	switch req.ResponseFormat.Type {
+	case "json_object":
+		schema = objectSchema
+	case "json_schema":
+		spec, _ := req.ResponseFormat.JSONSchema.(map[string]any)
+		raw, ok := spec["schema"]
+		if !ok {
+			return nil, nil
+		}
+		var err error
+		schema, err = jsonschema.Compile(raw)
+		if err != nil {
+			return nil, types.NewRouterError(http.StatusBadRequest,
+				types.InvalidParamError(err.Error(), "response_format.json_schema.schema"), err)
+		}
+	default:
+		return nil, nil
+	}
+
+	return func(resp *types.ChatCompletionResponse) error {
+		return checkChatResponseFormat(resp, req.ResponseFormat.Type, schema)
+	}, nil
+}
+
+// checkChatResponseFormat validates the content of each choice that didn't
+// end in tool calls against schema.
+func checkChatResponseFormat(resp *types.ChatCompletionResponse, formatType string, schema *jsonschema.Schema) error {
+	for i, choice := range resp.Choices {
+		if len(choice.Message.ToolCalls) > 0 || choice.Message.FunctionCall != nil {
+			continue
+		}
+		content, ok := choice.Message.Content.(string)
+		if !ok {
+			return responseFormatError(fmt.Sprintf("choices[%d].message.content is not a string", i), formatType)
+		}
+		if err := schema.ValidateJSON([]byte(content)); err != nil {
+			return responseFormatError(fmt.Sprintf("choices[%d].message.content: %v", i, err), formatType)
+		}
+	}
+	return nil
+}
+
+// responseFormatError is returned to the client when a backend's response
+// doesn't match the requested format.
+func responseFormatError(detail, formatType string) *types.RouterError {
+	code := "response_format_mismatch"
+	msg := fmt.Sprintf("backend response does not match the requested %s response_format: %s", formatType, detail)
+	return types.NewRouterError(http.StatusBadGateway, types.NewAPIError(msg, types.ErrorTypeServer, &code), nil)
+}
@@ -0,0 +1,141 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+const schemaRequest = `{"model":"test-model","messages":[{"role":"user","content":"hi"}],
+	"response_format":{"type":"json_schema","json_schema":{"name":"person","schema":{
+		"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}}}}`
+
+// contentBackend answers chat requests with the given contents in turn,
+// repeating the last one.
+func contentBackend(calls *atomic.Int64, contents ...string) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		i := min(int(calls.Add(1))-1, len(contents)-1)
+		return &types.ChatCompletionResponse{Choices: []types.Choice{
+			{Message: types.ChatMessage{Role: "assistant", Content: contents[i]}},
+		}}, nil
+	}
+	return b
+}
+
+func TestResponseFormatValidation_Mismatch(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithResponseFormatValidation())
+	r.AddBackend(context.Background(), contentBackend(&calls, `{"name":"Ada","age":"thirty-six"}`))
+
+	rec := postChat(t, r, schemaRequest)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "response_format_mismatch") || !strings.Contains(body, "choices[0].message.content: $.age: expected integer") {
+		t.Errorf("error should name the failing path, got %s", body)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no retry, got %d calls", calls.Load())
+	}
+}
+
+func TestResponseFormatValidation_Valid(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithResponseFormatValidation())
+	r.AddBackend(context.Background(), contentBackend(&calls, `{"name":"Ada","age":36}`))
+
+	if rec := postChat(t, r, schemaRequest); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResponseFormatValidation_RetryOnce(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithResponseFormatValidation(), WithResponseFormatRetry(true))
+	r.AddBackend(context.Background(), contentBackend(&calls, "Sure! Here is a person: Ada, 36.", `{"name":"Ada","age":36}`))
+
+	rec := postChat(t, r, schemaRequest)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `\"age\":36`) {
+		t.Fatalf("expected the retried response, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", calls.Load())
+	}
+
+	// A second mismatch fails the request
+	calls.Store(0)
+	r2, _ := NewRouter(WithResponseFormatValidation(), WithResponseFormatRetry(true))
+	r2.AddBackend(context.Background(), contentBackend(&calls, "prose"))
+	if rec := postChat(t, r2, schemaRequest); rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "invalid JSON") {
+		t.Errorf("expected 502 after the retry also failed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected exactly one retry, got %d calls", calls.Load())
+	}
+}
+
+func TestResponseFormatValidation_RetryOnAnotherBackend(t *testing.T) {
+	var callsA, callsB atomic.Int64
+	a := contentBackend(&callsA, "prose")
+	b := contentBackend(&callsB, `{"name":"Ada","age":36}`)
+	b.id, b.maxTokens = "backend-b", 10
+	b.caps = []Capability{CapabilityChat}
+	var sentB *types.ChatCompletionRequest
+	chatB := b.chatFn
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		sentB = req
+		return chatB(ctx, req)
+	}
+	r := newTestRouter(t, []Backend{a, b}, WithResponseFormatValidation(), WithResponseFormatRetry(true))
+
+	body := strings.Replace(schemaRequest, `"model":"test-model"`, `"model":"test-model","max_tokens":100`, 1)
+	rec := postChat(t, r, body)
+	if rec.Code != http.StatusOK || callsA.Load() != 1 || callsB.Load() != 1 {
+		t.Fatalf("got %d after %d, %d calls, want backend-b's response: %s", rec.Code, callsA.Load(), callsB.Load(), rec.Body.String())
+	}
+
+	// The retry is prepared for backend-b, and the mismatch counts against
+	// backend-a
+	if sentB == nil || sentB.MaxTokens == nil || *sentB.MaxTokens != 10 || rec.Header().Get(MaxTokensClampedHeader) != "10" {
+		t.Errorf("retry sent %+v with %s = %q, want max_tokens clamped to 10", sentB, MaxTokensClampedHeader, rec.Header().Get(MaxTokensClampedHeader))
+	}
+	for _, bs := range r.Stats().Backends {
+		if want := int64(map[string]int{"backend-a": 1}[bs.ID]); bs.Errors != want {
+			t.Errorf("%s errors = %d, want %d", bs.ID, bs.Errors, want)
+		}
+	}
+}
+
+func TestResponseFormatValidation_JSONObjectAndBadSchema(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter(WithResponseFormatValidation())
+	r.AddBackend(context.Background(), contentBackend(&calls, `["not","an","object"]`))
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("json_object: expected 502, got %d", rec.Code)
+	}
+
+	calls.Store(0)
+	rec = postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],
+		"response_format":{"type":"json_schema","json_schema":{"name":"x","schema":{"type":"string","pattern":"("}}}}`)
+	if rec.Code != http.StatusBadRequest || calls.Load() != 0 {
+		t.Errorf("bad schema: expected 400 without a backend call, got %d after %d calls", rec.Code, calls.Load())
+	}
+}
+
+func TestResponseFormatValidation_Disabled(t *testing.T) {
+	var calls atomic.Int64
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), contentBackend(&calls, "prose"))
+
+	if rec := postChat(t, r, schemaRequest); rec.Code != http.StatusOK {
+		t.Errorf("expected the response to pass through unchecked, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,233 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// Routing strategies, as reported by POST /admin/route.
+const (
+	strategyOverride       = "override"        // X-Backend-ID named the backend
+	strategyTypeQualified  = "type_qualified"  // A "model@type" ID picked the backend type
+	strategyModelPrefix    = "model_prefix"    // WithModelPrefixRoute picked the backend tag
+	strategyCanary         = "canary"          // The model's canary weights
+	strategySession        = "session"         // Session affinity
+	strategyPolicy         = "routing_policy"  // WithRoutingPolicy
+	strategyHealthScore    = "health_score"    // WithHealthScoring
+	strategyFastest        = "fastest"         // WithFastestRouting
+	strategyFirstAvailable = "first_available" // The first healthy backend of the preferred tier
+	strategyDefaultBackend = "default_backend" // No backend serves the model; WithDefaultBackend
+)
+
+// routeDecision is the backend selected for a request and how.
+type routeDecision struct {
+	backend  Backend
+	model    string    // Model to send, after any fallback, without a type qualifier
+	op       Operation // Operation backends were filtered by
+	strategy string
+
+	fallback      string // The WithModelFallback fallback served instead of the requested model
+	sessionBroken bool   // The session's backend was unhealthy, so another was used
+
+	// requested is the model as the client named it, when routing sends
+	// another that is authorized as requested: a stripped model prefix or a
+	// canary target naming a different model
+	requested string
+}
+
+// strategyReasons explains each routing strategy in POST /admin/route.
+var strategyReasons = map[string]string{
+	strategyOverride:       "the X-Backend-ID header names the backend",
+	strategyTypeQualified:  "the model ID names a backend type",
+	strategyModelPrefix:    "the model prefix routes to tagged backends",
+	strategyCanary:         "picked by the model's canary weights",
+	strategySession:        "session affinity for the session header",
+	strategyPolicy:         "picked by the routing policy",
+	strategyHealthScore:    "picked by health score",
+	strategyFastest:        "lowest average latency",
+	strategyFirstAvailable: "first healthy backend of the preferred tier",
+	strategyDefaultBackend: "no healthy backend serves the model; using the default backend",
+}
+
+type dryRunKey struct{}
+
+// isDryRun reports whether ctx belongs to a routing dry run, which must not
+// change any routing state it can avoid changing.
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// route selects the backend for a request for model that needs op. It
+// returns a 404 error if no backend serves the model.
+func (r *Router) route(req *http.Request, model string, op Operation) (routeDecision, *types.RouterError) {
+	d := routeDecision{model: model}
+
+	// Serve a fallback model if none of the model's backends is healthy
+	if fallback, ok := r.fallbackModel(req, model, op); ok {
+		d.model, d.fallback = fallback, fallback
+	}
+
+	// Namespaced models go to the backends tagged for their prefix
+	prefixRoute, prefixed := r.matchModelPrefix(d.model)
+	if prefixed && prefixRoute.strip {
+		d.requested = d.model
+		d.model = strings.TrimPrefix(d.model, prefixRoute.prefix)
+	}
+
+	// Look up only backends that can perform the operation. If none serving
+	// the model can, route as usual so the capability check names what's
+	// unsupported instead of reporting the model missing.
+	d.op = op
+	if !r.registry.servesOp(d.model, op) {
+		d.op = OperationAny
+	}
+
+	var ok bool
+	if pinned, rerr, pin := r.overrideBackend(req, d.model); pin {
+		// X-Backend-ID skips routing and sticks to that backend
+		if rerr != nil {
+			return d, rerr
+		}
+		d.backend, d.strategy = pinned, strategyOverride
+		return d, nil
+	} else if prefixed {
+		tagged, tok := r.registry.LookupByModelTaggedForOp(d.model, prefixRoute.tag, d.op)
+		if !tok {
+			return d, types.NewRouterError(http.StatusNotFound,
+				types.NotFoundError("model not found: no backend tagged "+prefixRoute.tag+" serves "+d.model), nil)
+		}
+		d.backend, d.strategy = tagged, strategyModelPrefix
+		return d, nil
+	} else if qualified, modelID, qok := r.lookupQualifiedModel(d.model); qok {
+		// Route "model@type" to that backend type, which only knows the bare ID
+		d.backend, d.model, d.strategy = qualified, modelID, strategyTypeQualified
+		return d, nil
+	} else if canary, canaryModel, cok := r.lookupCanary(d.model, d.op); cok {
+		// Split the model's traffic by its canary weights
+		if canaryModel != d.model {
+			d.requested, d.model = d.model, canaryModel
+		}
+		d.backend, d.strategy = canary, strategyCanary
+		return d, nil
+	} else if sessionID := req.Header.Get(r.sessionHeader); r.sessionAffinity && sessionID != "" {
+		// Keep a session on its backend; requests without one are routed
+		// as usual
+		var result LookupResult
+		if r.sessionStore != nil {
+			result, ok = r.lookupPinnedSession(req.Context(), d.model, sessionID, d.op)
+		} else {
+			result, ok = r.registry.LookupByModelWithSessionForOp(d.model, sessionID, d.op)
+		}
+		d.backend, d.sessionBroken, d.strategy = result.Backend, result.SessionBroken, strategySession
+	} else {
+		// Use default lookup
+		switch {
+		case r.routingPolicy != nil:
+			d.backend, ok = r.lookupByPolicy(d.model, d.op, isDryRun(req.Context()))
+			d.strategy = strategyPolicy
+		case r.healthScoring:
+			d.backend, ok = r.registry.LookupByModelWeightedForOp(d.model, d.op)
+			d.strategy = strategyHealthScore
+		case r.fastestRouting:
+			d.backend, ok = r.registry.LookupByModelFastestForOp(d.model, d.op)
+			d.strategy = strategyFastest
+		default:
+			d.backend, ok = r.registry.LookupByModelForOp(d.model, d.op)
+			d.strategy = strategyFirstAvailable
+		}
+	}
+
+	if !ok {
+		d.strategy = strategyDefaultBackend
+		if r.defaultBackend != "" {
+			d.backend, ok = r.registry.LookupByID(r.defaultBackend)
+		}
+		if !ok {
+			return d, types.NewRouterError(http.StatusNotFound, types.NotFoundError("model not found: "+d.model), nil)
+		}
+	}
+	return d, nil
+}
+
+// routeCandidate is a backend serving the model of a POST /admin/route
+// request.
+type routeCandidate struct {
+	BackendInfo
+	CanServe    bool    `json:"can_serve"` // Whether the backend can perform the operation
+	HealthScore float64 `json:"health_score"`
+}
+
+// routeResponse is the body of a POST /admin/route response.
+type routeResponse struct {
+	Model         string           `json:"model"`        // As requested
+	RoutedModel   string           `json:"routed_model"` // Sent to the backend
+	Fallback      string           `json:"fallback,omitempty"`
+	Operation     Operation        `json:"operation"`
+	BackendID     string           `json:"backend_id"`
+	Strategy      string           `json:"strategy"`
+	Reason        string           `json:"reason"`
+	SessionBroken bool             `json:"session_broken,omitempty"`
+	Candidates    []routeCandidate `json:"candidates"`
+}
+
+// handleAdminRoute handles POST /admin/route?model=..., reporting which
+// backend a request for the model would be sent to, and why, without
+// sending one. The operation query parameter (default chat) selects the
+// kind of request, and X-Backend-ID and session headers are honored as for
+// real requests. Routing policies implementing RoutingPolicyPeeker are
+// peeked at rather than advanced. Random choices, such as canary weights,
+// may differ from the next real request's.
+func (r *Router) handleAdminRoute(w http.ResponseWriter, req *http.Request) {
+	model := req.URL.Query().Get("model")
+	if model == "" {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidParamError("model is required", "model"))
+		return
+	}
+	op := Operation(req.URL.Query().Get("operation"))
+	switch op {
+	case "":
+		op = OperationChat
+	case OperationChat, OperationCompletions, OperationEmbeddings, OperationImageGeneration:
+	default:
+		types.WriteError(w, http.StatusBadRequest, types.InvalidParamError("operation must be chat, completions, embeddings, or image_generation", "operation"))
+		return
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), dryRunKey{}, true))
+	d, rerr := r.route(req, model, op)
+	if rerr != nil {
+		types.WriteError(w, rerr.StatusCode, rerr.APIError)
+		return
+	}
+
+	resp := routeResponse{
+		Model:         model,
+		RoutedModel:   d.model,
+		Fallback:      d.fallback,
+		Operation:     op,
+		BackendID:     d.backend.ID(),
+		Strategy:      d.strategy,
+		Reason:        strategyReasons[d.strategy],
+		SessionBroken: d.sessionBroken,
+		Candidates:    []routeCandidate{},
+	}
+	for _, info := range r.registry.SnapshotModel(d.model) {
+		b, ok := r.registry.LookupByID(info.ID)
+		if !ok {
+			continue
+		}
+		resp.Candidates = append(resp.Candidates, routeCandidate{
+			BackendInfo: info,
+			CanServe:    op.servedBy(b),
+			HealthScore: r.registry.healthScore(b),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,17 +1,26 @@
 package oairouter
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/stevemurr/oairouter/streaming"
+	"github.com/stevemurr/oairouter/tokenizer"
 	"github.com/stevemurr/oairouter/types"
+	"golang.org/x/sync/singleflight"
 )
 
 // Discoverer finds and monitors LLM backends.
@@ -43,8 +52,94 @@ type Router struct {
 	httpClient          *http.Client
 	logger              *slog.Logger
 	defaultBackend      string
+	defaultModel        string // Model used when a request omits one
 	healthCheckInterval time.Duration
-	sessionAffinity     bool // Enable session affinity via X-Session-ID header
+	healthCheckBackoff  Backoff
+	sessionAffinity     bool   // Enable session affinity via the session header
+	sessionHeader       string // Request header carrying the session ID
+	embeddingsCache     Cache
+	embeddingsCacheTTL  time.Duration
+	fanOutN             bool // Split n>1 chat requests into parallel n=1 requests
+	fanOutNRequireAll   bool // Fail the request if any fanned-out request fails
+	fanOutNLimit        int  // Largest n fanned out
+	logprobsPolicy      LogprobsPolicy
+	backendLogprobs     map[string]LogprobsPolicy // backendID -> policy override
+	localTokenCounting  bool                      // Estimate stream usage when backends omit it
+	adminEnabled        bool                      // Serve the /admin endpoints
+	adminToken          string                    // Bearer token required by /admin, if set
+	statsAuth           bool                      // Require adminToken for the stats endpoint too
+	backendFactory      BackendFactory            // Builds backends added through /admin
+	typeQualifiedModels bool                      // Route "model@type" IDs to that backend type
+	backendOverride     bool                      // Honor the X-Backend-ID header
+	embeddingBatchSize  int                       // Split embeddings inputs into batches of this size
+	healthScoring       bool                      // Weight backend selection by health score
+	fastestRouting      bool                      // Select the backend with the lowest latency average
+	streamCompression   bool                      // Gzip streams for clients that accept it
+	sessionStore        SessionStore              // Pins sessions to backends; nil hashes them
+	toolCallValidation  bool                      // Check streamed tool calls arrive whole
+	requestQueue        *requestQueue             // Holds requests for saturated backends, if set
+	upstreamErrors      bool                      // Forward upstream error responses as is
+	maxRequestBytes     int64                     // Largest request body read, 0 for no limit
+	loadShedding        func() bool               // Reports when to reject requests with 503, if set
+	modelFallbacks      map[string][]string       // model -> models tried when it has no healthy backend
+	batches             *batchRunner              // Runs /v1/batches jobs, if enabled
+	streamUsage         bool                      // Ask backends for usage in every chat stream and log it
+	streamUsageStrip    bool                      // Drop usage chunks from streams whose client didn't ask
+	modelAuthorizer     func(string, string) bool // Reports whether an API key may use a model, if set
+	forwardedHeaders    []string                  // Client request headers passed on to backends
+	modelPrefixes       []modelPrefixRoute        // Model prefixes routed to tagged backends
+	streamAggregation   StreamAggregateFunc       // Receives chat and completion streams put back together
+	warmup              WarmupFunc                // Sent to newly registered backends, if set
+	warmupRequired      bool                      // Treat new backends as unhealthy until warmed up
+	warmupRetry         Backoff                   // Spaces out retries of required warmups
+	warmupMu            sync.Mutex
+	warmupCtx           context.Context // Cancelled by Stop to abandon running warmups
+	warmupCancel        context.CancelFunc
+	warmups             sync.WaitGroup
+	unknownFields       bool                // Pass request members without a typed field on to backends
+	deepHealth          *deepHealthCheck    // Asks backends for a token periodically, if set
+	deepHealthTimeout   time.Duration       // Bounds each deep health check
+	convertEmbeddings   bool                // Return embeddings in the requested encoding_format
+	coalescing          *singleflight.Group // Shares one backend call among identical requests, if set
+	coalescingMu        sync.Mutex
+	coalescingCalls     map[string]*coalescedCall // Contexts of shared calls, by coalescing key
+	serverTiming        bool                      // Set Server-Timing on non-streaming responses
+	seedVerification    bool                      // Check seeded responses carry a system_fingerprint
+	modelReconcile      time.Duration             // How often model mappings are refreshed; 0 disables
+	modelMetadata       map[string]ModelMetadata  // model -> metadata merged into /v1/models
+	routingPolicy       RoutingPolicy             // Selects among a model's healthy backends, if set
+	maxRequestTimeout   time.Duration             // Caps X-Request-Timeout; also the default when set
+	shadow              *shadowTraffic            // Mirrors sampled chat requests, if set
+	shadowTimeout       time.Duration             // Bounds each mirrored request
+	shadowModel         string                    // Model mirrored requests ask for; empty keeps the resolved one
+	shadowConcurrency   int                       // Mirrored requests in flight at once
+	canaries            *canaryRoutes             // Weighted traffic splits per model
+	discoveryCallback   func(DiscoveryEvent)      // Notified of backends discovered or removed
+	discoveryEvents     chan DiscoveryEvent       // Events waiting for discoveryCallback
+	backendTLS          map[string]*tls.Config    // backendID -> TLS settings applied on registration
+	responseFormatCheck bool                      // Check chat responses against their response_format
+	responseFormatRetry bool                      // Retry once when a response fails the check
+	idempotencyCache    Cache                     // Responses stored by Idempotency-Key
+	idempotencyTTL      time.Duration
+	idempotencyInFlight sync.Map      // Idempotency cache keys of requests being served
+	auditSink           AuditSinkFunc // Opens a per-request copy of streamed responses
+	requestLogger       func(RequestLog)
+	accessLog           bool
+	requestLogBodyLimit int // Bytes of each body captured for requestLogger; 0 disables capture
+	logRedactor         func(*types.ChatCompletionRequest)
+	reliabilityHedge    *reliabilityHedge
+	counters            *routerCounters
+	readinessGrace      time.Duration // Report ready this long after Start even without models
+	ready               atomic.Bool
+	startedAt           atomic.Int64 // Unix nanoseconds of the last Start
+
+	gracefulTimeout time.Duration // How long Stop waits for in-flight requests
+	drainMu         sync.RWMutex
+	draining        bool           // Set by Stop; new requests get a 503
+	active          sync.WaitGroup // In-flight requests, when gracefulTimeout is set
+
+	middleware []func(http.Handler) http.Handler // From WithMiddleware, outermost first
+	handler    http.Handler                      // mux wrapped in middleware
 
 	mux     *http.ServeMux
 	cancel  context.CancelFunc
@@ -59,29 +154,73 @@ func NewRouter(opts ...Option) (*Router, error) {
 		httpClient:          &http.Client{Timeout: 5 * time.Minute},
 		logger:              slog.Default(),
 		healthCheckInterval: 30 * time.Second,
+		healthCheckBackoff:  DefaultHealthCheckBackoff,
+		sessionHeader:       SessionHeader,
+		counters:            newRouterCounters(),
+		logRedactor:         RedactChatRequest,
+		shadowTimeout:       DefaultShadowTimeout,
+		shadowConcurrency:   DefaultShadowConcurrency,
+		canaries:            newCanaryRoutes(),
+		warmupRetry:         DefaultWarmupBackoff,
+		deepHealthTimeout:   DefaultDeepHealthCheckTimeout,
+		forwardedHeaders:    DefaultForwardedHeaders,
+		fanOutNLimit:        DefaultFanOutNLimit,
 		mux:                 http.NewServeMux(),
 	}
+	r.registry.notify = r.handleRegistryEvent
+	r.counters.registered = r.registry.registered
 
 	for _, opt := range opts {
 		if err := opt(r); err != nil {
 			return nil, err
 		}
 	}
+	if r.requestQueue != nil {
+		r.registry.released = r.requestQueue.wake
+	}
+	if r.shadow != nil {
+		r.shadow.slots = make(chan struct{}, r.shadowConcurrency)
+	}
 
 	// Register routes
 	r.mux.HandleFunc("POST /v1/chat/completions", r.handleChatCompletions)
 	r.mux.HandleFunc("POST /v1/completions", r.handleCompletions)
 	r.mux.HandleFunc("POST /v1/embeddings", r.handleEmbeddings)
+	r.mux.HandleFunc("POST /v1/images/generations", r.handleImageGenerations)
 	r.mux.HandleFunc("GET /v1/models", r.handleListModels)
 	r.mux.HandleFunc("GET /v1/models/{model...}", r.handleGetModel)
 	r.mux.HandleFunc("GET /health", r.handleHealth)
+	r.mux.HandleFunc("GET /ready", r.handleReady)
+	if r.statsAuth {
+		r.mux.HandleFunc("GET /v1/router/stats", r.requireAdmin(r.handleStats))
+	} else {
+		r.mux.HandleFunc("GET /v1/router/stats", r.handleStats)
+	}
+	if r.batches != nil {
+		r.mux.HandleFunc("POST /v1/batches", r.handleCreateBatch)
+		r.mux.HandleFunc("GET /v1/batches/{id}", r.handleGetBatch)
+		r.mux.HandleFunc("POST /v1/batches/{id}/cancel", r.handleCancelBatch)
+	}
+	if r.adminEnabled {
+		r.registerAdminRoutes()
+	}
+
+	r.handler = r.mux
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		r.handler = r.middleware[i](r.handler)
+	}
 
 	return r, nil
 }
 
 // ServeHTTP implements http.Handler.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	r.mux.ServeHTTP(w, req)
+	r.limitRequestBody(w, req)
+	if r.gracefulTimeout > 0 {
+		r.serveTracked(w, req)
+		return
+	}
+	r.handler.ServeHTTP(w, req)
 }
 
 // Start begins discovery and health monitoring.
@@ -91,6 +230,19 @@ func (r *Router) Start(ctx context.Context) error {
 	}
 
 	ctx, r.cancel = context.WithCancel(ctx)
+	r.startedAt.Store(time.Now().UnixNano())
+
+	r.drainMu.Lock()
+	r.draining = false
+	r.drainMu.Unlock()
+	if r.batches != nil {
+		r.batches.resume()
+	}
+
+	if r.discoveryCallback != nil {
+		r.wg.Add(1)
+		go r.runDiscoveryCallback(ctx)
+	}
 
 	// Run initial discovery
 	for _, d := range r.discoverers {
@@ -101,10 +253,13 @@ func (r *Router) Start(ctx context.Context) error {
 		}
 
 		for _, b := range backends {
+			r.configureBackend(b)
 			if err := r.registry.Register(ctx, b); err != nil {
 				r.logger.Warn("failed to register backend", "backend", b.ID(), "error", err)
 			} else {
 				r.logger.Info("registered backend", "id", b.ID(), "type", b.Type(), "url", b.BaseURL())
+				r.notifyDiscovery(DiscoveryEvent{Type: EventAdded, Backend: b})
+				r.startWarmup(b)
 			}
 		}
 
@@ -122,29 +277,55 @@ func (r *Router) Start(ctx context.Context) error {
 	// Start health check loop
 	r.wg.Add(1)
 	go r.healthCheckLoop(ctx)
+	if r.deepHealth != nil {
+		r.wg.Add(1)
+		go r.deepHealthCheckLoop(ctx)
+	}
+	if r.modelReconcile > 0 {
+		r.wg.Add(1)
+		go r.modelReconcileLoop(ctx)
+	}
 
+	r.updateReadiness()
 	return nil
 }
 
-// Stop gracefully shuts down the router.
+// Stop gracefully shuts down the router. With WithGracefulShutdown, new
+// requests are rejected with a 503 and in-flight requests, including
+// streams, are given until the timeout to finish first; ErrShutdownTimeout is
+// returned if any were still running, after the rest of shutdown completes.
+// If ctx is done first, its error is returned instead. Running batches are
+// cancelled.
 func (r *Router) Stop(ctx context.Context) error {
-	if !r.started.CompareAndSwap(true, false) {
-		return nil
+	var drainErr error
+	if r.gracefulTimeout > 0 {
+		drainErr = r.drainRequests(ctx)
 	}
 
-	if r.cancel != nil {
-		r.cancel()
+	if r.batches != nil {
+		r.batches.stop()
+	}
+	r.stopWarmups()
+	if r.started.CompareAndSwap(true, false) {
+		if r.cancel != nil {
+			r.cancel()
+		}
+		r.registry.Close()
 	}
 
 	done := make(chan struct{})
 	go func() {
 		r.wg.Wait()
+		r.warmups.Wait()
+		if r.batches != nil {
+			r.batches.wg.Wait()
+		}
 		close(done)
 	}()
 
 	select {
 	case <-done:
-		return nil
+		return drainErr
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -157,12 +338,67 @@ func (r *Router) Backends() *BackendRegistry {
 
 // AddBackend manually registers a backend.
 func (r *Router) AddBackend(ctx context.Context, b Backend) error {
-	return r.registry.Register(ctx, b)
+	r.configureBackend(b)
+	if err := r.registry.Register(ctx, b); err != nil {
+		return err
+	}
+	r.startWarmup(b)
+	return nil
 }
 
 // RemoveBackend manually unregisters a backend.
 func (r *Router) RemoveBackend(id string) {
 	r.registry.Unregister(id)
+	r.counters.forgetBackend(id)
+}
+
+// ReplaceBackends atomically swaps the router's backends for a new set, e.g.
+// when reloading configuration. The new backends are health-checked and
+// indexed before the swap, so requests keep being served throughout. Backends
+// that are no longer present are removed immediately for new requests, and
+// ReplaceBackends waits for their in-flight requests to finish or for ctx to
+// be done.
+func (r *Router) ReplaceBackends(ctx context.Context, backends []Backend) error {
+	// Only backends that are new or swapped for a different instance are
+	// warmed up; ones carried over unchanged are already serving.
+	var changed []Backend
+	for _, b := range backends {
+		if existing, ok := r.registry.LookupByID(b.ID()); !ok || existing != b {
+			changed = append(changed, b)
+		}
+	}
+
+	for _, b := range changed {
+		r.configureBackend(b)
+	}
+
+	var wg sync.WaitGroup
+	for _, b := range backends {
+		wg.Add(1)
+		go func(b Backend) {
+			defer wg.Done()
+			if err := b.HealthCheck(ctx); err != nil {
+				r.logger.Warn("health check failed during reload", "backend", b.ID(), "error", err)
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	removed := r.registry.Replace(ctx, backends)
+	for _, b := range removed {
+		r.counters.forgetBackend(b.ID())
+	}
+	r.logger.Info("replaced backends", "backends", len(backends), "removed", len(removed))
+	for _, b := range changed {
+		r.startWarmup(b)
+	}
+
+	for _, b := range removed {
+		if err := r.registry.WaitIdle(ctx, b.ID()); err != nil {
+			return fmt.Errorf("draining backend %s: %w", b.ID(), err)
+		}
+	}
+	return nil
 }
 
 func (r *Router) watchEvents(ctx context.Context, name string, events <-chan DiscoveryEvent) {
@@ -179,39 +415,50 @@ func (r *Router) watchEvents(ctx context.Context, name string, events <-chan Dis
 
 			switch event.Type {
 			case EventAdded:
+				r.configureBackend(event.Backend)
 				if err := r.registry.Register(ctx, event.Backend); err != nil {
 					r.logger.Warn("failed to register backend", "backend", event.Backend.ID(), "error", err)
-				} else {
-					r.logger.Info("backend added", "id", event.Backend.ID(), "discoverer", name)
+					continue
 				}
+				r.logger.Info("backend added", "id", event.Backend.ID(), "discoverer", name)
+				r.startWarmup(event.Backend)
 			case EventRemoved:
-				r.registry.Unregister(event.Backend.ID())
+				r.RemoveBackend(event.Backend.ID())
 				r.logger.Info("backend removed", "id", event.Backend.ID(), "discoverer", name)
 			case EventUpdated:
 				if err := r.registry.RefreshModels(ctx, event.Backend.ID()); err != nil {
 					r.logger.Warn("failed to refresh models", "backend", event.Backend.ID(), "error", err)
 				}
+			default:
+				continue
 			}
+			r.notifyDiscovery(event)
 		}
 	}
 }
 
+// handleRegistryEvent is called by the registry when a background model
+// retry indexes a backend's models.
+func (r *Router) handleRegistryEvent(event DiscoveryEvent) {
+	r.logger.Info("backend models available", "id", event.Backend.ID(), "event", event.Type)
+	r.updateReadiness()
+}
+
 func (r *Router) healthCheckLoop(ctx context.Context) {
 	defer r.wg.Done()
 
 	ticker := time.NewTicker(r.healthCheckInterval)
 	defer ticker.Stop()
 
+	state := newHealthCheckState()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			for _, b := range r.registry.AllBackends() {
-				if err := b.HealthCheck(ctx); err != nil {
-					r.logger.Debug("health check failed", "backend", b.ID(), "error", err)
-				}
-			}
+		case now := <-ticker.C:
+			r.runHealthChecks(ctx, state, now)
+			r.updateReadiness()
 		}
 	}
 }
@@ -219,155 +466,911 @@ func (r *Router) healthCheckLoop(ctx context.Context) {
 // handlerConfig defines the operations for handling a specific API request type.
 type handlerConfig[Req any, Resp any] struct {
 	getModel     func(*Req) string
+	setModel     func(*Req, string)
+	validate     func(*Req) error
 	execute      func(Backend, context.Context, *Req) (*Resp, error)
 	stream       func(Backend, context.Context, *Req) (<-chan StreamEvent, error)
 	isStreaming  func(*Req) bool
 	errorContext string
+
+	// operation restricts backend lookup to backends that can perform it
+	operation Operation
+
+	// requires lists the capabilities a backend needs to serve the request
+	requires func(*Req) []Capability
+
+	// prepare adjusts or rejects the request for the selected backend before dispatch
+	prepare func(*Router, Backend, *Req) *types.RouterError
+
+	// fanOut optionally serves the request itself; it reports false if it didn't
+	fanOut func(*Router, context.Context, Backend, *Req) (*Resp, bool, error)
+
+	// cache returns the response cache for this endpoint, if any
+	cache func(*Router) (Cache, time.Duration)
+
+	// promptTokens estimates the prompt size for local usage counting
+	promptTokens func(*Req) int
+
+	// redactLog returns the request body to include in request logs when a
+	// log redactor is set
+	redactLog func(*Router, *Req) []byte
+
+	// redactStream returns the audit record for a streamed event when a log
+	// redactor is set, or "" to leave the event out
+	redactStream func(*Router, string) string
+
+	// logAttrs returns attributes describing the request, but not its
+	// content, for failure logs
+	logAttrs func(*Req) []any
+
+	// usage returns the token usage reported in a response, for request logs
+	usage func(*Resp) *types.Usage
+
+	// responseCheck returns a check that a non-streaming response must pass
+	// before it is returned, or nil if the request needs none
+	responseCheck func(*Router, *Req) (func(*Resp) error, *types.RouterError)
+
+	// shadow prepares a copy of the request for the shadow backend; nil means
+	// the endpoint isn't mirrored
+	shadow func(*Req)
+
+	// requestUsage asks the backend to end a stream with a usage chunk,
+	// reporting whether the client had asked for it itself
+	requestUsage func(*Req) bool
+
+	// aggregate parses a streamed chunk as the endpoint's chunk type and
+	// adds it to a StreamAggregate
+	aggregate func(*StreamAggregate, string) error
+
+	// dropExtra discards the request members its type has no field for,
+	// unless WithUnknownFieldPassthrough is set
+	dropExtra func(*Req)
+
+	// finish adjusts a successful non-streaming response before it is
+	// encoded, cached, and returned
+	finish func(*Router, *Req, *Resp)
+
+	// seed and fingerprint return a request's seed and a response's
+	// system_fingerprint, for WithSeedVerification; nil means the endpoint
+	// has neither
+	seed        func(*Req) *int
+	fingerprint func(*Resp) string
+
+	// tokenLimit returns the request's output token limit, for the backend's
+	// MaxTokensCap; nil means the endpoint has none
+	tokenLimit func(*Req) **int
+}
+
+// lookupQualifiedModel resolves a type-qualified model ID when enabled.
+func (r *Router) lookupQualifiedModel(id string) (Backend, string, bool) {
+	if !r.typeQualifiedModels {
+		return nil, "", false
+	}
+	return r.registry.LookupByQualifiedModel(id)
 }
 
 // handleAPIRequest is the generic handler for all API request types.
 func handleAPIRequest[Req any, Resp any](r *Router, w http.ResponseWriter, req *http.Request, cfg handlerConfig[Req, Resp]) {
+	r.counters.requests.Add(1)
+	received := time.Now()
+
+	if r.requestLogger != nil || r.accessLog {
+		var rec *requestRecorder
+		rec, req = r.startRequestLog(w, req)
+		w = rec
+		defer r.finishRequestLog(rec)
+	}
+
+	if r.shedLoad(w) {
+		return
+	}
+	req = r.forwardHeaders(req)
+
+	req, cancelTimeout, rerr := r.withRequestTimeout(req)
+	defer cancelTimeout()
+	if rerr != nil {
+		types.WriteError(w, rerr.StatusCode, rerr.APIError)
+		return
+	}
+
+	var shadowBody *bytes.Buffer
+	if cfg.shadow != nil {
+		shadowBody = r.sampleShadow(req)
+	}
+
 	var apiReq Req
 	if err := json.NewDecoder(req.Body).Decode(&apiReq); err != nil {
-		types.WriteError(w, http.StatusBadRequest, types.InvalidRequestError("invalid request body: "+err.Error()))
+		redactRequestLog(r, req, &apiReq, false, cfg)
+		writeDecodeError(w, err)
 		return
 	}
+	if !r.unknownFields && cfg.dropExtra != nil {
+		cfg.dropExtra(&apiReq)
+	}
+	redactRequestLog(r, req, &apiReq, true, cfg)
 
-	model := cfg.getModel(&apiReq)
+	if r.defaultModel != "" && cfg.getModel(&apiReq) == "" {
+		cfg.setModel(&apiReq, r.defaultModel)
+		r.logger.Debug("applied default model", "model", r.defaultModel, "endpoint", cfg.errorContext)
+	}
 
-	var backend Backend
-	var sessionBroken bool
+	if cfg.validate != nil {
+		if err := cfg.validate(&apiReq); err != nil {
+			var verr *types.ValidationError
+			if errors.As(err, &verr) {
+				types.WriteError(w, http.StatusBadRequest, verr.APIError())
+			} else {
+				types.WriteError(w, http.StatusBadRequest, types.InvalidRequestError(err.Error()))
+			}
+			return
+		}
+	}
 
-	if r.sessionAffinity {
-		// Use session affinity if enabled
-		sessionID := req.Header.Get(SessionHeader)
-		result, ok := r.registry.LookupByModelWithSession(model, sessionID)
-		if ok {
-			backend = result.Backend
-			sessionBroken = result.SessionBroken
+	model := cfg.getModel(&apiReq)
+	streaming := cfg.stream != nil && cfg.isStreaming != nil && cfg.isStreaming(&apiReq)
+	noteRequest(req, func(l *RequestLog) { l.Model, l.Stream = model, streaming })
+
+	var checkResponse func(*Resp) error
+	if cfg.responseCheck != nil && !streaming {
+		check, rerr := cfg.responseCheck(r, &apiReq)
+		if rerr != nil {
+			types.WriteError(w, rerr.StatusCode, rerr.APIError)
+			return
 		}
-		if !ok {
-			if r.defaultBackend != "" {
-				backend, ok = r.registry.LookupByID(r.defaultBackend)
-			}
-			if !ok {
-				types.WriteError(w, http.StatusNotFound, types.NotFoundError("model not found: "+model))
-				return
-			}
+		checkResponse = check
+	}
+
+	// Requests with an idempotency key are hashed as sent, before routing can
+	// change their model.
+	var idempotencyHash string
+	if r.idempotencyCache != nil && !streaming && req.Header.Get(IdempotencyKeyHeader) != "" {
+		idempotencyHash, _ = requestCacheKey(cfg.errorContext, &apiReq)
+	}
+
+	lookupStart := time.Now()
+	d, rerr := r.route(req, model, cfg.operation)
+	lookup := time.Since(lookupStart)
+	if rerr != nil {
+		types.WriteError(w, rerr.StatusCode, rerr.APIError)
+		return
+	}
+	if d.fallback != "" {
+		r.logger.Info("falling back to another model", "model", model, "fallback", d.fallback)
+		noteRequest(req, func(l *RequestLog) { l.Model = d.fallback })
+		w.Header().Set(ModelFallbackHeader, d.fallback)
+	}
+	if d.model != model {
+		model = d.model
+		cfg.setModel(&apiReq, model)
+	}
+	pinned := d.strategy == strategyOverride || d.strategy == strategyModelPrefix
+	if pinned {
+		req = withPinnedBackend(req)
+	}
+	backend, op, sessionBroken := d.backend, d.op, d.sessionBroken
+	typePinned := d.strategy == strategyTypeQualified
+	weighted := d.strategy == strategyCanary
+
+	// model is now the one that will be served, without any type qualifier.
+	// Stripped prefixes are authorized as requested, as they name a namespace,
+	// and so are canary targets, which serve the requested model's traffic.
+	authModel := model
+	if d.requested != "" {
+		authModel = d.requested
+	}
+	if !r.modelAllowed(req, authModel) {
+		types.WriteError(w, http.StatusForbidden, modelForbiddenError(authModel))
+		return
+	}
+
+	// Replay the stored response for a repeated idempotency key, once the
+	// caller is known to be allowed the model
+	var idempotent *idempotentRequest
+	if idempotencyHash != "" {
+		var handled bool
+		if idempotent, handled = r.startIdempotent(w, req, cfg.errorContext, idempotencyHash); handled {
+			return
 		}
-	} else {
-		// Use default lookup
-		var ok bool
-		backend, ok = r.registry.LookupByModel(model)
-		if !ok {
-			if r.defaultBackend != "" {
-				backend, ok = r.registry.LookupByID(r.defaultBackend)
-			}
-			if !ok {
-				types.WriteError(w, http.StatusNotFound, types.NotFoundError("model not found: "+model))
+		defer r.releaseIdempotent(idempotent)
+	}
+
+	// Hedged requests go to the preferred backend type first
+	var hedgeFallback Backend
+	if r.reliabilityHedge != nil && !typePinned && !pinned && !weighted && !streaming {
+		backend, hedgeFallback = r.hedgeBackends(model, op, backend)
+	}
+
+	r.counters.countModel(model)
+	noteRequest(req, func(l *RequestLog) { l.BackendID = backend.ID() })
+
+	// Serve from cache when enabled for this endpoint, before taking a slot
+	// on or preparing for a backend the hit doesn't need
+	var cache Cache
+	var cacheTTL time.Duration
+	var cacheKey string
+	if cfg.cache != nil && !streaming {
+		cache, cacheTTL = cfg.cache(r)
+	}
+	if cache != nil {
+		key, err := requestCacheKey(cfg.errorContext, &apiReq)
+		if err == nil {
+			cacheKey = key
+			data, ok, err := cache.Get(req.Context(), cacheKey)
+			if err != nil {
+				r.logger.Warn("cache get failed", "error", err)
+			} else if ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(data)
 				return
 			}
 		}
 	}
 
-	// Set session broken header if preferred backend was unhealthy
+	release, ok := r.acquireBackend(w, req, backend)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Set session headers if preferred backend was unhealthy
 	if sessionBroken {
 		w.Header().Set(SessionBrokenHeader, "true")
+		w.Header().Set(SessionRebalancedHeader, "true")
+	}
+
+	// Ask for usage so it can be logged, hiding it from clients that didn't
+	stripUsage := false
+	if streaming && r.streamUsage && cfg.requestUsage != nil {
+		stripUsage = !cfg.requestUsage(&apiReq) && r.streamUsageStrip
+	}
+
+	// apiReq stays as the client sent it; each backend tried gets its own copy
+	prepared, clamped, rerr := prepareRequest(r, backend, &apiReq, cfg)
+	if rerr != nil {
+		types.WriteError(w, rerr.StatusCode, rerr.APIError)
+		return
+	}
+	setMaxTokensClamped(w, clamped)
+
+	// Mirror the request once the real one has been served
+	if shadowBody != nil {
+		defer mirrorRequest(r, shadowBody.Bytes(), model, backend, cfg)
 	}
 
 	// Handle streaming if supported and requested
-	if cfg.stream != nil && cfg.isStreaming != nil && cfg.isStreaming(&apiReq) {
-		handleStream(r, w, req, backend, &apiReq, cfg.stream, cfg.errorContext)
+	if streaming {
+		handleStream(r, w, req, backend, &apiReq, prepared, typePinned, stripUsage, cfg)
 		return
 	}
 
-	resp, err := cfg.execute(backend, req.Context(), &apiReq)
+	// serve sends the request to a backend, setting response headers on w. A
+	// response failing checkResponse counts against its backend, and is
+	// retried once with WithResponseFormatRetry.
+	serve := func(w http.ResponseWriter, req *http.Request) (*Resp, Backend, error) {
+		var served Backend
+		var resp *Resp
+		var err error
+		var elapsed time.Duration
+		attempt := func(backend, hedgeFallback Backend, prepared *Req) bool {
+			start := time.Now()
+			resp, served, err = dispatch(r, w, req, backend, hedgeFallback, &apiReq, prepared, typePinned, cfg)
+			elapsed = time.Since(start)
+			invalid := false
+			if err == nil && checkResponse != nil {
+				err = checkResponse(resp)
+				invalid = err != nil
+			}
+			r.recordOutcome(req, served, elapsed, err)
+			return invalid
+		}
+		if attempt(backend, hedgeFallback, prepared) && r.responseFormatRetry {
+			next, nextReq, clamped, rerr := formatRetryTarget(r, req, served, &apiReq, typePinned, cfg)
+			if rerr == nil && next != served {
+				// The retry is skipped, keeping the validation error, if
+				// the other backend has no slot
+				release, qerr := r.acquireSlot(req.Context(), next)
+				if qerr != nil {
+					rerr = backendRouterError(qerr)
+				} else {
+					defer release()
+					noteRequest(req, func(l *RequestLog) { l.BackendID = next.ID() })
+				}
+			}
+			if rerr == nil {
+				r.logger.Warn(cfg.errorContext+" response failed validation, retrying", "backend", served.ID(), "next", next.ID(), "error", r.logError(err))
+				setMaxTokensClamped(w, clamped)
+				attempt(next, nil, nextReq)
+			}
+		}
+		if err != nil {
+			return nil, served, err
+		}
+		if cfg.usage != nil && resp != nil {
+			if usage := cfg.usage(resp); usage != nil {
+				r.counters.recordThroughput(model, usage.CompletionTokens, elapsed)
+			}
+		}
+		if cfg.finish != nil && resp != nil {
+			cfg.finish(r, &apiReq, resp)
+		}
+		return resp, served, nil
+	}
+
+	var resp *Resp
+	var err error
+	backendStart := time.Now()
+	if key, ok := r.coalescingKey(req, cfg.errorContext, &apiReq, backend, pinned || typePinned); ok {
+		resp, backend, err = coalesce(r, w, req, key, backend, serve)
+	} else {
+		resp, backend, err = serve(w, req)
+	}
+	if r.serverTiming {
+		timing := serverTiming(lookup, time.Since(backendStart), time.Since(received), backend.ID())
+		w.Header().Set(ServerTimingHeader, timing)
+	}
 	if err != nil {
-		r.logger.Error(cfg.errorContext+" failed", "backend", backend.ID(), "error", err)
-		types.WriteError(w, http.StatusInternalServerError, types.ServerError("backend error: "+err.Error()))
+		r.logger.Error(cfg.errorContext+" failed", failureLogArgs(r, backend, &apiReq, err, cfg)...)
+		r.writeBackendError(w, err)
 		return
 	}
+	if cfg.usage != nil && resp != nil {
+		if usage := cfg.usage(resp); usage != nil {
+			noteRequest(req, func(l *RequestLog) { l.Usage = usage })
+		}
+	}
+	if cfg.seed != nil && resp != nil && r.echoSeed(w, cfg.seed(&apiReq)) {
+		r.verifySeed(w, backend, model, cfg.fingerprint(resp))
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		types.WriteError(w, http.StatusInternalServerError, types.ServerError("failed to encode response: "+err.Error()))
+		return
+	}
+	data = append(data, '\n')
+
+	if cacheKey != "" {
+		if err := cache.Set(req.Context(), cacheKey, data, cacheTTL); err != nil {
+			r.logger.Warn("cache set failed", "error", err)
+		}
+	}
+	if idempotent != nil {
+		r.storeIdempotent(req, idempotent, data)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	w.Write(data)
+}
+
+// prepareRequest returns a copy of apiReq for backend, checked against the
+// backend's capabilities and context window, with its token limit clamped
+// to the backend's cap, and adjusted by cfg.prepare. Every backend a request
+// is tried on gets its own copy, so adjustments for one don't carry over to
+// the next. clamped is the cap the limit was lowered to, or 0.
+func prepareRequest[Req any, Resp any](r *Router, backend Backend, apiReq *Req, cfg handlerConfig[Req, Resp]) (*Req, int, *types.RouterError) {
+	if cfg.requires != nil {
+		promptTokens := -1
+		if cfg.promptTokens != nil && backend.Capabilities().MaxContextTokens > 0 {
+			promptTokens = cfg.promptTokens(apiReq)
+		}
+		if rerr := checkCapabilities(backend, cfg.getModel(apiReq), cfg.requires(apiReq), promptTokens); rerr != nil {
+			return nil, 0, rerr
+		}
+	}
+
+	req := *apiReq
+	clamped := 0
+	if cfg.tokenLimit != nil {
+		if clamped = clampMaxTokens(backend, cfg.tokenLimit(&req)); clamped > 0 {
+			r.logger.Debug("lowered token limit to backend cap", "backend", backend.ID(), "model", cfg.getModel(apiReq), "max_tokens", clamped)
+		}
+	}
+	if cfg.prepare != nil {
+		if rerr := cfg.prepare(r, backend, &req); rerr != nil {
+			return nil, 0, rerr
+		}
+	}
+	return &req, clamped, nil
+}
+
+// formatRetryTarget picks the backend a response that failed its format check
+// is retried on, and prepares apiReq for it: another replica that can take
+// the request if there is one, else the backend that served it.
+func formatRetryTarget[Req any, Resp any](r *Router, req *http.Request, served Backend, apiReq *Req, typePinned bool, cfg handlerConfig[Req, Resp]) (Backend, *Req, int, *types.RouterError) {
+	tried := map[string]bool{served.ID(): true}
+	for !backendPinned(req.Context()) {
+		next := r.rerouteBackend(cfg.getModel(apiReq), cfg.operation, served, typePinned, tried)
+		if next == nil {
+			break
+		}
+		if nextReq, clamped, rerr := prepareRequest(r, next, apiReq, cfg); rerr == nil {
+			return next, nextReq, clamped, nil
+		}
+	}
+	nextReq, clamped, rerr := prepareRequest(r, served, apiReq, cfg)
+	return served, nextReq, clamped, rerr
+}
+
+// setMaxTokensClamped sets MaxTokensClampedHeader to the cap the serving
+// backend lowered the request's token limit to, or removes it if clamped is
+// 0.
+func setMaxTokensClamped(w http.ResponseWriter, clamped int) {
+	if clamped > 0 {
+		w.Header().Set(MaxTokensClampedHeader, strconv.Itoa(clamped))
+	} else {
+		w.Header().Del(MaxTokensClampedHeader)
+	}
+}
+
+// dispatch sends a non-streaming request by fan-out, hedging, or to backend
+// alone, and returns the backend that served it. prepared is apiReq as
+// prepared for backend. A backend answering 429 is passed over until its
+// Retry-After and the request tried on another replica, of the same type if
+// typePinned; once none is left, the last 429 is returned.
+func dispatch[Req any, Resp any](r *Router, w http.ResponseWriter, req *http.Request, backend, hedgeFallback Backend, apiReq, prepared *Req, typePinned bool, cfg handlerConfig[Req, Resp]) (*Resp, Backend, error) {
+	if cfg.fanOut != nil {
+		if resp, handled, err := cfg.fanOut(r, req.Context(), backend, prepared); handled {
+			return resp, backend, err
+		}
+	}
+	if hedgeFallback != nil {
+		// A fallback that can't take the request isn't hedged to
+		if fallbackReq, clamped, rerr := prepareRequest(r, hedgeFallback, apiReq, cfg); rerr == nil {
+			resp, served, err := hedgeExecute(r, req.Context(), backend, hedgeFallback, prepared, fallbackReq, cfg.execute)
+			if served == hedgeFallback {
+				setMaxTokensClamped(w, clamped)
+			}
+			if err == nil {
+				noteRequest(req, func(l *RequestLog) { l.BackendID = served.ID() })
+				w.Header().Set(ServedByTypeHeader, string(served.Type()))
+				r.logger.Debug("hedged request served", "backend", served.ID(), "type", served.Type())
+			}
+			return resp, served, err
+		}
+	}
+	start := time.Now()
+	resp, err := cfg.execute(backend, req.Context(), prepared)
+	tried := map[string]bool{backend.ID(): true}
+	for !backendPinned(req.Context()) {
+		delay, limited := rateLimitDelay(err)
+		if !limited {
+			break
+		}
+		r.registry.markRateLimited(backend.ID(), delay)
+		next := r.rerouteBackend(cfg.getModel(apiReq), cfg.operation, backend, typePinned, tried)
+		if next == nil {
+			break
+		}
+		nextReq, clamped, rerr := prepareRequest(r, next, apiReq, cfg)
+		if rerr != nil {
+			continue
+		}
+		release, qerr := r.acquireSlot(req.Context(), next)
+		if qerr != nil {
+			break
+		}
+		defer release()
+		setMaxTokensClamped(w, clamped)
+
+		r.recordOutcome(req, backend, time.Since(start), err)
+		r.logger.Warn("backend rate limited, retrying on another", "backend", backend.ID(), "next", next.ID(), "retry_after", delay)
+		backend, prepared = next, nextReq
+		noteRequest(req, func(l *RequestLog) { l.BackendID = next.ID() })
+
+		start = time.Now()
+		resp, err = cfg.execute(backend, req.Context(), prepared)
+	}
+	return resp, backend, err
+}
+
+// recordOutcome feeds a request's result into the backend's stats and health
+// score. Requests abandoned by the client say nothing about the backend, so
+// they aren't counted as errors or scored.
+func (r *Router) recordOutcome(req *http.Request, backend Backend, latency time.Duration, err error) {
+	if req.Context().Err() != nil {
+		r.counters.countBackend(backend.ID(), nil)
+		return
+	}
+	r.counters.countBackend(backend.ID(), err)
+	r.registry.RecordOutcome(backend.ID(), latency, err)
 }
 
-// handleStream is the generic streaming handler.
-func handleStream[Req any](r *Router, w http.ResponseWriter, req *http.Request, backend Backend, apiReq *Req, streamFn func(Backend, context.Context, *Req) (<-chan StreamEvent, error), errorContext string) {
-	sse := streaming.NewWriter(w)
+// failureLogArgs returns the attributes logged when a request to backend fails.
+func failureLogArgs[Req any, Resp any](r *Router, backend Backend, apiReq *Req, err error, cfg handlerConfig[Req, Resp]) []any {
+	args := []any{"backend", backend.ID(), "error", r.logError(err)}
+	if cfg.logAttrs != nil {
+		args = append(args, cfg.logAttrs(apiReq)...)
+	}
+	return args
+}
+
+// handleStream is the generic streaming handler. prepared is apiReq as
+// prepared for backend. If typePinned, a rerouted stream stays on backends
+// of the same type. If stripUsage, usage the client didn't ask for is
+// removed from the stream.
+func handleStream[Req any, Resp any](r *Router, w http.ResponseWriter, req *http.Request, backend Backend, apiReq, prepared *Req, typePinned, stripUsage bool, cfg handlerConfig[Req, Resp]) {
+	newWriter := streaming.NewRequestWriter
+	if r.streamCompression {
+		newWriter = streaming.NewGzipRequestWriter
+	}
+	sse := newWriter(w, req)
 	if sse == nil {
 		types.WriteError(w, http.StatusInternalServerError, types.ServerError("streaming not supported"))
 		return
 	}
 
-	events, err := streamFn(backend, req.Context(), apiReq)
+	start := time.Now()
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	events, err := cfg.stream(backend, ctx, prepared)
 	if err != nil {
-		r.logger.Error(errorContext+" stream failed", "backend", backend.ID(), "error", err)
-		types.WriteError(w, http.StatusInternalServerError, types.ServerError("backend error: "+err.Error()))
+		r.recordOutcome(req, backend, time.Since(start), err)
+		r.logger.Error(cfg.errorContext+" stream failed", failureLogArgs(r, backend, apiReq, err, cfg)...)
+		r.writeBackendError(w, err)
 		return
 	}
 
+	// A backend that opens the stream with an error event hasn't sent the
+	// client anything yet, so the request can be retried on another backend.
+	first, open := <-events
+	tried := map[string]bool{backend.ID(): true}
+	for open && first.Err == nil && isErrorChunk(first.Data) && !backendPinned(req.Context()) {
+		next := r.rerouteBackend(cfg.getModel(apiReq), cfg.operation, backend, typePinned, tried)
+		if next == nil {
+			break
+		}
+		nextReq, clamped, rerr := prepareRequest(r, next, apiReq, cfg)
+		if rerr != nil {
+			continue
+		}
+		release, qerr := r.acquireSlot(req.Context(), next)
+		if qerr != nil {
+			break
+		}
+		defer release()
+		setMaxTokensClamped(w, clamped)
+
+		r.recordOutcome(req, backend, time.Since(start), errStreamErrorChunk)
+		r.logger.Warn("stream began with an error event, rerouting", "backend", backend.ID(), "next", next.ID(), "error", first.Data)
+		cancel()
+		go drainEvents(events)
+
+		backend, prepared = next, nextReq
+		noteRequest(req, func(l *RequestLog) { l.BackendID = next.ID() })
+
+		start = time.Now()
+		retryCtx, retryCancel := context.WithCancel(req.Context())
+		defer retryCancel()
+		cancel = retryCancel
+		events, err = cfg.stream(backend, retryCtx, prepared)
+		if err != nil {
+			r.recordOutcome(req, backend, time.Since(start), err)
+			r.logger.Error(cfg.errorContext+" stream failed", failureLogArgs(r, backend, apiReq, err, cfg)...)
+			r.writeBackendError(w, err)
+			return
+		}
+		first, open = <-events
+	}
+
+	var audit *auditTee
+	if r.auditSink != nil {
+		id := requestID(req)
+		w.Header().Set(RequestIDHeader, id)
+		if sink := r.auditSink(id); sink != nil {
+			audit = newAuditTee(sink, r.logger)
+			defer audit.close()
+		}
+	}
+	write := func(data string) error {
+		if err := sse.WriteData(data); err != nil {
+			return err
+		}
+		if audit != nil {
+			record := data
+			if r.logRedactor != nil && cfg.redactStream != nil && data != "[DONE]" {
+				record = cfg.redactStream(r, data)
+			}
+			if record != "" {
+				audit.write(record)
+			}
+		}
+		noteStreamUsage(req, data)
+		return nil
+	}
+
+	seeded := cfg.seed != nil && r.echoSeed(w, cfg.seed(apiReq))
+	var fingerprint string
+
 	sse.WriteHeaders()
+	defer sse.Close()
+
+	var usage *usageEstimator
+	if r.localTokenCounting && cfg.promptTokens != nil {
+		usage = newUsageEstimator(cfg.promptTokens(apiReq))
+	}
+	var toolCalls *toolCallValidator
+	if r.toolCallValidation {
+		toolCalls = newToolCallValidator()
+	}
+	var agg *StreamAggregate
+	if r.streamAggregation != nil && cfg.aggregate != nil {
+		agg = &StreamAggregate{Operation: cfg.operation, BackendID: backend.ID()}
+	}
 
-	streamEnded := false
-	for event := range events {
+	// Stream latency is time to the first event, so long responses aren't penalized
+	var firstEvent time.Duration
+	var streamErr error
+	defer func() {
+		r.recordOutcome(req, backend, firstEvent, streamErr)
+	}()
+
+	// Throughput is measured from the first generated chunk to the last
+	var reported *types.Usage
+	var firstToken, lastToken time.Time
+
+	complete := false
+	for event, ok := first, open; ok; event, ok = <-events {
+		if firstEvent == 0 {
+			firstEvent = time.Since(start)
+		}
 		if event.Err != nil {
+			streamErr = event.Err
 			r.logger.Error("stream error", "backend", backend.ID(), "error", event.Err)
 			break
 		}
 
 		if event.Done {
-			sse.WriteDone()
-			streamEnded = true
+			complete = true
 			break
 		}
 
 		if event.Data != "" {
-			if err := sse.WriteData(event.Data); err != nil {
+			if agg != nil {
+				if err := cfg.aggregate(agg, event.Data); err != nil {
+					agg.InvalidChunks++
+				} else {
+					agg.Chunks++
+				}
+			}
+			if usage != nil {
+				usage.observe(event.Data)
+			}
+			if toolCalls != nil {
+				toolCalls.observe(event.Data)
+			}
+			if seeded && fingerprint == "" {
+				fingerprint = chunkFingerprint(event.Data)
+			}
+			u, usageOnly := streamUsageChunk(event.Data)
+			if !usageOnly {
+				lastToken = time.Now()
+				if firstToken.IsZero() {
+					firstToken = lastToken
+				}
+			}
+			if u != nil {
+				reported = u
+				if r.streamUsage {
+					r.logger.Info("stream usage", "backend", backend.ID(), "model", cfg.getModel(apiReq),
+						"prompt_tokens", u.PromptTokens, "completion_tokens", u.CompletionTokens, "total_tokens", u.TotalTokens)
+					if usageOnly && stripUsage {
+						noteStreamUsage(req, event.Data)
+						continue
+					}
+				}
+			}
+			if err := write(event.Data); err != nil {
 				r.logger.Debug("failed to write SSE data", "error", err)
 				break
 			}
 		}
 	}
 
-	if !streamEnded {
-		sse.WriteDone()
+	if toolCalls != nil {
+		if problems := toolCalls.problems(); len(problems) > 0 {
+			r.counters.countIncompleteToolCalls(backend.ID())
+			r.logger.Warn("stream had incomplete tool calls", "backend", backend.ID(), "model", cfg.getModel(apiReq), "complete", complete, "problems", problems)
+		}
+	}
+
+	if seeded && complete && fingerprint == "" {
+		r.verifySeed(w, backend, cfg.getModel(apiReq), "")
+	}
+
+	if agg != nil {
+		if agg.InvalidChunks > 0 {
+			r.logger.Warn("stream had chunks that didn't parse", "backend", backend.ID(), "model", cfg.getModel(apiReq), "invalid", agg.InvalidChunks)
+		}
+		agg.Complete = complete
+		r.streamAggregation(req, *agg)
 	}
+
+	if complete {
+		tokens := 0
+		if reported != nil {
+			tokens = reported.CompletionTokens
+		} else if usage != nil {
+			tokens = usage.completionTokens()
+		}
+		r.counters.recordThroughput(cfg.getModel(apiReq), tokens, lastToken.Sub(firstToken))
+	}
+
+	// Only a cleanly finished stream gets an estimate; a truncated one would
+	// under-count and be mistaken for a complete response.
+	if complete && usage != nil {
+		if chunk, ok := usage.finalChunk(); ok {
+			if err := write(chunk); err != nil {
+				r.logger.Debug("failed to write SSE data", "error", err)
+			}
+		}
+	}
+
+	write("[DONE]")
 }
 
 // Handler configurations for each endpoint type
 var chatCompletionConfig = handlerConfig[types.ChatCompletionRequest, types.ChatCompletionResponse]{
 	getModel: func(r *types.ChatCompletionRequest) string { return r.Model },
+	setModel: func(r *types.ChatCompletionRequest, model string) { r.Model = model },
+	validate: (*types.ChatCompletionRequest).Validate,
 	execute: func(b Backend, ctx context.Context, r *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
-		return b.ChatCompletion(ctx, r)
+		return chatCompletion(ctx, b, r)
 	},
 	stream: func(b Backend, ctx context.Context, r *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
-		return b.ChatCompletionStream(ctx, r)
+		return chatCompletionStream(ctx, b, r)
+	},
+	isStreaming: func(r *types.ChatCompletionRequest) bool { return r.Stream },
+	requires: func(r *types.ChatCompletionRequest) []Capability {
+		required := []Capability{CapabilityChat}
+		if r.Stream {
+			required = append(required, CapabilityStreaming)
+			if r.N != nil && *r.N > 1 {
+				required = append(required, CapabilityStreamingN)
+			}
+		}
+		return required
+	},
+	prepare: func(r *Router, b Backend, req *types.ChatCompletionRequest) *types.RouterError {
+		if rerr := checkToolSupport(b, req); rerr != nil {
+			return rerr
+		}
+		r.normalizeMaxTokens(b, req)
+		return r.applyChatLogprobsPolicy(b, req)
 	},
-	isStreaming:  func(r *types.ChatCompletionRequest) bool { return r.Stream },
+	tokenLimit: func(req *types.ChatCompletionRequest) **int {
+		// max_completion_tokens wins when both are set; see normalizeMaxTokens
+		if req.MaxCompletionTokens != nil {
+			return &req.MaxCompletionTokens
+		}
+		return &req.MaxTokens
+	},
+	promptTokens: func(req *types.ChatCompletionRequest) int {
+		return tokenizer.CountMessages(req.Messages)
+	},
+	redactLog:     redactChatLog,
+	redactStream:  redactChatChunk,
+	usage:         func(resp *types.ChatCompletionResponse) *types.Usage { return resp.Usage },
+	responseCheck: chatResponseFormatCheck,
+	shadow: func(req *types.ChatCompletionRequest) {
+		// Shadow responses are discarded, so there is nothing to stream
+		req.Stream, req.StreamOptions = false, nil
+	},
+	requestUsage: func(req *types.ChatCompletionRequest) bool {
+		if req.StreamOptions == nil {
+			req.StreamOptions = &types.StreamOptions{}
+		}
+		requested := req.StreamOptions.IncludeUsage
+		req.StreamOptions.IncludeUsage = true
+		return requested
+	},
+	fanOut: func(r *Router, ctx context.Context, b Backend, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, bool, error) {
+		if !r.fanOutN || req.N == nil || *req.N <= 1 {
+			return nil, false, nil
+		}
+		resp, err := r.fanOutChatCompletion(ctx, b, req)
+		return resp, true, err
+	},
+	aggregate:    aggregateChatChunk,
+	dropExtra:    func(req *types.ChatCompletionRequest) { req.Extra = nil },
+	seed:         func(req *types.ChatCompletionRequest) *int { return req.Seed },
+	fingerprint:  func(resp *types.ChatCompletionResponse) string { return resp.SystemFingerprint },
 	errorContext: "chat completion",
+	operation:    OperationChat,
 }
 
 var completionConfig = handlerConfig[types.CompletionRequest, types.CompletionResponse]{
 	getModel: func(r *types.CompletionRequest) string { return r.Model },
+	setModel: func(r *types.CompletionRequest, model string) { r.Model = model },
+	validate: (*types.CompletionRequest).Validate,
 	execute: func(b Backend, ctx context.Context, r *types.CompletionRequest) (*types.CompletionResponse, error) {
 		return b.Completion(ctx, r)
 	},
 	stream: func(b Backend, ctx context.Context, r *types.CompletionRequest) (<-chan StreamEvent, error) {
 		return b.CompletionStream(ctx, r)
 	},
-	isStreaming:  func(r *types.CompletionRequest) bool { return r.Stream },
+	isStreaming: func(r *types.CompletionRequest) bool { return r.Stream },
+	requires: func(r *types.CompletionRequest) []Capability {
+		if r.Stream {
+			return []Capability{CapabilityCompletions, CapabilityStreaming}
+		}
+		return []Capability{CapabilityCompletions}
+	},
+	prepare: func(r *Router, b Backend, req *types.CompletionRequest) *types.RouterError {
+		return r.applyCompletionLogprobsPolicy(b, req)
+	},
+	promptTokens: func(req *types.CompletionRequest) int {
+		return tokenizer.CountPrompt(req.Prompt)
+	},
+	logAttrs: func(req *types.CompletionRequest) []any {
+		prompt, err := req.NormalizePrompt()
+		if err != nil {
+			return nil
+		}
+		return []any{"prompt", prompt}
+	},
+	usage:        func(resp *types.CompletionResponse) *types.Usage { return resp.Usage },
+	aggregate:    aggregateCompletionChunk,
+	dropExtra:    func(req *types.CompletionRequest) { req.Extra = nil },
+	seed:         func(req *types.CompletionRequest) *int { return req.Seed },
+	fingerprint:  func(resp *types.CompletionResponse) string { return resp.SystemFingerprint },
+	tokenLimit:   func(req *types.CompletionRequest) **int { return &req.MaxTokens },
 	errorContext: "completion",
+	operation:    OperationCompletions,
 }
 
 var embeddingsConfig = handlerConfig[types.EmbeddingsRequest, types.EmbeddingsResponse]{
 	getModel: func(r *types.EmbeddingsRequest) string { return r.Model },
+	setModel: func(r *types.EmbeddingsRequest, model string) { r.Model = model },
+	validate: (*types.EmbeddingsRequest).Validate,
 	execute: func(b Backend, ctx context.Context, r *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
 		return b.Embeddings(ctx, r)
 	},
-	stream:       nil,
-	isStreaming:  nil,
+	stream:      nil,
+	isStreaming: nil,
+	requires: func(r *types.EmbeddingsRequest) []Capability {
+		return []Capability{CapabilityEmbeddings}
+	},
+	fanOut: func(r *Router, ctx context.Context, b Backend, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, bool, error) {
+		if r.embeddingBatchSize <= 0 {
+			return nil, false, nil
+		}
+		batches := embeddingBatches(req.Input, r.embeddingBatchSize)
+		if batches == nil {
+			return nil, false, nil
+		}
+		resp, err := r.batchEmbeddings(ctx, b, req, batches)
+		return resp, true, err
+	},
+	cache: func(r *Router) (Cache, time.Duration) {
+		return r.embeddingsCache, r.embeddingsCacheTTL
+	},
+	usage:        func(resp *types.EmbeddingsResponse) *types.Usage { return resp.Usage },
+	dropExtra:    func(req *types.EmbeddingsRequest) { req.Extra = nil },
+	finish:       convertEmbeddingsFormat,
 	errorContext: "embeddings",
+	operation:    OperationEmbeddings,
+}
+
+var imageGenerationConfig = handlerConfig[types.ImageGenerationRequest, types.ImageGenerationResponse]{
+	getModel: func(r *types.ImageGenerationRequest) string { return r.Model },
+	setModel: func(r *types.ImageGenerationRequest, model string) { r.Model = model },
+	validate: (*types.ImageGenerationRequest).Validate,
+	execute: func(b Backend, ctx context.Context, r *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+		g, ok := b.(ImageGenerator)
+		if !ok {
+			return nil, imagesUnsupportedError(r.Model)
+		}
+		return g.GenerateImages(ctx, r)
+	},
+	prepare: func(r *Router, b Backend, req *types.ImageGenerationRequest) *types.RouterError {
+		if _, ok := b.(ImageGenerator); !ok {
+			return imagesUnsupportedError(req.Model)
+		}
+		return nil
+	},
+	dropExtra:    func(req *types.ImageGenerationRequest) { req.Extra = nil },
+	errorContext: "image generation",
+	operation:    OperationImageGeneration,
 }
 
 func (r *Router) handleChatCompletions(w http.ResponseWriter, req *http.Request) {
@@ -382,8 +1385,25 @@ func (r *Router) handleEmbeddings(w http.ResponseWriter, req *http.Request) {
 	handleAPIRequest(r, w, req, embeddingsConfig)
 }
 
+func (r *Router) handleImageGenerations(w http.ResponseWriter, req *http.Request) {
+	handleAPIRequest(r, w, req, imageGenerationConfig)
+}
+
 func (r *Router) handleListModels(w http.ResponseWriter, req *http.Request) {
-	models := r.registry.AllModels(req.Context())
+	capability := Capability(req.URL.Query().Get("capability"))
+	if capability != "" && !ValidCapability(capability) {
+		types.WriteError(w, http.StatusBadRequest, types.InvalidRequestError("unknown capability: "+string(capability)))
+		return
+	}
+
+	models := r.registry.AvailableModels(req.Context(), "")
+	r.applyModelMetadata(models)
+	if capability != "" {
+		models = slices.DeleteFunc(models, func(m types.Model) bool {
+			return !slices.Contains(m.Capabilities, string(capability))
+		})
+	}
+	models = r.allowedModels(req, models)
 
 	resp := types.ModelsResponse{
 		Object: "list",
@@ -407,10 +1427,20 @@ func (r *Router) handleGetModel(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Find the model across all backends
-	models := r.registry.AllModels(req.Context())
+	// A type-qualified ID describes the model served by that backend type
+	lookupID := modelID
+	if _, bareID, ok := r.lookupQualifiedModel(modelID); ok {
+		lookupID = bareID
+	}
+
+	// Find the model across all backends, among those the caller may use
+	models := r.allowedModels(req, r.registry.AllModels(req.Context()))
 	for _, model := range models {
-		if model.ID == modelID {
+		if model.ID == lookupID {
+			if meta, ok := r.modelMetadataFor(lookupID); ok {
+				meta.apply(&model)
+			}
+			model.ID = modelID
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(model)
 			return
@@ -420,21 +1450,31 @@ func (r *Router) handleGetModel(w http.ResponseWriter, req *http.Request) {
 	types.WriteError(w, http.StatusNotFound, types.NotFoundError("model not found: "+modelID))
 }
 
+// backendHealth is one backend's entry in the verbose /health response.
+type backendHealth struct {
+	ID        string      `json:"id"`
+	Type      BackendType `json:"type"`
+	Healthy   bool        `json:"healthy"`
+	LastError string      `json:"last_error,omitempty"` // Why the last health check failed
+}
+
 func (r *Router) handleHealth(w http.ResponseWriter, req *http.Request) {
 	backends := r.registry.AllBackends()
 
 	healthy := 0
 	for _, b := range backends {
-		if b.IsHealthy() {
+		if r.registry.isHealthy(b) {
 			healthy++
 		}
 	}
 
 	status := struct {
-		Status          string `json:"status"`
-		BackendsTotal   int    `json:"backends_total"`
-		BackendsHealthy int    `json:"backends_healthy"`
-		ModelsAvailable int    `json:"models_available"`
+		Status          string             `json:"status"`
+		BackendsTotal   int                `json:"backends_total"`
+		BackendsHealthy int                `json:"backends_healthy"`
+		ModelsAvailable int                `json:"models_available"`
+		HealthScores    map[string]float64 `json:"health_scores,omitempty"`
+		Backends        []backendHealth    `json:"backends,omitempty"`
 	}{
 		Status:          "ok",
 		BackendsTotal:   len(backends),
@@ -442,6 +1482,26 @@ func (r *Router) handleHealth(w http.ResponseWriter, req *http.Request) {
 		ModelsAvailable: r.registry.ModelCount(),
 	}
 
+	if r.healthScoring {
+		status.HealthScores = make(map[string]float64, len(backends))
+		for _, b := range backends {
+			status.HealthScores[b.ID()] = r.registry.HealthScore(b.ID())
+		}
+	}
+
+	// The per-backend list can be long, so it's only included on request
+	if verbose, _ := strconv.ParseBool(req.URL.Query().Get("verbose")); verbose {
+		status.Backends = make([]backendHealth, 0, len(backends))
+		for _, b := range backends {
+			bh := backendHealth{ID: b.ID(), Type: b.Type(), Healthy: r.registry.isHealthy(b)}
+			if reporter, ok := b.(HealthErrorReporter); ok {
+				bh.LastError = reporter.LastHealthError()
+			}
+			status.Backends = append(status.Backends, bh)
+		}
+		sort.Slice(status.Backends, func(i, j int) bool { return status.Backends[i].ID < status.Backends[j].ID })
+	}
+
 	if healthy == 0 && len(backends) > 0 {
 		status.Status = "degraded"
 	}
@@ -0,0 +1,112 @@
+package oairouter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// streamOf returns a closed channel containing the given data events followed by [DONE].
+func streamOf(data ...string) <-chan StreamEvent {
+	events := make(chan StreamEvent, len(data)+1)
+	for _, d := range data {
+		events <- StreamEvent{Data: d}
+	}
+	events <- StreamEvent{Data: "[DONE]", Done: true}
+	close(events)
+	return events
+}
+
+func TestStreaming_HTTP10Client(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"choices":[{"delta":{"content":"Hel"}}]}`, `{"choices":[{"delta":{"content":"lo"}}]}`), nil
+	}
+
+	r, err := NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), b)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	fmt.Fprintf(conn, "POST /v1/chat/completions HTTP/1.0\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if len(resp.TransferEncoding) != 0 {
+		t.Errorf("HTTP/1.0 response must not be chunked, got %v", resp.TransferEncoding)
+	}
+	if !resp.Close {
+		t.Error("expected connection to be closed after the stream")
+	}
+
+	// Reading to EOF only terminates if the server closes the connection
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{`"content":"Hel"`, `"content":"lo"`, "data: [DONE]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stream missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestStreaming_GzipCompression(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"choices":[{"delta":{"content":"Hel"}}]}`, `{"choices":[{"delta":{"content":"lo"}}]}`), nil
+	}
+	r, _ := NewRouter(WithStreamCompression(true))
+	r.AddBackend(context.Background(), b)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Accept-Encoding", "gzip") // Set explicitly, so the transport doesn't decode
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Hel"`) || !strings.HasSuffix(string(data), "data: [DONE]\n\n") {
+		t.Errorf("unexpected stream %q", data)
+	}
+}
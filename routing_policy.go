@@ -0,0 +1,85 @@
+package oairouter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RoutingPolicy chooses which backend serves a request. Select is called with
+// the model and the healthy backends serving it, in registration order, and
+// is never called with an empty candidate list. It reports false to reject
+// all candidates, in which case the request falls back to the default
+// backend, if any, or fails with 404. Select is called concurrently.
+type RoutingPolicy interface {
+	Select(model string, candidates []Backend) (Backend, bool)
+}
+
+// RoutingPolicyPeeker is implemented by routing policies that keep state
+// between selections, such as RoundRobin. Peek reports the backend Select
+// would pick without changing that state; POST /admin/route uses it so a dry
+// run doesn't affect the next real request. Policies without Peek have
+// Select called for dry runs too.
+type RoutingPolicyPeeker interface {
+	Peek(model string, candidates []Backend) (Backend, bool)
+}
+
+// RoutingPolicyFunc adapts a function to a RoutingPolicy.
+type RoutingPolicyFunc func(model string, candidates []Backend) (Backend, bool)
+
+func (f RoutingPolicyFunc) Select(model string, candidates []Backend) (Backend, bool) {
+	return f(model, candidates)
+}
+
+// FirstAvailable returns a policy that always picks the first candidate, so
+// a model's traffic goes to its earliest registered healthy backend. This
+// matches the router's behavior without a policy.
+func FirstAvailable() RoutingPolicy {
+	return RoutingPolicyFunc(func(model string, candidates []Backend) (Backend, bool) {
+		return candidates[0], true
+	})
+}
+
+// RoundRobin is a RoutingPolicy that rotates through a model's healthy
+// backends, keeping a separate rotation per model.
+type RoundRobin struct {
+	next sync.Map // model -> *atomic.Uint64
+}
+
+// NewRoundRobin returns a round-robin routing policy.
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{}
+}
+
+func (p *RoundRobin) Select(model string, candidates []Backend) (Backend, bool) {
+	v, _ := p.next.LoadOrStore(model, new(atomic.Uint64))
+	n := v.(*atomic.Uint64).Add(1) - 1
+	return candidates[n%uint64(len(candidates))], true
+}
+
+// Peek implements RoutingPolicyPeeker.
+func (p *RoundRobin) Peek(model string, candidates []Backend) (Backend, bool) {
+	var n uint64
+	if v, ok := p.next.Load(model); ok {
+		n = v.(*atomic.Uint64).Load()
+	}
+	return candidates[n%uint64(len(candidates))], true
+}
+
+// lookupByPolicy selects a backend that can perform op for model with the
+// routing policy, peeking at its choice for a dry run. If none is healthy,
+// it falls back to LookupByModelForOp, which returns an unhealthy one.
+func (r *Router) lookupByPolicy(model string, op Operation, dryRun bool) (Backend, bool) {
+	candidates := r.registry.HealthyBackendsForModelOp(model, op)
+	if len(candidates) == 0 {
+		return r.registry.LookupByModelForOp(model, op)
+	}
+	candidates = r.registry.preferTier(candidates)
+	var b Backend
+	var ok bool
+	if peeker, peek := r.routingPolicy.(RoutingPolicyPeeker); peek && dryRun {
+		b, ok = peeker.Peek(model, candidates)
+	} else {
+		b, ok = r.routingPolicy.Select(model, candidates)
+	}
+	return b, ok && b != nil
+}
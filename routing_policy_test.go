@@ -0,0 +1,69 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRoutingPolicy_RoundRobin(t *testing.T) {
+	r, _ := NewRouter(WithRoutingPolicy(NewRoundRobin()))
+	var aCalls, bCalls, cCalls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &aCalls))
+	r.AddBackend(context.Background(), idBackend("backend-b", &bCalls))
+	down := idBackend("backend-c", &cCalls)
+	down.SetHealthy(false)
+	r.AddBackend(context.Background(), down)
+
+	for range 6 {
+		if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+	if aCalls.Load() != 3 || bCalls.Load() != 3 || cCalls.Load() != 0 {
+		t.Errorf("calls a=%d b=%d c=%d, want 3/3/0", aCalls.Load(), bCalls.Load(), cCalls.Load())
+	}
+}
+
+func TestRoutingPolicy_FirstAvailable(t *testing.T) {
+	p := FirstAvailable()
+	a, b := newMockBackend("backend-a", true), newMockBackend("backend-b", true)
+	for range 3 {
+		if got, ok := p.Select("test-model", []Backend{a, b}); !ok || got != a {
+			t.Fatalf("Select() = %v, %v; want backend-a", got, ok)
+		}
+	}
+}
+
+func TestRoutingPolicy_Custom(t *testing.T) {
+	var seen []string
+	policy := RoutingPolicyFunc(func(model string, candidates []Backend) (Backend, bool) {
+		seen = seen[:0]
+		for _, c := range candidates {
+			seen = append(seen, c.ID())
+		}
+		if model == "rejected-model" {
+			return nil, false
+		}
+		return candidates[len(candidates)-1], true
+	})
+	r, _ := NewRouter(WithRoutingPolicy(policy))
+	var aCalls, bCalls atomic.Int64
+	a := idBackend("backend-a", &aCalls)
+	a.models = []string{"test-model", "rejected-model"}
+	r.AddBackend(context.Background(), a)
+	r.AddBackend(context.Background(), idBackend("backend-b", &bCalls))
+
+	postChat(t, r, testChatBody)
+	if len(seen) != 2 || seen[0] != "backend-a" || seen[1] != "backend-b" {
+		t.Errorf("policy saw candidates %v", seen)
+	}
+	if bCalls.Load() != 1 {
+		t.Errorf("expected the policy's choice to serve the request, got a=%d b=%d", aCalls.Load(), bCalls.Load())
+	}
+
+	if rec := postChat(t, r, `{"model":"rejected-model","messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("rejected selection: expected 404, got %d", rec.Code)
+	}
+}
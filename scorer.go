@@ -0,0 +1,120 @@
+package oairouter
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Scorer rates how well a backend suits a request; higher is better.
+// ScoredPolicy sends requests to backends in proportion to their scores.
+// The built-in scorers return values between 0 and 1, so they can be
+// combined with CombineScorers or with custom arithmetic. Scorers are called
+// concurrently.
+type Scorer func(Backend) float64
+
+// CombineScorers returns a scorer whose score is the product of scorers', so
+// a backend must do well on all of them, and any scorer at 0 rules it out
+// unless every backend is ruled out.
+func CombineScorers(scorers ...Scorer) Scorer {
+	return func(b Backend) float64 {
+		score := 1.0
+		for _, s := range scorers {
+			score *= s(b)
+		}
+		return score
+	}
+}
+
+// WeightScorer scores backends by their weight in weights, keyed by backend
+// ID, e.g. to prefer a bigger GPU. Backends not in weights weigh 1. Weights
+// must be finite and not negative; a weight of 0 rules a backend out.
+func WeightScorer(weights map[string]float64) (Scorer, error) {
+	for id, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weight for backend %s must be finite and not negative, got %v", id, w)
+		}
+	}
+	weights = maps.Clone(weights)
+	return func(b Backend) float64 {
+		if w, ok := weights[b.ID()]; ok {
+			return w
+		}
+		return 1
+	}, nil
+}
+
+// LatencyScorer scores backends by their moving average of response latency,
+// from 1 for an instant response down toward 0; a one-second average scores
+// 0.5. Backends not yet measured score 1, so they get tried.
+func (r *BackendRegistry) LatencyScorer() Scorer {
+	return func(b Backend) float64 {
+		avg, ok := r.LatencyEWMA(b.ID())
+		if !ok {
+			return 1
+		}
+		return float64(healthLatencyRef) / float64(healthLatencyRef+avg)
+	}
+}
+
+// InverseInFlightScorer scores backends by how few requests they are
+// serving: 1 when idle, 1/2 with one in flight, 1/3 with two, and so on.
+func (r *BackendRegistry) InverseInFlightScorer() Scorer {
+	return func(b Backend) float64 {
+		return 1 / (1 + float64(r.InFlight(b.ID())))
+	}
+}
+
+// FailureRecencyScorer scores backends by how long ago a request to them
+// last failed: 0 right after a failure, rising linearly to 1 once window has
+// passed. Backends that have not failed score 1.
+func (r *BackendRegistry) FailureRecencyScorer(window time.Duration) Scorer {
+	return func(b Backend) float64 {
+		last := r.healthStatsFor(b.ID()).lastFailed()
+		if last.IsZero() {
+			return 1
+		}
+		since := time.Since(last)
+		if since >= window {
+			return 1
+		}
+		return float64(since) / float64(window)
+	}
+}
+
+// ScoredPolicy returns a RoutingPolicy that picks among a model's healthy
+// backends at random in proportion to scorer's rating of each, so better
+// backends get more traffic while the rest keep getting some, keeping their
+// latency and failure stats current. A backend scoring 0 or less, NaN, or
+// infinity is ruled out unless every candidate is, in which case the first
+// is picked.
+func ScoredPolicy(scorer Scorer) RoutingPolicy {
+	return RoutingPolicyFunc(func(model string, candidates []Backend) (Backend, bool) {
+		if len(candidates) == 1 {
+			return candidates[0], true
+		}
+
+		scores := make([]float64, len(candidates))
+		total := 0.0
+		for i, b := range candidates {
+			if score := scorer(b); score > 0 && !math.IsInf(score, 1) {
+				scores[i] = score
+				total += score
+			}
+		}
+		if total == 0 {
+			return candidates[0], true
+		}
+
+		pick := rand.Float64() * total
+		for i, score := range scores {
+			if pick < score {
+				return candidates[i], true
+			}
+			pick -= score
+		}
+		return candidates[len(candidates)-1], true
+	})
+}
@@ -0,0 +1,143 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func scoredRegistry(ids ...string) *BackendRegistry {
+	reg := NewBackendRegistry()
+	for _, id := range ids {
+		reg.Register(context.Background(), newMockBackend(id, true))
+	}
+	return reg
+}
+
+// backendsOf returns reg's backends with ids, in order.
+func backendsOf(t *testing.T, reg *BackendRegistry, ids ...string) []Backend {
+	t.Helper()
+	backends := make([]Backend, len(ids))
+	for i, id := range ids {
+		b, ok := reg.LookupByID(id)
+		if !ok {
+			t.Fatalf("no backend %s", id)
+		}
+		backends[i] = b
+	}
+	return backends
+}
+
+// picks counts how often policy selects each of candidates in n tries.
+func picks(policy RoutingPolicy, candidates []Backend, n int) map[string]int {
+	counts := make(map[string]int)
+	for range n {
+		b, _ := policy.Select("test-model", candidates)
+		counts[b.ID()]++
+	}
+	return counts
+}
+
+func TestScoredPolicy_ProportionalToScore(t *testing.T) {
+	reg := scoredRegistry("big", "small", "off")
+	weights, err := WeightScorer(map[string]float64{"big": 3, "small": 1, "off": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := picks(ScoredPolicy(weights), backendsOf(t, reg, "big", "small", "off"), 4000)
+	if counts["off"] != 0 {
+		t.Errorf("backend weighted 0 picked %d times", counts["off"])
+	}
+	// Expect about 3000 and 1000; the lower-scored backend still gets traffic
+	if counts["big"] < 2700 || counts["small"] < 700 {
+		t.Errorf("picks = %v, want about 3:1", counts)
+	}
+}
+
+func TestScoredPolicy_CombinedScorers(t *testing.T) {
+	reg := scoredRegistry("fast", "flaky")
+	reg.RecordOutcome("fast", 50*time.Millisecond, nil)
+	reg.RecordOutcome("flaky", 10*time.Millisecond, nil)
+	reg.RecordOutcome("flaky", time.Millisecond, errors.New("boom"))
+
+	// A recent failure rules flaky out until the window passes
+	scorer := CombineScorers(reg.LatencyScorer(), reg.FailureRecencyScorer(time.Hour))
+	if counts := picks(ScoredPolicy(scorer), backendsOf(t, reg, "fast", "flaky"), 100); counts["fast"] != 100 {
+		t.Errorf("picks = %v, want all on fast", counts)
+	}
+}
+
+func TestScoredPolicy_AllRuledOut(t *testing.T) {
+	reg := scoredRegistry("a", "b")
+	for _, score := range []float64{0, -1, math.NaN()} {
+		policy := ScoredPolicy(func(Backend) float64 { return score })
+		if counts := picks(policy, backendsOf(t, reg, "a", "b"), 20); counts["a"] != 20 {
+			t.Errorf("score %v: picks = %v, want the first candidate", score, counts)
+		}
+	}
+}
+
+func TestWeightScorer_RejectsInvalidWeights(t *testing.T) {
+	for _, w := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := WeightScorer(map[string]float64{"a": w}); err == nil {
+			t.Errorf("accepted weight %v", w)
+		}
+	}
+}
+
+func TestInverseInFlightScorer(t *testing.T) {
+	reg := scoredRegistry("busy", "idle")
+	release := reg.Acquire("busy")
+	defer release()
+
+	scorer := reg.InverseInFlightScorer()
+	busy, _ := reg.LookupByID("busy")
+	if s := scorer(busy); s != 0.5 {
+		t.Errorf("busy score = %v, want 0.5", s)
+	}
+	idle, _ := reg.LookupByID("idle")
+	if s := scorer(idle); s != 1 {
+		t.Errorf("idle score = %v, want 1", s)
+	}
+}
+
+func TestFailureRecencyScorer_Recovers(t *testing.T) {
+	reg := scoredRegistry("a")
+	a, _ := reg.LookupByID("a")
+	scorer := reg.FailureRecencyScorer(20 * time.Millisecond)
+	if s := scorer(a); s != 1 {
+		t.Errorf("score before any failure = %v, want 1", s)
+	}
+
+	reg.RecordOutcome("a", time.Millisecond, errors.New("boom"))
+	if s := scorer(a); s >= 1 {
+		t.Errorf("score right after a failure = %v, want below 1", s)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if s := scorer(a); s != 1 {
+		t.Errorf("score after the window = %v, want 1", s)
+	}
+}
+
+func TestScoredRouting(t *testing.T) {
+	weights, _ := WeightScorer(map[string]float64{"backend-a": 0})
+	r := newTestRouter(t, []Backend{
+		modelBackend("backend-a", "test-model", true),
+		modelBackend("backend-b", "test-model", true),
+	}, WithScoredRouting(func(reg *BackendRegistry) Scorer {
+		return CombineScorers(reg.InverseInFlightScorer(), weights)
+	}))
+
+	for range 5 {
+		if got, _ := servedBy(t, r, "test-model"); got != "backend-b" {
+			t.Errorf("served by %q, want backend-b", got)
+		}
+	}
+
+	if _, err := NewRouter(WithScoredRouting(nil)); err == nil {
+		t.Error("accepted a nil scorer builder")
+	}
+}
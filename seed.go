@@ -0,0 +1,54 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SeedHeader is set with WithSeedVerification on responses to requests with
+// a seed, to the seed the backend was sent.
+const SeedHeader = "X-Seed"
+
+// SystemFingerprintHeader is set with WithSeedVerification on non-streaming
+// responses to requests with a seed, to the system_fingerprint the backend
+// returned.
+const SystemFingerprintHeader = "X-System-Fingerprint"
+
+// echoSeed sets SeedHeader for a request with seed, if seed verification is
+// on. It reports whether the response should be checked.
+func (r *Router) echoSeed(w http.ResponseWriter, seed *int) bool {
+	if !r.seedVerification || seed == nil {
+		return false
+	}
+	w.Header().Set(SeedHeader, strconv.Itoa(*seed))
+	return true
+}
+
+// verifySeed checks the system_fingerprint of a response to a request with
+// a seed, echoing it in SystemFingerprintHeader. A backend that returns none
+// may have ignored the seed, so the response may not be reproducible; that
+// is logged and counted per backend.
+func (r *Router) verifySeed(w http.ResponseWriter, backend Backend, model, fingerprint string) {
+	if fingerprint != "" {
+		w.Header().Set(SystemFingerprintHeader, fingerprint)
+		return
+	}
+	r.counters.countMissingFingerprint(backend.ID())
+	r.logger.Warn("backend returned no system_fingerprint for a seeded request; it may have ignored the seed",
+		"backend", backend.ID(), "model", model)
+}
+
+// chunkFingerprint returns the system_fingerprint of a streamed chunk, if it
+// has one.
+func chunkFingerprint(data string) string {
+	if !strings.Contains(data, `"system_fingerprint"`) {
+		return ""
+	}
+	var chunk struct {
+		SystemFingerprint string `json:"system_fingerprint"`
+	}
+	json.Unmarshal([]byte(data), &chunk)
+	return chunk.SystemFingerprint
+}
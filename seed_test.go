@@ -0,0 +1,97 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+const seededChatBody = `{"model":"test-model","seed":42,"messages":[{"role":"user","content":"hi"}]}`
+
+// fingerprintBackend answers chat requests with fingerprint as the
+// system_fingerprint, streamed or not.
+func fingerprintBackend(fingerprint string) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: "1", SystemFingerprint: fingerprint}, nil
+	}
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		if fingerprint == "" {
+			return streamOf(`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`), nil
+		}
+		return streamOf(`{"id":"1","system_fingerprint":"` + fingerprint + `","choices":[{"index":0,"delta":{"content":"Hi"}}]}`), nil
+	}
+	return b
+}
+
+func missingFingerprints(r *Router) int64 {
+	for _, b := range r.Stats().Backends {
+		if b.ID == "backend-a" {
+			return b.MissingFingerprints
+		}
+	}
+	return 0
+}
+
+func TestSeedVerification_EchoesSeedAndFingerprint(t *testing.T) {
+	r, _ := NewRouter(WithSeedVerification(true))
+	r.AddBackend(context.Background(), fingerprintBackend("fp_123"))
+
+	w := postChat(t, r, seededChatBody)
+	if w.Code != http.StatusOK || w.Header().Get(SeedHeader) != "42" || w.Header().Get(SystemFingerprintHeader) != "fp_123" {
+		t.Errorf("status = %d, headers = %v", w.Code, w.Header())
+	}
+	if n := missingFingerprints(r); n != 0 {
+		t.Errorf("missing fingerprints = %d, want 0", n)
+	}
+}
+
+func TestSeedVerification_CountsMissingFingerprint(t *testing.T) {
+	r, _ := NewRouter(WithSeedVerification(true))
+	r.AddBackend(context.Background(), fingerprintBackend(""))
+
+	w := postChat(t, r, seededChatBody)
+	if w.Code != http.StatusOK || w.Header().Get(SeedHeader) != "42" || w.Header().Get(SystemFingerprintHeader) != "" {
+		t.Errorf("status = %d, headers = %v", w.Code, w.Header())
+	}
+	if n := missingFingerprints(r); n != 1 {
+		t.Errorf("missing fingerprints = %d, want 1", n)
+	}
+
+	// Requests without a seed aren't checked
+	postChat(t, r, testChatBody)
+	if n := missingFingerprints(r); n != 1 {
+		t.Errorf("missing fingerprints = %d after an unseeded request, want 1", n)
+	}
+}
+
+func TestSeedVerification_Streams(t *testing.T) {
+	for _, fingerprint := range []string{"fp_123", ""} {
+		r, _ := NewRouter(WithSeedVerification(true))
+		r.AddBackend(context.Background(), fingerprintBackend(fingerprint))
+
+		w := postChat(t, r, `{"model":"test-model","seed":42,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+		if w.Header().Get(SeedHeader) != "42" {
+			t.Errorf("%q: headers = %v", fingerprint, w.Header())
+		}
+		want := int64(0)
+		if fingerprint == "" {
+			want = 1
+		}
+		if n := missingFingerprints(r); n != want {
+			t.Errorf("%q: missing fingerprints = %d, want %d", fingerprint, n, want)
+		}
+	}
+}
+
+func TestSeedVerification_OffByDefault(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), fingerprintBackend(""))
+
+	w := postChat(t, r, seededChatBody)
+	if w.Header().Get(SeedHeader) != "" || missingFingerprints(r) != 0 {
+		t.Errorf("headers = %v, missing fingerprints = %d", w.Header(), missingFingerprints(r))
+	}
+}
@@ -0,0 +1,29 @@
+package oairouter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ServerTimingHeader is set on non-streaming responses served by a backend
+// when WithServerTiming is enabled.
+const ServerTimingHeader = "Server-Timing"
+
+// serverTiming formats the Server-Timing header value for a request: the
+// time spent selecting a backend, waiting on backendID, and in total.
+func serverTiming(lookup, backend, total time.Duration, backendID string) string {
+	return fmt.Sprintf("lookup;dur=%s, backend;desc=%s;dur=%s, total;dur=%s",
+		timingMs(lookup), quoteTimingDesc(backendID), timingMs(backend), timingMs(total))
+}
+
+// timingMs formats d in milliseconds, as Server-Timing durations are.
+func timingMs(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d.Microseconds())/1000)
+}
+
+// quoteTimingDesc quotes s as an HTTP quoted-string.
+func quoteTimingDesc(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
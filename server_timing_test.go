@@ -0,0 +1,85 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+var serverTimingPattern = regexp.MustCompile(`^lookup;dur=([0-9.]+), backend;desc="(.*)";dur=([0-9.]+), total;dur=([0-9.]+)$`)
+
+func TestServerTiming_BreaksDownRequest(t *testing.T) {
+	r, _ := NewRouter(WithServerTiming())
+	b := modelBackend("backend-a", "llama-3", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		time.Sleep(5 * time.Millisecond)
+		return &types.ChatCompletionResponse{ID: "backend-a", Model: req.Model}, nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	w := postChat(t, r, chatBody("llama-3"))
+	header := w.Header().Get(ServerTimingHeader)
+	m := serverTimingPattern.FindStringSubmatch(header)
+	if w.Code != http.StatusOK || m == nil {
+		t.Fatalf("status = %d, Server-Timing = %q", w.Code, header)
+	}
+	if m[2] != "backend-a" {
+		t.Errorf("backend desc = %q, want backend-a", m[2])
+	}
+	lookup, _ := strconv.ParseFloat(m[1], 64)
+	backend, _ := strconv.ParseFloat(m[3], 64)
+	total, _ := strconv.ParseFloat(m[4], 64)
+	if backend < 5 || total < backend+lookup {
+		t.Errorf("lookup = %v, backend = %v, total = %v", lookup, backend, total)
+	}
+}
+
+func TestServerTiming_SetOnBackendErrors(t *testing.T) {
+	r, _ := NewRouter(WithServerTiming())
+	b := modelBackend("backend-a", "llama-3", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, errors.New("model crashed")
+	}
+	r.AddBackend(context.Background(), b)
+
+	w := postChat(t, r, chatBody("llama-3"))
+	if w.Code == http.StatusOK || !serverTimingPattern.MatchString(w.Header().Get(ServerTimingHeader)) {
+		t.Errorf("status = %d, Server-Timing = %q", w.Code, w.Header().Get(ServerTimingHeader))
+	}
+}
+
+func TestServerTiming_NotOnStreams(t *testing.T) {
+	r, _ := NewRouter(WithServerTiming())
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`), nil
+	}
+	r.AddBackend(context.Background(), b)
+
+	if w := postChat(t, r, streamChatBody); w.Header().Get(ServerTimingHeader) != "" {
+		t.Errorf("stream has Server-Timing %q", w.Header().Get(ServerTimingHeader))
+	}
+}
+
+func TestServerTiming_OffByDefault(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+
+	if w := postChat(t, r, chatBody("llama-3")); w.Header().Get(ServerTimingHeader) != "" {
+		t.Errorf("Server-Timing = %q without WithServerTiming", w.Header().Get(ServerTimingHeader))
+	}
+}
+
+func TestServerTiming_QuotesBackendID(t *testing.T) {
+	got := serverTiming(time.Millisecond, 2*time.Millisecond, 3*time.Millisecond, `gpu "a"\1`)
+	want := `lookup;dur=1.000, backend;desc="gpu \"a\"\\1";dur=2.000, total;dur=3.000`
+	if got != want {
+		t.Errorf("serverTiming = %s, want %s", got, want)
+	}
+}
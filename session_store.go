@@ -0,0 +1,137 @@
+package oairouter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SessionStore pins sessions to backends for session affinity. Without one,
+// sessions are mapped to backends by hashing, so adding or removing a backend
+// moves sessions between the others; a pinned session stays on its backend
+// until the pin expires or the backend becomes unhealthy or is removed.
+// Implementations must be safe for concurrent use, and may be shared by
+// several router replicas.
+type SessionStore interface {
+	// Get returns the backend ID pinned for key. The boolean is false if
+	// key isn't pinned or its pin has expired.
+	Get(ctx context.Context, key string) (string, bool, error)
+
+	// Set pins key to backendID, replacing any pin and restarting its expiry.
+	Set(ctx context.Context, key, backendID string) error
+}
+
+// MemorySessionStore is an in-process SessionStore whose pins expire after
+// a period without use.
+type MemorySessionStore struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	pins      map[string]sessionPin
+	lastSweep time.Time
+}
+
+type sessionPin struct {
+	backendID string
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewMemorySessionStore creates an empty in-memory session store. Pins
+// expire ttl after they were last used; a ttl of zero means they never do.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		ttl:       ttl,
+		pins:      make(map[string]sessionPin),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get implements SessionStore.
+func (s *MemorySessionStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pin, ok := s.pins[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !pin.expiresAt.IsZero() && time.Now().After(pin.expiresAt) {
+		delete(s.pins, key)
+		return "", false, nil
+	}
+	return pin.backendID, true, nil
+}
+
+// Set implements SessionStore.
+func (s *MemorySessionStore) Set(ctx context.Context, key, backendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	pin := sessionPin{backendID: backendID}
+	if s.ttl > 0 {
+		pin.expiresAt = now.Add(s.ttl)
+		// Sessions that are never seen again would otherwise stay forever
+		if now.Sub(s.lastSweep) > s.ttl {
+			for k, p := range s.pins {
+				if now.After(p.expiresAt) {
+					delete(s.pins, k)
+				}
+			}
+			s.lastSweep = now
+		}
+	}
+	s.pins[key] = pin
+	return nil
+}
+
+// Len returns the number of pins, including expired ones not yet evicted.
+func (s *MemorySessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pins)
+}
+
+// sessionKey is the SessionStore key for a session's requests to model, so a
+// session using several models can be pinned to a different backend for each.
+func sessionKey(model, sessionID string) string {
+	return model + "\x00" + sessionID
+}
+
+// lookupPinnedSession finds the backend for a session with the session
+// store. A pinned backend that is still healthy and can serve the model is
+// reused; otherwise a backend is picked by hashing and pinned, and the result
+// is marked SessionBroken if an earlier pin was lost. Store errors are logged
+// and fall back to hashing alone. Dry runs read pins without writing them.
+func (r *Router) lookupPinnedSession(ctx context.Context, model, sessionID string, op Operation) (LookupResult, bool) {
+	key := sessionKey(model, sessionID)
+	pinnedID, pinned, err := r.sessionStore.Get(ctx, key)
+	if err != nil {
+		r.logger.Warn("session store lookup failed", "error", err)
+		return r.registry.LookupByModelWithSessionForOp(model, sessionID, op)
+	}
+	if pinned {
+		if b, ok := r.registry.LookupByID(pinnedID); ok && r.registry.isHealthy(b) && op.servedBy(b) && r.registry.ServesModel(pinnedID, model) {
+			// Refresh the pin so active sessions don't expire
+			if isDryRun(ctx) {
+				return LookupResult{Backend: b}, true
+			}
+			if err := r.sessionStore.Set(ctx, key, pinnedID); err != nil {
+				r.logger.Warn("session store update failed", "error", err)
+			}
+			return LookupResult{Backend: b}, true
+		}
+	}
+
+	result, ok := r.registry.LookupByModelWithSessionForOp(model, sessionID, op)
+	if !ok {
+		return result, false
+	}
+	result.SessionBroken = pinned
+	if r.registry.isHealthy(result.Backend) && !isDryRun(ctx) {
+		if err := r.sessionStore.Set(ctx, key, result.Backend.ID()); err != nil {
+			r.logger.Warn("session store update failed", "error", err)
+		}
+	}
+	return result, true
+}
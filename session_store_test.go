@@ -0,0 +1,94 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// postSessionChat sends a chat request for session and returns the ID of the
+// backend that served it, with the response.
+func postSessionChat(t *testing.T, r *Router, session string) (string, *httptest.ResponseRecorder) {
+	t.Helper()
+	rec := postChat(t, r, testChatBody, withHeader(SessionHeader, session))
+
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	return resp.ID, rec
+}
+
+func TestMemorySessionStore_Expiry(t *testing.T) {
+	s := NewMemorySessionStore(20 * time.Millisecond)
+	ctx := context.Background()
+	s.Set(ctx, "session", "backend-a")
+	if id, ok, _ := s.Get(ctx, "session"); !ok || id != "backend-a" {
+		t.Fatalf("Get() = %q, %v", id, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok, _ := s.Get(ctx, "session"); ok {
+		t.Error("expected the pin to expire")
+	}
+}
+
+func TestSessionStore_PinSurvivesNewBackends(t *testing.T) {
+	r, err := NewRouter(WithSessionStore(NewMemorySessionStore(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	r.AddBackend(context.Background(), idBackend("backend-b", &calls))
+
+	pinned := map[string]string{}
+	for i := range 20 {
+		session := fmt.Sprintf("session-%d", i)
+		pinned[session], _ = postSessionChat(t, r, session)
+	}
+
+	for _, id := range []string{"backend-c", "backend-d", "backend-e"} {
+		r.AddBackend(context.Background(), idBackend(id, &calls))
+	}
+	for session, want := range pinned {
+		if got, _ := postSessionChat(t, r, session); got != want {
+			t.Errorf("%s moved from %s to %s after backends were added", session, want, got)
+		}
+	}
+}
+
+func TestSessionStore_RepinsWhenBackendUnhealthy(t *testing.T) {
+	r, _ := NewRouter(WithSessionStore(NewMemorySessionStore(time.Hour)))
+	var calls atomic.Int64
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	r.AddBackend(context.Background(), idBackend("backend-b", &calls))
+
+	first, _ := postSessionChat(t, r, "session")
+	r.registry.backends[first].(*mockBackend).healthy.Store(false)
+
+	second, rec := postSessionChat(t, r, "session")
+	if second == first {
+		t.Fatalf("expected the session to leave unhealthy %s", first)
+	}
+	if rec.Header().Get(SessionBrokenHeader) != "true" {
+		t.Error("expected X-Session-Broken when the pinned backend is unhealthy")
+	}
+
+	// The new pin holds after the old backend recovers
+	r.registry.backends[first].(*mockBackend).healthy.Store(true)
+	if third, rec := postSessionChat(t, r, "session"); third != second || rec.Header().Get(SessionBrokenHeader) != "" {
+		t.Errorf("expected the session to stay on %s, got %s", second, third)
+	}
+}
+
+func TestSessionStore_RequiresStore(t *testing.T) {
+	if _, err := NewRouter(WithSessionStore(nil)); err == nil {
+		t.Error("expected an error for a nil store")
+	}
+}
@@ -0,0 +1,80 @@
+package oairouter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// echoBackend returns a mock whose chat responses carry its own ID.
+func echoBackend(id string, healthy bool) *mockBackend {
+	b := newMockBackend(id, healthy)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{ID: id, Object: "chat.completion"}, nil
+	}
+	return b
+}
+
+func TestSessionHeader_CustomHeaderPinsBackend(t *testing.T) {
+	backends := []*mockBackend{
+		echoBackend("backend-a", true),
+		echoBackend("backend-b", true),
+		echoBackend("backend-c", true),
+	}
+
+	r, err := NewRouter(WithSessionHeader("X-Conversation-ID"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range backends {
+		r.AddBackend(context.Background(), b)
+	}
+
+	first := postChat(t, r, testChatBody, withHeader("X-Conversation-ID", "conv-42")).Body.String()
+	for i := 0; i < 10; i++ {
+		rec := postChat(t, r, testChatBody, withHeader("X-Conversation-ID", "conv-42"))
+		if rec.Body.String() != first {
+			t.Fatalf("session moved between backends: %s vs %s", first, rec.Body.String())
+		}
+		if rec.Header().Get(SessionRebalancedHeader) != "" {
+			t.Error("unexpected rebalanced header for a healthy session")
+		}
+	}
+
+	// Mark the pinned backend unhealthy and expect a rebalanced response
+	for _, b := range backends {
+		if strings.Contains(first, `"id":"`+b.ID()+`"`) {
+			b.SetHealthy(false)
+		}
+	}
+	rec := postChat(t, r, testChatBody, withHeader("X-Conversation-ID", "conv-42"))
+	if rec.Header().Get(SessionRebalancedHeader) != "true" {
+		t.Error("expected X-Session-Rebalanced when the pinned backend is unhealthy")
+	}
+	if rec.Body.String() == first {
+		t.Error("expected the session to move to a healthy backend")
+	}
+}
+
+func TestSessionHeader_AbsentUsesNormalLookup(t *testing.T) {
+	r, err := NewRouter(WithSessionHeader("X-Conversation-ID"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), echoBackend("backend-a", false))
+	r.AddBackend(context.Background(), echoBackend("backend-b", true))
+
+	rec := postChat(t, r, testChatBody)
+	if !strings.Contains(rec.Body.String(), "backend-b") {
+		t.Errorf("expected first healthy backend without a session header, got %s", rec.Body.String())
+	}
+	if rec.Header().Get(SessionRebalancedHeader) != "" {
+		t.Error("unexpected rebalanced header without a session")
+	}
+
+	if _, err := NewRouter(WithSessionHeader("")); err == nil {
+		t.Error("expected an error for an empty header name")
+	}
+}
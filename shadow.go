@@ -0,0 +1,101 @@
+package oairouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// DefaultShadowTimeout bounds each mirrored request when WithShadowTimeout
+// isn't set.
+const DefaultShadowTimeout = 30 * time.Second
+
+// DefaultShadowConcurrency is how many mirrored requests may be in flight at
+// once when WithShadowConcurrency isn't set.
+const DefaultShadowConcurrency = 16
+
+// shadowTraffic mirrors a sample of requests to a backend whose responses are
+// discarded.
+type shadowTraffic struct {
+	backendID  string
+	sampleRate float64
+	slots      chan struct{} // Held by each mirrored request in flight
+}
+
+// sampleShadow reports whether req should be mirrored, and if so tees its body
+// into the returned buffer as it is decoded.
+func (r *Router) sampleShadow(req *http.Request) *bytes.Buffer {
+	if r.shadow == nil || rand.Float64() >= r.shadow.sampleRate {
+		return nil
+	}
+	body := new(bytes.Buffer)
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(req.Body, body), req.Body}
+	return body
+}
+
+// mirrorRequest sends a copy of the request in body to the shadow backend in
+// the background. The copy is decoded afresh, so nothing the router changes
+// on the real request leaks into it apart from the model: the one set with
+// WithShadowModel, or else the resolved one. Requests already served by the
+// shadow backend aren't mirrored, and neither are requests arriving while
+// the shadow backend has its limit of mirrored requests in flight. Mirrored
+// requests take their backend slot like any other, through the request queue
+// if one is set, and are dropped if they don't get one.
+func mirrorRequest[Req any, Resp any](r *Router, body []byte, model string, served Backend, cfg handlerConfig[Req, Resp]) {
+	s := r.shadow
+	if served.ID() == s.backendID {
+		return
+	}
+	backend, ok := r.registry.LookupByID(s.backendID)
+	if !ok {
+		r.logger.Debug("shadow backend not registered", "backend", s.backendID)
+		return
+	}
+	if r.shadowModel != "" {
+		model = r.shadowModel
+	}
+
+	var shadowReq Req
+	if err := json.Unmarshal(body, &shadowReq); err != nil {
+		return
+	}
+	if !r.unknownFields && cfg.dropExtra != nil {
+		cfg.dropExtra(&shadowReq)
+	}
+	cfg.setModel(&shadowReq, model)
+	cfg.shadow(&shadowReq)
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		r.logger.Debug("shadow request dropped", "endpoint", cfg.errorContext, "backend", backend.ID(), "in_flight", cap(s.slots))
+		return
+	}
+	go func() {
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), r.shadowTimeout)
+		defer cancel()
+		release, err := r.acquireSlot(ctx, backend)
+		if err != nil {
+			r.logger.Debug("shadow request dropped", "endpoint", cfg.errorContext, "backend", backend.ID(), "error", err)
+			return
+		}
+		defer release()
+
+		start := time.Now()
+		_, err = cfg.execute(backend, ctx, &shadowReq)
+		latency := float64(time.Since(start).Microseconds()) / 1000
+		if err != nil {
+			r.logger.Warn("shadow request failed", "endpoint", cfg.errorContext, "backend", backend.ID(), "model", model, "latency_ms", latency, "error", r.logError(err))
+			return
+		}
+		r.logger.Info("shadow request completed", "endpoint", cfg.errorContext, "backend", backend.ID(), "model", model, "latency_ms", latency)
+	}()
+}
@@ -0,0 +1,191 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// shadowBackend reports each request it receives and then fails, or blocks
+// until the request is done. It serves a different model so it isn't picked
+// for test-model.
+func shadowBackend(received chan<- *types.ChatCompletionRequest, block bool) *mockBackend {
+	b := newMockBackend("shadow", true)
+	b.models = []string{"candidate-model"}
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		received <- req
+		if block {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, errors.New("shadow backend failed")
+	}
+	return b
+}
+
+func TestShadow_MirrorsRequest(t *testing.T) {
+	r, err := NewRouter(WithShadowBackend("shadow", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int64
+	received := make(chan *types.ChatCompletionRequest, 1)
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	r.AddBackend(context.Background(), shadowBackend(received, false))
+
+	rec := postChat(t, r, testChatBody)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "backend-a") {
+		t.Fatalf("expected backend-a's response despite the shadow failing, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case req := <-received:
+		if req.Model != "test-model" || len(req.Messages) != 1 {
+			t.Errorf("expected a copy of the request, got %+v", req)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request was not mirrored")
+	}
+}
+
+func TestShadow_StreamMirroredWithoutStreaming(t *testing.T) {
+	r, _ := NewRouter(WithShadowBackend("shadow", 1))
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"id":"1"}`), nil
+	}
+	received := make(chan *types.ChatCompletionRequest, 1)
+	r.AddBackend(context.Background(), b)
+	r.AddBackend(context.Background(), shadowBackend(received, false))
+
+	rec := postChat(t, r, `{"model":"test-model","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+	if !strings.Contains(rec.Body.String(), "[DONE]") {
+		t.Fatalf("expected the full stream, got %s", rec.Body.String())
+	}
+	req := <-received
+	if req.Stream || req.StreamOptions != nil {
+		t.Errorf("expected the mirrored request not to stream, got %+v", req)
+	}
+}
+
+func TestShadow_OwnTimeout(t *testing.T) {
+	r, _ := NewRouter(WithShadowBackend("shadow", 1), WithShadowTimeout(20*time.Millisecond))
+	var calls atomic.Int64
+	received := make(chan *types.ChatCompletionRequest, 1)
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	shadow := shadowBackend(received, true)
+	done := make(chan error, 1)
+	chatFn := shadow.chatFn
+	shadow.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		_, err := chatFn(ctx, req)
+		done <- err
+		return nil, err
+	}
+	r.AddBackend(context.Background(), shadow)
+
+	start := time.Now()
+	if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("client waited %v for the shadow request", elapsed)
+	}
+	<-received
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the shadow request to time out, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shadow request ignored its timeout")
+	}
+}
+
+func TestShadow_SkipsShadowBackend(t *testing.T) {
+	r, _ := NewRouter(WithShadowBackend("shadow", 1))
+	received := make(chan *types.ChatCompletionRequest, 2)
+	r.AddBackend(context.Background(), shadowBackend(received, false))
+
+	// The shadow backend serving the request itself isn't mirrored to
+	postChat(t, r, `{"model":"candidate-model","messages":[{"role":"user","content":"hi"}]}`)
+	time.Sleep(20 * time.Millisecond)
+	if len(received) != 1 {
+		t.Errorf("expected only the real request, got %d", len(received))
+	}
+}
+
+func TestShadow_Model(t *testing.T) {
+	r, _ := NewRouter(WithShadowBackend("shadow", 1), WithShadowModel("candidate-model"))
+	var calls atomic.Int64
+	received := make(chan *types.ChatCompletionRequest, 1)
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	r.AddBackend(context.Background(), shadowBackend(received, false))
+
+	postChat(t, r, testChatBody)
+	select {
+	case req := <-received:
+		if req.Model != "candidate-model" {
+			t.Errorf("mirrored model = %q, want candidate-model", req.Model)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request was not mirrored")
+	}
+}
+
+func TestShadow_DropsWhenSaturated(t *testing.T) {
+	r, _ := NewRouter(WithShadowBackend("shadow", 1), WithShadowConcurrency(1))
+	var calls atomic.Int64
+	received := make(chan *types.ChatCompletionRequest, 3)
+	r.AddBackend(context.Background(), idBackend("backend-a", &calls))
+	shadow := shadowBackend(received, false)
+	release := make(chan struct{})
+	shadow.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		received <- req
+		<-release
+		return nil, errors.New("shadow backend failed")
+	}
+	r.AddBackend(context.Background(), shadow)
+
+	postChat(t, r, testChatBody)
+	<-received
+	for range 2 {
+		if rec := postChat(t, r, testChatBody); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(received); n != 0 {
+		t.Errorf("mirrored %d requests past the limit, want none", n)
+	}
+
+	// A freed slot mirrors again
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	postChat(t, r, testChatBody)
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("request was not mirrored after the slot was freed")
+	}
+}
+
+func TestShadow_Options(t *testing.T) {
+	for _, opt := range []Option{
+		WithShadowBackend("", 0.5),
+		WithShadowBackend("shadow", 0),
+		WithShadowBackend("shadow", 1.5),
+		WithShadowTimeout(0),
+		WithShadowModel(""),
+		WithShadowConcurrency(0),
+	} {
+		if _, err := NewRouter(opt); err == nil {
+			t.Error("expected an error")
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// ErrShutdownTimeout is returned by Stop when requests were still in flight
+// at the graceful shutdown deadline.
+var ErrShutdownTimeout = errors.New("timed out waiting for in-flight requests")
+
+// trackRequest registers an in-flight request for graceful shutdown. It
+// returns false once the router is draining.
+func (r *Router) trackRequest() (done func(), ok bool) {
+	r.drainMu.RLock()
+	defer r.drainMu.RUnlock()
+	if r.draining {
+		return nil, false
+	}
+	r.active.Add(1)
+	return r.active.Done, true
+}
+
+// serveTracked serves a request as part of the in-flight set, or rejects it
+// with a 503 if the router is shutting down.
+func (r *Router) serveTracked(w http.ResponseWriter, req *http.Request) {
+	done, ok := r.trackRequest()
+	if !ok {
+		w.Header().Set("Connection", "close")
+		types.WriteError(w, http.StatusServiceUnavailable, types.ServerError("router is shutting down"))
+		return
+	}
+	defer done()
+	r.handler.ServeHTTP(w, req)
+}
+
+// drainRequests rejects new requests and waits for in-flight ones to finish,
+// up to the graceful shutdown timeout. It returns ctx's error if ctx is done
+// first.
+func (r *Router) drainRequests(ctx context.Context) error {
+	// Taking the write lock orders every active.Add before the Wait below
+	r.drainMu.Lock()
+	r.draining = true
+	r.drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.active.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(r.gracefulTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrShutdownTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
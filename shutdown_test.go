@@ -0,0 +1,111 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// heldStreamBackend streams one chunk, signals started, and finishes the
+// stream when release is closed.
+func heldStreamBackend(started, release chan struct{}) *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		events := make(chan StreamEvent)
+		go func() {
+			defer close(events)
+			events <- StreamEvent{Data: `{"choices":[{"delta":{"content":"Hel"}}]}`}
+			close(started)
+			<-release
+			events <- StreamEvent{Data: `{"choices":[{"delta":{"content":"lo"}}]}`}
+			events <- StreamEvent{Data: "[DONE]", Done: true}
+		}()
+		return events, nil
+	}
+	return b
+}
+
+func TestGracefulShutdown_DrainsStreams(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r, _ := NewRouter(WithGracefulShutdown(5 * time.Second))
+	r.AddBackend(context.Background(), heldStreamBackend(started, release))
+
+	streamed := make(chan *httptest.ResponseRecorder)
+	go func() {
+		streamed <- postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	}()
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- r.Stop(context.Background()) }()
+
+	// Wait for Stop to begin draining, then check new requests are refused
+	deadline := time.Now().Add(time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("new requests still accepted during shutdown")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before the stream finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if rec := <-streamed; !strings.Contains(rec.Body.String(), "lo") || !strings.Contains(rec.Body.String(), "[DONE]") {
+		t.Errorf("stream was cut short: %q", rec.Body.String())
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() = %v, want nil", err)
+	}
+}
+
+func TestGracefulShutdown_Timeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	r, _ := NewRouter(WithGracefulShutdown(20 * time.Millisecond))
+	r.AddBackend(context.Background(), heldStreamBackend(started, release))
+
+	go postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	<-started
+
+	if err := r.Stop(context.Background()); !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("Stop() = %v, want ErrShutdownTimeout", err)
+	}
+}
+
+func TestGracefulShutdown_ContextDone(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	r, _ := NewRouter(WithGracefulShutdown(time.Minute))
+	r.AddBackend(context.Background(), heldStreamBackend(started, release))
+
+	go postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGracefulShutdown_InvalidTimeout(t *testing.T) {
+	if _, err := NewRouter(WithGracefulShutdown(0)); err == nil {
+		t.Error("expected error for zero timeout")
+	}
+}
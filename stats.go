@@ -0,0 +1,173 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RouterStats is a point-in-time snapshot of the router's request counters
+// and backend state, served at GET /v1/router/stats.
+type RouterStats struct {
+	StartTime     time.Time        `json:"start_time"`
+	UptimeSeconds float64          `json:"uptime_seconds"`
+	TotalRequests int64            `json:"total_requests"` // API requests received, including rejected ones
+	Errors        int64            `json:"errors"`         // Backend requests that failed
+	Models        map[string]int64 `json:"models"`         // Requests routed per model
+	Backends      []BackendStats   `json:"backends"`
+
+	// ShedRequests counts requests rejected with WithLoadShedding
+	ShedRequests int64 `json:"shed_requests,omitempty"`
+
+	// Throughput reports completion token rates per model, for requests
+	// whose token usage is known
+	Throughput map[string]ModelThroughput `json:"throughput,omitempty"`
+}
+
+// BackendStats reports the state and request counts of one backend.
+type BackendStats struct {
+	ID       string      `json:"id"`
+	Type     BackendType `json:"type"`
+	Healthy  bool        `json:"healthy"`
+	InFlight int64       `json:"in_flight"`
+	Requests int64       `json:"requests"`
+	Errors   int64       `json:"errors"`
+
+	// IncompleteToolCalls counts streams with a truncated or malformed tool
+	// call, with WithToolCallValidation
+	IncompleteToolCalls int64 `json:"incomplete_tool_calls,omitempty"`
+
+	// MissingFingerprints counts responses to seeded requests without a
+	// system_fingerprint, with WithSeedVerification
+	MissingFingerprints int64 `json:"missing_system_fingerprints,omitempty"`
+}
+
+// routerCounters are the internal counters behind Stats. They are always
+// maintained, so no metrics integration is needed to use them.
+type routerCounters struct {
+	start    time.Time
+	requests atomic.Int64
+	errors   atomic.Int64
+	shed     atomic.Int64
+	models   sync.Map // model -> *atomic.Int64
+	tokens   sync.Map // model -> *modelThroughput
+	backends sync.Map // backendID -> *backendCounters
+
+	// registered reports whether a backend is registered; removed backends
+	// aren't given counters again by requests that finish after removal
+	registered func(backendID string) bool
+}
+
+type backendCounters struct {
+	requests            atomic.Int64
+	errors              atomic.Int64
+	incompleteToolCalls atomic.Int64
+	missingFingerprints atomic.Int64
+}
+
+func newRouterCounters() *routerCounters {
+	return &routerCounters{start: time.Now()}
+}
+
+// countModel records a request routed for model. Only models that resolved
+// to a backend are counted, so clients can't grow the map with arbitrary IDs.
+func (c *routerCounters) countModel(model string) {
+	v, _ := c.models.LoadOrStore(model, new(atomic.Int64))
+	v.(*atomic.Int64).Add(1)
+}
+
+// backend returns a backend's counters, or nil if it isn't registered.
+func (c *routerCounters) backend(backendID string) *backendCounters {
+	if v, ok := c.backends.Load(backendID); ok {
+		return v.(*backendCounters)
+	}
+	if c.registered != nil && !c.registered(backendID) {
+		return nil
+	}
+	v, _ := c.backends.LoadOrStore(backendID, new(backendCounters))
+	return v.(*backendCounters)
+}
+
+// countBackend records the outcome of one request to a backend.
+func (c *routerCounters) countBackend(backendID string, err error) {
+	if err != nil {
+		c.errors.Add(1)
+	}
+	bc := c.backend(backendID)
+	if bc == nil {
+		return
+	}
+	bc.requests.Add(1)
+	if err != nil {
+		bc.errors.Add(1)
+	}
+}
+
+// forgetBackend drops a removed backend's counters, so backends coming and
+// going don't grow the map.
+func (c *routerCounters) forgetBackend(backendID string) {
+	c.backends.Delete(backendID)
+}
+
+// countIncompleteToolCalls records a stream from a backend whose tool calls
+// didn't arrive whole.
+func (c *routerCounters) countIncompleteToolCalls(backendID string) {
+	if bc := c.backend(backendID); bc != nil {
+		bc.incompleteToolCalls.Add(1)
+	}
+}
+
+// countMissingFingerprint records a response to a seeded request from a
+// backend that returned no system_fingerprint.
+func (c *routerCounters) countMissingFingerprint(backendID string) {
+	if bc := c.backend(backendID); bc != nil {
+		bc.missingFingerprints.Add(1)
+	}
+}
+
+// Stats returns a snapshot of request counts, per-backend health and load,
+// and uptime.
+func (r *Router) Stats() RouterStats {
+	c := r.counters
+	stats := RouterStats{
+		StartTime:     c.start,
+		UptimeSeconds: time.Since(c.start).Seconds(),
+		TotalRequests: c.requests.Load(),
+		Errors:        c.errors.Load(),
+		ShedRequests:  c.shed.Load(),
+		Models:        make(map[string]int64),
+	}
+
+	c.models.Range(func(k, v any) bool {
+		stats.Models[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	c.tokens.Range(func(k, v any) bool {
+		if stats.Throughput == nil {
+			stats.Throughput = make(map[string]ModelThroughput)
+		}
+		stats.Throughput[k.(string)] = v.(*modelThroughput).snapshot()
+		return true
+	})
+
+	infos := r.registry.Snapshot()
+	stats.Backends = make([]BackendStats, 0, len(infos))
+	for _, info := range infos {
+		bs := BackendStats{ID: info.ID, Type: info.Type, Healthy: info.Healthy, InFlight: info.InFlight}
+		if v, ok := c.backends.Load(info.ID); ok {
+			bc := v.(*backendCounters)
+			bs.Requests, bs.Errors = bc.requests.Load(), bc.errors.Load()
+			bs.IncompleteToolCalls = bc.incompleteToolCalls.Load()
+			bs.MissingFingerprints = bc.missingFingerprints.Load()
+		}
+		stats.Backends = append(stats.Backends, bs)
+	}
+	return stats
+}
+
+func (r *Router) handleStats(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Stats())
+}
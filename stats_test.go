@@ -0,0 +1,117 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestStats_CountsRequests(t *testing.T) {
+	a := newMockBackend("backend-a", true)
+	a.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return &types.ChatCompletionResponse{Model: req.Model}, nil
+	}
+	b := newMockBackend("backend-b", true)
+	b.models = []string{"other-model"}
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		return nil, errors.New("boom")
+	}
+
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), a)
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, testChatBody)
+	postChat(t, r, testChatBody)
+	postChat(t, r, `{"model":"other-model","messages":[{"role":"user","content":"hi"}]}`)
+	postChat(t, r, `{"model":"unknown-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	stats := r.Stats()
+	if stats.TotalRequests != 4 {
+		t.Errorf("TotalRequests = %d, want 4", stats.TotalRequests)
+	}
+	if stats.Errors != 1 {
+		t.Errorf("Errors = %d, want 1", stats.Errors)
+	}
+	if stats.Models["test-model"] != 2 || stats.Models["other-model"] != 1 {
+		t.Errorf("Models = %v", stats.Models)
+	}
+	if _, ok := stats.Models["unknown-model"]; ok {
+		t.Error("unrouted model was counted")
+	}
+
+	want := []BackendStats{
+		{ID: "backend-a", Type: BackendGeneric, Healthy: true, Requests: 2},
+		{ID: "backend-b", Type: BackendGeneric, Healthy: true, Requests: 1, Errors: 1},
+	}
+	if len(stats.Backends) != len(want) {
+		t.Fatalf("Backends = %+v", stats.Backends)
+	}
+	for i := range want {
+		if stats.Backends[i] != want[i] {
+			t.Errorf("Backends[%d] = %+v, want %+v", i, stats.Backends[i], want[i])
+		}
+	}
+	if stats.UptimeSeconds <= 0 {
+		t.Error("expected positive uptime")
+	}
+}
+
+func TestStats_Endpoint(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+	postChat(t, r, testChatBody)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/router/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var stats RouterStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 1 || stats.Models["test-model"] != 1 || len(stats.Backends) != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestStats_ForgetsRemovedBackends(t *testing.T) {
+	r := newTestRouter(t, []Backend{newMockBackend("backend-a", true)})
+	postChat(t, r, testChatBody)
+	if _, ok := r.counters.backends.Load("backend-a"); !ok {
+		t.Fatal("backend-a has no counters")
+	}
+
+	r.RemoveBackend("backend-a")
+	r.counters.countBackend("backend-a", errors.New("finished after removal"))
+	if _, ok := r.counters.backends.Load("backend-a"); ok {
+		t.Error("removed backend's counters kept")
+	}
+	if stats := r.Stats(); stats.Errors != 1 {
+		t.Errorf("errors = %d, want the late error counted in the total", stats.Errors)
+	}
+}
+
+func TestStats_Auth(t *testing.T) {
+	for _, tc := range []struct {
+		opts  []Option
+		token string
+		want  int
+	}{
+		{[]Option{WithAdmin("secret")}, "", http.StatusOK},
+		{[]Option{WithAdmin("secret"), WithStatsAuth(true)}, "", http.StatusUnauthorized},
+		{[]Option{WithAdmin("secret"), WithStatsAuth(true)}, "secret", http.StatusOK},
+	} {
+		r := newTestRouter(t, nil, tc.opts...)
+		if w := adminDo(r, http.MethodGet, "/v1/router/stats", "", tc.token); w.Code != tc.want {
+			t.Errorf("status = %d, want %d", w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// StreamAggregate is a streamed chat or completion response put back
+// together from its chunks, each parsed as the endpoint's chunk type.
+type StreamAggregate struct {
+	Operation Operation // OperationChat or OperationCompletions
+	BackendID string
+	ID        string
+	Model     string
+	Choices   []AggregateChoice // In the order they first appeared
+	Usage     *types.Usage      // As reported by the backend, nil if it sent none
+
+	Chunks        int  // Chunks that parsed
+	InvalidChunks int  // Chunks that didn't, which were still passed through
+	Complete      bool // The stream ended with [DONE] rather than an error or disconnect
+}
+
+// AggregateChoice is one choice of a StreamAggregate.
+type AggregateChoice struct {
+	Index        int
+	Text         string // Concatenated content or text deltas
+	FinishReason string
+}
+
+// StreamAggregateFunc receives the aggregate of a chat or completion stream
+// once it has ended.
+type StreamAggregateFunc func(req *http.Request, agg StreamAggregate)
+
+// addChunk records the identity and usage of a parsed chunk.
+func (a *StreamAggregate) addChunk(id, model string, usage *types.Usage) {
+	if a.ID == "" {
+		a.ID, a.Model = id, model
+	}
+	if usage != nil {
+		a.Usage = usage
+	}
+}
+
+// addChoice appends text to the choice with index.
+func (a *StreamAggregate) addChoice(index int, text string, finishReason *string) {
+	i := 0
+	for i < len(a.Choices) && a.Choices[i].Index != index {
+		i++
+	}
+	if i == len(a.Choices) {
+		a.Choices = append(a.Choices, AggregateChoice{Index: index})
+	}
+	a.Choices[i].Text += text
+	if finishReason != nil {
+		a.Choices[i].FinishReason = *finishReason
+	}
+}
+
+// aggregateChatChunk adds a chat.completion.chunk to agg.
+func aggregateChatChunk(agg *StreamAggregate, data string) error {
+	var chunk types.ChatCompletionChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return err
+	}
+	agg.addChunk(chunk.ID, chunk.Model, chunk.Usage)
+	for _, c := range chunk.Choices {
+		agg.addChoice(c.Index, c.Delta.Content, c.FinishReason)
+	}
+	return nil
+}
+
+// aggregateCompletionChunk adds a legacy text_completion chunk to agg.
+func aggregateCompletionChunk(agg *StreamAggregate, data string) error {
+	var chunk types.CompletionChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return err
+	}
+	agg.addChunk(chunk.ID, chunk.Model, chunk.Usage)
+	for _, c := range chunk.Choices {
+		agg.addChoice(c.Index, c.Text, c.FinishReason)
+	}
+	return nil
+}
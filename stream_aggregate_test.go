@@ -0,0 +1,83 @@
+package oairouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// aggregateRouter returns a router with b that records stream aggregates.
+func aggregateRouter(t *testing.T, b Backend) (*Router, *[]StreamAggregate) {
+	t.Helper()
+	var aggs []StreamAggregate
+	r := newTestRouter(t, []Backend{b}, WithStreamAggregation(func(req *http.Request, agg StreamAggregate) {
+		aggs = append(aggs, agg)
+	}))
+	return r, &aggs
+}
+
+func TestStreamAggregation_Chat(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"chat-1","model":"test-model","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}},{"index":1,"delta":{"content":"Bye"}}]}`,
+			`{"id":"chat-1","model":"test-model","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+			`not json`,
+			`{"id":"chat-1","model":"test-model","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+		), nil
+	}
+	r, aggs := aggregateRouter(t, b)
+
+	rec := postChat(t, r, streamChatBody)
+	if !strings.Contains(rec.Body.String(), "data: not json") {
+		t.Errorf("stream wasn't passed through unchanged:\n%s", rec.Body)
+	}
+	if len(*aggs) != 1 {
+		t.Fatalf("got %d aggregates, want 1", len(*aggs))
+	}
+	agg := (*aggs)[0]
+	if agg.Operation != OperationChat || agg.BackendID != "backend-a" || agg.ID != "chat-1" || agg.Model != "test-model" || !agg.Complete {
+		t.Errorf("aggregate = %+v", agg)
+	}
+	if agg.Chunks != 3 || agg.InvalidChunks != 1 {
+		t.Errorf("chunks = %d, invalid = %d, want 3 and 1", agg.Chunks, agg.InvalidChunks)
+	}
+	want := []AggregateChoice{{Index: 0, Text: "Hello", FinishReason: "stop"}, {Index: 1, Text: "Bye"}}
+	if len(agg.Choices) != 2 || agg.Choices[0] != want[0] || agg.Choices[1] != want[1] {
+		t.Errorf("choices = %+v, want %+v", agg.Choices, want)
+	}
+	if agg.Usage == nil || agg.Usage.TotalTokens != 5 {
+		t.Errorf("usage = %+v", agg.Usage)
+	}
+}
+
+func TestStreamAggregation_Completion(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.completionStreamFn = func(ctx context.Context, req *types.CompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"cmpl-1","object":"text_completion","model":"test-model","choices":[{"index":0,"text":"Once ","finish_reason":null}]}`,
+			`{"id":"cmpl-1","object":"text_completion","model":"test-model","choices":[{"index":0,"text":"upon","finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+		), nil
+	}
+	r, aggs := aggregateRouter(t, b)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(`{"model":"test-model","prompt":"hi","stream":true}`))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if len(*aggs) != 1 {
+		t.Fatalf("got %d aggregates, want 1", len(*aggs))
+	}
+	agg := (*aggs)[0]
+	if agg.Operation != OperationCompletions || agg.Chunks != 2 || agg.InvalidChunks != 0 {
+		t.Errorf("aggregate = %+v", agg)
+	}
+	if len(agg.Choices) != 1 || agg.Choices[0].Text != "Once upon" || agg.Choices[0].FinishReason != "length" {
+		t.Errorf("choices = %+v", agg.Choices)
+	}
+	if agg.Usage == nil || agg.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v", agg.Usage)
+	}
+}
@@ -1,46 +1,150 @@
 package streaming
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // Writer wraps an http.ResponseWriter for SSE streaming.
 type Writer struct {
 	w       http.ResponseWriter
-	flusher http.Flusher
+	flusher *http.ResponseController
+
+	// closeDelimited is set for HTTP/1.0 clients, which can't use chunked
+	// transfer encoding; the stream ends when the connection closes.
+	closeDelimited bool
+
+	// compress is set when the stream should be gzipped; gz is created by
+	// WriteHeaders and writes through to w.
+	compress bool
+	gz       *gzip.Writer
 }
 
 // NewWriter creates a new SSE writer.
-// Returns nil if the response writer doesn't support flushing.
+// Returns nil if the response writer doesn't support flushing. A writer
+// wrapped by middleware can be flushed if the wrapper implements
+// http.Flusher or has an Unwrap method returning one that can.
 func NewWriter(w http.ResponseWriter) *Writer {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if !canFlush(w) {
 		return nil
 	}
 
 	return &Writer{
 		w:       w,
-		flusher: flusher,
+		flusher: http.NewResponseController(w),
+	}
+}
+
+// canFlush reports whether w can be flushed through an
+// http.ResponseController: it, or a writer it unwraps to, implements
+// http.Flusher or FlushError.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// NewRequestWriter creates a new SSE writer whose headers suit the client's
+// HTTP version. Returns nil if the response writer doesn't support flushing.
+func NewRequestWriter(w http.ResponseWriter, req *http.Request) *Writer {
+	s := NewWriter(w)
+	if s != nil && !req.ProtoAtLeast(1, 1) {
+		s.closeDelimited = true
+	}
+	return s
+}
+
+// NewGzipRequestWriter is NewRequestWriter, except that the stream is gzipped
+// if the client's Accept-Encoding allows it. Each event is flushed as a
+// complete gzip block, so clients can decode it as soon as it arrives. Close
+// must be called at the end of the stream to finish the gzip data.
+func NewGzipRequestWriter(w http.ResponseWriter, req *http.Request) *Writer {
+	s := NewRequestWriter(w, req)
+	if s != nil && AcceptsGzip(req) {
+		s.compress = true
+	}
+	return s
+}
+
+// AcceptsGzip reports whether req's Accept-Encoding lists gzip with a
+// nonzero quality.
+func AcceptsGzip(req *http.Request) bool {
+	for _, header := range req.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "gzip" && name != "x-gzip" {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v > 0 {
+				return true
+			}
+		}
 	}
+	return false
 }
 
 // WriteHeaders sets the required SSE headers.
 func (s *Writer) WriteHeaders() {
 	s.w.Header().Set("Content-Type", "text/event-stream")
 	s.w.Header().Set("Cache-Control", "no-cache")
-	s.w.Header().Set("Connection", "keep-alive")
+	if s.closeDelimited {
+		// HTTP/1.0 has no chunked encoding: each event is flushed as it is
+		// written and closing the connection marks the end of the stream.
+		s.w.Header().Set("Connection", "close")
+	} else {
+		s.w.Header().Set("Connection", "keep-alive")
+	}
 	s.w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	if s.compress {
+		s.w.Header().Set("Content-Encoding", "gzip")
+		s.w.Header().Add("Vary", "Accept-Encoding")
+		s.w.Header().Del("Content-Length")
+		s.gz = gzip.NewWriter(s.w)
+	}
+}
+
+// out returns where event data is written.
+func (s *Writer) out() io.Writer {
+	if s.gz != nil {
+		return s.gz
+	}
+	return s.w
+}
+
+// flush sends everything written so far to the client, ending a gzip block
+// first if the stream is compressed.
+func (s *Writer) flush() error {
+	if s.gz != nil {
+		if err := s.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.flusher.Flush()
 }
 
 // WriteData writes a data line and flushes.
 func (s *Writer) WriteData(data string) error {
-	_, err := fmt.Fprintf(s.w, "data: %s\n\n", data)
+	_, err := fmt.Fprintf(s.out(), "data: %s\n\n", data)
 	if err != nil {
 		return err
 	}
-	s.flusher.Flush()
-	return nil
+	return s.flush()
 }
 
 // WriteDone writes the [DONE] terminator.
@@ -50,12 +154,11 @@ func (s *Writer) WriteDone() error {
 
 // WriteEvent writes a named event with data.
 func (s *Writer) WriteEvent(event, data string) error {
-	_, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	_, err := fmt.Fprintf(s.out(), "event: %s\ndata: %s\n\n", event, data)
 	if err != nil {
 		return err
 	}
-	s.flusher.Flush()
-	return nil
+	return s.flush()
 }
 
 // WriteError writes an error as an SSE event.
@@ -65,5 +168,16 @@ func (s *Writer) WriteError(errMsg string) error {
 
 // Flush manually flushes the response.
 func (s *Writer) Flush() {
+	s.flush()
+}
+
+// Close finishes a compressed stream by writing the gzip trailer. It does
+// nothing for uncompressed streams.
+func (s *Writer) Close() error {
+	if s.gz == nil {
+		return nil
+	}
+	err := s.gz.Close()
 	s.flusher.Flush()
+	return err
 }
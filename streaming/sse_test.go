@@ -0,0 +1,150 @@
+package streaming
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRequestWriter_ConnectionHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		proto      string
+		major      int
+		minor      int
+		connection string
+	}{
+		{"HTTP/1.1 keeps connection alive", "HTTP/1.1", 1, 1, "keep-alive"},
+		{"HTTP/1.0 closes connection", "HTTP/1.0", 1, 0, "close"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			req.Proto, req.ProtoMajor, req.ProtoMinor = tt.proto, tt.major, tt.minor
+
+			rec := httptest.NewRecorder()
+			s := NewRequestWriter(rec, req)
+			if s == nil {
+				t.Fatal("expected writer")
+			}
+			s.WriteHeaders()
+
+			if got := rec.Header().Get("Connection"); got != tt.connection {
+				t.Errorf("Connection = %q, want %q", got, tt.connection)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"GZIP", true},
+		{"gzip;q=0", false},
+		{"br, deflate", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Encoding", tt.header)
+		}
+		if got := AcceptsGzip(req); got != tt.want {
+			t.Errorf("AcceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipRequestWriter_FlushesCompleteEvents(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	s := NewGzipRequestWriter(rec, req)
+	s.WriteHeaders()
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+
+	// The first event must be decodable before the stream is closed
+	s.WriteData(`{"id":"1"}`)
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := make([]byte, len("data: {\"id\":\"1\"}\n\n"))
+	if _, err := io.ReadFull(zr, first); err != nil || string(first) != "data: {\"id\":\"1\"}\n\n" {
+		t.Fatalf("first event = %q, %v", first, err)
+	}
+
+	s.WriteDone()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, _ = gzip.NewReader(rec.Body)
+	all, err := io.ReadAll(zr)
+	if err != nil || string(all) != "data: {\"id\":\"1\"}\n\ndata: [DONE]\n\n" {
+		t.Errorf("stream = %q, %v", all, err)
+	}
+}
+
+func TestGzipRequestWriter_PlainWithoutAcceptEncoding(t *testing.T) {
+	rec := httptest.NewRecorder()
+	s := NewGzipRequestWriter(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	s.WriteHeaders()
+	s.WriteDone()
+	s.Close()
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "data: [DONE]\n\n" {
+		t.Errorf("expected an uncompressed stream, got %q", rec.Body.String())
+	}
+}
+
+// statusWriter is a logging middleware's wrapper: it captures the status
+// but doesn't implement http.Flusher itself.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// unwrappingStatusWriter is a statusWriter exposing the writer it wraps.
+type unwrappingStatusWriter struct {
+	statusWriter
+}
+
+func (w *unwrappingStatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestNewWriter_FlushesThroughUnwrap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &unwrappingStatusWriter{statusWriter{ResponseWriter: rec}}
+	s := NewWriter(w)
+	if s == nil {
+		t.Fatal("expected writer for a wrapper with Unwrap")
+	}
+	s.WriteHeaders()
+	if err := s.WriteData(`{"id":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Flushed {
+		t.Error("data wasn't flushed to the wrapped writer")
+	}
+}
+
+func TestNewWriter_NilWithoutFlusher(t *testing.T) {
+	if s := NewWriter(&statusWriter{ResponseWriter: httptest.NewRecorder()}); s != nil {
+		t.Error("expected nil for a wrapper that can't be flushed")
+	}
+}
@@ -0,0 +1,67 @@
+package oairouter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// throughputEWMAAlpha is the weight of each request's rate in a model's
+// moving tokens-per-second average.
+const throughputEWMAAlpha = 0.2
+
+// ModelThroughput reports how fast a model generates completion tokens. For
+// streams, generation time runs from the first chunk to the last; for other
+// requests, it is the whole request, including prompt processing.
+type ModelThroughput struct {
+	CompletionTokens int64   `json:"completion_tokens"` // Tokens generated by measured requests
+	Seconds          float64 `json:"seconds"`           // Time spent generating them
+	TokensPerSecond  float64 `json:"tokens_per_second"` // Moving average of recent requests' rates
+}
+
+// modelThroughput accumulates one model's throughput. It is updated without
+// locks, since it is recorded on every request.
+type modelThroughput struct {
+	tokens  atomic.Int64
+	elapsed atomic.Int64  // Nanoseconds
+	rate    atomic.Uint64 // Bits of the float64 moving average; 0 before the first sample
+}
+
+func (t *modelThroughput) record(tokens int, elapsed time.Duration) {
+	t.tokens.Add(int64(tokens))
+	t.elapsed.Add(int64(elapsed))
+
+	sample := float64(tokens) / elapsed.Seconds()
+	for {
+		old := t.rate.Load()
+		next := sample
+		if old != 0 {
+			avg := math.Float64frombits(old)
+			next = avg + throughputEWMAAlpha*(sample-avg)
+		}
+		if t.rate.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+func (t *modelThroughput) snapshot() ModelThroughput {
+	return ModelThroughput{
+		CompletionTokens: t.tokens.Load(),
+		Seconds:          time.Duration(t.elapsed.Load()).Seconds(),
+		TokensPerSecond:  math.Float64frombits(t.rate.Load()),
+	}
+}
+
+// recordThroughput records a request for model that generated tokens in
+// elapsed. Requests without both are skipped, since they carry no rate.
+func (c *routerCounters) recordThroughput(model string, tokens int, elapsed time.Duration) {
+	if tokens <= 0 || elapsed <= 0 {
+		return
+	}
+	v, ok := c.tokens.Load(model)
+	if !ok {
+		v, _ = c.tokens.LoadOrStore(model, new(modelThroughput))
+	}
+	v.(*modelThroughput).record(tokens, elapsed)
+}
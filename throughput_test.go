@@ -0,0 +1,77 @@
+package oairouter
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestThroughput_NonStreaming(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		time.Sleep(10 * time.Millisecond)
+		return &types.ChatCompletionResponse{ID: "chat", Usage: &types.Usage{PromptTokens: 5, CompletionTokens: 50, TotalTokens: 55}}, nil
+	}
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, testChatBody)
+	got := r.Stats().Throughput["test-model"]
+	if got.CompletionTokens != 50 || got.Seconds < 0.01 {
+		t.Fatalf("throughput = %+v, want 50 tokens over at least 10ms", got)
+	}
+	if want := 50 / got.Seconds; math.Abs(got.TokensPerSecond-want) > 1e-6 {
+		t.Errorf("TokensPerSecond = %f, want %f", got.TokensPerSecond, want)
+	}
+}
+
+func TestThroughput_StreamingFromFirstToLastChunk(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		events := make(chan StreamEvent)
+		go func() {
+			defer close(events)
+			// Time before the first chunk isn't generation time
+			time.Sleep(50 * time.Millisecond)
+			events <- StreamEvent{Data: `{"choices":[{"delta":{"content":"Hel"}}]}`}
+			time.Sleep(10 * time.Millisecond)
+			events <- StreamEvent{Data: `{"choices":[{"delta":{"content":"lo"}}]}`}
+			events <- StreamEvent{Data: `{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`}
+			events <- StreamEvent{Data: "[DONE]", Done: true}
+		}()
+		return events, nil
+	}
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true}`)
+	got := r.Stats().Throughput["test-model"]
+	if got.CompletionTokens != 2 || got.Seconds < 0.01 || got.Seconds >= 0.05 {
+		t.Errorf("throughput = %+v, want 2 tokens over 10-50ms", got)
+	}
+}
+
+func TestThroughput_MovingAverage(t *testing.T) {
+	var tp modelThroughput
+	tp.record(100, time.Second)
+	tp.record(200, time.Second)
+	got := tp.snapshot()
+	if got.CompletionTokens != 300 || got.Seconds != 2 {
+		t.Errorf("totals = %+v", got)
+	}
+	if want := 100 + throughputEWMAAlpha*100; math.Abs(got.TokensPerSecond-want) > 1e-9 {
+		t.Errorf("TokensPerSecond = %f, want %f", got.TokensPerSecond, want)
+	}
+}
+
+func TestThroughput_SkipsUnknownUsage(t *testing.T) {
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), newMockBackend("backend-a", true))
+	postChat(t, r, testChatBody)
+	if tp := r.Stats().Throughput; tp != nil {
+		t.Errorf("Throughput = %+v, want none without usage", tp)
+	}
+}
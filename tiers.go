@@ -0,0 +1,62 @@
+package oairouter
+
+// backendTier returns b's routing tier, 0 unless b implements Tiered.
+func backendTier(b Backend) int {
+	if t, ok := b.(Tiered); ok {
+		return t.Tier()
+	}
+	return 0
+}
+
+// saturated reports whether b is serving as many requests as its
+// concurrency limit allows, or is rate limited after answering 429.
+func (r *BackendRegistry) saturated(b Backend) bool {
+	return r.rateLimited(b.ID()) || r.atLimit(b)
+}
+
+// atLimit reports whether b is serving as many requests as its concurrency
+// limit allows. Unlike saturated it ignores rate limits: the request queue
+// waits only for slots, which are released as requests finish, and a request
+// to a rate-limited backend is forwarded its 429.
+func (r *BackendRegistry) atLimit(b Backend) bool {
+	limiter, ok := b.(ConcurrencyLimiter)
+	if !ok {
+		return false
+	}
+	limit := limiter.MaxConcurrency()
+	return limit > 0 && r.InFlight(b.ID()) >= int64(limit)
+}
+
+// preferTier narrows healthy backends to those a lookup should choose from:
+// the lowest tier's backends below their concurrency limit, or if every
+// backend is at its limit, the lowest tier's. Order is preserved.
+func (r *BackendRegistry) preferTier(healthy []Backend) []Backend {
+	if len(healthy) < 2 {
+		return healthy
+	}
+
+	bestTier, busyTier := 0, 0
+	haveFree, haveBusy := false, false
+	for _, b := range healthy {
+		tier := backendTier(b)
+		if r.saturated(b) {
+			if !haveBusy || tier < busyTier {
+				busyTier, haveBusy = tier, true
+			}
+		} else if !haveFree || tier < bestTier {
+			bestTier, haveFree = tier, true
+		}
+	}
+	wantFree := haveFree
+	if !haveFree {
+		bestTier = busyTier
+	}
+
+	var preferred []Backend
+	for _, b := range healthy {
+		if backendTier(b) == bestTier && (!wantFree || !r.saturated(b)) {
+			preferred = append(preferred, b)
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,132 @@
+package oairouter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// tieredBackend is a mockBackend with a tier and concurrency limit.
+type tieredBackend struct {
+	*mockBackend
+	tier, limit int
+}
+
+func (b *tieredBackend) Tier() int           { return b.tier }
+func (b *tieredBackend) MaxConcurrency() int { return b.limit }
+
+func newTieredBackend(id string, tier, limit int) *tieredBackend {
+	return &tieredBackend{mockBackend: newMockBackend(id, true), tier: tier, limit: limit}
+}
+
+func TestLookupByModel_PrefersLowestTier(t *testing.T) {
+	reg := NewBackendRegistry()
+	overflow := newTieredBackend("overflow", 1, 0)
+	primary := newTieredBackend("primary", 0, 1)
+	reg.Register(context.Background(), overflow)
+	reg.Register(context.Background(), primary)
+
+	lookup := func() string {
+		b, ok := reg.LookupByModel("test-model")
+		if !ok {
+			t.Fatal("no backend")
+		}
+		return b.ID()
+	}
+
+	if got := lookup(); got != "primary" {
+		t.Errorf("expected tier 0 despite registration order, got %s", got)
+	}
+
+	release := reg.Acquire("primary")
+	if got := lookup(); got != "overflow" {
+		t.Errorf("expected tier 1 while tier 0 is at its limit, got %s", got)
+	}
+	release()
+	if got := lookup(); got != "primary" {
+		t.Errorf("expected tier 0 once it has capacity, got %s", got)
+	}
+
+	primary.healthy.Store(false)
+	if got := lookup(); got != "overflow" {
+		t.Errorf("expected tier 1 while tier 0 is unhealthy, got %s", got)
+	}
+	primary.healthy.Store(true)
+
+	// Every backend at its limit: the preferred tier is used anyway
+	overflow.limit = 1
+	defer reg.Acquire("primary")()
+	defer reg.Acquire("overflow")()
+	if got := lookup(); got != "primary" {
+		t.Errorf("expected tier 0 when all are at their limits, got %s", got)
+	}
+}
+
+func TestLookupByModelWithSession_PrefersLowestTier(t *testing.T) {
+	reg := NewBackendRegistry()
+	overflow := newTieredBackend("overflow", 1, 0)
+	primaryA := newTieredBackend("primary-a", 0, 1)
+	primaryB := newTieredBackend("primary-b", 0, 1)
+	for _, b := range []Backend{overflow, primaryA, primaryB} {
+		reg.Register(context.Background(), b)
+	}
+
+	lookup := func(session string) LookupResult {
+		t.Helper()
+		result, ok := reg.LookupByModelWithSession("test-model", session)
+		if !ok {
+			t.Fatal("no backend")
+		}
+		return result
+	}
+
+	if got := lookup("").Backend.ID(); got != "primary-a" && got != "primary-b" {
+		t.Errorf("sessionless request went to %s, want a tier 0 backend", got)
+	}
+
+	homes := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		result := lookup(session)
+		if backendTier(result.Backend) != 0 || result.SessionBroken {
+			t.Fatalf("%s went to %s (broken=%v), want its tier 0 home", session, result.Backend.ID(), result.SessionBroken)
+		}
+		homes[session] = result.Backend.ID()
+	}
+
+	// A busy home hands its sessions to the other tier 0 backend, not the
+	// overflow, and the rest stay put
+	release := reg.Acquire("primary-a")
+	for session, home := range homes {
+		result := lookup(session)
+		if result.Backend.ID() != "primary-b" || result.SessionBroken != (home == "primary-a") {
+			t.Errorf("%s (home %s) went to %s (broken=%v) while primary-a is busy", session, home, result.Backend.ID(), result.SessionBroken)
+		}
+	}
+
+	// With all of tier 0 busy, the overflow takes over
+	defer reg.Acquire("primary-b")()
+	if result := lookup("session-0"); result.Backend.ID() != "overflow" || !result.SessionBroken {
+		t.Errorf("with tier 0 busy, went to %s (broken=%v), want overflow", result.Backend.ID(), result.SessionBroken)
+	}
+	release()
+}
+
+func TestRoutingPolicy_StaysInPreferredTier(t *testing.T) {
+	r, _ := NewRouter(WithRoutingPolicy(NewRoundRobin()))
+	r.AddBackend(context.Background(), newTieredBackend("primary-a", 0, 0))
+	r.AddBackend(context.Background(), newTieredBackend("primary-b", 0, 0))
+	r.AddBackend(context.Background(), newTieredBackend("overflow", 1, 0))
+
+	for range 10 {
+		b, _ := r.lookupByPolicy("test-model", OperationChat, false)
+		if b.ID() == "overflow" {
+			t.Fatal("round robin reached tier 1 while tier 0 was available")
+		}
+	}
+
+	info, _ := r.registry.BackendInfo("overflow")
+	if info.Tier != 1 {
+		t.Errorf("BackendInfo.Tier = %d, want 1", info.Tier)
+	}
+}
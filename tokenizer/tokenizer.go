@@ -0,0 +1,101 @@
+// Package tokenizer estimates token counts for text without a model-specific
+// vocabulary. Estimates are intended for usage reporting when a backend
+// doesn't provide counts, not for enforcing context limits.
+package tokenizer
+
+import (
+	"unicode"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// charsPerToken approximates how many word characters a BPE token covers.
+const charsPerToken = 4
+
+// Per-message overheads used by OpenAI's chat format: each message carries
+// role/separator tokens, and every reply is primed with a few more.
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// Count estimates the number of tokens in text. Runs of letters and digits
+// count one token per four characters (rounded up), each punctuation or
+// symbol character counts as one token, and whitespace is free.
+func Count(text string) int {
+	tokens := 0
+	run := 0
+	flush := func() {
+		tokens += (run + charsPerToken - 1) / charsPerToken
+		run = 0
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			run++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// CountMessages estimates the prompt tokens for a chat conversation,
+// including the per-message formatting overhead.
+func CountMessages(messages []types.ChatMessage) int {
+	tokens := tokensPerReply
+	for _, m := range messages {
+		tokens += tokensPerMessage + Count(m.Role) + Count(m.Name) + countContent(m.Content)
+	}
+	return tokens
+}
+
+// CountPrompt estimates the tokens in a legacy completion prompt, which may be
+// a string, a list of strings, or pre-tokenized integer arrays. Token arrays
+// are counted exactly. Malformed prompts count as zero.
+func CountPrompt(prompt any) int {
+	p, err := types.NormalizePrompt(prompt)
+	if err != nil {
+		return 0
+	}
+
+	tokens := 0
+	for _, s := range p.Text {
+		tokens += Count(s)
+	}
+	for _, ids := range p.Tokens {
+		tokens += len(ids)
+	}
+	return tokens
+}
+
+// countContent counts string content or the text parts of multi-modal content.
+func countContent(content any) int {
+	switch c := content.(type) {
+	case string:
+		return Count(c)
+	case []any:
+		tokens := 0
+		for _, part := range c {
+			if m, ok := part.(map[string]any); ok {
+				if text, ok := m["text"].(string); ok {
+					tokens += Count(text)
+				}
+			}
+		}
+		return tokens
+	case []types.ContentPart:
+		tokens := 0
+		for _, part := range c {
+			tokens += Count(part.Text)
+		}
+		return tokens
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hi", 1},
+		{"Hello, world!", 6},       // Hello(2) ,(1) world(2) !(1)
+		{"The quick brown fox", 6}, // The(1) quick(2) brown(2) fox(1)
+		{"   spaced   out   ", 3},  // spaced(2) out(1)
+		{"a1b2c3d4", 2},            // one 8-character run
+		{"naïve café", 3},          // unicode letters count as word characters
+	}
+
+	for _, tt := range tests {
+		if got := Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	messages := []types.ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello there"},
+	}
+
+	// reply(3) + system: 4 + role(2) + "Be brief."(1+2+1)
+	//          + user:   4 + role(1) + "Hello there"(2+2)
+	want := 3 + (4 + 2 + 4) + (4 + 1 + 4)
+	if got := CountMessages(messages); got != want {
+		t.Errorf("CountMessages = %d, want %d", got, want)
+	}
+}
+
+func TestCountMessages_MultiModalContent(t *testing.T) {
+	var msg types.ChatMessage
+	json.Unmarshal([]byte(`{"role":"user","content":[{"type":"text","text":"describe this"},{"type":"image_url","image_url":{"url":"http://x"}}]}`), &msg)
+
+	// reply(3) + 4 + role(1) + "describe this"(2+1)
+	if got := CountMessages([]types.ChatMessage{msg}); got != 11 {
+		t.Errorf("CountMessages = %d, want 11", got)
+	}
+}
+
+func TestCountPrompt(t *testing.T) {
+	var tokenIDs any
+	json.Unmarshal([]byte(`[101, 2023, 2003]`), &tokenIDs)
+	var batch any
+	json.Unmarshal([]byte(`["one two", "three"]`), &batch)
+
+	tests := []struct {
+		name   string
+		prompt any
+		want   int
+	}{
+		{"string", "one two", 2},
+		{"token ids", tokenIDs, 3},
+		{"string batch", batch, 4},
+		{"nil", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := CountPrompt(tt.prompt); got != tt.want {
+			t.Errorf("%s: CountPrompt = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// toolCallValidator reassembles the tool calls in a chat stream's deltas to
+// check that each one arrives whole: with an ID and function name, and with
+// arguments that add up to valid JSON. It only reads the stream.
+type toolCallValidator struct {
+	calls map[toolCallKey]*toolCallState
+}
+
+// toolCallKey identifies a tool call by its choice and position.
+type toolCallKey struct {
+	choice, index int
+}
+
+type toolCallState struct {
+	id, name string
+	args     strings.Builder
+}
+
+func newToolCallValidator() *toolCallValidator {
+	return &toolCallValidator{calls: make(map[toolCallKey]*toolCallState)}
+}
+
+// observe records the tool call deltas in one SSE data payload. Payloads
+// that aren't chat chunks are ignored.
+func (v *toolCallValidator) observe(data string) {
+	if !strings.Contains(data, `"tool_calls"`) {
+		return
+	}
+	var chunk types.ChatCompletionChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	for _, choice := range chunk.Choices {
+		for i, call := range choice.Delta.ToolCalls {
+			// Deltas carry their index; a backend that omits it sends each
+			// call whole, in order
+			key := toolCallKey{choice: choice.Index, index: i}
+			if call.Index != nil {
+				key.index = *call.Index
+			}
+			state, ok := v.calls[key]
+			if !ok {
+				state = new(toolCallState)
+				v.calls[key] = state
+			}
+			if call.ID != "" {
+				state.id = call.ID
+			}
+			if call.Function.Name != "" {
+				state.name = call.Function.Name
+			}
+			state.args.WriteString(call.Function.Arguments)
+		}
+	}
+}
+
+// problems describes each tool call that didn't arrive whole, in choice and
+// index order. It is empty if all were complete, or the stream had none.
+func (v *toolCallValidator) problems() []string {
+	keys := make([]toolCallKey, 0, len(v.calls))
+	for key := range v.calls {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].choice != keys[j].choice {
+			return keys[i].choice < keys[j].choice
+		}
+		return keys[i].index < keys[j].index
+	})
+
+	var problems []string
+	for _, key := range keys {
+		state := v.calls[key]
+		var missing []string
+		if state.id == "" {
+			missing = append(missing, "no id")
+		}
+		if state.name == "" {
+			missing = append(missing, "no function name")
+		}
+		if !json.Valid([]byte(state.args.String())) {
+			missing = append(missing, "arguments are not valid JSON")
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("choices[%d].tool_calls[%d]: %s", key.choice, key.index, strings.Join(missing, ", ")))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,63 @@
+package oairouter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestToolCallValidator(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		problems int
+	}{
+		{"arguments split across deltas", []string{
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		}, 0},
+		{"truncated arguments", []string{
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		}, 1},
+		{"missing name", []string{
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"arguments":"{}"}}]}}]}`,
+		}, 1},
+		{"two calls, one bad", []string{
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"a","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"b","arguments":"{"}}]}}]}`,
+		}, 1},
+		{"no tool calls", []string{`{"choices":[{"index":0,"delta":{"content":"hi"}}]}`}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newToolCallValidator()
+			for _, chunk := range tt.chunks {
+				v.observe(chunk)
+			}
+			if got := v.problems(); len(got) != tt.problems {
+				t.Errorf("problems() = %q, want %d", got, tt.problems)
+			}
+		})
+	}
+}
+
+func TestToolCallValidation_CountsIncompleteStreams(t *testing.T) {
+	truncated := `{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(truncated), nil
+	}
+	r, _ := NewRouter(WithToolCallValidation(true))
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, `{"model":"test-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if !strings.Contains(rec.Body.String(), "data: "+truncated+"\n\n") {
+		t.Errorf("expected the chunk passed through unchanged, got %s", rec.Body.String())
+	}
+	if got := r.Stats().Backends[0].IncompleteToolCalls; got != 1 {
+		t.Errorf("IncompleteToolCalls = %d, want 1", got)
+	}
+}
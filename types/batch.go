@@ -0,0 +1,78 @@
+package types
+
+import "encoding/json"
+
+// Batch statuses, as reported by the OpenAI batch API.
+const (
+	BatchStatusInProgress = "in_progress"
+	BatchStatusCompleted  = "completed"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// BatchRequest creates a batch job. The router has no files API, so the
+// requests that would be uploaded as the input file are listed inline.
+type BatchRequest struct {
+	InputFileID      string             `json:"input_file_id,omitempty"` // Not supported; use Requests
+	Endpoint         string             `json:"endpoint"`                // e.g. /v1/chat/completions
+	CompletionWindow string             `json:"completion_window,omitempty"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	Requests         []BatchRequestItem `json:"requests"`
+}
+
+// BatchRequestItem is one request of a batch, in the shape of a line of an
+// OpenAI batch input file.
+type BatchRequestItem struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method,omitempty"` // POST if empty
+	URL      string          `json:"url,omitempty"`    // The batch's endpoint if empty
+	Body     json.RawMessage `json:"body"`
+}
+
+// Batch is the state of a batch job. Results holds the outcome of each
+// finished request, in place of an output file.
+type Batch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"` // Always "batch"
+	Endpoint         string             `json:"endpoint"`
+	CompletionWindow string             `json:"completion_window,omitempty"`
+	Status           string             `json:"status"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     int64              `json:"in_progress_at,omitempty"`
+	CompletedAt      int64              `json:"completed_at,omitempty"`
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	Results          []BatchResult      `json:"results,omitempty"`
+}
+
+// BatchRequestCounts counts a batch's requests by outcome.
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// BatchResult is the outcome of one batch request, in the shape of a line of
+// an OpenAI batch output file. Response is set when the request was served,
+// even with an error status; Error is set when it couldn't be.
+type BatchResult struct {
+	ID       string         `json:"id"`
+	CustomID string         `json:"custom_id"`
+	Response *BatchResponse `json:"response"`
+	Error    *BatchError    `json:"error"`
+}
+
+// BatchResponse is the response to one batch request.
+type BatchResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchError describes a batch request that couldn't be served.
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -1,24 +1,54 @@
 package types
 
+import "encoding/json"
+
 // ChatCompletionRequest represents an OpenAI chat completion request.
 type ChatCompletionRequest struct {
-	Model            string           `json:"model"`
-	Messages         []ChatMessage    `json:"messages"`
-	Temperature      *float64         `json:"temperature,omitempty"`
-	TopP             *float64         `json:"top_p,omitempty"`
-	N                *int             `json:"n,omitempty"`
-	Stream           bool             `json:"stream,omitempty"`
-	StreamOptions    *StreamOptions   `json:"stream_options,omitempty"`
-	Stop             []string         `json:"stop,omitempty"`
-	MaxTokens        *int             `json:"max_tokens,omitempty"`
-	PresencePenalty  *float64         `json:"presence_penalty,omitempty"`
-	FrequencyPenalty *float64         `json:"frequency_penalty,omitempty"`
-	LogitBias        map[string]int   `json:"logit_bias,omitempty"`
-	User             string           `json:"user,omitempty"`
-	Seed             *int             `json:"seed,omitempty"`
-	Tools            []Tool           `json:"tools,omitempty"`
-	ToolChoice       any              `json:"tool_choice,omitempty"`
-	ResponseFormat   *ResponseFormat  `json:"response_format,omitempty"`
+	Model               string          `json:"model"`
+	Messages            []ChatMessage   `json:"messages"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	N                   *int            `json:"n,omitempty"`
+	Stream              bool            `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
+	Stop                StopSequences   `json:"stop,omitempty"`
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+	PresencePenalty     *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64        `json:"frequency_penalty,omitempty"`
+	LogitBias           map[string]int  `json:"logit_bias,omitempty"`
+	Logprobs            *bool           `json:"logprobs,omitempty"`
+	TopLogprobs         *int            `json:"top_logprobs,omitempty"`
+	User                string          `json:"user,omitempty"`
+	Seed                *int            `json:"seed,omitempty"`
+	Tools               []Tool          `json:"tools,omitempty"`
+	ToolChoice          any             `json:"tool_choice,omitempty"`
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+
+	// Functions and FunctionCall are the deprecated forms of Tools and
+	// ToolChoice, still sent by older clients
+	Functions    []ToolFunction `json:"functions,omitempty"`
+	FunctionCall any            `json:"function_call,omitempty"` // "none", "auto", or {"name": ...}
+
+	// Extra holds request members without a field above, such as vendor
+	// parameters like repetition_penalty. They are encoded back into the
+	// request, so they reach the backend.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// chatCompletionRequest is ChatCompletionRequest without its JSON methods.
+type chatCompletionRequest ChatCompletionRequest
+
+// UnmarshalJSON decodes the request, collecting unknown members in Extra.
+func (r *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
+	extra, err := unmarshalExtra(data, (*chatCompletionRequest)(r))
+	r.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the request with the members of Extra.
+func (r ChatCompletionRequest) MarshalJSON() ([]byte, error) {
+	return marshalExtra(chatCompletionRequest(r), r.Extra)
 }
 
 // StreamOptions configures streaming behavior.
@@ -28,11 +58,14 @@ type StreamOptions struct {
 
 // ChatMessage represents a message in a chat conversation.
 type ChatMessage struct {
-	Role       string     `json:"role"` // system, user, assistant, tool
+	Role       string     `json:"role"`    // system, user, assistant, tool
 	Content    any        `json:"content"` // string or []ContentPart
 	Name       string     `json:"name,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// FunctionCall is the deprecated form of ToolCalls, for a single call
+	FunctionCall *ToolCallFunction `json:"function_call,omitempty"`
 }
 
 // ContentPart represents a part of multi-modal content.
@@ -63,6 +96,7 @@ type ToolFunction struct {
 
 // ToolCall represents a tool call made by the model.
 type ToolCall struct {
+	Index    *int             `json:"index,omitempty"` // Position among the tool calls; set in stream deltas
 	ID       string           `json:"id"`
 	Type     string           `json:"type"` // function
 	Function ToolCallFunction `json:"function"`
@@ -93,9 +127,10 @@ type ChatCompletionResponse struct {
 
 // Choice represents a completion choice.
 type Choice struct {
-	Index        int         `json:"index"`
-	Message      ChatMessage `json:"message"`
-	FinishReason string      `json:"finish_reason"` // stop, length, tool_calls, content_filter
+	Index        int           `json:"index"`
+	Message      ChatMessage   `json:"message"`
+	Logprobs     *ChatLogprobs `json:"logprobs,omitempty"` // Set when the request asked for logprobs
+	FinishReason string        `json:"finish_reason"`      // stop, length, tool_calls, function_call, content_filter
 }
 
 // ChatCompletionChunk represents a streaming chunk.
@@ -111,14 +146,38 @@ type ChatCompletionChunk struct {
 
 // ChunkChoice represents a streaming choice.
 type ChunkChoice struct {
-	Index        int       `json:"index"`
-	Delta        ChatDelta `json:"delta"`
-	FinishReason *string   `json:"finish_reason"` // null until done
+	Index        int           `json:"index"`
+	Delta        ChatDelta     `json:"delta"`
+	Logprobs     *ChatLogprobs `json:"logprobs,omitempty"` // For the chunk's tokens
+	FinishReason *string       `json:"finish_reason"`      // null until done
+}
+
+// ChatLogprobs holds the log probabilities of a chat choice's tokens.
+type ChatLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+	Refusal []TokenLogprob `json:"refusal,omitempty"`
+}
+
+// TokenLogprob is the log probability of an output token, with the most
+// likely alternatives when top_logprobs was requested.
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"` // UTF-8 bytes of the token, null if it has none
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// TopLogprob is one of the most likely tokens at a position.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
 }
 
 // ChatDelta represents the delta content in a streaming chunk.
 type ChatDelta struct {
-	Role      string     `json:"role,omitempty"`
-	Content   string     `json:"content,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role         string            `json:"role,omitempty"`
+	Content      string            `json:"content,omitempty"`
+	ToolCalls    []ToolCall        `json:"tool_calls,omitempty"`
+	FunctionCall *ToolCallFunction `json:"function_call,omitempty"` // Deprecated form of ToolCalls
 }
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChatLogprobs_RoundTrip(t *testing.T) {
+	for _, body := range []string{
+		`{"id":"c","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},` +
+			`"logprobs":{"content":[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.1,"bytes":[72,105]},{"token":"Hey","logprob":-2.5,"bytes":null}]}]},` +
+			`"finish_reason":"stop"}]}`,
+		`{"id":"c","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"Hi"},` +
+			`"logprobs":{"content":[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[]}]},"finish_reason":null}]}`,
+	} {
+		var v any = &ChatCompletionResponse{}
+		if strings.Contains(body, "chunk") {
+			v = &ChatCompletionChunk{}
+		}
+		if err := json.Unmarshal([]byte(body), v); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != body {
+			t.Errorf("round trip changed the body:\n got %s\nwant %s", out, body)
+		}
+	}
+}
+
+func TestChatLogprobs_OmittedWhenUnset(t *testing.T) {
+	out, _ := json.Marshal(ChatCompletionRequest{Model: "m", Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
+	if strings.Contains(string(out), "logprobs") {
+		t.Errorf("request without logprobs marshals %s", out)
+	}
+	out, _ = json.Marshal(ChatCompletionResponse{Choices: []Choice{{Message: ChatMessage{Role: "assistant"}}}})
+	if strings.Contains(string(out), "logprobs") {
+		t.Errorf("response without logprobs marshals %s", out)
+	}
+}
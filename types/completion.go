@@ -1,15 +1,17 @@
 package types
 
+import "encoding/json"
+
 // CompletionRequest represents an OpenAI legacy completion request.
 type CompletionRequest struct {
 	Model            string   `json:"model"`
-	Prompt           any      `json:"prompt"` // string or []string
+	Prompt           any      `json:"prompt"` // string, []string, or token IDs; see NormalizePrompt
 	MaxTokens        *int     `json:"max_tokens,omitempty"`
 	Temperature      *float64 `json:"temperature,omitempty"`
 	TopP             *float64 `json:"top_p,omitempty"`
 	N                *int     `json:"n,omitempty"`
 	Stream           bool     `json:"stream,omitempty"`
-	Stop             []string `json:"stop,omitempty"`
+	Stop             StopSequences `json:"stop,omitempty"`
 	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
 	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
 	LogitBias        map[string]int `json:"logit_bias,omitempty"`
@@ -18,6 +20,25 @@ type CompletionRequest struct {
 	Echo             bool     `json:"echo,omitempty"`
 	BestOf           *int     `json:"best_of,omitempty"`
 	Logprobs         *int     `json:"logprobs,omitempty"`
+
+	// Extra holds request members without a field above; see
+	// ChatCompletionRequest.Extra.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// completionRequest is CompletionRequest without its JSON methods.
+type completionRequest CompletionRequest
+
+// UnmarshalJSON decodes the request, collecting unknown members in Extra.
+func (r *CompletionRequest) UnmarshalJSON(data []byte) error {
+	extra, err := unmarshalExtra(data, (*completionRequest)(r))
+	r.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the request with the members of Extra.
+func (r CompletionRequest) MarshalJSON() ([]byte, error) {
+	return marshalExtra(completionRequest(r), r.Extra)
 }
 
 // CompletionResponse represents an OpenAI legacy completion response.
@@ -55,6 +76,7 @@ type CompletionChunk struct {
 	Model             string                  `json:"model"`
 	SystemFingerprint string                  `json:"system_fingerprint,omitempty"`
 	Choices           []CompletionChunkChoice `json:"choices"`
+	Usage             *Usage                  `json:"usage,omitempty"` // Only in final chunk if requested
 }
 
 // CompletionChunkChoice represents a streaming completion choice.
@@ -1,5 +1,13 @@
 package types
 
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
 // EmbeddingsRequest represents an OpenAI embeddings request.
 type EmbeddingsRequest struct {
 	Model          string `json:"model"`
@@ -7,6 +15,25 @@ type EmbeddingsRequest struct {
 	EncodingFormat string `json:"encoding_format,omitempty"` // float or base64
 	Dimensions     *int   `json:"dimensions,omitempty"`
 	User           string `json:"user,omitempty"`
+
+	// Extra holds request members without a field above; see
+	// ChatCompletionRequest.Extra.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// embeddingsRequest is EmbeddingsRequest without its JSON methods.
+type embeddingsRequest EmbeddingsRequest
+
+// UnmarshalJSON decodes the request, collecting unknown members in Extra.
+func (r *EmbeddingsRequest) UnmarshalJSON(data []byte) error {
+	extra, err := unmarshalExtra(data, (*embeddingsRequest)(r))
+	r.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the request with the members of Extra.
+func (r EmbeddingsRequest) MarshalJSON() ([]byte, error) {
+	return marshalExtra(embeddingsRequest(r), r.Extra)
 }
 
 // EmbeddingsResponse represents an OpenAI embeddings response.
@@ -22,4 +49,84 @@ type EmbeddingData struct {
 	Object    string    `json:"object"` // embedding
 	Embedding []float64 `json:"embedding"`
 	Index     int       `json:"index"`
+
+	// Base64 is set when Embedding is encoded as a base64 string, as
+	// requested with encoding_format base64. Either form decodes into
+	// Embedding.
+	Base64 bool `json:"-"`
+}
+
+// embeddingData is EmbeddingData with the embedding in either form.
+type embeddingData struct {
+	Object    string          `json:"object"`
+	Embedding json.RawMessage `json:"embedding"`
+	Index     int             `json:"index"`
+}
+
+// UnmarshalJSON decodes an embedding given as an array of numbers or as a
+// base64 string, setting Base64 for the latter.
+func (d *EmbeddingData) UnmarshalJSON(data []byte) error {
+	var raw embeddingData
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	d.Object, d.Index, d.Base64 = raw.Object, raw.Index, false
+	d.Embedding = nil
+
+	if len(raw.Embedding) == 0 {
+		return nil
+	}
+	if raw.Embedding[0] != '"' {
+		return json.Unmarshal(raw.Embedding, &d.Embedding)
+	}
+	var encoded string
+	if err := json.Unmarshal(raw.Embedding, &encoded); err != nil {
+		return err
+	}
+	embedding, err := DecodeBase64Embedding(encoded)
+	if err != nil {
+		return err
+	}
+	d.Embedding, d.Base64 = embedding, true
+	return nil
+}
+
+// MarshalJSON encodes the embedding as a base64 string if Base64 is set,
+// or else as an array of numbers.
+func (d EmbeddingData) MarshalJSON() ([]byte, error) {
+	embedding, err := json.Marshal(d.Embedding)
+	if d.Base64 {
+		embedding, err = json.Marshal(EncodeBase64Embedding(d.Embedding))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(embeddingData{Object: d.Object, Embedding: embedding, Index: d.Index})
+}
+
+// DecodeBase64Embedding decodes an embedding in the base64 encoding_format:
+// little-endian float32 values, base64 encoded.
+func DecodeBase64Embedding(s string) ([]float64, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 embedding: %w", err)
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("invalid base64 embedding: %d bytes is not a whole number of float32 values", len(data))
+	}
+	embedding := make([]float64, len(data)/4)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+	}
+	return embedding, nil
+}
+
+// EncodeBase64Embedding encodes an embedding in the base64 encoding_format.
+// Values are rounded to float32.
+func EncodeBase64Embedding(embedding []float64) string {
+	data := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(data)
 }
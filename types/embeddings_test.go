@@ -0,0 +1,65 @@
+package types
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestBase64Embedding_KnownVectors(t *testing.T) {
+	tests := []struct {
+		encoded   string
+		embedding []float64
+	}{
+		{"AACAPwAAIMAAAAA+", []float64{1, -2.5, 0.125}},
+		{"zczMPc3MTD4=", []float64{float64(float32(0.1)), float64(float32(0.2))}},
+		{"", []float64{}},
+	}
+	for _, tt := range tests {
+		got, err := DecodeBase64Embedding(tt.encoded)
+		if err != nil {
+			t.Fatalf("decode %q: %v", tt.encoded, err)
+		}
+		if !slices.Equal(got, tt.embedding) {
+			t.Errorf("decode %q = %v, want %v", tt.encoded, got, tt.embedding)
+		}
+		if enc := EncodeBase64Embedding(tt.embedding); enc != tt.encoded {
+			t.Errorf("encode %v = %q, want %q", tt.embedding, enc, tt.encoded)
+		}
+	}
+
+	// Values are rounded to float32 when encoded
+	if enc := EncodeBase64Embedding([]float64{0.1, 0.2}); enc != "zczMPc3MTD4=" {
+		t.Errorf("encode [0.1 0.2] = %q", enc)
+	}
+
+	for _, invalid := range []string{"not base64!", "AAC", "AACAPwA="} {
+		if _, err := DecodeBase64Embedding(invalid); err == nil {
+			t.Errorf("decode %q succeeded", invalid)
+		}
+	}
+}
+
+func TestEmbeddingData_JSON(t *testing.T) {
+	for _, body := range []string{
+		`{"object":"embedding","embedding":[1,-2.5,0.125],"index":0}`,
+		`{"object":"embedding","embedding":"AACAPwAAIMAAAAA+","index":1}`,
+	} {
+		var d EmbeddingData
+		if err := json.Unmarshal([]byte(body), &d); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(d.Embedding, []float64{1, -2.5, 0.125}) {
+			t.Errorf("%s decoded to %v", body, d.Embedding)
+		}
+		out, _ := json.Marshal(d)
+		if string(out) != body {
+			t.Errorf("round trip changed the body:\n got %s\nwant %s", out, body)
+		}
+	}
+
+	var d EmbeddingData
+	if err := json.Unmarshal([]byte(`{"object":"embedding","embedding":"AACAPw=","index":0}`), &d); err == nil {
+		t.Error("decoded a truncated base64 embedding")
+	}
+}
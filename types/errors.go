@@ -45,6 +45,13 @@ func InvalidRequestError(message string) *APIError {
 	return NewAPIError(message, ErrorTypeInvalidRequest, nil)
 }
 
+// InvalidParamError creates an invalid request error pointing at a request field.
+func InvalidParamError(message, param string) *APIError {
+	apiErr := InvalidRequestError(message)
+	apiErr.Error.Param = &param
+	return apiErr
+}
+
 // NotFoundError creates a not found error.
 func NotFoundError(message string) *APIError {
 	code := "model_not_found"
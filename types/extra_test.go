@@ -0,0 +1,57 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestExtra_RoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		v    any
+	}{
+		{`{"model":"m","messages":[{"role":"user","content":"hi"}],"max_tokens":5,"min_p":0.1,"repetition_penalty":1.1}`, &ChatCompletionRequest{}},
+		{`{"model":"m","prompt":"hi","top_k":40}`, &CompletionRequest{}},
+		{`{"model":"m","input":"hi","truncate":"END"}`, &EmbeddingsRequest{}},
+		{`{"model":"m","prompt":"a cat","output_format":"webp"}`, &ImageGenerationRequest{}},
+	} {
+		if err := json.Unmarshal([]byte(tc.body), tc.v); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(tc.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != tc.body {
+			t.Errorf("round trip changed the body:\n got %s\nwant %s", out, tc.body)
+		}
+	}
+}
+
+func TestRequestExtra_KnownFields(t *testing.T) {
+	var req ChatCompletionRequest
+	body := `{"MODEL":"m","messages":[],"Stream":true,"repetition_penalty":1.1}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "m" || !req.Stream {
+		t.Errorf("request = %+v", req)
+	}
+	if len(req.Extra) != 1 || string(req.Extra["repetition_penalty"]) != "1.1" {
+		t.Errorf("extra = %v, want only repetition_penalty", req.Extra)
+	}
+
+	// Fields win over extra members with the same name
+	req.Extra["model"] = json.RawMessage(`"other"`)
+	out, _ := json.Marshal(req)
+	if want := `{"model":"m","messages":[],"stream":true,"repetition_penalty":1.1}`; string(out) != want {
+		t.Errorf("marshaled %s, want %s", out, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"model":"m"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Extra != nil {
+		t.Errorf("extra = %v, want nil without unknown members", req.Extra)
+	}
+}
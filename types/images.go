@@ -0,0 +1,48 @@
+package types
+
+import "encoding/json"
+
+// ImageGenerationRequest represents an OpenAI image generation request.
+type ImageGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              *int   `json:"n,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"` // url or b64_json
+	Size           string `json:"size,omitempty"`            // e.g. 1024x1024
+	Style          string `json:"style,omitempty"`
+	User           string `json:"user,omitempty"`
+
+	// Extra holds request members without a field above, such as
+	// background or output_format; see ChatCompletionRequest.Extra.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// imageGenerationRequest is ImageGenerationRequest without its JSON methods.
+type imageGenerationRequest ImageGenerationRequest
+
+// UnmarshalJSON decodes the request, collecting unknown members in Extra.
+func (r *ImageGenerationRequest) UnmarshalJSON(data []byte) error {
+	extra, err := unmarshalExtra(data, (*imageGenerationRequest)(r))
+	r.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the request with the members of Extra.
+func (r ImageGenerationRequest) MarshalJSON() ([]byte, error) {
+	return marshalExtra(imageGenerationRequest(r), r.Extra)
+}
+
+// ImageGenerationResponse represents an OpenAI image generation response.
+type ImageGenerationResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+// ImageData is one generated image, as a URL or base64-encoded data
+// depending on the request's response_format.
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
@@ -6,6 +6,16 @@ type Model struct {
 	Object  string `json:"object"` // model
 	Created int64  `json:"created"`
 	OwnedBy string `json:"owned_by"`
+
+	// Capabilities lists the API surfaces and features (chat, completions,
+	// embeddings, logprobs) available for this model. Populated by the
+	// router, not by backends, from the backends serving the model or its
+	// model metadata.
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// ContextLength is the model's context window, in tokens, if known.
+	// Backends may report it; the router's model metadata overrides it.
+	ContextLength int `json:"context_length,omitempty"`
 }
 
 // ModelsResponse represents the response from /v1/models.
@@ -19,4 +29,8 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	// Estimated is a non-standard field set when the router counted tokens
+	// locally because the backend didn't report usage.
+	Estimated bool `json:"estimated,omitempty"`
 }
@@ -0,0 +1,127 @@
+package types
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// PromptKind discriminates the forms a completion prompt can take.
+type PromptKind string
+
+const (
+	PromptText   PromptKind = "text"   // one or more strings
+	PromptTokens PromptKind = "tokens" // one or more arrays of token IDs
+)
+
+// Prompt is a completion prompt normalized to a list of prompts. The API
+// accepts a string, an array of strings, an array of token IDs, or an array
+// of token ID arrays; single prompts become one-element lists.
+type Prompt struct {
+	Kind   PromptKind
+	Text   []string // Set when Kind is PromptText
+	Tokens [][]int  // Set when Kind is PromptTokens
+}
+
+// Len returns the number of prompts.
+func (p Prompt) Len() int {
+	if p.Kind == PromptTokens {
+		return len(p.Tokens)
+	}
+	return len(p.Text)
+}
+
+// LogValue summarizes the prompt for logging without including its content.
+func (p Prompt) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("kind", string(p.Kind)), slog.Int("count", p.Len())}
+	if p.Kind == PromptTokens {
+		n := 0
+		for _, t := range p.Tokens {
+			n += len(t)
+		}
+		attrs = append(attrs, slog.Int("tokens", n))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// NormalizePrompt interprets the request's prompt. It only reads Prompt, so
+// the request is still forwarded to backends in the form the client sent.
+func (r *CompletionRequest) NormalizePrompt() (Prompt, error) {
+	return NormalizePrompt(r.Prompt)
+}
+
+// NormalizePrompt interprets a completion prompt as decoded from JSON (where
+// token IDs are float64) or built in Go ([]string, []int, [][]int).
+func NormalizePrompt(prompt any) (Prompt, error) {
+	switch p := prompt.(type) {
+	case string:
+		return Prompt{Kind: PromptText, Text: []string{p}}, nil
+	case []string:
+		return Prompt{Kind: PromptText, Text: p}, nil
+	case []int:
+		return Prompt{Kind: PromptTokens, Tokens: [][]int{p}}, nil
+	case [][]int:
+		return Prompt{Kind: PromptTokens, Tokens: p}, nil
+	case []any:
+		return normalizePromptList(p)
+	}
+	return Prompt{}, fmt.Errorf("prompt must be a string, an array of strings, or an array of token IDs, got %T", prompt)
+}
+
+// normalizePromptList handles a decoded JSON array, whose first element
+// decides the form the rest must share.
+func normalizePromptList(items []any) (Prompt, error) {
+	if len(items) == 0 {
+		return Prompt{Kind: PromptText, Text: []string{}}, nil
+	}
+
+	switch items[0].(type) {
+	case string:
+		text := make([]string, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return Prompt{}, fmt.Errorf("prompt[%d]: expected a string, got %T", i, item)
+			}
+			text[i] = s
+		}
+		return Prompt{Kind: PromptText, Text: text}, nil
+
+	case float64:
+		ids, err := tokenIDs(items, "prompt")
+		if err != nil {
+			return Prompt{}, err
+		}
+		return Prompt{Kind: PromptTokens, Tokens: [][]int{ids}}, nil
+
+	case []any:
+		tokens := make([][]int, len(items))
+		for i, item := range items {
+			list, ok := item.([]any)
+			if !ok {
+				return Prompt{}, fmt.Errorf("prompt[%d]: expected an array of token IDs, got %T", i, item)
+			}
+			ids, err := tokenIDs(list, fmt.Sprintf("prompt[%d]", i))
+			if err != nil {
+				return Prompt{}, err
+			}
+			tokens[i] = ids
+		}
+		return Prompt{Kind: PromptTokens, Tokens: tokens}, nil
+	}
+	return Prompt{}, fmt.Errorf("prompt[0]: unsupported element type %T", items[0])
+}
+
+// tokenIDs converts decoded JSON numbers to token IDs, rejecting anything that
+// isn't a non-negative integer.
+func tokenIDs(items []any, path string) ([]int, error) {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		f, ok := item.(float64)
+		if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+			return nil, fmt.Errorf("%s[%d]: expected a token ID, got %v", path, i, item)
+		}
+		ids[i] = int(f)
+	}
+	return ids, nil
+}
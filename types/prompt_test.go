@@ -0,0 +1,75 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Prompt
+		wantErr bool
+	}{
+		{"string", `"hello"`, Prompt{Kind: PromptText, Text: []string{"hello"}}, false},
+		{"string array", `["a","b"]`, Prompt{Kind: PromptText, Text: []string{"a", "b"}}, false},
+		{"token ids", `[1,2,3]`, Prompt{Kind: PromptTokens, Tokens: [][]int{{1, 2, 3}}}, false},
+		{"token id arrays", `[[1,2],[3]]`, Prompt{Kind: PromptTokens, Tokens: [][]int{{1, 2}, {3}}}, false},
+		{"mixed", `["a",1]`, Prompt{}, true},
+		{"fractional token", `[1.5]`, Prompt{}, true},
+		{"negative token", `[-1]`, Prompt{}, true},
+		{"nested strings", `[["a"]]`, Prompt{}, true},
+		{"object", `{"a":1}`, Prompt{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req CompletionRequest
+			if err := json.Unmarshal([]byte(`{"model":"m","prompt":`+tt.json+`}`), &req); err != nil {
+				t.Fatal(err)
+			}
+			got, err := req.NormalizePrompt()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizePrompt() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePrompt_GoTypes(t *testing.T) {
+	got, err := NormalizePrompt([][]int{{7, 8}})
+	if err != nil || got.Kind != PromptTokens || got.Len() != 1 {
+		t.Errorf("NormalizePrompt([][]int) = %+v, %v", got, err)
+	}
+	got, err = NormalizePrompt([]string{"x", "y"})
+	if err != nil || got.Kind != PromptText || got.Len() != 2 {
+		t.Errorf("NormalizePrompt([]string) = %+v, %v", got, err)
+	}
+}
+
+func TestCompletionRequest_PromptRoundTrip(t *testing.T) {
+	for _, prompt := range []string{`"hello"`, `["a","b"]`, `[50256,198,1000000]`, `[[1,2],[3]]`} {
+		var req CompletionRequest
+		if err := json.Unmarshal([]byte(`{"model":"m","prompt":`+prompt+`}`), &req); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := req.NormalizePrompt(); err != nil {
+			t.Fatalf("%s: %v", prompt, err)
+		}
+
+		out, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]json.RawMessage
+		json.Unmarshal(out, &fields)
+		if got := string(fields["prompt"]); got != prompt {
+			t.Errorf("prompt re-encoded as %s, want %s", got, prompt)
+		}
+	}
+}
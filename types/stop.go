@@ -0,0 +1,26 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StopSequences is the stop parameter of chat and completion requests. The
+// API accepts a single string or an array of strings; both decode to a list,
+// which is always encoded as an array since every backend accepts that form.
+type StopSequences []string
+
+// UnmarshalJSON decodes a string, an array of strings, or null.
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err != nil {
+		return fmt.Errorf("stop must be a string or an array of strings")
+	}
+	*s = StopSequences{single}
+	return nil
+}
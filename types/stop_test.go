@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStopSequences_Unmarshal(t *testing.T) {
+	tests := []struct {
+		body string
+		want StopSequences
+	}{
+		{`{"stop":"\n"}`, StopSequences{"\n"}},
+		{`{"stop":["a","b"]}`, StopSequences{"a", "b"}},
+		{`{"stop":null}`, nil},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var req ChatCompletionRequest
+		if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		if !reflect.DeepEqual(req.Stop, tt.want) {
+			t.Errorf("%s: Stop = %q, want %q", tt.body, req.Stop, tt.want)
+		}
+	}
+
+	var req CompletionRequest
+	if err := json.Unmarshal([]byte(`{"model":"m","prompt":"p","stop":42}`), &req); err == nil {
+		t.Error("expected an error for a numeric stop")
+	}
+}
+
+func TestStopSequences_MarshalsAsArray(t *testing.T) {
+	for _, body := range []string{`{"model":"m","prompt":"p","stop":"\n"}`, `{"model":"m","prompt":"p","stop":["\n"]}`} {
+		var req CompletionRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatal(err)
+		}
+		out, _ := json.Marshal(req)
+		var fields map[string]json.RawMessage
+		json.Unmarshal(out, &fields)
+		if got := string(fields["stop"]); got != `["\n"]` {
+			t.Errorf("%s: stop encoded as %s, want [\"\\n\"]", body, got)
+		}
+	}
+}
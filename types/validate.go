@@ -0,0 +1,127 @@
+package types
+
+import "fmt"
+
+// ValidationError reports an invalid request field.
+type ValidationError struct {
+	Param   string // the offending field, e.g. "messages[1].role"
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// APIError converts the validation error to an invalid_request_error.
+func (e *ValidationError) APIError() *APIError {
+	return InvalidParamError(e.Message, e.Param)
+}
+
+func invalidParam(param, format string, args ...any) *ValidationError {
+	return &ValidationError{Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+// validRoles lists the chat message roles accepted by the OpenAI API.
+var validRoles = map[string]bool{
+	"system":    true,
+	"developer": true,
+	"user":      true,
+	"assistant": true,
+	"tool":      true,
+	"function":  true,
+}
+
+// validateSampling checks the sampling parameters shared by chat and
+// legacy completion requests.
+func validateSampling(temperature, topP *float64, n *int) error {
+	if temperature != nil && (*temperature < 0 || *temperature > 2) {
+		return invalidParam("temperature", "temperature must be between 0 and 2, got %g", *temperature)
+	}
+	if topP != nil && (*topP < 0 || *topP > 1) {
+		return invalidParam("top_p", "top_p must be between 0 and 1, got %g", *topP)
+	}
+	if n != nil && *n < 1 {
+		return invalidParam("n", "n must be at least 1, got %d", *n)
+	}
+	return nil
+}
+
+// Validate checks the request for errors a backend would reject, returning a
+// *ValidationError naming the offending field.
+func (r *ChatCompletionRequest) Validate() error {
+	if r.Model == "" {
+		return invalidParam("model", "model is required")
+	}
+	if len(r.Messages) == 0 {
+		return invalidParam("messages", "messages must contain at least one message")
+	}
+	for i, m := range r.Messages {
+		if !validRoles[m.Role] {
+			return invalidParam(fmt.Sprintf("messages[%d].role", i), "invalid role %q", m.Role)
+		}
+	}
+	return validateSampling(r.Temperature, r.TopP, r.N)
+}
+
+// Validate checks the request for errors a backend would reject, returning a
+// *ValidationError naming the offending field.
+func (r *CompletionRequest) Validate() error {
+	if r.Model == "" {
+		return invalidParam("model", "model is required")
+	}
+	if isEmptyInput(r.Prompt) {
+		return invalidParam("prompt", "prompt is required")
+	}
+	if _, err := r.NormalizePrompt(); err != nil {
+		return invalidParam("prompt", "%s", err.Error())
+	}
+	return validateSampling(r.Temperature, r.TopP, r.N)
+}
+
+// Validate checks the request for errors a backend would reject, returning a
+// *ValidationError naming the offending field.
+func (r *EmbeddingsRequest) Validate() error {
+	if r.Model == "" {
+		return invalidParam("model", "model is required")
+	}
+	if isEmptyInput(r.Input) {
+		return invalidParam("input", "input must not be empty")
+	}
+	if r.Dimensions != nil && *r.Dimensions < 1 {
+		return invalidParam("dimensions", "dimensions must be at least 1, got %d", *r.Dimensions)
+	}
+	return nil
+}
+
+// Validate checks the request for errors a backend would reject, returning a
+// *ValidationError naming the offending field.
+func (r *ImageGenerationRequest) Validate() error {
+	if r.Model == "" {
+		return invalidParam("model", "model is required")
+	}
+	if r.Prompt == "" {
+		return invalidParam("prompt", "prompt is required")
+	}
+	if r.N != nil && *r.N < 1 {
+		return invalidParam("n", "n must be at least 1, got %d", *r.N)
+	}
+	if f := r.ResponseFormat; f != "" && f != "url" && f != "b64_json" {
+		return invalidParam("response_format", "response_format must be url or b64_json, got %q", f)
+	}
+	return nil
+}
+
+// isEmptyInput reports whether a string-or-array input has no content.
+func isEmptyInput(input any) bool {
+	switch v := input.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
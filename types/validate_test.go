@@ -0,0 +1,120 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestChatCompletionRequest_Validate(t *testing.T) {
+	valid := func() ChatCompletionRequest {
+		return ChatCompletionRequest{Model: "m", Messages: []ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}}
+	}
+
+	tests := []struct {
+		name      string
+		mutate    func(*ChatCompletionRequest)
+		wantParam string
+	}{
+		{"valid", func(r *ChatCompletionRequest) {}, ""},
+		{"missing model", func(r *ChatCompletionRequest) { r.Model = "" }, "model"},
+		{"empty messages", func(r *ChatCompletionRequest) { r.Messages = nil }, "messages"},
+		{"invalid role", func(r *ChatCompletionRequest) { r.Messages[1].Role = "robot" }, "messages[1].role"},
+		{"missing role", func(r *ChatCompletionRequest) { r.Messages[0].Role = "" }, "messages[0].role"},
+		{"temperature too high", func(r *ChatCompletionRequest) { r.Temperature = ptr(2.5) }, "temperature"},
+		{"temperature negative", func(r *ChatCompletionRequest) { r.Temperature = ptr(-0.1) }, "temperature"},
+		{"temperature bounds", func(r *ChatCompletionRequest) { r.Temperature = ptr(2.0); r.TopP = ptr(0.0) }, ""},
+		{"top_p too high", func(r *ChatCompletionRequest) { r.TopP = ptr(1.5) }, "top_p"},
+		{"n zero", func(r *ChatCompletionRequest) { r.N = ptr(0) }, "n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.mutate(&req)
+			checkValidation(t, req.Validate(), tt.wantParam)
+		})
+	}
+}
+
+func TestCompletionRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       CompletionRequest
+		wantParam string
+	}{
+		{"valid", CompletionRequest{Model: "m", Prompt: "once upon"}, ""},
+		{"missing model", CompletionRequest{Prompt: "once upon"}, "model"},
+		{"missing prompt", CompletionRequest{Model: "m"}, "prompt"},
+		{"empty prompt list", CompletionRequest{Model: "m", Prompt: []any{}}, "prompt"},
+		{"token prompt", CompletionRequest{Model: "m", Prompt: []any{float64(1), float64(2)}}, ""},
+		{"mixed prompt", CompletionRequest{Model: "m", Prompt: []any{"a", float64(2)}}, "prompt"},
+		{"top_p out of range", CompletionRequest{Model: "m", Prompt: "x", TopP: ptr(-1.0)}, "top_p"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidation(t, tt.req.Validate(), tt.wantParam)
+		})
+	}
+}
+
+func TestEmbeddingsRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       EmbeddingsRequest
+		wantParam string
+	}{
+		{"valid", EmbeddingsRequest{Model: "m", Input: []any{"a", "b"}}, ""},
+		{"missing model", EmbeddingsRequest{Input: "a"}, "model"},
+		{"empty input", EmbeddingsRequest{Model: "m", Input: ""}, "input"},
+		{"missing input", EmbeddingsRequest{Model: "m"}, "input"},
+		{"bad dimensions", EmbeddingsRequest{Model: "m", Input: "a", Dimensions: ptr(0)}, "dimensions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidation(t, tt.req.Validate(), tt.wantParam)
+		})
+	}
+}
+
+func TestImageGenerationRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       ImageGenerationRequest
+		wantParam string
+	}{
+		{"valid", ImageGenerationRequest{Model: "m", Prompt: "a cat", ResponseFormat: "b64_json"}, ""},
+		{"missing model", ImageGenerationRequest{Prompt: "a cat"}, "model"},
+		{"missing prompt", ImageGenerationRequest{Model: "m"}, "prompt"},
+		{"n zero", ImageGenerationRequest{Model: "m", Prompt: "a cat", N: ptr(0)}, "n"},
+		{"bad response_format", ImageGenerationRequest{Model: "m", Prompt: "a cat", ResponseFormat: "png"}, "response_format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidation(t, tt.req.Validate(), tt.wantParam)
+		})
+	}
+}
+
+func checkValidation(t *testing.T, err error, wantParam string) {
+	t.Helper()
+	if wantParam == "" {
+		if err != nil {
+			t.Errorf("Validate() = %v, want nil", err)
+		}
+		return
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() = %v, want *ValidationError", err)
+	}
+	if verr.Param != wantParam {
+		t.Errorf("Param = %q, want %q", verr.Param, wantParam)
+	}
+	apiErr := verr.APIError()
+	if apiErr.Error.Type != ErrorTypeInvalidRequest || apiErr.Error.Param == nil || *apiErr.Error.Param != wantParam {
+		t.Errorf("APIError() = %+v", apiErr.Error)
+	}
+}
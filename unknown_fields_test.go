@@ -0,0 +1,74 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// extraRouter returns a router whose backend records the body each chat
+// request would be sent with.
+func extraRouter(t *testing.T, opts ...Option) (*Router, *string) {
+	t.Helper()
+	var sent string
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		data, _ := json.Marshal(req)
+		sent = string(data)
+		return &types.ChatCompletionResponse{ID: "chat", Model: req.Model}, nil
+	}
+	return newTestRouter(t, []Backend{b}, opts...), &sent
+}
+
+const extraChatBody = `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"repetition_penalty":1.1}`
+
+func TestUnknownFields_DroppedByDefault(t *testing.T) {
+	r, sent := extraRouter(t)
+	if w := postChat(t, r, extraChatBody); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if strings.Contains(*sent, "repetition_penalty") {
+		t.Errorf("backend was sent %s", *sent)
+	}
+}
+
+func TestUnknownFields_Passthrough(t *testing.T) {
+	r, sent := extraRouter(t, WithUnknownFieldPassthrough(true))
+	if w := postChat(t, r, extraChatBody); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if *sent != extraChatBody {
+		t.Errorf("backend was sent %s, want %s", *sent, extraChatBody)
+	}
+}
+
+// sentImageBackend records the body each image generation request would be
+// sent with.
+type sentImageBackend struct {
+	*mockBackend
+	sent string
+}
+
+func (b *sentImageBackend) GenerateImages(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	data, _ := json.Marshal(req)
+	b.sent = string(data)
+	return &types.ImageGenerationResponse{}, nil
+}
+
+func TestUnknownFields_ImageGeneration(t *testing.T) {
+	const body = `{"model":"test-model","prompt":"a cat","output_format":"webp"}`
+	for _, passthrough := range []bool{false, true} {
+		b := &sentImageBackend{mockBackend: newMockBackend("images", true)}
+		r := newTestRouter(t, []Backend{b}, WithUnknownFieldPassthrough(passthrough))
+		if w := postImages(r, body); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+		if forwarded := strings.Contains(b.sent, "output_format"); forwarded != passthrough {
+			t.Errorf("passthrough %v: backend was sent %s", passthrough, b.sent)
+		}
+	}
+}
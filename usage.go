@@ -0,0 +1,113 @@
+package oairouter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/stevemurr/oairouter/tokenizer"
+	"github.com/stevemurr/oairouter/types"
+)
+
+// usageEstimator accumulates streamed completion text so a usage chunk can be
+// synthesized for backends that never report one.
+type usageEstimator struct {
+	promptTokens int
+	text         strings.Builder
+	sawUsage     bool
+
+	// Identity of the stream, copied onto the synthesized chunk.
+	id      string
+	object  string
+	created int64
+	model   string
+}
+
+// streamChunk covers the fields of chat and legacy completion chunks that the
+// estimator needs.
+type streamChunk struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Usage   *types.Usage `json:"usage"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func newUsageEstimator(promptTokens int) *usageEstimator {
+	return &usageEstimator{promptTokens: promptTokens}
+}
+
+// observe records one SSE data payload. Payloads that aren't JSON are ignored.
+func (u *usageEstimator) observe(data string) {
+	var chunk streamChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if chunk.Usage != nil {
+		u.sawUsage = true
+	}
+	if u.id == "" {
+		u.id, u.object, u.created, u.model = chunk.ID, chunk.Object, chunk.Created, chunk.Model
+	}
+	for _, c := range chunk.Choices {
+		u.text.WriteString(c.Delta.Content)
+		u.text.WriteString(c.Text)
+	}
+}
+
+// completionTokens estimates the tokens in the completion text seen so far.
+func (u *usageEstimator) completionTokens() int {
+	return tokenizer.Count(u.text.String())
+}
+
+// streamUsageChunk returns the usage carried by a streamed chunk, if any.
+// usageOnly reports whether the chunk has no choices, like the final chunk
+// sent for stream_options.include_usage.
+func streamUsageChunk(data string) (usage *types.Usage, usageOnly bool) {
+	if !strings.Contains(data, `"usage"`) {
+		return nil, false
+	}
+	var chunk struct {
+		Usage   *types.Usage      `json:"usage"`
+		Choices []json.RawMessage `json:"choices"`
+	}
+	if json.Unmarshal([]byte(data), &chunk) != nil || chunk.Usage == nil {
+		return nil, false
+	}
+	return chunk.Usage, len(chunk.Choices) == 0
+}
+
+// finalChunk returns a chunk carrying the estimated usage, in the shape of an
+// OpenAI include_usage chunk. It reports false if the backend sent usage itself.
+func (u *usageEstimator) finalChunk() (string, bool) {
+	if u.sawUsage {
+		return "", false
+	}
+
+	completionTokens := u.completionTokens()
+	chunk := map[string]any{
+		"id":      u.id,
+		"object":  u.object,
+		"created": u.created,
+		"model":   u.model,
+		"choices": []any{},
+		"usage": types.Usage{
+			PromptTokens:     u.promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      u.promptTokens + completionTokens,
+			Estimated:        true,
+		},
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
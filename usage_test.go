@@ -0,0 +1,142 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// streamUsage posts a streaming chat request and returns the usage chunks
+// and the total number of data lines, including [DONE].
+func streamUsage(t *testing.T, r *Router) ([]types.Usage, int) {
+	t.Helper()
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"Say hello"}],"stream":true}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var usages []types.Usage
+	lines := 0
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		lines++
+		var chunk struct {
+			Usage *types.Usage `json:"usage"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) == nil && chunk.Usage != nil {
+			usages = append(usages, *chunk.Usage)
+		}
+	}
+	return usages, lines
+}
+
+func TestLocalTokenCounting_EstimatesMissingUsage(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"delta":{"content":"Hello, "}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"delta":{"content":"world!"}}]}`,
+		), nil
+	}
+
+	r, _ := NewRouter(WithLocalTokenCounting(true))
+	r.AddBackend(context.Background(), b)
+
+	usages, lines := streamUsage(t, r)
+	if len(usages) != 1 {
+		t.Fatalf("got %d usage chunks, want 1", len(usages))
+	}
+	if lines != 4 {
+		t.Errorf("got %d data lines, want 4 (2 content, usage, [DONE])", lines)
+	}
+
+	// "Hello, world!" -> Hello(2) ,(1) world(2) !(1)
+	// prompt: reply(3) + message(4) + "user"(1) + "Say hello"(1+2)
+	want := types.Usage{PromptTokens: 11, CompletionTokens: 6, TotalTokens: 17, Estimated: true}
+	if usages[0] != want {
+		t.Errorf("usage = %+v, want %+v", usages[0], want)
+	}
+}
+
+func TestLocalTokenCounting_KeepsBackendUsage(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(
+			`{"choices":[{"delta":{"content":"Hello"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":1,"total_tokens":10}}`,
+		), nil
+	}
+
+	r, _ := NewRouter(WithLocalTokenCounting(true))
+	r.AddBackend(context.Background(), b)
+
+	usages, _ := streamUsage(t, r)
+	if len(usages) != 1 || usages[0].Estimated {
+		t.Fatalf("usages = %+v, want only the backend's", usages)
+	}
+}
+
+func TestLocalTokenCounting_Disabled(t *testing.T) {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		return streamOf(`{"choices":[{"delta":{"content":"Hello"}}]}`), nil
+	}
+
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	if usages, _ := streamUsage(t, r); len(usages) != 0 {
+		t.Errorf("usages = %+v, want none", usages)
+	}
+}
+
+// usageBackend streams one content chunk, followed by a usage chunk only if
+// the request asks for one.
+func usageBackend() *mockBackend {
+	b := newMockBackend("backend-a", true)
+	b.chatStreamFn = func(ctx context.Context, req *types.ChatCompletionRequest) (<-chan StreamEvent, error) {
+		chunks := []string{`{"choices":[{"delta":{"content":"Hello"}}]}`}
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			chunks = append(chunks, `{"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":1,"total_tokens":10}}`)
+		}
+		return streamOf(chunks...), nil
+	}
+	return b
+}
+
+func TestStreamUsage_LogsUsageClientDidNotRequest(t *testing.T) {
+	for _, strip := range []bool{true, false} {
+		logs := make(chan RequestLog, 1)
+		r, _ := NewRouter(WithStreamUsage(strip), WithRequestLogger(func(l RequestLog) { logs <- l }))
+		r.AddBackend(context.Background(), usageBackend())
+
+		usages, _ := streamUsage(t, r)
+		if want := map[bool]int{true: 0, false: 1}[strip]; len(usages) != want {
+			t.Errorf("strip %v: client got %d usage chunks, want %d", strip, len(usages), want)
+		}
+		if l := <-logs; l.Usage == nil || l.Usage.TotalTokens != 10 {
+			t.Errorf("strip %v: logged usage = %+v, want the backend's", strip, l.Usage)
+		}
+	}
+}
+
+func TestStreamUsage_KeepsRequestedUsage(t *testing.T) {
+	r, _ := NewRouter(WithStreamUsage(true))
+	r.AddBackend(context.Background(), usageBackend())
+
+	body := `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if !strings.Contains(w.Body.String(), `"total_tokens":10`) {
+		t.Errorf("usage chunk missing from a stream that asked for it:\n%s", w.Body)
+	}
+}
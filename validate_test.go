@@ -0,0 +1,38 @@
+package oairouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+func TestValidation_RejectsBeforeDispatch(t *testing.T) {
+	var calls atomic.Int64
+	b := newMockBackend("backend-a", true)
+	b.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		calls.Add(1)
+		return &types.ChatCompletionResponse{}, nil
+	}
+	r, _ := NewRouter()
+	r.AddBackend(context.Background(), b)
+
+	rec := postChat(t, r, `{"model":"test-model","messages":[{"role":"user","content":"hi"}],"temperature":3}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+
+	var apiErr types.APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if apiErr.Error.Type != types.ErrorTypeInvalidRequest || apiErr.Error.Param == nil || *apiErr.Error.Param != "temperature" {
+		t.Errorf("error = %+v, want invalid_request_error for temperature", apiErr.Error)
+	}
+	if calls.Load() != 0 {
+		t.Error("invalid request was forwarded to the backend")
+	}
+}
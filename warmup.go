@@ -0,0 +1,162 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// DefaultWarmupTimeout bounds each warmup attempt. It is generous because a
+// backend's first request often waits for the model to load.
+const DefaultWarmupTimeout = 2 * time.Minute
+
+// DefaultWarmupBackoff spaces out warmup attempts for backends treated as
+// unhealthy until their warmup succeeds (see WithWarmupRequired).
+var DefaultWarmupBackoff = Backoff{
+	Initial: time.Second,
+	Max:     30 * time.Second,
+}
+
+// WarmupFunc sends a backend its first request after it is registered, so
+// the cost of loading a model isn't paid by a client's request.
+type WarmupFunc func(ctx context.Context, b Backend) error
+
+// DefaultWarmup sends a one-token request for the backend's first model: a
+// chat completion if it serves chat, otherwise a completion or embeddings.
+func DefaultWarmup(ctx context.Context, b Backend) error {
+	model, err := firstModel(ctx, b)
+	if err != nil {
+		return err
+	}
+	return sendOneToken(ctx, b, model)
+}
+
+// firstModel returns the ID of the first model b lists.
+func firstModel(ctx context.Context, b Backend) (string, error) {
+	models, err := b.Models(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(models) == 0 {
+		return "", errors.New("backend lists no models")
+	}
+	return models[0].ID, nil
+}
+
+// sendOneToken asks b for a single token from model: a chat completion if
+// it serves chat, otherwise a completion or embeddings.
+func sendOneToken(ctx context.Context, b Backend, model string) error {
+	var err error
+	maxTokens := 1
+	caps := b.Capabilities()
+	switch {
+	case caps.SupportsChat:
+		_, err = b.ChatCompletion(ctx, &types.ChatCompletionRequest{
+			Model:     model,
+			Messages:  []types.ChatMessage{{Role: "user", Content: "hi"}},
+			MaxTokens: &maxTokens,
+		})
+	case caps.SupportsCompletions:
+		_, err = b.Completion(ctx, &types.CompletionRequest{Model: model, Prompt: "hi", MaxTokens: &maxTokens})
+	case caps.SupportsEmbeddings:
+		_, err = b.Embeddings(ctx, &types.EmbeddingsRequest{Model: model, Input: "hi"})
+	default:
+		return errors.New("backend serves no chat, completions, or embeddings")
+	}
+	return err
+}
+
+// warmingUp reports whether b is treated as unhealthy until its warmup
+// succeeds.
+func (r *BackendRegistry) warmingUp(b Backend) bool {
+	held, ok := r.warming.Load(b.ID())
+	return ok && held == b
+}
+
+// holdForWarmup treats b as unhealthy until finishWarmup.
+func (r *BackendRegistry) holdForWarmup(b Backend) {
+	r.warming.Store(b.ID(), b)
+}
+
+// finishWarmup releases b's hold, if it still has one.
+func (r *BackendRegistry) finishWarmup(b Backend) {
+	r.warming.CompareAndDelete(b.ID(), b)
+}
+
+// holdForWarmup holds back a backend about to be registered with
+// WithWarmupRequired. Backends replacing one with the same ID aren't held,
+// so a reload doesn't take a serving backend out of rotation.
+func (r *Router) holdForWarmup(b Backend) {
+	if r.warmup == nil || !r.warmupRequired {
+		return
+	}
+	if _, exists := r.registry.LookupByID(b.ID()); exists {
+		return
+	}
+	r.registry.holdForWarmup(b)
+}
+
+// warmupContext returns the context a new warmup runs under, creating it if
+// Stop cancelled the previous one, and counts the warmup for Stop to wait on.
+func (r *Router) warmupContext() context.Context {
+	r.warmupMu.Lock()
+	defer r.warmupMu.Unlock()
+	if r.warmupCtx == nil {
+		r.warmupCtx, r.warmupCancel = context.WithCancel(context.Background())
+	}
+	r.warmups.Add(1)
+	return r.warmupCtx
+}
+
+// stopWarmups cancels running warmups. Stop waits for them to return.
+func (r *Router) stopWarmups() {
+	r.warmupMu.Lock()
+	defer r.warmupMu.Unlock()
+	if r.warmupCancel != nil {
+		r.warmupCancel()
+	}
+	r.warmupCtx, r.warmupCancel = nil, nil
+}
+
+// startWarmup warms up a newly registered backend in the background. A
+// backend held back is retried until its warmup succeeds, it is
+// unregistered, or the router stops; otherwise a failed warmup is only
+// logged.
+func (r *Router) startWarmup(b Backend) {
+	if r.warmup == nil {
+		return
+	}
+	lifecycle := r.warmupContext()
+	go func() {
+		defer r.warmups.Done()
+		for attempt := 0; ; attempt++ {
+			ctx, cancel := context.WithTimeout(lifecycle, DefaultWarmupTimeout)
+			start := time.Now()
+			err := r.warmup(ctx, b)
+			cancel()
+			if lifecycle.Err() != nil {
+				return
+			}
+			if err == nil {
+				r.registry.finishWarmup(b)
+				r.logger.Info("backend warmed up", "backend", b.ID(), "duration", time.Since(start))
+				return
+			}
+			r.logger.Warn("backend warmup failed", "backend", b.ID(), "duration", time.Since(start), "attempt", attempt+1, "error", err)
+
+			if !r.registry.warmingUp(b) {
+				return
+			}
+			select {
+			case <-lifecycle.Done():
+				return
+			case <-time.After(r.warmupRetry.Delay(attempt)):
+			}
+			if !r.registry.warmingUp(b) {
+				return
+			}
+		}
+	}()
+}
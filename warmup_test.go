@@ -0,0 +1,221 @@
+package oairouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stevemurr/oairouter/types"
+)
+
+// waitWarmedUp polls until backend id is no longer held for warmup.
+func waitWarmedUp(t *testing.T, r *Router, id string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, _ := r.registry.BackendInfo(id)
+		if !info.Warming {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backend %s still warming up", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDefaultWarmup(t *testing.T) {
+	chat := modelBackend("chat", "llama-3", true)
+	var chatReq *types.ChatCompletionRequest
+	chat.chatFn = func(ctx context.Context, req *types.ChatCompletionRequest) (*types.ChatCompletionResponse, error) {
+		chatReq = req
+		return &types.ChatCompletionResponse{}, nil
+	}
+	if err := DefaultWarmup(context.Background(), chat); err != nil {
+		t.Fatal(err)
+	}
+	if chatReq == nil || chatReq.Model != "llama-3" || chatReq.MaxTokens == nil || *chatReq.MaxTokens != 1 {
+		t.Errorf("chat warmup request = %+v", chatReq)
+	}
+
+	embed := modelBackend("embed", "bge", true)
+	embed.caps = []Capability{CapabilityEmbeddings}
+	var embedModel string
+	embed.embeddingsFn = func(ctx context.Context, req *types.EmbeddingsRequest) (*types.EmbeddingsResponse, error) {
+		embedModel = req.Model
+		return &types.EmbeddingsResponse{}, nil
+	}
+	if err := DefaultWarmup(context.Background(), embed); err != nil {
+		t.Fatal(err)
+	}
+	if embedModel != "bge" {
+		t.Errorf("embeddings warmup model = %q, want bge", embedModel)
+	}
+
+	empty := newMockBackend("empty", true)
+	empty.modelsFn = func(ctx context.Context) ([]types.Model, error) { return nil, nil }
+	if err := DefaultWarmup(context.Background(), empty); err == nil {
+		t.Error("warmup of a backend without models succeeded")
+	}
+}
+
+func TestWarmup_RoutesWhileWarmingByDefault(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int64
+	r, err := NewRouter(WithWarmup(func(ctx context.Context, b Backend) error {
+		calls.Add(1)
+		<-release
+		return errors.New("cold")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+
+	if w := postChat(t, r, chatBody("llama-3")); w.Code != http.StatusOK {
+		t.Errorf("status during warmup = %d, want 200", w.Code)
+	}
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("warmup ran %d times, want 1 without WithWarmupRequired", n)
+	}
+}
+
+func TestWarmup_RequiredHoldsBackendUntilWarm(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int64
+	r, err := NewRouter(
+		WithWarmup(func(ctx context.Context, b Backend) error {
+			if calls.Add(1) < 3 {
+				return errors.New("cold")
+			}
+			<-release
+			return nil
+		}),
+		WithWarmupRequired(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.warmupRetry = Backoff{Initial: time.Millisecond}
+	// Registered directly, so it isn't held back
+	r.registry.Register(context.Background(), modelBackend("backend-warm", "llama-3", true))
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+
+	for range 5 {
+		if id, _ := servedBy(t, r, "llama-3"); id != "backend-warm" {
+			t.Fatalf("request served by %s before its warmup succeeded", id)
+		}
+	}
+	if info, _ := r.registry.BackendInfo("backend-a"); !info.Warming || info.Healthy {
+		t.Errorf("backend info during warmup = %+v", info)
+	}
+
+	close(release)
+	waitWarmedUp(t, r, "backend-a")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("warmup ran %d times, want 3", n)
+	}
+	r.RemoveBackend("backend-warm")
+	if id, _ := servedBy(t, r, "llama-3"); id != "backend-a" {
+		t.Errorf("request served by %s after warmup, want backend-a", id)
+	}
+}
+
+func TestWarmup_RequiredSkipsReplacedBackends(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	r, _ := NewRouter(
+		WithWarmup(func(ctx context.Context, b Backend) error {
+			if b.ID() == "backend-a" {
+				return nil
+			}
+			<-release
+			return nil
+		}),
+		WithWarmupRequired(true),
+	)
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+	waitWarmedUp(t, r, "backend-a")
+
+	// A reload swaps backend-a for a new instance and adds backend-b; only
+	// the new ID is held
+	err := r.ReplaceBackends(context.Background(), []Backend{
+		modelBackend("backend-a", "llama-3", true),
+		modelBackend("backend-b", "llama-3", true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.registry.BackendInfo("backend-a"); info.Warming || !info.Healthy {
+		t.Errorf("replaced backend info = %+v", info)
+	}
+	if info, _ := r.registry.BackendInfo("backend-b"); !info.Warming {
+		t.Errorf("new backend info = %+v, want warming", info)
+	}
+
+	r.RemoveBackend("backend-b")
+	if _, held := r.registry.warming.Load("backend-b"); held {
+		t.Error("removed backend still held")
+	}
+}
+
+func TestWarmup_ReplaceSkipsUnchangedBackends(t *testing.T) {
+	var warmed sync.Map
+	r, _ := NewRouter(WithWarmup(func(ctx context.Context, b Backend) error {
+		n, _ := warmed.LoadOrStore(b, new(atomic.Int64))
+		n.(*atomic.Int64).Add(1)
+		return nil
+	}))
+	unchanged := modelBackend("backend-a", "llama-3", true)
+	swapped := modelBackend("backend-b", "llama-3", true)
+	r.AddBackend(context.Background(), unchanged)
+	r.AddBackend(context.Background(), swapped)
+
+	swappedFor := modelBackend("backend-b", "llama-3", true)
+	added := modelBackend("backend-c", "llama-3", true)
+	if err := r.ReplaceBackends(context.Background(), []Backend{unchanged, swappedFor, added}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		b    Backend
+		want int64
+	}{{unchanged, 1}, {swapped, 1}, {swappedFor, 1}, {added, 1}} {
+		var got int64
+		if n, ok := warmed.Load(tc.b); ok {
+			got = n.(*atomic.Int64).Load()
+		}
+		if got != tc.want {
+			t.Errorf("%s warmed up %d times, want %d", tc.b.ID(), got, tc.want)
+		}
+	}
+}
+
+func TestWarmup_StopCancelsWarmups(t *testing.T) {
+	started := make(chan struct{})
+	r, _ := NewRouter(
+		WithWarmup(func(ctx context.Context, b Backend) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		WithWarmupRequired(true),
+	)
+	r.AddBackend(context.Background(), modelBackend("backend-a", "llama-3", true))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Stop(ctx); err != nil {
+		t.Fatalf("Stop = %v, want warmups cancelled", err)
+	}
+}